@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	driverbson "go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Query parameters of the audit log filters (exact values)
+var AUDIT_LOG_FILTER_PARAMS = []string{"actorId", "collection", "action"}
+
+// GET AUDIT LOG -----------
+// -------------------------
+// This method lists the entries of the audit log, the most recent first
+// (Optional) filters: actorId, collection, action and the created_dtFrom - created_dtTo time range
+func GetAuditLog(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// PAGING - SKIP AND LIMIT RESULTS - LIMIT
+		pageRequested, limit, err := pageAndLimit(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), err.Error())
+			return
+		}
+
+		// Construct the filter of the query
+		filterObject := bson.M{}
+		for _, parameter := range AUDIT_LOG_FILTER_PARAMS {
+			if value := ctx.Query(parameter); utils.CheckStringNotEmpty(value) {
+				filterObject[parameter] = value
+			}
+		}
+
+		// Created Date From, To (RFC 3339)
+		createdDateRange, err := createdDateRangeFilter(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid created date range.", err.Error())
+			return
+		}
+		if createdDateRange != nil {
+			filterObject["createdDt"] = createdDateRange
+		}
+
+		// Count the matching entries
+		collection := dataStore.Collection(db.DB_TABLE_AUDIT_LOG)
+		countDocumentsResult, err := collection.CountDocuments(context.TODO(), filterObject)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the entries of the audit log.", err.Error())
+			return
+		}
+
+		searchOpts := &store.FindOptions{
+			Skip:  (pageRequested - 1) * limit,
+			Limit: limit,
+			Sort:  driverbson.D{{Key: "createdDt", Value: -1}, {Key: "_id", Value: -1}},
+		}
+
+		entriesList := []models.AuditLogEntry{}
+		if err = collection.Find(context.TODO(), filterObject, searchOpts, &entriesList); err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving the entries of the audit log.", err.Error())
+			return
+		}
+
+		// Send the response with the entries
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":               "Retrieved {" + fmt.Sprint(len(entriesList)) + "} entries of the audit log.",
+			"data":                  entriesList,
+			"rows":                  len(entriesList),
+			"currentPage":           pageRequested,
+			"totalPages":            math.Ceil(float64(countDocumentsResult) / float64(limit)),
+			"totalNumbersDocuments": countDocumentsResult,
+		})
+	}
+}
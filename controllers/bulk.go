@@ -0,0 +1,443 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Operations of the items of the bulk writes
+const BULK_OPERATION_CREATE = "create"
+const BULK_OPERATION_UPDATE = "update"
+const BULK_OPERATION_DELETE = "delete"
+
+// Results of the items of the bulk writes
+// The skipped items are not executed, because an item before them failed (ordered bulk writes)
+const BULK_RESULT_CREATED = "created"
+const BULK_RESULT_UPDATED = "updated"
+const BULK_RESULT_DELETED = "deleted"
+const BULK_RESULT_FAILED = "failed"
+const BULK_RESULT_SKIPPED = "skipped"
+
+// Max number of items of a bulk write
+const MAX_BULK_ITEMS = 1000
+
+// Aborts the transaction of an ordered bulk write at its first failed write
+var errBulkWriteStopped = errors.New("the ordered bulk write stopped at a failed write")
+
+// Request of a bulk write
+// The bulk writes are ordered by default: the items run in order and the first failed item stops the rest
+type bulkRequest struct {
+	Ordered *bool      `json:"ordered"`
+	Items   []bulkItem `json:"operations"`
+}
+
+// Item of a bulk write
+// The updates and the deletions name the document and its version (the value of its ETag)
+type bulkItem struct {
+	Op       string                 `json:"op"`
+	ID       string                 `json:"_id"`
+	Version  int64                  `json:"version"`
+	Document map[string]interface{} `json:"document"`
+}
+
+// Report of an item of a bulk write, with the HTTP status of the item
+type bulkItemReport struct {
+	Index   int                     `json:"index"`
+	Op      string                  `json:"op"`
+	ID      string                  `json:"_id,omitempty"`
+	Result  string                  `json:"result"`
+	Status  int                     `json:"status"`
+	Version int64                   `json:"version,omitempty"`
+	Message string                  `json:"message,omitempty"`
+	Errors  models.ValidationErrors `json:"errors,omitempty"`
+}
+
+// Private
+// This method marks the item as failed with the status and the message of the error
+// (422 with the errors of the fields, the status of the request errors, 409 for the duplicate keys)
+func (report *bulkItemReport) fail(err error) {
+	var fieldErrors models.ValidationErrors
+	var requestErr *requestError
+	report.Result = BULK_RESULT_FAILED
+	report.Version = 0
+
+	switch {
+	case errors.As(err, &fieldErrors):
+		report.Status, report.Message, report.Errors = http.StatusUnprocessableEntity, "Not valid document data.", fieldErrors
+	case errors.As(err, &requestErr):
+		report.Status, report.Message = requestErr.status, requestErr.message
+	case store.IsDuplicateKey(err):
+		report.Status, report.Message = http.StatusConflict, "The data conflicts with an existent document."
+	default:
+		report.Status, report.Message = http.StatusInternalServerError, err.Error()
+	}
+}
+
+// Private
+// This method marks the item as skipped (not executed)
+func (report *bulkItemReport) skip() {
+	report.Result, report.Status, report.Version = BULK_RESULT_SKIPPED, http.StatusFailedDependency, 0
+	report.Message = "Not executed, a previous operation of the ordered bulk write failed."
+}
+
+// Private
+// This method translates an item of the bulk write to a write of the store, after the checks of the item
+// The operations of the item must be allowed by the policy of the resource and the deletions are soft
+// The writes of the bulk have the same date (the date of the deletions), so the filter of the written document is exact
+// Output: the write, the filter of the written document and the report of the successful item, or a request error / ValidationErrors
+func bulkOperation[T any](resource registry.Resource, item bulkItem, options deletion, report *bulkItemReport) (store.BulkOperation, bson.M, error) {
+	operation := map[string]registry.Operation{
+		BULK_OPERATION_CREATE: registry.OPERATION_CREATE,
+		BULK_OPERATION_UPDATE: registry.OPERATION_UPDATE,
+		BULK_OPERATION_DELETE: registry.OPERATION_DELETE,
+	}[item.Op]
+	if operation == "" {
+		return store.BulkOperation{}, nil, newRequestError(http.StatusBadRequest, "Not valid operation '"+item.Op+"', expected create, update or delete.", errors.New("not valid bulk operation"))
+	}
+	if _, allowed := resource.Policy[operation]; !allowed {
+		message := "The operation '" + item.Op + "' is not allowed for the collection { " + resource.Name + " }, use its own API."
+		return store.BulkOperation{}, nil, newRequestError(http.StatusMethodNotAllowed, message, errors.New("not allowed bulk operation"))
+	}
+
+	// New document with its id, so the report has the id of every created document
+	if item.Op == BULK_OPERATION_CREATE {
+		if len(item.Document) == 0 {
+			return store.BulkOperation{}, nil, newRequestError(http.StatusBadRequest, "Error parsing and decoding document data.", errors.New("empty document data"))
+		}
+		if err := newDocumentData[T](item.Document); err != nil {
+			return store.BulkOperation{}, nil, err
+		}
+		objectID := primitive.NewObjectID()
+		item.Document["_id"] = objectID
+		report.ID, report.Result, report.Status, report.Version = objectID.Hex(), BULK_RESULT_CREATED, http.StatusCreated, utils.INITIAL_VERSION
+		return store.BulkOperation{Kind: store.BULK_INSERT, Document: item.Document}, nil, nil
+	}
+
+	// Only the requested version of the document is updated or deleted
+	objectID, err := primitive.ObjectIDFromHex(item.ID)
+	if err != nil {
+		return store.BulkOperation{}, nil, newRequestError(http.StatusBadRequest, "cannot convert hex id to bson id", err)
+	}
+	if item.Version <= 0 {
+		message := "The 'version' of the document is required. Retrieve the document first."
+		return store.BulkOperation{}, nil, newRequestError(http.StatusPreconditionRequired, message, errors.New("missing version"))
+	}
+	filter := withVersion(utils.NotDeleted(bson.M{"_id": objectID}), item.Version)
+	written := bson.M{"_id": objectID, utils.VERSION_FIELD: item.Version + 1}
+	report.Version = item.Version + 1
+
+	if item.Op == BULK_OPERATION_DELETE {
+		if children := referencingCollections(resource.Name); len(children) > 0 {
+			message := fmt.Sprintf("The documents of the collection { %s } are referenced by %v, delete them with the delete APIs (delete policies).", resource.Name, children)
+			return store.BulkOperation{}, nil, newRequestError(http.StatusUnprocessableEntity, message, errors.New("not supported bulk deletion"))
+		}
+		written[utils.DELETED_AT_FIELD] = options.deletedAt
+		report.Result, report.Status = BULK_RESULT_DELETED, http.StatusOK
+		return store.BulkOperation{Kind: store.BULK_UPDATE, Filter: filter, Update: softDeleteUpdate(options)}, written, nil
+	}
+
+	if len(item.Document) == 0 {
+		return store.BulkOperation{}, nil, newRequestError(http.StatusBadRequest, "Error parsing and decoding document data.", errors.New("empty document data"))
+	}
+	update, err := documentUpdateData[T](item.Document)
+	if err != nil {
+		return store.BulkOperation{}, nil, err
+	}
+	update["$set"].(bson.M)["lastUpdatedDt"] = options.deletedAt
+	written["lastUpdatedDt"] = options.deletedAt
+	report.Result, report.Status = BULK_RESULT_UPDATED, http.StatusOK
+	return store.BulkOperation{Kind: store.BULK_UPDATE, Filter: filter, Update: utils.IncrementVersion(update)}, written, nil
+}
+
+// Private
+// This method returns the child collections that reference the documents of the collection
+func referencingCollections(collectionName string) []string {
+	children := []string{}
+	for _, reference := range DOCUMENT_REFERENCES {
+		if reference.parentCollectionName == collectionName {
+			children = append(children, reference.collectionName)
+		}
+	}
+	return children
+}
+
+// Private
+// This method returns the error of an update (or a soft deletion) of the bulk write, when the bulk write matched fewer documents
+// The bulk writes report only the total matched count, so the written documents are found with their next version and the date of the write
+// Output: nil if the document was written, 412 if it was modified by another request, 404 if it is not existent (or deleted)
+func bulkWriteConflict(ctx context.Context, collection store.Collection, written bson.M) error {
+	count, err := collection.CountDocuments(ctx, written)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	return versionConflict(ctx, collection, utils.NotDeleted(bson.M{"_id": written["_id"]}))
+}
+
+// Private
+// This method executes the writes of an ordered bulk write with one bulk write of the store, in a transaction
+// MongoDB goes on after an update (deletion) that matched no document, so a bulk write that matched fewer documents
+// and a bulk write with a write error are aborted. Then the first update that matches no document is found before the writes
+// and only the writes before the failed write are written again, so the writes after it are never kept
+// The items of the failed write and of the writes after it are marked in the reports
+func orderedBulkWrite(ctx context.Context, dataStore store.Store, collection store.Collection, operations []store.BulkOperation, itemIndexes []int, reports []bulkItemReport) error {
+	failedPosition := len(operations)
+	var failure error
+	for written, checked := len(operations), false; written > 0; written, checked = failedPosition, true {
+		err := dataStore.WithTransaction(ctx, func(txCtx context.Context) error {
+			if checked {
+				position, err := firstNotMatchedWrite(txCtx, collection, operations[:written])
+				if err != nil {
+					return err
+				}
+				if position >= 0 {
+					failedPosition, failure = position, versionConflict(txCtx, collection, utils.NotDeleted(bson.M{"_id": operations[position].Filter.(bson.M)["_id"]}))
+					written = position
+				}
+				if written == 0 {
+					return nil
+				}
+			}
+
+			result, err := collection.BulkWrite(txCtx, operations[:written], true)
+			if err != nil {
+				return err
+			}
+			if len(result.Errors) > 0 {
+				failedPosition, failure = result.Errors[0].Index, result.Errors[0].Err
+				return errBulkWriteStopped
+			}
+			// The same writes again, after the check of the updates (deletions)
+			if result.MatchedCount != updatesCount(operations[:written]) {
+				failedPosition = written
+				return errBulkWriteStopped
+			}
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errBulkWriteStopped) {
+			return err
+		}
+	}
+
+	if failure != nil {
+		reports[itemIndexes[failedPosition]].fail(failure)
+		for _, index := range itemIndexes[failedPosition+1:] {
+			reports[index].skip()
+		}
+	}
+	return nil
+}
+
+// Private
+// This method returns the position of the first update (deletion) of the writes that matches no document, before the writes
+// Output: -1 if every update (deletion) matches its document
+func firstNotMatchedWrite(ctx context.Context, collection store.Collection, operations []store.BulkOperation) (int, error) {
+	filters := []bson.M{}
+	for _, operation := range operations {
+		if operation.Kind == store.BULK_UPDATE {
+			filters = append(filters, operation.Filter.(bson.M))
+		}
+	}
+	if len(filters) == 0 {
+		return -1, nil
+	}
+
+	var matchedDocuments []bson.M
+	err := collection.Find(ctx, bson.M{"$or": filters}, &store.FindOptions{Projection: bson.M{"_id": 1}}, &matchedDocuments)
+	if err != nil {
+		return -1, err
+	}
+	matchedIDs := map[interface{}]bool{}
+	for _, document := range matchedDocuments {
+		matchedIDs[document["_id"]] = true
+	}
+
+	for position, operation := range operations {
+		if operation.Kind == store.BULK_UPDATE && !matchedIDs[operation.Filter.(bson.M)["_id"]] {
+			return position, nil
+		}
+	}
+	return -1, nil
+}
+
+// Private
+// This method returns the number of the updates (deletions) of the writes
+func updatesCount(operations []store.BulkOperation) int64 {
+	var count int64
+	for _, operation := range operations {
+		if operation.Kind == store.BULK_UPDATE {
+			count++
+		}
+	}
+	return count
+}
+
+// Private
+// This method executes the writes of an unordered bulk write with one bulk write of the store
+// The failed writes and the updates (deletions) that matched no document are marked in the reports
+func unorderedBulkWrite(ctx context.Context, collection store.Collection, operations []store.BulkOperation, writtenFilters []bson.M, itemIndexes []int, reports []bulkItemReport) error {
+	result, err := collection.BulkWrite(ctx, operations, false)
+	if err != nil {
+		return err
+	}
+
+	failedWrites := map[int]bool{}
+	for _, writeError := range result.Errors {
+		reports[itemIndexes[writeError.Index]].fail(writeError.Err)
+		failedWrites[writeError.Index] = true
+	}
+
+	// Updates and deletions that matched no document (another version, not existent or deleted)
+	expectedMatches := updatesCount(operations)
+	for position, operation := range operations {
+		if operation.Kind == store.BULK_UPDATE && failedWrites[position] {
+			expectedMatches--
+		}
+	}
+	if result.MatchedCount == expectedMatches {
+		return nil
+	}
+	for position, operation := range operations {
+		if operation.Kind != store.BULK_UPDATE || failedWrites[position] {
+			continue
+		}
+		if err = bulkWriteConflict(ctx, collection, writtenFilters[position]); err != nil {
+			reports[itemIndexes[position]].fail(err)
+		}
+	}
+	return nil
+}
+
+// BULK WRITE --------------
+// -------------------------
+// This method creates, updates and (soft) deletes documents of the collection with a bulk write
+// The ordered bulk writes stop at the first failed item (also an update or deletion of another version
+// or of a not existent document) and keep only the writes before it, the unordered ones run every valid item
+// The response reports the result of every item, in the order of the request
+func BulkWriteDocuments[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// Set the collection and its policy
+		resource, err := registry.ResourceOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
+			return
+		}
+
+		// Retrieve and read the request body
+		var request bulkRequest
+		decoderData := json.NewDecoder(ctx.Request.Body)
+
+		// Cast all integers or floats as Number
+		decoderData.UseNumber()
+
+		// Decode the data
+		err = decoderData.Decode(&request)
+		if err != nil || len(request.Items) == 0 {
+			if err == nil {
+				err = errors.New("no operations in the bulk write")
+			}
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing and decoding the operations of the bulk write.", err.Error())
+			return
+		}
+		if len(request.Items) > MAX_BULK_ITEMS {
+			message := fmt.Sprintf("The bulk write has more than %d operations.", MAX_BULK_ITEMS)
+			utils.HandleError(ctx, http.StatusBadRequest, message, message)
+			return
+		}
+		ordered := request.Ordered == nil || *request.Ordered
+
+		// The deletions of the bulk writes are soft (restorable from the trash)
+		options, err := deletionOptions(ctx)
+		if err == nil && options.hard {
+			err = newRequestError(http.StatusBadRequest, "The bulk writes delete the documents only softly.", errors.New("hard bulk deletion"))
+		}
+		if err != nil {
+			handleTransactionError(ctx, err, "Not valid deletion options.")
+			return
+		}
+
+		// The writes of the valid items (the first not valid item stops the ordered bulk writes)
+		reports := make([]bulkItemReport, len(request.Items))
+		operations := []store.BulkOperation{}
+		writtenFilters := []bson.M{}
+		itemIndexes := []int{}
+		stopped := false
+		documentIDs := map[string]bool{}
+		for index, item := range request.Items {
+			reports[index] = bulkItemReport{Index: index, Op: item.Op, ID: item.ID}
+			if stopped {
+				reports[index].skip()
+				continue
+			}
+
+			// Every document is written once, so the result of its write is known from its version
+			var operation store.BulkOperation
+			var written bson.M
+			var err error
+			if item.Op != BULK_OPERATION_CREATE && documentIDs[item.ID] {
+				err = newRequestError(http.StatusBadRequest, "The document is in more than one operation of the bulk write.", errors.New("duplicate bulk document"))
+			} else {
+				operation, written, err = bulkOperation[T](resource, item, options, &reports[index])
+			}
+			if item.Op != BULK_OPERATION_CREATE {
+				documentIDs[item.ID] = true
+			}
+			if err != nil {
+				reports[index].fail(err)
+				stopped = ordered
+				continue
+			}
+			operations = append(operations, operation)
+			writtenFilters = append(writtenFilters, written)
+			itemIndexes = append(itemIndexes, index)
+		}
+
+		// Execute the writes of the valid items
+		collection := dataStore.Collection(resource.Name)
+		if len(operations) > 0 {
+			if ordered {
+				err = orderedBulkWrite(context.TODO(), dataStore, collection, operations, itemIndexes, reports)
+			} else {
+				err = unorderedBulkWrite(context.TODO(), collection, operations, writtenFilters, itemIndexes, reports)
+			}
+			if err != nil {
+				utils.HandleError(ctx, http.StatusInternalServerError, "Error executing the bulk write.", err.Error())
+				return
+			}
+		}
+
+		// Counts of the results
+		counts := map[string]int{}
+		for _, report := range reports {
+			counts[report.Result]++
+		}
+		fmt.Println("Bulk write result:", counts)
+
+		// Return response
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Bulk write finished for the collection { " + resource.Name + " }.",
+			"ordered": ordered,
+			"created": counts[BULK_RESULT_CREATED],
+			"updated": counts[BULK_RESULT_UPDATED],
+			"deleted": counts[BULK_RESULT_DELETED],
+			"failed":  counts[BULK_RESULT_FAILED],
+			"skipped": counts[BULK_RESULT_SKIPPED],
+			"data":    reports,
+		})
+	}
+}
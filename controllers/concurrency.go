@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Headers of the optimistic concurrency control
+const ETAG_HEADER = "ETag"
+const IF_MATCH_HEADER = "If-Match"
+const IF_NONE_MATCH_HEADER = "If-None-Match"
+
+// Private
+// This method returns the version of the If-Match header, which is required by the updates
+// Output: 428 request error if the header is missing, 400 if it is not a valid ETag
+func requiredVersion(ctx *gin.Context) (int64, error) {
+	ifMatch := strings.TrimSpace(ctx.GetHeader(IF_MATCH_HEADER))
+	if ifMatch == "" {
+		message := "The header '" + IF_MATCH_HEADER + "' with the ETag of the document is required. Retrieve the document first."
+		return 0, newRequestError(http.StatusPreconditionRequired, message, errors.New("missing If-Match header"))
+	}
+
+	version, err := utils.ParseETag(ifMatch)
+	if err != nil {
+		return 0, newRequestError(http.StatusBadRequest, "Not valid '"+IF_MATCH_HEADER+"' header.", err)
+	}
+	return version, nil
+}
+
+// Private
+// This method adds the version of the If-Match header to the filter of the update
+func withVersion(filter bson.M, version int64) bson.M {
+	versionFilter := bson.M{utils.VERSION_FIELD: version}
+	for key, value := range filter {
+		versionFilter[key] = value
+	}
+	return versionFilter
+}
+
+// Private
+// This method returns the error of an update that matched no document of the requested version
+// Output: 404 request error if the document is not existent (or deleted), 412 if it exists with another version
+func versionConflict(ctx context.Context, collection store.Collection, filter bson.M) error {
+	if err := documentExists(ctx, collection, filter); err != nil {
+		return err
+	}
+
+	message := "The document was modified by another request. Retrieve it again and retry with its new ETag."
+	return newRequestError(http.StatusPreconditionFailed, message, errors.New("version conflict"))
+}
+
+// Private
+// This method checks that a live document matches the filter (without version), before its version is checked
+// Output: 404 request error if the document is not existent (or deleted)
+func documentExists(ctx context.Context, collection store.Collection, filter bson.M) error {
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return newRequestError(http.StatusNotFound, "The document is not existent or it is deleted.", errors.New("document not found"))
+	}
+	return nil
+}
+
+// Private
+// This method checks that the document data has no version (set only by the writes)
+func checkNoVersion(requestBody map[string]interface{}) error {
+	if _, found := requestBody[utils.VERSION_FIELD]; found {
+		return errors.New("field '" + utils.VERSION_FIELD + "' is not allowed in the request body, use the '" + IF_MATCH_HEADER + "' header.")
+	}
+	return nil
+}
+
+// Private
+// This method sets the ETag header of the document version
+func setETag(ctx *gin.Context, version int64) {
+	ctx.Header(ETAG_HEADER, utils.FormatETag(version))
+}
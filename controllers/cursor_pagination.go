@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	driverbson "go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Query parameters of the cursor (keyset) pagination
+// An empty after starts from the first page, e.g. GET /api/v1/licenses?after=&limit=50
+const CURSOR_AFTER_PARAM = "after"
+const CURSOR_BEFORE_PARAM = "before"
+
+// Query parameter that adds the total number of the (filtered) documents to the cursor pages (count=true)
+const CURSOR_COUNT_PARAM = "count"
+
+// Private
+// This method checks if the list is requested with a cursor (after or before)
+func isCursorRequest(ctx *gin.Context) bool {
+	_, isAfter := ctx.GetQuery(CURSOR_AFTER_PARAM)
+	_, isBefore := ctx.GetQuery(CURSOR_BEFORE_PARAM)
+	return isAfter || isBefore
+}
+
+// Private
+// This method responds with the page of the documents after (or before) the cursor of the request
+// The pages are found by the values of the sort keys (no skip), and the documents are counted only with count=true
+// The sort keys are always read for the cursors, while the response has only the fields of the projection
+func respondCursorPage[T any](ctx *gin.Context, collection store.Collection, collectionName string, filterObject bson.M, sortKeys driverbson.D, limit int64, projection models.Projection) {
+	afterToken, isAfter := ctx.GetQuery(CURSOR_AFTER_PARAM)
+	beforeToken, isBefore := ctx.GetQuery(CURSOR_BEFORE_PARAM)
+	if isAfter && isBefore {
+		utils.HandleError(ctx, http.StatusBadRequest, "Invalid cursor.", "Only one of the after and before cursors is allowed.")
+		return
+	}
+
+	token, backwards := afterToken, isBefore
+	if backwards {
+		token = beforeToken
+	}
+
+	// Documents after (or before) the cursor, with the same filters
+	pageFilter := filterObject
+	if utils.CheckStringNotEmpty(token) {
+		cursor, err := utils.DecodeCursor(token)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid cursor.", err.Error())
+			return
+		}
+		if cursor.Sort != models.SortSignature(sortKeys) {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid cursor.", "The cursor is of another sort: "+cursor.Sort)
+			return
+		}
+
+		keysetFilter, err := models.KeysetFilter(sortKeys, cursor.Values, backwards)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid cursor.", err.Error())
+			return
+		}
+		pageFilter = bson.M{"$and": []bson.M{filterObject, bson.M(keysetFilter)}}
+	} else if backwards {
+		utils.HandleError(ctx, http.StatusBadRequest, "Invalid cursor.", "The before cursor is empty.")
+		return
+	}
+
+	// One more document tells if there is another page in the same direction
+	searchOpts := &store.FindOptions{Limit: limit + 1, Sort: sortKeys, Projection: projection.With(models.SortFields(sortKeys)...).Query}
+	if backwards {
+		searchOpts.Sort = models.ReverseSort(sortKeys)
+	}
+
+	documentsList := []T{}
+	if err := collection.Find(context.TODO(), pageFilter, searchOpts, &documentsList); err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving documents list.", err.Error())
+		return
+	}
+
+	hasMore := int64(len(documentsList)) > limit
+	if hasMore {
+		documentsList = documentsList[:limit]
+	}
+
+	// The documents before the cursor are found in the reversed order
+	hasNext, hasPrev := hasMore, utils.CheckStringNotEmpty(token)
+	if backwards {
+		for left, right := 0, len(documentsList)-1; left < right; left, right = left+1, right-1 {
+			documentsList[left], documentsList[right] = documentsList[right], documentsList[left]
+		}
+		hasNext, hasPrev = true, hasMore
+	}
+
+	// Only the requested fields of the documents
+	selectedDocuments, err := models.SelectFields(documentsList, projection)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "Error selecting the fields of the documents.", err.Error())
+		return
+	}
+
+	response := gin.H{
+		"message": "Retrieved documents successfully. Retrieved {" + fmt.Sprint(len(documentsList)) + "} Documents from Collection { " + collectionName + " }.",
+		"data":    selectedDocuments,
+		"rows":    len(documentsList),
+		"limit":   limit,
+		"next":    nil,
+		"prev":    nil,
+	}
+
+	// Links of the pages after the last and before the first document
+	if len(documentsList) > 0 {
+		if hasNext {
+			link, err := cursorLink(ctx, sortKeys, documentsList[len(documentsList)-1], CURSOR_AFTER_PARAM)
+			if err != nil {
+				utils.HandleError(ctx, http.StatusInternalServerError, "Error creating the cursor of the next page.", err.Error())
+				return
+			}
+			response["next"] = link
+		}
+		if hasPrev {
+			link, err := cursorLink(ctx, sortKeys, documentsList[0], CURSOR_BEFORE_PARAM)
+			if err != nil {
+				utils.HandleError(ctx, http.StatusInternalServerError, "Error creating the cursor of the previous page.", err.Error())
+				return
+			}
+			response["prev"] = link
+		}
+	}
+
+	// Total number of the documents (optional, it reads all the matching documents)
+	if ctx.Query(CURSOR_COUNT_PARAM) == "true" {
+		countDocumentsResult, err := collection.CountDocuments(context.TODO(), filterObject)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the documents in the collection.", err.Error())
+			return
+		}
+		response["totalNumbersDocuments"] = countDocumentsResult
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// Private
+// This method returns the link of the page after (or before) the document
+// The link is the URL of the request with the cursor of the document
+func cursorLink[T any](ctx *gin.Context, sortKeys driverbson.D, document T, cursorParam string) (string, error) {
+	values, err := models.SortValues(document, sortKeys)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := utils.EncodeCursor(utils.Cursor{Sort: models.SortSignature(sortKeys), Values: values})
+	if err != nil {
+		return "", err
+	}
+
+	query := ctx.Request.URL.Query()
+	query.Del(CURSOR_AFTER_PARAM)
+	query.Del(CURSOR_BEFORE_PARAM)
+	query.Set(cursorParam, token)
+
+	link := url.URL{Path: ctx.Request.URL.Path, RawQuery: query.Encode()}
+	return link.String(), nil
+}
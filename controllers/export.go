@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Number of the rows of an export that are sent to the client together
+const EXPORT_FLUSH_ROWS = 500
+
+// EXPORT DOCUMENTS --------
+// -------------------------
+// This method exports every document that matches the filters of the list (filter[field][operator]=value),
+// in the order of the sort (sort=-createdDt) and with the columns of the fields (fields=title,priceEurosMonthly)
+// The format is csv (default), xlsx or ndjson, and the excluded fields (e.g. password) are never exported
+// The rows are streamed from the cursor of the collection, so the documents are never loaded all together
+func ExportDocuments[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// Format of the export (format=csv|xlsx|ndjson)
+		format, err := utils.ParseExportFormat(ctx.Query(utils.EXPORT_FORMAT_PARAM))
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid format.", err.Error())
+			return
+		}
+
+		// Filters of the query parameters (filter[field][operator]=value)
+		filterObject, err := documentsFilter[T](ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid filter.", err.Error())
+			return
+		}
+
+		// Sorting of the query parameters (sort=-createdDt,title), insertion order by default
+		sortKeys, err := models.CompileSort[T](ctx.Query(models.SORT_PARAM), models.DEFAULT_SORT)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid sort.", err.Error())
+			return
+		}
+
+		// Columns of the export (fields=title,priceEurosMonthly), never the excluded fields (e.g. password)
+		projection, err := models.CompileProjection[T](ctx.Query(models.FIELDS_PARAM))
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid fields.", err.Error())
+			return
+		}
+		columns := models.ExportColumns[T](projection)
+
+		// Set the collection
+		collectionName, err := registry.CollectionOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
+			return
+		}
+		collection := dataStore.Collection(collectionName)
+
+		// The cursor is closed when the client goes away
+		requestCtx := ctx.Request.Context()
+		searchOpts := &store.FindOptions{Sort: sortKeys, Projection: projection.Query}
+		cursor, err := collection.FindCursor(requestCtx, filterObject, searchOpts)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving documents list.", err.Error())
+			return
+		}
+		defer cursor.Close(context.TODO())
+
+		ctx.Header("Content-Type", format.ContentType)
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", collectionName, format.Extension))
+		ctx.Header("Cache-Control", "no-store")
+		exportWriter, err := utils.NewExportWriter(format, ctx.Writer)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error exporting the documents.", err.Error())
+			return
+		}
+		ctx.Status(http.StatusOK)
+
+		// The status is already sent, so an error stops the export (truncated file) and is only logged
+		if err = writeExportRows[T](ctx, exportWriter, cursor, columns); err != nil {
+			fmt.Println("Error exporting the documents of", collectionName+":", err)
+			ctx.Abort()
+			return
+		}
+	}
+}
+
+// Private
+// This method writes the header and a row per document of the cursor, and ends the export
+func writeExportRows[T any](ctx *gin.Context, exportWriter utils.ExportWriter, cursor store.Cursor, columns []string) error {
+	requestCtx := ctx.Request.Context()
+	if err := exportWriter.WriteHeader(columns); err != nil {
+		return err
+	}
+
+	rows := 0
+	for cursor.Next(requestCtx) {
+		var document T
+		if err := cursor.Decode(&document); err != nil {
+			return err
+		}
+		row, err := models.ExportRow(document, columns)
+		if err != nil {
+			return err
+		}
+		if err = exportWriter.WriteRow(row); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%EXPORT_FLUSH_ROWS == 0 {
+			if err = exportWriter.Flush(); err != nil {
+				return err
+			}
+			ctx.Writer.Flush()
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if err := exportWriter.Close(); err != nil {
+		return err
+	}
+	ctx.Writer.Flush()
+	return nil
+}
@@ -6,741 +6,955 @@ import (
 	"errors"
 	"fmt"
 	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/middleware"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"go-essentials/go-mongodb-rest-api/store"
 	"go-essentials/go-mongodb-rest-api/utils"
 	"math"
 	"net/http"
+	"os"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/mgo.v2/bson"
 )
 
+// Default number of the documents of a page and the (configurable) max number
+const DEFAULT_PAGE_LIMIT int64 = 100
+const MAX_PAGE_LIMIT_ENV = "MAX_PAGE_LIMIT"
+const DEFAULT_MAX_PAGE_LIMIT int64 = 1000
+
 // Private
-// This method converts the model name to the corresponding collection name
-func modelToCollectionName(modelName string) (string, error) {
-	switch modelName {
-	case "models.User":
-		// USERS
-		return db.DB_TABLE_USERS, nil
-
-	case "models.LicenseCategory":
-		// LICENSE CATEGORIES
-		return db.DB_TABLE_LICENSES_CATEGORIES, nil
-
-	case "models.License":
-		// LICENSES
-		return db.DB_TABLE_LICENCES, nil
-
-	default:
-		return "", errors.New("not found corresponding model to collection name: " + modelName)
+// This method constructs the createdDt range filter from the created_dtFrom and created_dtTo query parameters
+// Output: nil if none of the parameters is given
+func createdDateRangeFilter(ctx *gin.Context) (bson.M, error) {
+	dateRange := bson.M{}
+	for _, bound := range [][2]string{{"created_dtFrom", "$gte"}, {"created_dtTo", "$lte"}} {
+		parameter, operator := bound[0], bound[1]
+		value := ctx.Query(parameter)
+		fmt.Println(parameter+":", value)
+		if !utils.CheckStringNotEmpty(value) {
+			continue
+		}
+
+		date, err := utils.ParseDate(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", parameter, err)
+		}
+		dateRange[operator] = date
+	}
+
+	if len(dateRange) == 0 {
+		return nil, nil
 	}
+	return dateRange, nil
 }
 
-// CREATE DOCUMENT -------------
-// -----------------------------
-func CreateDocument[T any](ctx *gin.Context) {
+// Private
+// This method returns the requested page (default 1) and limit (default 100) of the query parameters
+func pageAndLimit(ctx *gin.Context) (int64, int64, error) {
+	var pageRequested int64 = 1
+	if pageNumberString := ctx.Query("page"); utils.CheckStringNotEmpty(pageNumberString) {
+		pageNumber, err := strconv.ParseInt(pageNumberString, 10, 64)
+		if err != nil || pageNumber <= 0 {
+			return 0, 0, errors.New("Error transforming page number to int64 OR page number is lower/equal to ZERO.")
+		}
+		pageRequested = pageNumber
+	}
 
-	// Get the type to retrieve
-	var docRetrieve T
-	fmt.Printf("Models Type: %T\n", docRetrieve)
+	var limit int64 = DEFAULT_PAGE_LIMIT
+	if limitResultString := ctx.Query("limit"); utils.CheckStringNotEmpty(limitResultString) {
+		limitNumber, err := strconv.ParseInt(limitResultString, 10, 64)
+		if err != nil || limitNumber <= 0 {
+			return 0, 0, errors.New("Error transforming limit to int64 OR limit is lower/equal to ZERO.")
+		}
+		limit = limitNumber
+	}
 
-	// Set the collection
-	collectionName, err := modelToCollectionName(fmt.Sprintf("%T", docRetrieve))
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
+	if maxLimit := maxPageLimit(); limit > maxLimit {
+		return 0, 0, fmt.Errorf("The limit is greater than the max limit {%d}.", maxLimit)
 	}
+	return pageRequested, limit, nil
+}
 
-	// Terminate the create document action if it is the 'users' API
-	if collectionName == db.DB_TABLE_USERS {
-		messageShowError := "create document is not allowed for 'users'. Use register API or createAdmin."
-		utils.HandleError(ctx, http.StatusMethodNotAllowed, messageShowError, messageShowError)
-		return
+// Private
+// This method returns the max limit of the pages (MAX_PAGE_LIMIT of the environment)
+func maxPageLimit() int64 {
+	if maxLimitString := os.Getenv(MAX_PAGE_LIMIT_ENV); utils.CheckStringNotEmpty(maxLimitString) {
+		maxLimit, err := utils.TransformStringToInteger64(maxLimitString)
+		if err == nil && maxLimit > 0 {
+			return maxLimit
+		}
+		fmt.Println(MAX_PAGE_LIMIT_ENV, "must be a positive number, using the default:", DEFAULT_MAX_PAGE_LIMIT)
 	}
+	return DEFAULT_MAX_PAGE_LIMIT
+}
 
-	// Retrieve and read the request body
-	requestBody := make(map[string]interface{})
-	decoderData := json.NewDecoder(ctx.Request.Body)
+// Private
+// This method compiles the filter[...] query parameters of the model (see models.CompileFilters)
+// The soft deleted documents are always excluded
+func documentsFilter[T any](ctx *gin.Context) (bson.M, error) {
+	filter, err := models.CompileFilters[T](ctx.Request.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	return utils.NotDeleted(bson.M(filter)), nil
+}
 
-	// Cast all integers or floats as Number
-	decoderData.UseNumber()
+// Private
+// This method checks and converts the data of a new document and sets the fields of the server (dates, flags, version)
+// Output: ValidationErrors of the not valid fields, or a request error (400)
+func newDocumentData[T any](requestBody map[string]interface{}) error {
 
-	// Decode the data
-	err = decoderData.Decode(&requestBody)
-	fmt.Println("Request body:", requestBody)
-	fmt.Printf("Type:%T\n", requestBody)
+	// The soft delete markers are set only by the delete APIs and the version only by the writes
+	if err := checkNoDeleteMarkers(requestBody); err != nil {
+		return newRequestError(http.StatusBadRequest, err.Error(), err)
+	}
+	if err := checkNoVersion(requestBody); err != nil {
+		return newRequestError(http.StatusBadRequest, err.Error(), err)
+	}
 
-	// Check if error OR the request map is empty
-	if err != nil || len(requestBody) == 0 {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing and decoding document data.", err.Error())
-		return
+	// Types, rules and known fields of the model (e.g. required, max, no _id or createdDt)
+	if err := models.ValidateDocument[T](requestBody, false); err != nil {
+		return err
+	}
+
+	if err := convertDocumentValues[T](requestBody, "Invalid date in the document data.", "Invalid value in the document data."); err != nil {
+		return err
 	}
 
-	// Created dt and last update dt (YYYY-MM-DD HH:MM:SS)
-	NOW_TIME := time.Now().Format("2006-01-02 15:04:05")
+	// Created dt and last update dt (native dates in UTC)
+	NOW_TIME := utils.NowUTC()
 	requestBody["createdDt"] = NOW_TIME
 	requestBody["lastUpdatedDt"] = NOW_TIME
-	requestBody["isActive"] = "1"
+	requestBody["isActive"] = true
+	requestBody[utils.DELETED_AT_FIELD] = nil
+	requestBody[utils.VERSION_FIELD] = utils.INITIAL_VERSION
+	return nil
+}
+
+// Private
+// This method checks and converts the data of a (shallow) update of a document
+// Output: the $set statement of the given fields, ValidationErrors of the not valid fields, or a request error (400)
+func documentUpdateData[T any](requestBody map[string]interface{}) (bson.M, error) {
+
+	// The soft delete markers are set only by the delete APIs and the version only by the writes
+	if err := checkNoDeleteMarkers(requestBody); err != nil {
+		return nil, newRequestError(http.StatusBadRequest, err.Error(), err)
+	}
+	if err := checkNoVersion(requestBody); err != nil {
+		return nil, newRequestError(http.StatusBadRequest, err.Error(), err)
+	}
 
-	// Print the data to insert
-	fmt.Println("Document data to insert: ", requestBody)
+	// Types and rules of the given fields of the model (e.g. no immutable or unknown fields)
+	if err := models.ValidateDocument[T](requestBody, true); err != nil {
+		return nil, err
+	}
 
-	// Insert the document into the database
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(collectionName)
-	result, err := collection.InsertOne(context.TODO(), requestBody)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error storing the new document in the database.", err.Error())
+	if err := convertDocumentValues[T](requestBody, "Invalid date in the document data.", "Invalid value in the document data."); err != nil {
+		return nil, err
+	}
+	return bson.M{"$set": bson.M(requestBody)}, nil
+}
+
+// Private
+// This method converts the dates (RFC 3339 strings), the flags and the category types of the top level fields to their types
+// Output: 400 request error with the given messages if a value is not valid
+func convertDocumentValues[T any](values map[string]interface{}, dateMessage string, valueMessage string) error {
+
+	// Dates of the model (RFC 3339 strings) to native dates
+	if err := utils.ConvertDateFields(values, utils.DateFieldsOf[T]()); err != nil {
+		return newRequestError(http.StatusBadRequest, dateMessage, err)
+	}
+
+	// Flags and category type of the model to their types (the deprecated "0" / "1" strings are accepted)
+	if err := models.ConvertTypedFields[T](values); err != nil {
+		return newRequestError(http.StatusBadRequest, valueMessage, err)
+	}
+	return nil
+}
+
+// Private
+// This method responds with the error of not valid document data: 422 with the errors of the fields, or the request error
+func handleDocumentDataError(ctx *gin.Context, err error) {
+	var fieldErrors models.ValidationErrors
+	if errors.As(err, &fieldErrors) {
+		utils.HandleValidationError(ctx, "Not valid document data.", err.Error(), fieldErrors)
 		return
 	}
+	handleTransactionError(ctx, err, "Not valid document data.")
+}
 
-	// Print the insert result
-	fmt.Println("Insert Result:", result)
+// Private
+// This method restricts the filter to the documents of the user without the permission to delete all the documents
+// Output: the filter and whether it is restricted (the resources without an owner field are only deleted with that permission, 403)
+func ownedDocumentFilter(ctx *gin.Context, resource registry.Resource, filter bson.M) (bson.M, bool, error) {
+	principal, found := middleware.GetPrincipal(ctx)
+	if !found {
+		return nil, false, newRequestError(http.StatusUnauthorized, "Unauthorized user.", errors.New("not authorized user"))
+	}
+	if principal.HasPermission(middleware.PERMISSION_DELETE_DOCUMENTS) {
+		return filter, false, nil
+	}
+	if resource.OwnerField == "" {
+		return nil, false, newRequestError(http.StatusForbidden, "The user has no permission for this request.", errors.New("not owned document of the "+resource.Name))
+	}
 
-	// Success response
-	ctx.JSON(http.StatusCreated, gin.H{
-		"message": "Document inserted successfully in the collection { " + collectionName + " }.",
-		"data": []map[string]any{
-			{
-				"_id": result.InsertedID,
-			},
-		},
-	})
+	ownerID, err := primitive.ObjectIDFromHex(principal.UserID)
+	if err != nil {
+		return nil, false, newRequestError(http.StatusUnauthorized, "Unauthorized user.", err)
+	}
+	return bson.M{"$and": []bson.M{filter, {resource.OwnerField: ownerID}}}, true, nil
 }
 
-// GET ALL DOCUMENTS -----------
+// CREATE DOCUMENT -------------
 // -----------------------------
-func GetAllDocuments[T any](ctx *gin.Context) {
-
-	// Console the Query
-	fmt.Println("Request Query:", ctx.Request.URL)
+func CreateDocument[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Console all the query parameters
-	queryGivenParams := ctx.Request.URL.Query()
-	fmt.Println("Query Parameters:", queryGivenParams)
+		// Get the type to retrieve
+		var docRetrieve T
+		fmt.Printf("Models Type: %T\n", docRetrieve)
 
-	// PAGING - SKIP
-	pageNumberString := ctx.Query("page")
-	fmt.Println("Page requested (string):", pageNumberString)
+		// Set the collection
+		collectionName, err := registry.CollectionOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
+			return
+		}
 
-	var pageRequested int64 = 1
-	if utils.CheckStringNotEmpty(pageNumberString) {
-		pageNumberLocal, err := strconv.ParseInt(pageNumberString, 10, 64)
-		if err != nil || pageNumberLocal <= 0 {
-			errorMsg := "Error transforming page number to int64 OR page number is lower/equal to ZERO."
-			utils.HandleError(ctx, http.StatusBadRequest, errorMsg, errorMsg)
+		// Terminate the create document action if it is the 'users' API
+		if collectionName == db.DB_TABLE_USERS {
+			messageShowError := "create document is not allowed for 'users'. Use register API or createAdmin."
+			utils.HandleError(ctx, http.StatusMethodNotAllowed, messageShowError, messageShowError)
 			return
 		}
-		fmt.Println("Page number (INT PARSED):", pageNumberLocal)
-		pageRequested = pageNumberLocal
-	}
 
-	// LIMIT RESULTS - LIMIT
-	limitResultString := ctx.Query("limit")
-	fmt.Println("Limit Results (string):", limitResultString)
+		// Retrieve and read the request body
+		requestBody := make(map[string]interface{})
+		decoderData := json.NewDecoder(ctx.Request.Body)
+
+		// Cast all integers or floats as Number
+		decoderData.UseNumber()
 
-	var limit int64 = 100
-	if utils.CheckStringNotEmpty(limitResultString) {
-		limitLocal, err := strconv.ParseInt(limitResultString, 10, 64)
-		if err != nil || limitLocal <= 0 {
-			errorMsg := "Error transforming limit to int64 OR limit is lower/equal to ZERO."
-			utils.HandleError(ctx, http.StatusBadRequest, errorMsg, errorMsg)
+		// Decode the data
+		err = decoderData.Decode(&requestBody)
+		fmt.Println("Request body:", requestBody)
+		fmt.Printf("Type:%T\n", requestBody)
+
+		// Check if error OR the request map is empty
+		if err != nil || len(requestBody) == 0 {
+			if err == nil {
+				err = errors.New("empty document data")
+			}
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing and decoding document data.", err.Error())
 			return
 		}
-		fmt.Println("Limit Results (INT PARSED):", limitLocal)
-		limit = limitLocal
-	}
-
-	// Set the query search options
-	searchOpts := options.Find()
-	skipNumberValues := (pageRequested - 1) * limit
-	searchOpts.SetSkip(skipNumberValues).SetLimit(limit)
 
-	// Get the type to retrieve
-	var docRetrieve T
-	fmt.Printf("Models Type: %T\n", docRetrieve)
+		// Checks, types, rules and conversions of the document data and the fields of the server (dates, version)
+		if err = newDocumentData[T](requestBody); err != nil {
+			handleDocumentDataError(ctx, err)
+			return
+		}
 
-	// Retrieve all the collection documents
-	var rows *mongo.Cursor
-	var err error
+		// Print the data to insert
+		fmt.Println("Document data to insert: ", requestBody)
 
-	// Set the collection
-	collectionName, err := modelToCollectionName(fmt.Sprintf("%T", docRetrieve))
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
-	}
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(collectionName)
+		// Insert the document into the database
+		collection := dataStore.Collection(collectionName)
+		insertedID, err := collection.InsertOne(context.TODO(), requestBody)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error storing the new document in the database.", err.Error())
+			return
+		}
 
-	// Find the total number of documents in the requested collection
-	countDocumentsResult, err := collection.CountDocuments(context.TODO(), bson.M{})
+		// Print the insert result
+		fmt.Println("Insert Result:", insertedID)
 
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the documents in the collection.", err.Error())
-		return
+		// Success response
+		ctx.JSON(http.StatusCreated, gin.H{
+			"message": "Document inserted successfully in the collection { " + collectionName + " }.",
+			"data": []map[string]any{
+				{
+					"_id": insertedID,
+				},
+			},
+		})
 	}
+}
 
-	// Check the requested page number if it is viable
-	if skipNumberValues > countDocumentsResult {
-		utils.HandleError(ctx, http.StatusInternalServerError, "The requested page is not existent. Page: "+pageNumberString, "The requested page is not existent.")
-		return
-	}
+// GET ALL DOCUMENTS -----------
+// -----------------------------
+func GetAllDocuments[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Remove password from the documents if is is the "users" collection
-	if collectionName == db.DB_TABLE_USERS {
-		rows, err = collection.Find(context.TODO(), bson.M{}, searchOpts.SetProjection(bson.M{"password": 0}))
-	} else {
-		rows, err = collection.Find(context.TODO(), bson.M{}, searchOpts)
-	}
+		// Console the Query
+		fmt.Println("Request Query:", ctx.Request.URL)
 
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving documents list.", err.Error())
-		return
-	}
+		// Console all the query parameters
+		queryGivenParams := ctx.Request.URL.Query()
+		fmt.Println("Query Parameters:", queryGivenParams)
 
-	// Return all documents data
-	documentsList := []T{}
-	if err = rows.All(context.TODO(), &documentsList); err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving documents list.", err.Error())
-		return
-	}
+		// PAGING - SKIP AND LIMIT RESULTS - LIMIT
+		pageRequested, limit, err := pageAndLimit(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), err.Error())
+			return
+		}
+		fmt.Println("Page requested:", pageRequested, "Limit Results:", limit)
 
-	// Decode all the received documents
-	for rows.Next(context.TODO()) {
+		// Filters of the query parameters (filter[field][operator]=value)
+		filterObject, err := documentsFilter[T](ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid filter.", err.Error())
+			return
+		}
 
-		// Local Variable
-		var resDoc T
+		// Sorting of the query parameters (sort=-createdDt,title), insertion order by default
+		sortKeys, err := models.CompileSort[T](ctx.Query(models.SORT_PARAM), models.DEFAULT_SORT)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid sort.", err.Error())
+			return
+		}
 
-		err = rows.Decode(&resDoc)
+		// Fields of the response (fields=title,priceEurosMonthly), never the excluded fields (e.g. password)
+		// The sort keys are also read for the cursors of the pages
+		projection, err := models.CompileProjection[T](ctx.Query(models.FIELDS_PARAM))
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error decoding documents list.", err.Error())
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid fields.", err.Error())
 			return
 		}
-		documentsList = append(documentsList, resDoc)
-	}
 
-	// Calculate the total number of pages remaining in the requested collection
-	totalPages := math.Floor(float64(countDocumentsResult) / float64(limit))
-	if math.Ceil(float64(totalPages*float64(limit))) < float64(countDocumentsResult) {
-		totalPages += 1
-	}
-	fmt.Println("Total Pages:", totalPages)
-
-	// Send the response with all the documents
-	// The GIN package will automatically encode the response in JSON format
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":               "Retrieved documents successfully. Retrieved {" + fmt.Sprint(len(documentsList)) + "} Documents from Collection { " + collectionName + " }.",
-		"data":                  documentsList,
-		"rows":                  len(documentsList),
-		"currentPage":           pageRequested,
-		"totalPages":            totalPages,
-		"totalNumbersDocuments": countDocumentsResult,
-	})
-}
+		// Set the query search options
+		skipNumberValues := (pageRequested - 1) * limit
+		searchOpts := &store.FindOptions{Skip: skipNumberValues, Limit: limit, Sort: sortKeys, Projection: projection.With(models.SortFields(sortKeys)...).Query}
 
-// GET A DOCUMENT BY ID --------
-// -----------------------------
-func GetDocumentByID[T any](ctx *gin.Context) {
+		// Get the type to retrieve
+		var docRetrieve T
+		fmt.Printf("Models Type: %T\n", docRetrieve)
 
-	// Error variable
-	var err error
+		// Set the collection
+		collectionName, err := registry.CollectionOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
+			return
+		}
+		collection := dataStore.Collection(collectionName)
 
-	// Retrieve the document id from the parameters
-	documentID := ctx.Param("id")
-	if documentID == "" {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error finding document ID.", errors.New("error finding document ID").Error())
-		return
-	}
+		// Cursor (keyset) pagination: after / before instead of page
+		if isCursorRequest(ctx) {
+			respondCursorPage[T](ctx, collection, collectionName, filterObject, sortKeys, limit, projection)
+			return
+		}
 
-	// String id not object id
-	oID, err := primitive.ObjectIDFromHex(documentID)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
-		return
-	}
+		// Find the total number of (filtered) documents in the requested collection
+		// The pages after the last one are empty
+		countDocumentsResult, err := collection.CountDocuments(context.TODO(), filterObject)
 
-	// Get the type to retrieve
-	var docRetrieve T
-	fmt.Printf("Models Type: %T\n", docRetrieve)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the documents in the collection.", err.Error())
+			return
+		}
 
-	// Set the collection
-	collectionName, err := modelToCollectionName(fmt.Sprintf("%T", docRetrieve))
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
-	}
+		// Return all documents data
+		documentsList := []T{}
+		if err = collection.Find(context.TODO(), filterObject, searchOpts, &documentsList); err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving documents list.", err.Error())
+			return
+		}
 
-	// Retrieve a specific document
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(collectionName)
+		// Calculate the total number of pages remaining in the requested collection
+		totalPages := math.Floor(float64(countDocumentsResult) / float64(limit))
+		if math.Ceil(float64(totalPages*float64(limit))) < float64(countDocumentsResult) {
+			totalPages += 1
+		}
+		fmt.Println("Total Pages:", totalPages)
 
-	// Remove password from the documents if is is the "users" collection
-	if collectionName == db.DB_TABLE_USERS {
-		err = collection.FindOne(context.TODO(), bson.M{"_id": oID}, options.FindOne().SetProjection(bson.M{"password": 0})).Decode(&docRetrieve)
-	} else {
-		err = collection.FindOne(context.TODO(), bson.M{"_id": oID}).Decode(&docRetrieve)
-	}
+		// Only the requested fields of the documents
+		selectedDocuments, err := models.SelectFields(documentsList, projection)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error selecting the fields of the documents.", err.Error())
+			return
+		}
 
-	// Check the error
-	if err != nil {
-		utils.HandleError(ctx, http.StatusNotFound, "cannot retrieve specific document.", err.Error())
-		return
+		// Send the response with all the documents
+		// The GIN package will automatically encode the response in JSON format
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":               "Retrieved documents successfully. Retrieved {" + fmt.Sprint(len(documentsList)) + "} Documents from Collection { " + collectionName + " }.",
+			"data":                  selectedDocuments,
+			"rows":                  len(documentsList),
+			"currentPage":           pageRequested,
+			"totalPages":            totalPages,
+			"totalNumbersDocuments": countDocumentsResult,
+		})
 	}
-
-	// Return the found document
-	// The GIN package will automatically encode the response in JSON format
-	ctx.JSON(http.StatusOK, gin.H{
-		"message": "Document retrieved successfully from Collection { " + collectionName + " }.",
-		"data":    docRetrieve,
-	})
 }
 
-// GET LAST X DOCUMENTS --------
+// GET A DOCUMENT BY ID --------
 // -----------------------------
-func GetLastXDocuments[T any](ctx *gin.Context) {
-
-	// Retrieve all the URL Parameters ------------
-	// --------------------------------------------
-	fmt.Println("Request Query:", ctx.Request.URL)
+func GetDocumentByID[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Console all the query parameters
-	queryGivenParams := ctx.Request.URL.Query()
-	fmt.Println("Query Parameters:", queryGivenParams)
+		// Error variable
+		var err error
 
-	// PAGING - SKIP
-	pageNumberString := ctx.Query("page")
-	fmt.Println("Page requested (string):", pageNumberString)
+		// Retrieve the document id from the parameters
+		documentID := ctx.Param("id")
+		if documentID == "" {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error finding document ID.", errors.New("error finding document ID").Error())
+			return
+		}
 
-	var pageRequested int64 = 1
-	if utils.CheckStringNotEmpty(pageNumberString) {
-		pageNumberLocal, err := strconv.ParseInt(pageNumberString, 10, 64)
-		if err != nil || pageNumberLocal <= 0 {
-			errorMsg := "Error transforming page number to int64 OR page number is lower/equal to ZERO."
-			utils.HandleError(ctx, http.StatusBadRequest, errorMsg, errorMsg)
+		// String id not object id
+		oID, err := primitive.ObjectIDFromHex(documentID)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
 			return
 		}
-		fmt.Println("Page number (INT PARSED):", pageNumberLocal)
-		pageRequested = pageNumberLocal
-	}
 
-	// LIMIT RESULTS - LIMIT
-	limitResultString := ctx.Query("limit")
-	fmt.Println("Limit Results (string):", limitResultString)
+		// Get the type to retrieve
+		var docRetrieve T
+		fmt.Printf("Models Type: %T\n", docRetrieve)
 
-	var limit int64 = 100
-	if utils.CheckStringNotEmpty(limitResultString) {
-		limitLocal, err := strconv.ParseInt(limitResultString, 10, 64)
-		if err != nil || limitLocal <= 0 {
-			errorMsg := "Error transforming limit to int64 OR limit is lower/equal to ZERO."
-			utils.HandleError(ctx, http.StatusBadRequest, errorMsg, errorMsg)
+		// Set the collection
+		collectionName, err := registry.CollectionOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
 			return
 		}
-		fmt.Println("Limit Results (INT PARSED):", limitLocal)
-		limit = limitLocal
-	}
 
-	// Set the query search options
-	skipNumberValues := (pageRequested - 1) * limit
+		// Fields of the response (fields=title,priceEurosMonthly), never the excluded fields (e.g. password)
+		// The version is also read for the ETag
+		projection, err := models.CompileProjection[T](ctx.Query(models.FIELDS_PARAM))
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid fields.", err.Error())
+			return
+		}
 
-	// Created Date From
-	createdDateFrom := ctx.Query("created_dtFrom")
-	fmt.Println("Created Date From:", createdDateFrom)
+		// Retrieve a specific document
+		// The soft deleted documents are not found (trash)
+		collection := dataStore.Collection(collectionName)
+		filter := utils.NotDeleted(bson.M{"_id": oID})
+		err = collection.FindOne(context.TODO(), filter, &store.FindOptions{Projection: projection.With(utils.VERSION_FIELD).Query}, &docRetrieve)
 
-	// Created Date To
-	createdDateTo := ctx.Query("created_dtTo")
-	fmt.Println("Created Date To:", createdDateTo)
+		// Check the error
+		if err != nil {
+			utils.HandleError(ctx, http.StatusNotFound, "cannot retrieve specific document.", err.Error())
+			return
+		}
 
-	// Retrieve all the collection documents
-	var rows *mongo.Cursor
-	var err error
+		// ETag of the document version
+		// Conditional GET: the client has the current version of the document
+		if versioned, ok := any(docRetrieve).(models.Versioned); ok {
+			setETag(ctx, versioned.DocumentVersion())
+			if ifNoneMatch := ctx.GetHeader(IF_NONE_MATCH_HEADER); ifNoneMatch != "" && utils.ETagMatches(ifNoneMatch, versioned.DocumentVersion()) {
+				ctx.Status(http.StatusNotModified)
+				return
+			}
+		}
 
-	// Get the type to retrieve
-	var docRetrieve T
-	fmt.Printf("Models Type: %T\n", docRetrieve)
+		// Only the requested fields of the document
+		selectedDocument, err := models.SelectDocumentFields(docRetrieve, projection)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error selecting the fields of the document.", err.Error())
+			return
+		}
 
-	// Set the collection
-	collectionName, err := modelToCollectionName(fmt.Sprintf("%T", docRetrieve))
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
+		// Return the found document
+		// The GIN package will automatically encode the response in JSON format
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Document retrieved successfully from Collection { " + collectionName + " }.",
+			"data":    selectedDocument,
+		})
 	}
+}
 
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(collectionName)
+// GET LAST X DOCUMENTS --------
+// -----------------------------
+func GetLastXDocuments[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Find the total number of documents in the requested collection
-	countDocumentsResult, err := collection.CountDocuments(context.TODO(), bson.M{})
+		// Retrieve all the URL Parameters ------------
+		// --------------------------------------------
+		fmt.Println("Request Query:", ctx.Request.URL)
 
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the documents in the collection.", err.Error())
-		return
-	}
+		// Console all the query parameters
+		queryGivenParams := ctx.Request.URL.Query()
+		fmt.Println("Query Parameters:", queryGivenParams)
 
-	// Check the requested page number if it is viable
-	if skipNumberValues > countDocumentsResult {
-		utils.HandleError(ctx, http.StatusInternalServerError, "The requested page is not existent. Page: "+pageNumberString, "The requested page is not existent.")
-		return
-	}
+		// PAGING - SKIP AND LIMIT RESULTS - LIMIT
+		pageRequested, limit, err := pageAndLimit(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), err.Error())
+			return
+		}
+		fmt.Println("Page requested:", pageRequested, "Limit Results:", limit)
+
+		// Set the query search options
+		skipNumberValues := (pageRequested - 1) * limit
+
+		// Created Date From, To (RFC 3339)
+		createdDateRange, err := createdDateRangeFilter(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid created date range.", err.Error())
+			return
+		}
+
+		// Filters of the query parameters (filter[field][operator]=value)
+		filterObject, err := documentsFilter[T](ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid filter.", err.Error())
+			return
+		}
 
-	// Search with the given filters
-	// If not given any filters, then the API returns all the users
-	// Construct the filter query and the options
-	filterObject := bson.M{}
-	opts := options.Find()
-	opts.SetSkip(skipNumberValues).SetLimit(limit)
+		// Created Date From, To (both apply if the createdDt is also filtered)
+		if createdDateRange != nil {
+			if createdConditions, found := filterObject["createdDt"]; found {
+				filterObject["$and"] = []bson.M{{"createdDt": createdConditions}, {"createdDt": createdDateRange}}
+				delete(filterObject, "createdDt")
+			} else {
+				filterObject["createdDt"] = createdDateRange
+			}
+		}
 
-	// Check all the fields --------
-	// -----------------------------
+		// Sorting of the query parameters (sort=-createdDt,title), most recent first by default
+		sortKeys, err := models.CompileSort[T](ctx.Query(models.SORT_PARAM), models.DEFAULT_SORT_MOST_RECENT)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid sort.", err.Error())
+			return
+		}
 
-	// Created Date From, To
-	if utils.CheckStringNotEmpty(createdDateFrom) && utils.CheckStringNotEmpty(createdDateTo) {
-		filterObject["createdDt"] = bson.M{
-			"$gte": createdDateFrom,
-			"$lte": createdDateTo,
+		// Fields of the response (fields=title,priceEurosMonthly), never the excluded fields (e.g. password)
+		projection, err := models.CompileProjection[T](ctx.Query(models.FIELDS_PARAM))
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid fields.", err.Error())
+			return
 		}
-	} else {
-		if utils.CheckStringNotEmpty(createdDateFrom) {
-			filterObject["createdDt"] = bson.M{"$gte": createdDateFrom}
-		} else if utils.CheckStringNotEmpty(createdDateTo) {
-			filterObject["createdDt"] = bson.M{"$lte": createdDateTo}
+
+		// Get the type to retrieve
+		var docRetrieve T
+		fmt.Printf("Models Type: %T\n", docRetrieve)
+
+		// Set the collection
+		collectionName, err := registry.CollectionOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
+			return
 		}
-	}
 
-	// Retrieve last X documents from the database
-	// Remove password from the documents if is is the "users" collection
-	if collectionName == db.DB_TABLE_USERS {
-		rows, err = collection.Find(context.TODO(), filterObject, opts.SetProjection(bson.M{"password": 0}))
-	} else {
-		rows, err = collection.Find(context.TODO(), filterObject, opts)
-	}
+		collection := dataStore.Collection(collectionName)
 
-	if err != nil {
-		utils.HandleError(ctx, http.StatusNotFound, "Error retrieving the last X documents.", err.Error())
-		return
-	}
+		// Search with the given filters
+		// If not given any filters, then the API returns all the users
+		// The sort keys are also read for the cursors of the pages
+		opts := &store.FindOptions{Skip: skipNumberValues, Limit: limit, Sort: sortKeys, Projection: projection.With(models.SortFields(sortKeys)...).Query}
 
-	// Return all documents data
-	documentsList := []T{}
-	if err = rows.All(context.TODO(), &documentsList); err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving documents list.", err.Error())
-		return
-	}
+		// Cursor (keyset) pagination: after / before instead of page
+		if isCursorRequest(ctx) {
+			respondCursorPage[T](ctx, collection, collectionName, filterObject, sortKeys, limit, projection)
+			return
+		}
 
-	// Decode all the received documents
-	for rows.Next(context.TODO()) {
+		// Find the total number of (filtered) documents in the requested collection
+		// The pages after the last one are empty
+		countDocumentsResult, err := collection.CountDocuments(context.TODO(), filterObject)
 
-		// Local Variable
-		var resDoc T
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the documents in the collection.", err.Error())
+			return
+		}
+
+		// Return all documents data
+		documentsList := []T{}
+		if err = collection.Find(context.TODO(), filterObject, opts, &documentsList); err != nil {
+			utils.HandleError(ctx, http.StatusNotFound, "Error retrieving the last X documents.", err.Error())
+			return
+		}
 
-		err = rows.Decode(&resDoc)
+		// Calculate the total number of pages remaining in the requested collection
+		totalPages := math.Floor(float64(countDocumentsResult) / float64(limit))
+		if math.Ceil(float64(totalPages*float64(limit))) < float64(countDocumentsResult) {
+			totalPages += 1
+		}
+		fmt.Println("Total Pages:", totalPages)
+
+		// Only the requested fields of the documents
+		selectedDocuments, err := models.SelectFields(documentsList, projection)
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error decoding documents list.", err.Error())
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error selecting the fields of the documents.", err.Error())
 			return
 		}
-		documentsList = append(documentsList, resDoc)
-	}
 
-	// Calculate the total number of pages remaining in the requested collection
-	totalPages := math.Floor(float64(countDocumentsResult) / float64(limit))
-	if math.Ceil(float64(totalPages*float64(limit))) < float64(countDocumentsResult) {
-		totalPages += 1
+		// Send the response with all the documents
+		// The GIN package will automatically encode the response in JSON format
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":               "Retrieved documents successfully. Retrieved {" + fmt.Sprint(len(documentsList)) + "} Documents from Collection { " + collectionName + " }.",
+			"data":                  selectedDocuments,
+			"rows":                  len(documentsList),
+			"currentPage":           pageRequested,
+			"totalPages":            totalPages,
+			"totalNumbersDocuments": countDocumentsResult,
+		})
 	}
-	fmt.Println("Total Pages:", totalPages)
-
-	// Send the response with all the documents
-	// The GIN package will automatically encode the response in JSON format
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":               "Retrieved documents successfully. Retrieved {" + fmt.Sprint(len(documentsList)) + "} Documents from Collection { " + collectionName + " }.",
-		"data":                  documentsList,
-		"rows":                  len(documentsList),
-		"currentPage":           pageRequested,
-		"totalPages":            totalPages,
-		"totalNumbersDocuments": countDocumentsResult,
-	})
 }
 
 // COUNT ALL DOCUMENTS -----
 // -------------------------
-func CountAllDocuments[T any](ctx *gin.Context) {
+func CountAllDocuments[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Get the type to retrieve
-	var docRetrieve T
-	fmt.Printf("Models Type: %T\n", docRetrieve)
+		// Get the type to retrieve
+		var docRetrieve T
+		fmt.Printf("Models Type: %T\n", docRetrieve)
 
-	// Set the collection
-	collectionName, err := modelToCollectionName(fmt.Sprintf("%T", docRetrieve))
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
-	}
+		// Set the collection
+		collectionName, err := registry.CollectionOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
+			return
+		}
 
-	// Count all documents in the provided collection
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(collectionName)
-	countDocumentsResult, err := collection.CountDocuments(context.TODO(), bson.M{})
+		// Filters of the query parameters (filter[field][operator]=value)
+		filterObject, err := documentsFilter[T](ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid filter.", err.Error())
+			return
+		}
 
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the documents in the collection.", err.Error())
-		return
-	}
+		// Count all (filtered) documents in the provided collection
+		collection := dataStore.Collection(collectionName)
+		countDocumentsResult, err := collection.CountDocuments(context.TODO(), filterObject)
+
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the documents in the collection.", err.Error())
+			return
+		}
 
-	// Send the response with the count of documents
-	// The GIN package will automatically encode the response in JSON format
-	ctx.JSON(http.StatusOK, gin.H{
-		"message": "Counted {" + fmt.Sprint(countDocumentsResult) + "} Documents in the Collection { " + collectionName + " }.",
-		"rows":    countDocumentsResult,
-	})
+		// Send the response with the count of documents
+		// The GIN package will automatically encode the response in JSON format
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Counted {" + fmt.Sprint(countDocumentsResult) + "} Documents in the Collection { " + collectionName + " }.",
+			"rows":    countDocumentsResult,
+		})
+	}
 }
 
 // DELETE DOCUMENT ---------
 // -------------------------
-func DeleteDocument[T any](ctx *gin.Context) {
+func DeleteDocument[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Retrieve the user id from the parameters
-	documentID := ctx.Param("id")
-	if documentID == "" {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error finding document ID.", errors.New("error finding document ID").Error())
-		return
-	}
+		// Retrieve the user id from the parameters
+		documentID := ctx.Param("id")
+		if documentID == "" {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error finding document ID.", errors.New("error finding document ID").Error())
+			return
+		}
 
-	// String id not object id
-	oID, err := primitive.ObjectIDFromHex(documentID)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
-		return
-	}
+		// String id not object id
+		oID, err := primitive.ObjectIDFromHex(documentID)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
+			return
+		}
 
-	// Get the type to retrieve
-	var docRetrieve T
-	fmt.Printf("Models Type: %T\n", docRetrieve)
+		// Get the type to retrieve
+		var docRetrieve T
+		fmt.Printf("Models Type: %T\n", docRetrieve)
 
-	// Set the collection
-	collectionName, err := modelToCollectionName(fmt.Sprintf("%T", docRetrieve))
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
-	}
+		// Set the collection
+		resource, err := registry.ResourceOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
+			return
+		}
+		collectionName := resource.Name
 
-	// Count all documents in the provided collection
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(collectionName)
+		// Only the own documents of the users without the permission to delete all the documents
+		filter, owned, err := ownedDocumentFilter(ctx, resource, bson.M{"_id": oID})
+		if err != nil {
+			handleTransactionError(ctx, err, "The user has no permission for this request.")
+			return
+		}
 
-	// Delete the specific document from the collection
-	// Set the delete statement
-	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": oID})
-	if err != nil || result.DeletedCount <= 0 {
-		utils.HandleError(ctx, http.StatusInternalServerError, "deletion of the document data failed", errors.New("deletion of the document data failed").Error())
-		return
-	}
+		// Soft (default) or hard deletion and the target of the reassigned references
+		options, err := deletionOptions(ctx)
+		if err != nil {
+			handleTransactionError(ctx, err, "Not valid deletion options.")
+			return
+		}
+
+		// Delete (soft by default) the specific document from the collection with the policies of its references in a transaction
+		var report *deleteReport
+		err = dataStore.WithTransaction(context.TODO(), func(txCtx context.Context) error {
+			if owned {
+				if err := documentExists(txCtx, dataStore.Collection(collectionName), filter); err != nil {
+					return err
+				}
+			}
+
+			var err error
+			report, err = deleteWithReferences(txCtx, dataStore, collectionName, filter, 1, options)
+			return err
+		})
+		if err != nil {
+			handleTransactionError(ctx, err, "deletion of the document data failed")
+			return
+		}
+		if report.deletedCount <= 0 {
+			utils.HandleError(ctx, http.StatusInternalServerError, "deletion of the document data failed", errors.New("deletion of the document data failed").Error())
+			return
+		}
 
-	// Return response
-	ctx.JSON(http.StatusOK, gin.H{
-		"message": "Document deleted successfully.",
-	})
+		// Return response
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":              "Document deleted successfully.",
+			"cascadesDeleted":      report.cascadedCounts,
+			"referencesReassigned": report.reassignedCount,
+		})
+	}
 }
 
 // DELETE MULTIPLE DOCUMENTS
 // -------------------------
-func DeleteMultipleDocuments[T any](ctx *gin.Context) {
+func DeleteMultipleDocuments[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Documents IDs Struct
-	type usersIds struct {
-		ListOfIds []string `json:"listOfIds"`
-	}
-
-	// Extract the list of documents IDs to delete from the request body
-	var documentsIdsListObject usersIds
-
-	decoderData := json.NewDecoder(ctx.Request.Body)
+		// Documents IDs Struct
+		type usersIds struct {
+			ListOfIds []string `json:"listOfIds"`
+		}
 
-	// Cast all integers or floats as Number
-	decoderData.UseNumber()
+		// Extract the list of documents IDs to delete from the request body
+		var documentsIdsListObject usersIds
 
-	// Decode the data
-	err := decoderData.Decode(&documentsIdsListObject)
-	fmt.Println("List of documents IDs:", documentsIdsListObject.ListOfIds)
+		decoderData := json.NewDecoder(ctx.Request.Body)
 
-	// Check the list of documents IDs
-	if len(documentsIdsListObject.ListOfIds) <= 0 || err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "No documents IDs found to delete.", errors.New("no documents IDs found to delete").Error())
-		return
-	}
+		// Cast all integers or floats as Number
+		decoderData.UseNumber()
 
-	// Get the type to retrieve
-	var docRetrieve T
-	fmt.Printf("Models Type: %T\n", docRetrieve)
+		// Decode the data
+		err := decoderData.Decode(&documentsIdsListObject)
+		fmt.Println("List of documents IDs:", documentsIdsListObject.ListOfIds)
 
-	// Set the collection
-	collectionName, err := modelToCollectionName(fmt.Sprintf("%T", docRetrieve))
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
-	}
+		// Check the list of documents IDs
+		if len(documentsIdsListObject.ListOfIds) <= 0 || err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "No documents IDs found to delete.", errors.New("no documents IDs found to delete").Error())
+			return
+		}
 
-	// Convert every id to Object ID
-	objectIDs := []primitive.ObjectID{}
-	for _, val := range documentsIdsListObject.ListOfIds {
+		// Get the type to retrieve
+		var docRetrieve T
+		fmt.Printf("Models Type: %T\n", docRetrieve)
 
-		// Convert the ID to oid
-		oID, err := primitive.ObjectIDFromHex(val)
+		// Set the collection
+		collectionName, err := registry.CollectionOf[T]()
 		if err != nil {
-			utils.HandleError(ctx, http.StatusNotFound, "cannot convert hex id to bson id", err.Error())
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
 			return
 		}
 
-		// Add it to the list of OIDs
-		objectIDs = append(objectIDs, oID)
-	}
+		// Convert every id to Object ID
+		objectIDs := []primitive.ObjectID{}
+		for _, val := range documentsIdsListObject.ListOfIds {
 
-	// Delete multiple users in the database
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(collectionName)
-	deleteMultipleUsersResult, err := collection.DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$in": objectIDs}})
+			// Convert the ID to oid
+			oID, err := primitive.ObjectIDFromHex(val)
+			if err != nil {
+				utils.HandleError(ctx, http.StatusNotFound, "cannot convert hex id to bson id", err.Error())
+				return
+			}
 
-	if err != nil || deleteMultipleUsersResult.DeletedCount < 0 {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error deleting multiple users.", err.Error())
-		return
-	}
-
-	// Send the response with the deleted users count
-	// The GIN package will automatically encode the response in JSON format
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":          "Deleted multiple documents successfully. Deleted {" + fmt.Sprint(deleteMultipleUsersResult.DeletedCount) + "} Documents for Collection { " + collectionName + " }.",
-		"documentsDeleted": deleteMultipleUsersResult.DeletedCount,
-	})
-}
-
-// DELETE ALL DOCUMENTS ----
-// -------------------------
-func DeleteAllDocuments[T any](ctx *gin.Context) {
-
-	// Delete all the users
-	// Get the type to retrieve
-	var docRetrieve T
-	fmt.Printf("Models Type: %T\n", docRetrieve)
+			// Add it to the list of OIDs
+			objectIDs = append(objectIDs, oID)
+		}
 
-	// Set the collection
-	collectionName, err := modelToCollectionName(fmt.Sprintf("%T", docRetrieve))
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
-	}
+		// Soft (default) or hard deletion and the target of the reassigned references
+		options, err := deletionOptions(ctx)
+		if err != nil {
+			handleTransactionError(ctx, err, "Not valid deletion options.")
+			return
+		}
 
-	// Delete all users in the database
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(collectionName)
-	deleteAllUsersResult, err := collection.DeleteMany(context.TODO(), bson.M{})
+		// Delete (soft by default) multiple documents in the database with the policies of their references in a transaction
+		var report *deleteReport
+		err = dataStore.WithTransaction(context.TODO(), func(txCtx context.Context) error {
+			var err error
+			report, err = deleteWithReferences(txCtx, dataStore, collectionName, bson.M{"_id": bson.M{"$in": objectIDs}}, 0, options)
+			return err
+		})
 
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
-	}
+		if err != nil {
+			handleTransactionError(ctx, err, "Error deleting multiple documents.")
+			return
+		}
 
-	// Check the deleteCount field
-	if deleteAllUsersResult.DeletedCount >= 0 {
 		// Send the response with the deleted users count
 		// The GIN package will automatically encode the response in JSON format
 		ctx.JSON(http.StatusOK, gin.H{
-			"message":          "Deleted all documents successfully. Deleted {" + fmt.Sprint(deleteAllUsersResult.DeletedCount) + "} Documents for Collection { " + collectionName + " }.",
-			"documentsDeleted": deleteAllUsersResult.DeletedCount,
+			"message":              "Deleted multiple documents successfully. Deleted {" + fmt.Sprint(report.deletedCount) + "} Documents for Collection { " + collectionName + " }.",
+			"documentsDeleted":     report.deletedCount,
+			"cascadesDeleted":      report.cascadedCounts,
+			"referencesReassigned": report.reassignedCount,
 		})
-
-	} else {
-		// Handle the error
-		utils.HandleError(ctx, http.StatusMethodNotAllowed, "Deletion is not allowed for this user.", errors.New("error happened in deleting all the documents").Error())
-		return
 	}
 }
 
-// UPDATE DOCUMENT ----------
-// --------------------------
-func UpdateDocument[T any](ctx *gin.Context) {
-
-	// Retrieve the user id from the parameters
-	documentID := ctx.Param("id")
-	if documentID == "" {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error finding document ID.", errors.New("error finding document ID").Error())
-		return
-	}
+// DELETE ALL DOCUMENTS ----
+// -------------------------
+func DeleteAllDocuments[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Retrieve and read the request body
-	requestBody := make(map[string]interface{})
+		// Delete all the users
+		// Get the type to retrieve
+		var docRetrieve T
+		fmt.Printf("Models Type: %T\n", docRetrieve)
 
-	decoderData := json.NewDecoder(ctx.Request.Body)
+		// Set the collection
+		collectionName, err := registry.CollectionOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
+			return
+		}
 
-	// Cast all integers or floats as Number
-	decoderData.UseNumber()
+		// Soft (default) or hard deletion and the target of the reassigned references
+		options, err := deletionOptions(ctx)
+		if err != nil {
+			handleTransactionError(ctx, err, "Not valid deletion options.")
+			return
+		}
 
-	// Decode the data
-	err := decoderData.Decode(&requestBody)
-	fmt.Println("Request body:", requestBody)
-	fmt.Printf("Type:%T\n", requestBody)
+		// Delete (soft by default) all documents in the database with the policies of their references in a transaction
+		var report *deleteReport
+		err = dataStore.WithTransaction(context.TODO(), func(txCtx context.Context) error {
+			var err error
+			report, err = deleteWithReferences(txCtx, dataStore, collectionName, bson.M{}, 0, options)
+			return err
+		})
 
-	// Check if error OR the request map is empty
-	if err != nil || len(requestBody) == 0 {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing and decoding document data.", err.Error())
-		return
-	}
+		if err != nil {
+			handleTransactionError(ctx, err, "Error deleting all the documents.")
+			return
+		}
 
-	// Update the specific document
-	// Convert the ID to oid
-	oID, err := primitive.ObjectIDFromHex(documentID)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id", err.Error())
-		return
+		// Check the deleteCount field
+		if report.deletedCount >= 0 {
+			// Send the response with the deleted users count
+			// The GIN package will automatically encode the response in JSON format
+			ctx.JSON(http.StatusOK, gin.H{
+				"message":              "Deleted all documents successfully. Deleted {" + fmt.Sprint(report.deletedCount) + "} Documents for Collection { " + collectionName + " }.",
+				"documentsDeleted":     report.deletedCount,
+				"cascadesDeleted":      report.cascadedCounts,
+				"referencesReassigned": report.reassignedCount,
+			})
+
+		} else {
+			// Handle the error
+			utils.HandleError(ctx, http.StatusMethodNotAllowed, "Deletion is not allowed for this user.", errors.New("error happened in deleting all the documents").Error())
+			return
+		}
 	}
+}
 
-	// Set the filter for _id and the last updated date
-	filter := bson.M{"_id": oID}
-	requestBody["lastUpdatedDt"] = time.Now().Format("2006-01-02 15:04:05")
-
-	// 'Cast' the request body to bson.M Map
-	update := bson.M{"$set": requestBody}
-
-	// Get the type to retrieve
-	var docRetrieve T
-	fmt.Printf("Models Type: %T\n", docRetrieve)
+// UPDATE DOCUMENT ----------
+// --------------------------
+func UpdateDocument[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Set the collection
-	collectionName, err := modelToCollectionName(fmt.Sprintf("%T", docRetrieve))
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
-		return
-	}
+		// Retrieve the user id from the parameters
+		documentID := ctx.Param("id")
+		if documentID == "" {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error finding document ID.", errors.New("error finding document ID").Error())
+			return
+		}
 
-	// Check if provided password in the request body
-	if requestBody["password"] != nil && utils.CheckStringNotEmpty(requestBody["password"].(string)) {
-		utils.HandleError(ctx, http.StatusBadRequest, "field 'password' in not allowed in the request body.", errors.New("not allowed editing of field 'password'").Error())
-		return
-	}
+		// Convert the ID to oid
+		oID, err := primitive.ObjectIDFromHex(documentID)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id", err.Error())
+			return
+		}
 
-	// Role - ONLY FOR "users" COLLECTION
-	if collectionName == db.DB_TABLE_USERS && requestBody["role"] != nil && utils.CheckStringNotEmpty(requestBody["role"].(string)) {
-		// Allowed roles for the users
-		if !utils.CheckAllowedRole(requestBody["role"].(string)) {
-			utils.HandleError(ctx, http.StatusBadRequest, "the provided role is not supported", errors.New("the provided role is not supported").Error())
+		// Set the collection
+		collectionName, err := registry.CollectionOf[T]()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
 			return
 		}
-	}
+		collection := dataStore.Collection(collectionName)
 
-	if len(update) > 0 {
+		// The document must exist (not deleted) before its version is checked
+		filter := utils.NotDeleted(bson.M{"_id": oID})
+		if err = documentExists(context.TODO(), collection, filter); err != nil {
+			handleTransactionError(ctx, err, "update of the user data failed")
+			return
+		}
 
-		// Execute the statement
-		collection := db.MongoClient.Database(db.DB_NAME).Collection(collectionName)
-		result, err := collection.UpdateOne(context.TODO(), filter, update)
+		// Version of the document that the client updates (If-Match)
+		version, err := requiredVersion(ctx)
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "update of the user data failed", err.Error())
+			handleTransactionError(ctx, err, "Not valid version of the document.")
 			return
 		}
 
-		// All successful
-		fmt.Println("Update successful: ", result)
-
-		// Return response
-		ctx.JSON(http.StatusOK, gin.H{
-			"message":          "Document updated successfully.",
-			"documentsUpdated": result.ModifiedCount,
-		})
+		// Update statement of the request body (JSON document, JSON Merge Patch or JSON Patch) and its preconditions
+		updateBody, preconditions, ok := requestUpdate[T](ctx)
+		if !ok {
+			return
+		}
 
-	} else {
-		utils.HandleError(ctx, http.StatusInternalServerError, "no available data to update", errors.New("no available data to update").Error())
-		return
+		// Set the last updated date
+		setFields, _ := updateBody["$set"].(bson.M)
+		if setFields == nil {
+			setFields = bson.M{}
+			updateBody["$set"] = setFields
+		}
+		setFields["lastUpdatedDt"] = utils.NowUTC()
+
+		// Increment the version
+		update := utils.IncrementVersion(updateBody)
+
+		// Get the type to retrieve
+		var docRetrieve T
+		fmt.Printf("Models Type: %T\n", docRetrieve)
+
+		if len(update) > 0 {
+
+			// Execute the statement
+			// Only the requested version of the document is updated
+			// and only if the preconditions of the patch hold
+			result, err := collection.UpdateOne(context.TODO(), withPreconditions(withVersion(filter, version), preconditions), update)
+			if err != nil {
+				utils.HandleError(ctx, http.StatusInternalServerError, "update of the user data failed", err.Error())
+				return
+			}
+
+			// The document was updated (or deleted) by another request after the client retrieved it, or a precondition failed
+			if result.MatchedCount == 0 {
+				if len(preconditions) > 0 {
+					err = preconditionConflict(context.TODO(), collection, filter, version)
+				} else {
+					err = versionConflict(context.TODO(), collection, filter)
+				}
+				handleTransactionError(ctx, err, "update of the user data failed")
+				return
+			}
+			setETag(ctx, version+1)
+
+			// All successful
+			fmt.Println("Update successful: ", result)
+
+			// Return response
+			ctx.JSON(http.StatusOK, gin.H{
+				"message":          "Document updated successfully.",
+				"documentsUpdated": result.ModifiedCount,
+			})
+
+		} else {
+			utils.HandleError(ctx, http.StatusInternalServerError, "no available data to update", errors.New("no available data to update").Error())
+			return
+		}
 	}
 }
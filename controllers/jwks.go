@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// This method returns the public JWT keys as a JSON Web Key Set
+// Other services use these keys in order to verify our tokens offline
+func GetJWKS(ctx *gin.Context) {
+
+	// Retrieve the public keys
+	keySet, err := utils.GetJWKS()
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving the JWT keys.", err.Error())
+		return
+	}
+
+	// The keys can be cached by the verifiers
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, keySet)
+}
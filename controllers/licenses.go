@@ -7,553 +7,501 @@ import (
 	"fmt"
 	"go-essentials/go-mongodb-rest-api/db"
 	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
 	"go-essentials/go-mongodb-rest-api/utils"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/mgo.v2/bson"
 )
 
 // This method creates a new license
-func CreateLicense(ctx *gin.Context) {
+func CreateLicense(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// License Data
-	var licenseData models.License
-	err := ctx.ShouldBindJSON(&licenseData)
+		// License Data
+		var licenseData models.License
+		err := ctx.ShouldBindJSON(&licenseData)
 
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing license data.", err.Error())
-		return
-	}
-
-	// Print the received license data
-	fmt.Println("Received license data:", licenseData)
-
-	// CONTROLS AND VARIOUS LICENSE CHECKS ------------------
-	// ------------------------------------------------------
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing license data.", err.Error())
+			return
+		}
 
-	// Expiration Date and user holder id
-	if !utils.CheckStringNotEmpty(licenseData.Expiration_dt) || !utils.CheckStringNotEmpty(licenseData.UserHolderId) {
-		utils.HandleError(ctx, http.StatusBadRequest, "Please provide all the necessary data in order to create a new license.", errors.New("missing license data").Error())
-		return
-	}
+		// Print the received license data
+		fmt.Println("Received license data:", licenseData)
 
-	// User full name and category id
-	if !utils.CheckStringNotEmpty(licenseData.UserFullName) || !utils.CheckStringNotEmpty(licenseData.CategoryId) {
-		utils.HandleError(ctx, http.StatusBadRequest, "Please provide all the necessary data in order to create a new license.", errors.New("missing license data").Error())
-		return
-	}
+		// CONTROLS AND VARIOUS LICENSE CHECKS ------------------
+		// ------------------------------------------------------
 
-	// Category type and category title
-	if !utils.CheckStringNotEmpty(licenseData.CategoryType) || !utils.CheckStringNotEmpty(licenseData.CategoryTitle) {
-		utils.HandleError(ctx, http.StatusBadRequest, "Please provide all the necessary data in order to create a new license.", errors.New("missing license data").Error())
-		return
-	}
+		// Expiration Date and user holder id
+		if licenseData.Expiration_dt.IsZero() || !utils.CheckStringNotEmpty(licenseData.UserHolderId) {
+			utils.HandleError(ctx, http.StatusBadRequest, "Please provide all the necessary data in order to create a new license.", errors.New("missing license data").Error())
+			return
+		}
 
-	numCategoryTypeTransform, err := utils.TransformStringToInteger64(licenseData.CategoryType)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing category type to number.", errors.New("error integer transformation").Error())
-		return
-	}
-	fmt.Println("Category Type: ", numCategoryTypeTransform)
+		// User full name and category id
+		if !utils.CheckStringNotEmpty(licenseData.UserFullName) || !utils.CheckStringNotEmpty(licenseData.CategoryId) {
+			utils.HandleError(ctx, http.StatusBadRequest, "Please provide all the necessary data in order to create a new license.", errors.New("missing license data").Error())
+			return
+		}
 
-	// Time span type
-	if licenseData.TimeSpanType <= 0 {
-		utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
-		return
-	}
+		// Category type and category title
+		if licenseData.CategoryType < 0 || !utils.CheckStringNotEmpty(licenseData.CategoryTitle) {
+			utils.HandleError(ctx, http.StatusBadRequest, "Please provide all the necessary data in order to create a new license.", errors.New("missing license data").Error())
+			return
+		}
+		fmt.Println("Category Type: ", licenseData.CategoryType)
 
-	// Check if time span type IN [1, 3, 6, 12]
-	acceptTimeSpanType := false
-	timeSpanAcceptedValue := []int64{1, 3, 6, 12}
-	for _, value := range timeSpanAcceptedValue {
-		if value == licenseData.TimeSpanType {
-			acceptTimeSpanType = true
-			break
+		// Time span type
+		if licenseData.TimeSpanType <= 0 {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
+			return
 		}
-	}
 
-	if !acceptTimeSpanType {
-		utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
-		return
-	}
+		// Check if time span type IN [1, 3, 6, 12]
+		acceptTimeSpanType := false
+		timeSpanAcceptedValue := []int64{1, 3, 6, 12}
+		for _, value := range timeSpanAcceptedValue {
+			if value == licenseData.TimeSpanType {
+				acceptTimeSpanType = true
+				break
+			}
+		}
 
-	// Check if the user has already active licenses
-	// String id not object id
-	oID, err := primitive.ObjectIDFromHex(licenseData.UserHolderId)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
-		return
-	}
+		if !acceptTimeSpanType {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
+			return
+		}
 
-	// Get the type to retrieve
-	var userRetrieve models.User
+		// String id not object id
+		oUserHolderID, err := primitive.ObjectIDFromHex(licenseData.UserHolderId)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
+			return
+		}
 
-	// Check user existence in the database and find the user by id
-	// Retrieve a specific document
-	collectionUsers := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_USERS)
+		// Transform the category id to object id
+		oCategoryID, err := utils.StringIDtoObjectID(licenseData.CategoryId)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error transforming the category ID to object ID.", err.Error())
+			return
+		}
 
-	// Remove password from the documents
-	removePasswordOption := bson.M{"password": 0}
-	err = collectionUsers.FindOne(context.TODO(), bson.M{"_id": oID}, options.FindOne().SetProjection(removePasswordOption)).Decode(&userRetrieve)
+		// Created dt and last update dt (native dates in UTC)
+		NOW_TIME := utils.NowUTC()
+		licenseData.CreatedDt = NOW_TIME
+		licenseData.LastUpdatedDt = NOW_TIME
+		licenseData.IsActive = true
+		licenseData.IsExpired = false
+
+		// Cast the license data for insertion
+		type LicenseDataForInsertion struct {
+			ID                primitive.ObjectID  `bson:"_id,omitempty" json:"_id"`
+			LicenseKey        string              `bson:"licenseKey,omitempty" json:"licenseKey"`
+			Begin_dt          time.Time           `bson:"begin_dt,omitempty" json:"begin_dt"`
+			Expiration_dt     time.Time           `bson:"expiration_dt,omitempty" json:"expiration_dt"`
+			UserHolderId      primitive.ObjectID  `bson:"userHolderId,omitempty" json:"userHolderId"`
+			UserFullName      string              `bson:"userFullName,omitempty" json:"userFullName"`
+			CategoryId        primitive.ObjectID  `bson:"categoryId,omitempty" json:"categoryId"`
+			CategoryType      models.CategoryType `bson:"categoryType" json:"categoryType"`
+			CategoryTitle     string              `bson:"categoryTitle,omitempty" json:"categoryTitle"`
+			ActivatedOnDevice string              `bson:"activatedOnDevice,omitempty" json:"activatedOnDevice"`
+			TimeSpanType      int64               `bson:"timeSpanType,omitempty" json:"timeSpanType"`
+			Comments          string              `bson:"comments,omitempty" json:"comments"`
+			IsActive          models.Flag         `bson:"isActive" json:"isActive"`
+			IsExpired         models.Flag         `bson:"isExpired" json:"isExpired"`
+			CreatedDt         time.Time           `bson:"createdDt,omitempty" json:"createdDt"`
+			LastUpdatedDt     time.Time           `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt"`
+			Version           int64               `bson:"version" json:"version"`
+			DeletedAt         *time.Time          `bson:"deletedAt" json:"-"`
+		}
 
-	// Check the error
-	if err != nil {
-		utils.HandleError(ctx, http.StatusNotFound, "cannot retrieve specific user.", err.Error())
-		return
-	}
+		// CREATE THE LICENSE IN A TRANSACTION ------------------
+		// ------------------------------------------------------
 
-	// Check the user exists in the database
-	if !utils.CheckStringNotEmpty(userRetrieve.ID) || !utils.CheckStringNotEmpty(userRetrieve.Email) {
-		utils.HandleError(ctx, http.StatusNotFound, "cannot retrieve specific user.", errors.New("cannot retrieve specific user").Error())
-		return
-	}
+		// The user check, the unique values and the insert are committed together
+		// Concurrent creations for the same user are rejected by the unique partial index (one active license per user)
+		var insertedID primitive.ObjectID
+		err = dataStore.WithTransaction(context.TODO(), func(txCtx context.Context) error {
 
-	// Count all the licenses for this user
-	collectionLicenses := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_LICENCES)
-	countDocumentsResult, err := collectionLicenses.CountDocuments(context.TODO(), bson.M{"userHolderId": oID})
+			// Check user existence in the database and find the user by id (without password)
+			var userRetrieve models.User
+			collectionUsers := dataStore.Collection(db.DB_TABLE_USERS)
+			err := collectionUsers.FindOne(txCtx, utils.NotDeleted(bson.M{"_id": oUserHolderID}), &store.FindOptions{Projection: bson.M{"password": 0}}, &userRetrieve)
+			if err != nil {
+				return newRequestError(http.StatusNotFound, "cannot retrieve specific user.", err)
+			}
+			if !utils.CheckStringNotEmpty(userRetrieve.ID) || !utils.CheckStringNotEmpty(userRetrieve.Email) {
+				return newRequestError(http.StatusNotFound, "cannot retrieve specific user.", errors.New("cannot retrieve specific user"))
+			}
 
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the documents in the collection.", err.Error())
-		return
-	}
-	fmt.Println("Number of Licenses for this user:", countDocumentsResult)
+			// Count the active licenses for this user
+			collectionLicenses := dataStore.Collection(db.DB_TABLE_LICENCES)
+			countDocumentsResult, err := collectionLicenses.CountDocuments(txCtx, utils.NotDeleted(bson.M{"userHolderId": oUserHolderID, "isActive": true}))
+			if err != nil {
+				return newRequestError(http.StatusInternalServerError, "Error counting the documents in the collection.", err)
+			}
+			fmt.Println("Number of active Licenses for this user:", countDocumentsResult)
 
-	// Check the count
-	if countDocumentsResult > 0 {
-		utils.HandleError(ctx, http.StatusInternalServerError, "User already has { "+fmt.Sprintf("%d", countDocumentsResult)+" } licenses.", errors.New("user has licenses").Error())
-		return
-	}
+			if countDocumentsResult > 0 {
+				return newRequestError(http.StatusConflict, "User already has { "+fmt.Sprintf("%d", countDocumentsResult)+" } active licenses.", errors.New("user has licenses"))
+			}
 
-	// LICENSE KEY AND ACTIVATED ON DEVICE ------------------
-	// ------------------------------------------------------
+			// Produce a new UNIQUE license key and a new UNIQUE secure random HASH for the activatedOnDevice tag
+			licenseData.LicenseKey, err = generateUniqueLicenseValue(txCtx, collectionLicenses, "licenseKey")
+			if err != nil {
+				return newRequestError(http.StatusInternalServerError, "Error generating the license key.", err)
+			}
+			licenseData.ActivatedOnDevice, err = generateUniqueLicenseValue(txCtx, collectionLicenses, "activatedOnDevice")
+			if err != nil {
+				return newRequestError(http.StatusInternalServerError, "Error generating the activatedOnDevice value.", err)
+			}
 
-	// Struct Type for data hashing
-	type DataForHashing struct {
-		Timestamp   string `json:"timestamp"`
-		RandomBytes string `json:"random_bytes"`
-	}
+			// Set the license data for insertion
+			licenseDataInsert := LicenseDataForInsertion{
+				LicenseKey:        licenseData.LicenseKey,
+				Begin_dt:          NOW_TIME,
+				Expiration_dt:     licenseData.Expiration_dt,
+				UserHolderId:      oUserHolderID,
+				UserFullName:      licenseData.UserFullName,
+				CategoryId:        oCategoryID,
+				CategoryType:      licenseData.CategoryType,
+				CategoryTitle:     licenseData.CategoryTitle,
+				ActivatedOnDevice: licenseData.ActivatedOnDevice,
+				TimeSpanType:      licenseData.TimeSpanType,
+				Comments:          licenseData.Comments,
+				IsActive:          licenseData.IsActive,
+				IsExpired:         licenseData.IsExpired,
+				CreatedDt:         licenseData.CreatedDt,
+				LastUpdatedDt:     licenseData.LastUpdatedDt,
+				Version:           utils.INITIAL_VERSION,
+			}
 
-	// Produce a new UNIQUE license key for this license
-	licenseKeyGenerated := ""
-	for {
+			// Print the data to insert
+			fmt.Println("License data to insert: ", licenseDataInsert)
 
-		// Generate secure random bytes (32 bytes - 256 bit)
-		secureRandomHexString, err := utils.GenerateSecureRandomBytes(32)
+			// Insert the license into the database
+			insertedID, err = collectionLicenses.InsertOne(txCtx, licenseDataInsert)
+			return err
+		})
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error generating the license key.", err.Error())
+			handleTransactionError(ctx, err, "Error storing the new license in the database.")
 			return
 		}
 
-		// Create a new license key
-		contructedDataForHashing := DataForHashing{
-			Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
-			RandomBytes: secureRandomHexString,
-		}
+		// Print the insert result
+		fmt.Println("Insert Result:", insertedID)
 
-		// Marshal the above struct into JSON string
-		jsonData, err := json.Marshal(contructedDataForHashing)
-		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error generating the license key.", err.Error())
-			return
-		}
-
-		licenseKeyGeneratedLocal, err := utils.GenerateSHA512Key(string(jsonData))
-		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error generating the license key.", err.Error())
-			return
+		// CREATE THE QR CODE WITH THE LICENSE KEY --------------
+		// ------------------------------------------------------
+		qrCodeDataInput := utils.QRCodeProduct{
+			Content: licenseData.LicenseKey,
+			Size:    256,
 		}
-		licenseKeyGenerated = licenseKeyGeneratedLocal
-
-		// Check if the license key is UNIQUE inside the LICENSES collection
-		countDocumentsWithSameLicenseKeyResult, err := collectionLicenses.CountDocuments(context.TODO(), bson.M{"licenseKey": licenseKeyGenerated})
 
+		qrCodeBase64Data, err := qrCodeDataInput.GenerateQRCode()
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error counting licenses with same license key in the collection.", err.Error())
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error creating the QR code of the license key.", err.Error())
 			return
 		}
-		fmt.Println("Number of Licenses with the same license key:", countDocumentsWithSameLicenseKeyResult)
 
-		// Check the count of licenses with the same license key
-		if countDocumentsResult <= 0 {
-			break
-		}
+		// Success response
+		ctx.JSON(http.StatusCreated, gin.H{
+			"message": "License inserted successfully in the collection { " + db.DB_TABLE_LICENCES + " }.",
+			"data": []map[string]any{
+				{
+					"_id":        insertedID,
+					"licenseKey": licenseData.LicenseKey,
+					"QRCode":     qrCodeBase64Data,
+				},
+			},
+		})
 	}
+}
 
-	// Produce a new Secure Random HASH for the activatedOnDevice tag of the specific license
-	activateOnDeviceGenerated := ""
-	for {
+// This method renews an existent license
+func RenewLicense(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-		// Generate secure random bytes (32 bytes - 256 bit)
-		secureRandomHexString, err := utils.GenerateSecureRandomBytes(32)
-		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error generating the activatedOnDevice value.", err.Error())
+		// Retrieve the license id from the parameters
+		licenseID := ctx.Param("id")
+		if !utils.CheckStringNotEmpty(licenseID) {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error finding license ID.", errors.New("error finding license ID").Error())
 			return
 		}
 
-		// Create a new hash key
-		contructedDataForHashing := DataForHashing{
-			Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
-			RandomBytes: secureRandomHexString,
-		}
-
-		// Marshal the above struct into JSON string
-		jsonData, err := json.Marshal(contructedDataForHashing)
+		// String id not object id
+		licenseIDObject, err := primitive.ObjectIDFromHex(licenseID)
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error generating the activatedOnDevice value.", err.Error())
+			utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
 			return
 		}
 
-		activatedOnDeviceGeneratedLocal, err := utils.GenerateSHA512Key(string(jsonData))
+		// Version of the license that the client updates (If-Match)
+		version, err := requiredVersion(ctx)
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error generating the activatedOnDevice value.", err.Error())
+			handleTransactionError(ctx, err, "Not valid version of the license.")
 			return
 		}
-		activateOnDeviceGenerated = activatedOnDeviceGeneratedLocal
 
-		// Check if the activatedOnDevice value is UNIQUE inside the LICENSES collection
-		countDocumentsWithSameActivatedOnDeviceResult, err := collectionLicenses.CountDocuments(context.TODO(), bson.M{"activatedOnDevice": activateOnDeviceGenerated})
+		// Console the body for renewing the license
+		// License Data
+		var licenseData models.License
+		err = ctx.ShouldBindJSON(&licenseData)
 
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error counting licenses with same activatedOnDevice value in the collection.", err.Error())
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing license data.", err.Error())
 			return
 		}
-		fmt.Println("Number of Licenses with the same activatedOnDevice value:", countDocumentsWithSameActivatedOnDeviceResult)
-
-		// Check the count of licenses with the same activatedOnDevice value
-		if countDocumentsWithSameActivatedOnDeviceResult <= 0 {
-			break
-		}
-	}
-
-	// Set the appropriate values on the license data
-	licenseData.ActivatedOnDevice = activateOnDeviceGenerated
-	licenseData.LicenseKey = licenseKeyGenerated
-
-	// Created dt and last update dt (YYYY-MM-DD HH:MM:SS)
-	NOW_TIME := time.Now().Format("2006-01-02 15:04:05")
-	licenseData.CreatedDt = NOW_TIME
-	licenseData.LastUpdatedDt = NOW_TIME
-	licenseData.IsActive = "1"
-	licenseData.IsExpired = "0"
-
-	// Transform the category id to object id
-	oCategoryID, err := utils.StringIDtoObjectID(licenseData.CategoryId)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error transforming the category ID to object ID.", err.Error())
-		return
-	}
-
-	// Transform the user holder id to object id
-	oUserHolderID, err := utils.StringIDtoObjectID(licenseData.UserHolderId)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error transforming the user holder ID to object ID.", err.Error())
-		return
-	}
-
-	// Cast the license data for insertion
-	type LicenseDataForInsertion struct {
-		ID                primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
-		LicenseKey        string             `bson:"licenseKey,omitempty" json:"licenseKey"`
-		Begin_dt          string             `bson:"begin_dt,omitempty" json:"begin_dt"`
-		Expiration_dt     string             `bson:"expiration_dt,omitempty" json:"expiration_dt"`
-		UserHolderId      primitive.ObjectID `bson:"userHolderId,omitempty" json:"userHolderId"`
-		UserFullName      string             `bson:"userFullName,omitempty" json:"userFullName"`
-		CategoryId        primitive.ObjectID `bson:"categoryId,omitempty" json:"categoryId"`
-		CategoryType      string             `bson:"categoryType,omitempty" json:"categoryType"`
-		CategoryTitle     string             `bson:"categoryTitle,omitempty" json:"categoryTitle"`
-		ActivatedOnDevice string             `bson:"activatedOnDevice,omitempty" json:"activatedOnDevice"`
-		TimeSpanType      int64              `bson:"timeSpanType,omitempty" json:"timeSpanType"`
-		Comments          string             `bson:"comments,omitempty" json:"comments"`
-		IsActive          string             `bson:"isActive,omitempty" json:"isActive"`
-		IsExpired         string             `bson:"isExpired,omitempty" json:"isExpired"`
-		CreatedDt         string             `bson:"createdDt,omitempty" json:"createdDt"`
-		LastUpdatedDt     string             `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt"`
-	}
-
-	// Set the license data for insertion
-	licenseDataInsert := LicenseDataForInsertion{
-		LicenseKey:        licenseData.LicenseKey,
-		Begin_dt:          NOW_TIME,
-		Expiration_dt:     licenseData.Expiration_dt,
-		UserHolderId:      oUserHolderID,
-		UserFullName:      licenseData.UserFullName,
-		CategoryId:        oCategoryID,
-		CategoryType:      licenseData.CategoryType,
-		CategoryTitle:     licenseData.CategoryTitle,
-		ActivatedOnDevice: licenseData.ActivatedOnDevice,
-		TimeSpanType:      licenseData.TimeSpanType,
-		Comments:          licenseData.Comments,
-		IsActive:          licenseData.IsActive,
-		IsExpired:         licenseData.IsExpired,
-		CreatedDt:         licenseData.CreatedDt,
-		LastUpdatedDt:     licenseData.LastUpdatedDt,
-	}
-
-	// Print the data to insert
-	fmt.Println("License data to insert: ", licenseDataInsert)
-
-	// Insert the license into the database
-	result, err := collectionLicenses.InsertOne(context.TODO(), licenseDataInsert)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error storing the new license in the database.", err.Error())
-		return
-	}
-
-	// Print the insert result
-	fmt.Println("Insert Result:", result)
 
-	// CREATE THE QR CODE WITH THE LICENSE KEY --------------
-	// ------------------------------------------------------
-	qrCodeDataInput := utils.QRCodeProduct{
-		Content: licenseData.LicenseKey,
-		Size:    256,
-	}
-
-	qrCodeBase64Data, err := qrCodeDataInput.GenerateQRCode()
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error creating the QR code of the license key.", err.Error())
-		return
-	}
-
-	// Success response
-	ctx.JSON(http.StatusCreated, gin.H{
-		"message": "License inserted successfully in the collection { " + db.DB_TABLE_LICENCES + " }.",
-		"data": []map[string]any{
-			{
-				"_id":        result.InsertedID,
-				"licenseKey": licenseKeyGenerated,
-				"QRCode":     qrCodeBase64Data,
-			},
-		},
-	})
-}
-
-// This method renews an existent license
-func RenewLicense(ctx *gin.Context) {
-
-	// Retrieve the license id from the parameters
-	licenseID := ctx.Param("id")
-	if !utils.CheckStringNotEmpty(licenseID) {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error finding license ID.", errors.New("error finding license ID").Error())
-		return
-	}
-
-	// String id not object id
-	licenseIDObject, err := primitive.ObjectIDFromHex(licenseID)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
-		return
-	}
-
-	// Console the body for renewing the license
-	// License Data
-	var licenseData models.License
-	err = ctx.ShouldBindJSON(&licenseData)
-
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing license data.", err.Error())
-		return
-	}
+		// Print the received license data
+		fmt.Println("Received license data:", licenseData)
 
-	// Print the received license data
-	fmt.Println("Received license data:", licenseData)
+		// CONTROLS AND VARIOUS LICENSE CHECKS ------------------
+		// ------------------------------------------------------
 
-	// CONTROLS AND VARIOUS LICENSE CHECKS ------------------
-	// ------------------------------------------------------
+		// Expiration Date and time span type
+		if licenseData.Expiration_dt.IsZero() {
+			utils.HandleError(ctx, http.StatusBadRequest, "Please provide all the necessary data in order to renew the license.", errors.New("missing license data").Error())
+			return
+		}
 
-	// Expiration Date and time span type
-	if !utils.CheckStringNotEmpty(licenseData.Expiration_dt) {
-		utils.HandleError(ctx, http.StatusBadRequest, "Please provide all the necessary data in order to renew the license.", errors.New("missing license data").Error())
-		return
-	}
+		// Time span type
+		if licenseData.TimeSpanType <= 0 {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
+			return
+		}
 
-	// Time span type
-	if licenseData.TimeSpanType <= 0 {
-		utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
-		return
-	}
+		// Check if time span type IN [1, 3, 6, 12]
+		acceptTimeSpanType := false
+		timeSpanAcceptedValue := []int64{1, 3, 6, 12}
+		for _, value := range timeSpanAcceptedValue {
+			if value == licenseData.TimeSpanType {
+				acceptTimeSpanType = true
+				break
+			}
+		}
 
-	// Check if time span type IN [1, 3, 6, 12]
-	acceptTimeSpanType := false
-	timeSpanAcceptedValue := []int64{1, 3, 6, 12}
-	for _, value := range timeSpanAcceptedValue {
-		if value == licenseData.TimeSpanType {
-			acceptTimeSpanType = true
-			break
+		if !acceptTimeSpanType {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
+			return
 		}
-	}
 
-	if !acceptTimeSpanType {
-		utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
-		return
-	}
+		// Set the filter for _id and the last updated date
+		filter := utils.NotDeleted(bson.M{"_id": licenseIDObject})
+
+		// Set the proper fields for update
+		licenseData.IsActive = true
+		licenseData.IsExpired = false
+		licenseData.LastUpdatedDt = utils.NowUTC()
+
+		// Cast the license data for renewal
+		// Only the renewal fields, so the zero values of the rest (e.g. category type 0) are not written
+		type LicenseDataForRenewal struct {
+			Expiration_dt time.Time   `bson:"expiration_dt,omitempty" json:"expiration_dt"`
+			TimeSpanType  int64       `bson:"timeSpanType,omitempty" json:"timeSpanType"`
+			Comments      string      `bson:"comments,omitempty" json:"comments"`
+			IsActive      models.Flag `bson:"isActive" json:"isActive"`
+			IsExpired     models.Flag `bson:"isExpired" json:"isExpired"`
+			LastUpdatedDt time.Time   `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt"`
+		}
 
-	// Set the filter for _id and the last updated date
-	filter := bson.M{"_id": licenseIDObject}
+		// Set the license data for renewal
+		licenseDataRenewal := LicenseDataForRenewal{
+			Expiration_dt: licenseData.Expiration_dt,
+			TimeSpanType:  licenseData.TimeSpanType,
+			Comments:      licenseData.Comments,
+			IsActive:      licenseData.IsActive,
+			IsExpired:     licenseData.IsExpired,
+			LastUpdatedDt: licenseData.LastUpdatedDt,
+		}
 
-	// Set the proper fields for update
-	licenseData.IsActive = "1"
-	licenseData.IsExpired = "0"
-	licenseData.LastUpdatedDt = time.Now().Format("2006-01-02 15:04:05")
+		// 'Cast' the request body to bson.M Map and increment the version
+		update := utils.IncrementVersion(bson.M{"$set": licenseDataRenewal})
+
+		// Find the license and update the data
+		if len(update) > 0 {
+
+			// Execute the statement in a transaction and return the UPDATED license document after the update
+			// The reactivated license is rejected if the user has another active license
+			collection := dataStore.Collection(db.DB_TABLE_LICENCES)
+			result := models.License{}
+			// Only the requested version of the license is renewed
+			err := dataStore.WithTransaction(context.TODO(), func(txCtx context.Context) error {
+				err := collection.FindOneAndUpdate(txCtx, withVersion(filter, version), update, &result)
+				if errors.Is(err, store.ErrNotFound) {
+					return versionConflict(txCtx, collection, filter)
+				}
+				return err
+			})
+			if err != nil {
+				handleTransactionError(ctx, err, "renew of the selected license failed")
+				return
+			}
+			setETag(ctx, result.Version)
 
-	// 'Cast' the request body to bson.M Map
-	update := bson.M{"$set": licenseData}
+			// All successful
+			fmt.Println("License Renew successful: ", result)
 
-	// Find the license and update the data
-	if len(update) > 0 {
+			// Return response
+			ctx.JSON(http.StatusOK, gin.H{
+				"message": "License renewed successfully.",
+				"data":    []models.License{result},
+			})
 
-		// Execute the statement and return the UPDATED license document after the update
-		collection := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_LICENCES)
-		result := models.License{}
-		err := collection.FindOneAndUpdate(context.TODO(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&result)
-		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "renew of the selected license failed", err.Error())
+		} else {
+			utils.HandleError(ctx, http.StatusInternalServerError, "no available data to renew license", errors.New("no available data to renew").Error())
 			return
 		}
-
-		// All successful
-		fmt.Println("License Renew successful: ", result)
-
-		// Return response
-		ctx.JSON(http.StatusOK, gin.H{
-			"message": "License renewed successfully.",
-			"data":    []models.License{result},
-		})
-
-	} else {
-		utils.HandleError(ctx, http.StatusInternalServerError, "no available data to renew license", errors.New("no available data to renew").Error())
-		return
 	}
 }
 
 // This method upgrades an existent license
-func UpgradeLicense(ctx *gin.Context) {
+func UpgradeLicense(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Retrieve the license id from the parameters
-	licenseID := ctx.Param("id")
-	if !utils.CheckStringNotEmpty(licenseID) {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error finding license ID.", errors.New("error finding license ID").Error())
-		return
-	}
+		// Retrieve the license id from the parameters
+		licenseID := ctx.Param("id")
+		if !utils.CheckStringNotEmpty(licenseID) {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error finding license ID.", errors.New("error finding license ID").Error())
+			return
+		}
 
-	// String id not object id
-	licenseIDObject, err := primitive.ObjectIDFromHex(licenseID)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
-		return
-	}
+		// String id not object id
+		licenseIDObject, err := primitive.ObjectIDFromHex(licenseID)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
+			return
+		}
 
-	// Console the body for renewing the license
-	// License Data
-	var licenseData models.License
-	err = ctx.ShouldBindJSON(&licenseData)
+		// Version of the license that the client updates (If-Match)
+		version, err := requiredVersion(ctx)
+		if err != nil {
+			handleTransactionError(ctx, err, "Not valid version of the license.")
+			return
+		}
 
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing license data.", err.Error())
-		return
-	}
+		// Console the body for renewing the license
+		// License Data
+		var licenseData models.License
+		err = ctx.ShouldBindJSON(&licenseData)
 
-	// Print the received license data
-	fmt.Println("Received license data:", licenseData)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing license data.", err.Error())
+			return
+		}
 
-	// CONTROLS AND VARIOUS LICENSE CHECKS ------------------
-	// ------------------------------------------------------
+		// Print the received license data
+		fmt.Println("Received license data:", licenseData)
 
-	// Expiration Date and begin_dt
-	if !utils.CheckStringNotEmpty(licenseData.Expiration_dt) || !utils.CheckStringNotEmpty(licenseData.Begin_dt) {
-		utils.HandleError(ctx, http.StatusBadRequest, "Please provide the expiration date and the begin date in order to renew the license.", errors.New("missing license data").Error())
-		return
-	}
+		// CONTROLS AND VARIOUS LICENSE CHECKS ------------------
+		// ------------------------------------------------------
 
-	// categoryId, categoryType and categoryTitle
-	if !utils.CheckStringNotEmpty(licenseData.CategoryId) || !utils.CheckStringNotEmpty(licenseData.CategoryType) || !utils.CheckStringNotEmpty(licenseData.CategoryTitle) {
-		utils.HandleError(ctx, http.StatusBadRequest, "Please provide the category data (id, type, title) in order to renew the license.", errors.New("missing license data").Error())
-		return
-	}
+		// Expiration Date and begin_dt
+		if licenseData.Expiration_dt.IsZero() || licenseData.Begin_dt.IsZero() {
+			utils.HandleError(ctx, http.StatusBadRequest, "Please provide the expiration date and the begin date in order to renew the license.", errors.New("missing license data").Error())
+			return
+		}
 
-	// Time span type
-	if licenseData.TimeSpanType <= 0 {
-		utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
-		return
-	}
+		// categoryId, categoryType and categoryTitle
+		if !utils.CheckStringNotEmpty(licenseData.CategoryId) || licenseData.CategoryType < 0 || !utils.CheckStringNotEmpty(licenseData.CategoryTitle) {
+			utils.HandleError(ctx, http.StatusBadRequest, "Please provide the category data (id, type, title) in order to renew the license.", errors.New("missing license data").Error())
+			return
+		}
 
-	// Check if time span type IN [1, 3, 6, 12]
-	acceptTimeSpanType := false
-	timeSpanAcceptedValue := []int64{1, 3, 6, 12}
-	for _, value := range timeSpanAcceptedValue {
-		if value == licenseData.TimeSpanType {
-			acceptTimeSpanType = true
-			break
+		// Time span type
+		if licenseData.TimeSpanType <= 0 {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
+			return
 		}
-	}
 
-	if !acceptTimeSpanType {
-		utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
-		return
-	}
+		// Check if time span type IN [1, 3, 6, 12]
+		acceptTimeSpanType := false
+		timeSpanAcceptedValue := []int64{1, 3, 6, 12}
+		for _, value := range timeSpanAcceptedValue {
+			if value == licenseData.TimeSpanType {
+				acceptTimeSpanType = true
+				break
+			}
+		}
 
-	// Set the filter for _id and the last updated date
-	filter := bson.M{"_id": licenseIDObject}
+		if !acceptTimeSpanType {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid TimeSpanType field value", errors.New("invalid value").Error())
+			return
+		}
 
-	// Set the proper fields for upgrade
-	licenseData.IsActive = "1"
-	licenseData.IsExpired = "0"
-	licenseData.LastUpdatedDt = time.Now().Format("2006-01-02 15:04:05")
+		// Set the filter for _id and the last updated date
+		filter := utils.NotDeleted(bson.M{"_id": licenseIDObject})
 
-	// Transform the category id to object id
-	oCategoryID, err := utils.StringIDtoObjectID(licenseData.CategoryId)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error transforming the category ID to object ID.", err.Error())
-		return
-	}
+		// Set the proper fields for upgrade
+		licenseData.IsActive = true
+		licenseData.IsExpired = false
+		licenseData.LastUpdatedDt = utils.NowUTC()
 
-	// Cast the license data for upgrade
-	type LicenseDataForUpgrade struct {
-		Begin_dt      string             `bson:"begin_dt,omitempty" json:"begin_dt"`
-		Expiration_dt string             `bson:"expiration_dt,omitempty" json:"expiration_dt"`
-		CategoryId    primitive.ObjectID `bson:"categoryId,omitempty" json:"categoryId"`
-		CategoryType  string             `bson:"categoryType,omitempty" json:"categoryType"`
-		CategoryTitle string             `bson:"categoryTitle,omitempty" json:"categoryTitle"`
-		TimeSpanType  int64              `bson:"timeSpanType,omitempty" json:"timeSpanType"`
-		IsActive      string             `bson:"isActive,omitempty" json:"isActive"`
-		IsExpired     string             `bson:"isExpired,omitempty" json:"isExpired"`
-		LastUpdatedDt string             `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt"`
-	}
+		// Transform the category id to object id
+		oCategoryID, err := utils.StringIDtoObjectID(licenseData.CategoryId)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error transforming the category ID to object ID.", err.Error())
+			return
+		}
 
-	// Set the license data for upgrade
-	licenseDataUpgrade := LicenseDataForUpgrade{
-		Begin_dt:      licenseData.Begin_dt,
-		Expiration_dt: licenseData.Expiration_dt,
-		CategoryId:    oCategoryID,
-		CategoryType:  licenseData.CategoryType,
-		CategoryTitle: licenseData.CategoryTitle,
-		TimeSpanType:  licenseData.TimeSpanType,
-		IsActive:      licenseData.IsActive,
-		IsExpired:     licenseData.IsExpired,
-		LastUpdatedDt: licenseData.LastUpdatedDt,
-	}
+		// Cast the license data for upgrade
+		type LicenseDataForUpgrade struct {
+			Begin_dt      time.Time           `bson:"begin_dt,omitempty" json:"begin_dt"`
+			Expiration_dt time.Time           `bson:"expiration_dt,omitempty" json:"expiration_dt"`
+			CategoryId    primitive.ObjectID  `bson:"categoryId,omitempty" json:"categoryId"`
+			CategoryType  models.CategoryType `bson:"categoryType" json:"categoryType"`
+			CategoryTitle string              `bson:"categoryTitle,omitempty" json:"categoryTitle"`
+			TimeSpanType  int64               `bson:"timeSpanType,omitempty" json:"timeSpanType"`
+			IsActive      models.Flag         `bson:"isActive" json:"isActive"`
+			IsExpired     models.Flag         `bson:"isExpired" json:"isExpired"`
+			LastUpdatedDt time.Time           `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt"`
+		}
 
-	// Print the data to insert
-	fmt.Println("License data to upgrade: ", licenseDataUpgrade)
+		// Find the category and upgrade the license in a transaction
+		// The category type and title are copied from the category, as read in the same snapshot
+		result := models.License{}
+		err = dataStore.WithTransaction(context.TODO(), func(txCtx context.Context) error {
+			var category models.LicenseCategory
+			err := dataStore.Collection(db.DB_TABLE_LICENSES_CATEGORIES).FindOne(txCtx, utils.NotDeleted(bson.M{"_id": oCategoryID}), nil, &category)
+			if err != nil {
+				return newRequestError(http.StatusNotFound, "cannot retrieve the license category of the upgrade.", err)
+			}
 
-	// 'Cast' the request body to bson.M Map
-	upgradeStatement := bson.M{"$set": licenseDataUpgrade}
+			// Set the license data for upgrade
+			licenseDataUpgrade := LicenseDataForUpgrade{
+				Begin_dt:      licenseData.Begin_dt,
+				Expiration_dt: licenseData.Expiration_dt,
+				CategoryId:    oCategoryID,
+				CategoryType:  category.CategoryType,
+				CategoryTitle: category.Title,
+				TimeSpanType:  licenseData.TimeSpanType,
+				IsActive:      licenseData.IsActive,
+				IsExpired:     licenseData.IsExpired,
+				LastUpdatedDt: licenseData.LastUpdatedDt,
+			}
 
-	// Find the license and upgrade the data
-	if len(upgradeStatement) > 0 {
+			// Print the data to upgrade
+			fmt.Println("License data to upgrade: ", licenseDataUpgrade)
 
-		// Execute the statement and return the UPGRADED license document after the upgrade
-		collection := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_LICENCES)
-		result := models.License{}
-		err := collection.FindOneAndUpdate(context.TODO(), filter, upgradeStatement, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&result)
+			// Execute the statement and return the UPGRADED license document after the upgrade
+			// Only the requested version of the license is upgraded
+			upgradeStatement := utils.IncrementVersion(bson.M{"$set": licenseDataUpgrade})
+			collectionLicenses := dataStore.Collection(db.DB_TABLE_LICENCES)
+			err = collectionLicenses.FindOneAndUpdate(txCtx, withVersion(filter, version), upgradeStatement, &result)
+			if errors.Is(err, store.ErrNotFound) {
+				return versionConflict(txCtx, collectionLicenses, filter)
+			}
+			return err
+		})
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "upgrade of the selected license failed", err.Error())
+			handleTransactionError(ctx, err, "upgrade of the selected license failed")
 			return
 		}
+		setETag(ctx, result.Version)
 
 		// All successful
 		fmt.Println("License Upgrade successful: ", result)
@@ -563,158 +511,155 @@ func UpgradeLicense(ctx *gin.Context) {
 			"message": "License upgraded successfully.",
 			"data":    []models.License{result},
 		})
-
-	} else {
-		utils.HandleError(ctx, http.StatusInternalServerError, "no available data to upgrade license", errors.New("no available data to renew").Error())
-		return
 	}
 }
 
 // This method counts licenses per category
 // (Optional) FROM - TO Begin_dt parameters
-func CountLicensesPerCategory(ctx *gin.Context) {
-
-	// Console the Query
-	fmt.Println("Request Query:", ctx.Request.URL)
-
-	// Created Date From
-	createdDateFrom := ctx.Query("created_dtFrom")
-	fmt.Println("Created Date From:", createdDateFrom)
-
-	// Created Date To
-	createdDateTo := ctx.Query("created_dtTo")
-	fmt.Println("Created Date To:", createdDateTo)
-
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_LICENCES)
-
-	// Search with the given filters
-	// If not given any filters, then the API returns the counting for all licenses
-	// Construct the filter query
-	matchStage := bson.M{}
+func CountLicensesPerCategory(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Check all the fields --------
-	// -----------------------------
+		// Console the Query
+		fmt.Println("Request Query:", ctx.Request.URL)
 
-	// Created Date From, To
-	if utils.CheckStringNotEmpty(createdDateFrom) && utils.CheckStringNotEmpty(createdDateTo) {
-		matchStage = bson.M{"$match": bson.M{"createdDt": bson.M{"$gte": createdDateFrom, "$lte": createdDateTo}}}
-
-	} else {
-		if utils.CheckStringNotEmpty(createdDateFrom) {
-			matchStage = bson.M{"$match": bson.M{"createdDt": bson.M{"$gte": createdDateFrom}}}
-		} else if utils.CheckStringNotEmpty(createdDateTo) {
-			matchStage = bson.M{"$match": bson.M{"createdDt": bson.M{"$lte": createdDateFrom}}}
+		// Created Date From, To (RFC 3339)
+		createdDateRange, err := createdDateRangeFilter(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid created date range.", err.Error())
+			return
 		}
-	}
 
-	// Set the group stage
-	groupStage := bson.M{
-		"$group": bson.M{
-			"_id":           "$categoryId",
-			"countLicenses": bson.M{"$sum": 1},
-		},
-	}
-
-	// Set the group aggregation pipe stage
-	fmt.Println("Match Stage:", matchStage)
-	fmt.Println("Group Stage:", groupStage)
+		collection := dataStore.Collection(db.DB_TABLE_LICENCES)
 
-	// Pipeline Filter
-	pipelineFilter := []bson.M{}
-	if len(matchStage) > 0 {
-		pipelineFilter = append(pipelineFilter, matchStage, groupStage)
-	} else {
-		pipelineFilter = append(pipelineFilter, groupStage)
-	}
+		// Search with the given filters
+		// If not given any filters, then the API returns the counting for all licenses (not deleted)
+		// Construct the filter query
+		filterObject := utils.NotDeleted(bson.M{})
 
-	fmt.Println("Pipeline Filter:", pipelineFilter)
+		// Check all the fields --------
+		// -----------------------------
 
-	// Aggregate and filter
-	rows, err := collection.Aggregate(context.TODO(), pipelineFilter)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "error happened in licenses counting per category", err.Error())
-		return
-	}
-
-	// Return all counting data
-	licensesCountingResult := []bson.M{}
-	if err = rows.All(context.TODO(), &licensesCountingResult); err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error counting licenses per category.", err.Error())
-		return
-	}
-
-	// All successful
-	fmt.Println("License counting per category successful: ", licensesCountingResult)
+		// Created Date From, To
+		if createdDateRange != nil {
+			filterObject["createdDt"] = createdDateRange
+		}
+		fmt.Println("Filter:", filterObject)
 
-	// Retrieve all the license categories
-	if len(licensesCountingResult) > 0 {
-		arrayWithOIDs := []primitive.ObjectID{}
-		for _, val := range licensesCountingResult {
-			if val["_id"] != nil && val["_id"] != "" {
+		// Count the licenses per category and retrieve their license categories in the same transaction,
+		// so the counting and the categories come from the same snapshot
+		groupCounts := []store.GroupCount{}
+		licenseCategories := []models.LicenseCategory{}
+		err = dataStore.WithTransaction(context.TODO(), func(txCtx context.Context) error {
+			var err error
+			groupCounts, err = collection.CountByField(txCtx, filterObject, "categoryId")
+			if err != nil {
+				return newRequestError(http.StatusInternalServerError, "error happened in licenses counting per category", err)
+			}
 
-				// Append the oID in the arrayWithOIDs array
-				arrayWithOIDs = append(arrayWithOIDs, val["_id"].(primitive.ObjectID))
+			arrayWithOIDs := []primitive.ObjectID{}
+			for _, groupCount := range groupCounts {
+				if oID, isObjectID := groupCount.Value.(primitive.ObjectID); isObjectID {
+					arrayWithOIDs = append(arrayWithOIDs, oID)
+				}
 			}
-		}
+			fmt.Println("Array with OIDs: ", arrayWithOIDs)
 
-		fmt.Println("Array with OIDs: ", arrayWithOIDs)
+			if len(arrayWithOIDs) == 0 {
+				return nil
+			}
 
-		filterQuery := bson.M{"_id": bson.M{"$in": arrayWithOIDs}}
-		rowsLicenseCategories, err := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_LICENSES_CATEGORIES).Find(context.TODO(), filterQuery)
+			// Receive all the categories data and place them in an array
+			filterQuery := utils.NotDeleted(bson.M{"_id": bson.M{"$in": arrayWithOIDs}})
+			err = dataStore.Collection(db.DB_TABLE_LICENSES_CATEGORIES).Find(txCtx, filterQuery, nil, &licenseCategories)
+			if err != nil {
+				return newRequestError(http.StatusInternalServerError, "Error retrieving license categories.", err)
+			}
+			return nil
+		})
 		if err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving license categories.", err.Error())
+			handleTransactionError(ctx, err, "error happened in licenses counting per category")
 			return
 		}
 
-		// Receive all the categories data and place them in an array
-		licenseCategories := []models.LicenseCategory{}
-		if err = rowsLicenseCategories.All(context.TODO(), &licenseCategories); err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving license categories.", err.Error())
-			return
+		// Return all counting data
+		licensesCountingResult := []bson.M{}
+		for _, groupCount := range groupCounts {
+			licensesCountingResult = append(licensesCountingResult, bson.M{"_id": groupCount.Value, "countLicenses": groupCount.Count})
 		}
 
-		// Decode all the received license categories
-		for rowsLicenseCategories.Next(context.TODO()) {
-
-			// Local Variable
-			var resLicenseCat models.LicenseCategory
-
-			err = rowsLicenseCategories.Decode(&resLicenseCat)
-			if err != nil {
-				utils.HandleError(ctx, http.StatusInternalServerError, "Error decoding license categories.", err.Error())
-				return
-			}
-			licenseCategories = append(licenseCategories, resLicenseCat)
-		}
+		// All successful
+		fmt.Println("License counting per category successful: ", licensesCountingResult)
 
 		// For every license category found, place the info in the main result
 		for _, licenseCategory := range licenseCategories {
 			for _, valRes := range licensesCountingResult {
-				if valRes["_id"].(primitive.ObjectID).Hex() != "" {
-					if valRes["_id"].(primitive.ObjectID).Hex() == licenseCategory.ID {
-						valRes["title"] = licenseCategory.Title
-						valRes["categoryType"] = licenseCategory.CategoryType
-						break
-					}
+				if oID, isObjectID := valRes["_id"].(primitive.ObjectID); isObjectID && oID.Hex() == licenseCategory.ID {
+					valRes["title"] = licenseCategory.Title
+					valRes["categoryType"] = licenseCategory.CategoryType
+					break
 				}
 			}
 		}
-	}
 
-	// Find the total counting of licenses
-	var totalLicensesCount int32 = 0
-	if len(licensesCountingResult) > 0 {
-		for _, value := range licensesCountingResult {
-			if value["countLicenses"] != nil {
-				totalLicensesCount += value["countLicenses"].(int32)
+		// Find the total counting of licenses
+		var totalLicensesCount int64 = 0
+		if len(licensesCountingResult) > 0 {
+			for _, value := range licensesCountingResult {
+				if value["countLicenses"] != nil {
+					totalLicensesCount += value["countLicenses"].(int64)
+				}
 			}
 		}
+
+		// Return response
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":       "License counting per category successful.",
+			"data":          licensesCountingResult,
+			"licensesCount": totalLicensesCount,
+		})
 	}
+}
+
+// Struct Type for data hashing (license key and activatedOnDevice values)
+type DataForHashing struct {
+	Timestamp   string `json:"timestamp"`
+	RandomBytes string `json:"random_bytes"`
+}
+
+// Private
+// This method produces a new secure random SHA-512 value that is UNIQUE for the field inside the LICENSES collection
+func generateUniqueLicenseValue(ctx context.Context, collectionLicenses store.Collection, field string) (string, error) {
+	for {
+
+		// Generate secure random bytes (32 bytes - 256 bit)
+		secureRandomHexString, err := utils.GenerateSecureRandomBytes(32)
+		if err != nil {
+			return "", err
+		}
+
+		// Marshal the data for hashing into JSON string
+		jsonData, err := json.Marshal(DataForHashing{
+			Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
+			RandomBytes: secureRandomHexString,
+		})
+		if err != nil {
+			return "", err
+		}
 
-	// Return response
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":       "License counting per category successful.",
-		"data":          licensesCountingResult,
-		"licensesCount": totalLicensesCount,
-	})
+		generatedValue, err := utils.GenerateSHA512Key(string(jsonData))
+		if err != nil {
+			return "", err
+		}
+
+		// Check if the value is UNIQUE inside the LICENSES collection
+		countDocumentsWithSameValue, err := collectionLicenses.CountDocuments(ctx, bson.M{field: generatedValue})
+		if err != nil {
+			return "", err
+		}
+		fmt.Println("Number of Licenses with the same "+field+":", countDocumentsWithSameValue)
+
+		if countDocumentsWithSameValue <= 0 {
+			return generatedValue, nil
+		}
+	}
 }
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Cookie with the pending OIDC login (state, nonce and PKCE verifier)
+const OIDC_LOGIN_COOKIE = "oidc_login"
+const OIDC_LOGIN_MAX_AGE = 10 * time.Minute
+
+// Audience of the pending OIDC login cookie, never the audience of the access tokens
+const OIDC_LOGIN_AUDIENCE = "oidc-login"
+
+// Signed claims of the pending OIDC login cookie (utils.JWT_TYPE_OIDC_LOGIN tokens)
+type oidcLoginClaims struct {
+	jwt.StandardClaims
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"codeVerifier"`
+}
+
+// This method validates the claims of the pending login: the expiration (required) and the audience
+func (claims oidcLoginClaims) Valid() error {
+	if claims.ExpiresAt == 0 {
+		return errors.New("missing login expiration")
+	}
+	if err := claims.StandardClaims.Valid(); err != nil {
+		return err
+	}
+	if !claims.VerifyAudience(OIDC_LOGIN_AUDIENCE, true) {
+		return errors.New("not valid login audience")
+	}
+	return nil
+}
+
+// This method starts the OpenID Connect login (authorization code + PKCE)
+// and redirects the user to the provider
+func OIDCLogin(ctx *gin.Context) {
+
+	// Find the requested provider
+	provider, found := utils.OIDCProviders[ctx.Param("provider")]
+	if !found {
+		utils.HandleError(ctx, http.StatusNotFound, "Not supported login provider.", errors.New("not supported login provider").Error())
+		return
+	}
+
+	// State, nonce and PKCE pair
+	state, err := utils.GenerateSecureRandomBytes(16)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "Error starting the login.", err.Error())
+		return
+	}
+	nonce, err := utils.GenerateSecureRandomBytes(16)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "Error starting the login.", err.Error())
+		return
+	}
+	codeVerifier, codeChallenge, err := utils.NewPKCE()
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "Error starting the login.", err.Error())
+		return
+	}
+
+	// Provider authorization URL
+	authorizationURL, err := provider.AuthCodeURL(ctx.Request.Context(), state, nonce, codeChallenge)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadGateway, "Error contacting the login provider.", err.Error())
+		return
+	}
+
+	// Keep the pending login in a signed cookie, so any server instance can complete it
+	loginCookie, err := utils.SignClaims(oidcLoginClaims{
+		StandardClaims: jwt.StandardClaims{Audience: OIDC_LOGIN_AUDIENCE, ExpiresAt: time.Now().Add(OIDC_LOGIN_MAX_AGE).Unix()},
+		Provider:       provider.Name,
+		State:          state,
+		Nonce:          nonce,
+		CodeVerifier:   codeVerifier,
+	}, utils.JWT_TYPE_OIDC_LOGIN)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "Error starting the login.", err.Error())
+		return
+	}
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(OIDC_LOGIN_COOKIE, loginCookie, int(OIDC_LOGIN_MAX_AGE.Seconds()), "/", "", ctx.Request.TLS != nil, true)
+
+	// Redirect to the provider
+	ctx.Redirect(http.StatusFound, authorizationURL)
+}
+
+// This method completes the OpenID Connect login, links or creates the user and issues our JWT
+func OIDCCallback(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// Find the requested provider
+		provider, found := utils.OIDCProviders[ctx.Param("provider")]
+		if !found {
+			utils.HandleError(ctx, http.StatusNotFound, "Not supported login provider.", errors.New("not supported login provider").Error())
+			return
+		}
+
+		// Error returned by the provider
+		if utils.CheckStringNotEmpty(ctx.Query("error")) {
+			utils.HandleError(ctx, http.StatusUnauthorized, "Login was rejected by the provider.", ctx.Query("error")+" "+ctx.Query("error_description"))
+			return
+		}
+
+		// Retrieve and verify the pending login
+		loginCookie, err := ctx.Cookie(OIDC_LOGIN_COOKIE)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Missing or expired login session.", errors.New("missing login session").Error())
+			return
+		}
+		ctx.SetCookie(OIDC_LOGIN_COOKIE, "", -1, "/", "", ctx.Request.TLS != nil, true)
+
+		loginClaims := oidcLoginClaims{}
+		err = utils.ParseSignedClaims(loginCookie, &loginClaims, utils.JWT_TYPE_OIDC_LOGIN)
+		if err != nil || loginClaims.Provider != provider.Name {
+			utils.HandleError(ctx, http.StatusBadRequest, "Missing or expired login session.", errors.New("not valid login session").Error())
+			return
+		}
+
+		// The state protects against CSRF
+		if !utils.CheckStringNotEmpty(ctx.Query("state")) || ctx.Query("state") != loginClaims.State {
+			utils.HandleError(ctx, http.StatusBadRequest, "Not valid login state.", errors.New("state mismatch").Error())
+			return
+		}
+
+		// Exchange the code and verify the ID token
+		rawIDToken, err := provider.Exchange(ctx.Request.Context(), ctx.Query("code"), loginClaims.CodeVerifier)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusUnauthorized, "Error exchanging the authorization code.", err.Error())
+			return
+		}
+
+		identity, err := provider.VerifyIDToken(ctx.Request.Context(), rawIDToken, loginClaims.Nonce)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusUnauthorized, "Not valid identity token.", err.Error())
+			return
+		}
+
+		// Only verified emails can be linked to our users
+		if !utils.CheckStringNotEmpty(identity.Email) || !identity.EmailVerified {
+			utils.HandleError(ctx, http.StatusUnauthorized, "The email of the external account is not verified.", errors.New("not verified email").Error())
+			return
+		}
+
+		// Find, link or create the user
+		user, err := findOrCreateOIDCUser(dataStore, provider.Name, identity)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error linking the external account.", err.Error())
+			return
+		}
+
+		// Create the JWT token for the user
+		token, err := utils.GenerateToken(user.Email, user.ID, utils.AUTH_METHOD_OIDC+":"+provider.Name)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error logging in the user.", err.Error())
+			return
+		}
+
+		// Success response
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Login successfull.",
+			"token":   token,
+		})
+	}
+}
+
+// Private
+// This method returns the user of the external identity
+// 1. User already linked with the provider subject
+// 2. User with the same (verified) email, that gets linked now
+// 3. New normal user, created the way the register API does
+func findOrCreateOIDCUser(dataStore store.Store, providerName string, identity *utils.OIDCIdentity) (models.User, error) {
+
+	collection := dataStore.Collection(db.DB_TABLE_USERS)
+	removePasswordOption := &store.FindOptions{Projection: bson.M{"password": 0}}
+
+	// 1. Already linked identity
+	var user models.User
+	linkedFilter := utils.NotDeleted(bson.M{"externalIdentities": bson.M{"$elemMatch": bson.M{"provider": providerName, "subject": identity.Subject}}})
+	err := collection.FindOne(context.TODO(), linkedFilter, removePasswordOption, &user)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return user, err
+	}
+
+	// New link of this identity
+	externalIdentity := models.ExternalIdentity{
+		Provider: providerName,
+		Subject:  identity.Subject,
+		LinkedDt: utils.NowUTC(),
+	}
+
+	// 2. Existent user with the same email
+	err = collection.FindOne(context.TODO(), utils.NotDeleted(bson.M{"email": identity.Email}), removePasswordOption, &user)
+	if err == nil {
+		oID, err := utils.StringIDtoObjectID(user.ID)
+		if err != nil {
+			return user, err
+		}
+
+		update := utils.IncrementVersion(bson.M{
+			"$push": bson.M{"externalIdentities": externalIdentity},
+			"$set":  bson.M{"lastUpdatedDt": externalIdentity.LinkedDt},
+		})
+		_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": oID}, update)
+		fmt.Println("Linked external identity", providerName, "to user", user.ID)
+		return user, err
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return user, err
+	}
+
+	// 3. New user with a random password (login only through the provider until a password is set)
+	randomPassword, err := utils.GenerateSecureRandomBytes(32)
+	if err != nil {
+		return user, err
+	}
+
+	user = models.User{
+		FirstName:          identity.GivenName,
+		LastName:           identity.FamilyName,
+		Email:              identity.Email,
+		Password:           randomPassword,
+		ExternalIdentities: []models.ExternalIdentity{externalIdentity},
+	}
+
+	// The names are required by the schema
+	if !utils.CheckStringNotEmpty(user.FirstName) {
+		user.FirstName = identity.Name
+	}
+	if !utils.CheckStringNotEmpty(user.FirstName) {
+		user.FirstName = strings.Split(identity.Email, "@")[0]
+	}
+	if !utils.CheckStringNotEmpty(user.LastName) {
+		user.LastName = "-"
+	}
+
+	insertedID, err := insertNormalUser(dataStore, user)
+	if err != nil {
+		return user, err
+	}
+
+	user.ID = insertedID.(primitive.ObjectID).Hex()
+	return user, nil
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Header with the supported content types of the updates (PATCH)
+const ACCEPT_PATCH_HEADER = "Accept-Patch"
+
+// Supported content types of the updates
+var PATCH_CONTENT_TYPES = []string{utils.CONTENT_TYPE_JSON, utils.CONTENT_TYPE_MERGE_PATCH, utils.CONTENT_TYPE_JSON_PATCH}
+
+// Private
+// This method returns the update statement and the preconditions of the request body, according to its content type:
+// a JSON document (shallow update of the given fields), a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902)
+// Output: false if the response is already sent (400 not valid body, 422 not valid fields or not supported patch)
+func requestUpdate[T any](ctx *gin.Context) (bson.M, []bson.M, bool) {
+	ctx.Header(ACCEPT_PATCH_HEADER, strings.Join(PATCH_CONTENT_TYPES, ", "))
+
+	// Retrieve and read the request body
+	var requestBody interface{}
+	decoderData := json.NewDecoder(ctx.Request.Body)
+
+	// Cast all integers or floats as Number
+	decoderData.UseNumber()
+
+	// Decode the data
+	err := decoderData.Decode(&requestBody)
+	fmt.Println("Request body:", requestBody)
+	fmt.Printf("Type:%T\n", requestBody)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing and decoding document data.", err.Error())
+		return nil, nil, false
+	}
+
+	var patch utils.DocumentPatch
+	switch ctx.ContentType() {
+	case utils.CONTENT_TYPE_JSON_PATCH:
+		operations, ok := requestBody.([]interface{})
+		if !ok {
+			err = errors.New("the JSON Patch is not a list of operations")
+		} else {
+			patch, err = utils.CompileJSONPatch(operations)
+		}
+
+	case utils.CONTENT_TYPE_MERGE_PATCH:
+		mergePatch, ok := requestBody.(map[string]interface{})
+		if !ok || len(mergePatch) == 0 {
+			err = errors.New("the JSON Merge Patch is not an object with the changed fields")
+		} else {
+			patch, err = utils.CompileMergePatch(mergePatch)
+		}
+
+	default:
+		document, ok := requestBody.(map[string]interface{})
+		if !ok || len(document) == 0 {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing and decoding document data.", "empty document data")
+			return nil, nil, false
+		}
+		update, ok := documentUpdate[T](ctx, document)
+		return update, nil, ok
+	}
+
+	if errors.Is(err, utils.ErrUnsupportedPatch) {
+		utils.HandleError(ctx, http.StatusUnprocessableEntity, "Not supported patch of the document.", err.Error())
+		return nil, nil, false
+	}
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "Not valid patch of the document.", err.Error())
+		return nil, nil, false
+	}
+	return patchUpdate[T](ctx, patch)
+}
+
+// Private
+// This method returns the update statement of a JSON document ($set of the given top level fields)
+// Output: false if the response is already sent
+func documentUpdate[T any](ctx *gin.Context, requestBody map[string]interface{}) (bson.M, bool) {
+	update, err := documentUpdateData[T](requestBody)
+	if err != nil {
+		handleDocumentDataError(ctx, err)
+		return nil, false
+	}
+	return update, true
+}
+
+// Private
+// This method validates the patch against the model and returns its update statement and its preconditions
+// The values of the top level fields are validated with their types and rules, the other changes by their fields
+// Output: false if the response is already sent
+func patchUpdate[T any](ctx *gin.Context, patch utils.DocumentPatch) (bson.M, []bson.M, bool) {
+	if len(patch.ChangedPaths()) == 0 {
+		utils.HandleError(ctx, http.StatusBadRequest, "Not valid patch of the document.", "the patch changes no field")
+		return nil, nil, false
+	}
+
+	// Values of the top level fields and the other changed paths
+	values := map[string]interface{}{}
+	patched := models.PatchedFields{Removed: []string{}, Nested: []string{}}
+	for path, value := range patch.Set {
+		if strings.Contains(path, ".") {
+			patched.Nested = append(patched.Nested, path)
+		} else {
+			values[path] = value
+		}
+	}
+	for _, path := range patch.Unset {
+		if strings.Contains(path, ".") {
+			patched.Nested = append(patched.Nested, path)
+		} else {
+			patched.Removed = append(patched.Removed, path)
+		}
+	}
+	for path := range patch.Push {
+		patched.Items = append(patched.Items, path)
+	}
+	for path := range patch.Tests {
+		patched.Tested = append(patched.Tested, path)
+	}
+	sort.Strings(patched.Nested)
+	sort.Strings(patched.Items)
+	sort.Strings(patched.Tested)
+
+	// Every not valid field of the values and of the other changes
+	fieldErrors := models.ValidationErrors{}
+	for _, err := range []error{models.ValidateDocument[T](values, true), models.ValidatePatchedFields[T](patched)} {
+		var validationErrors models.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			fieldErrors = append(fieldErrors, validationErrors...)
+		} else if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), err.Error())
+			return nil, nil, false
+		}
+	}
+	if len(fieldErrors) > 0 {
+		utils.HandleValidationError(ctx, "Not valid document data.", fieldErrors.Error(), fieldErrors)
+		return nil, nil, false
+	}
+
+	// Dates, flags and category types of the values and of the tested values
+	err := convertDocumentValues[T](values, "Invalid date in the document data.", "Invalid value in the document data.")
+	if err == nil {
+		err = convertDocumentValues[T](patch.Tests, "Invalid date in the test operations.", "Invalid value in the test operations.")
+	}
+	if err != nil {
+		handleDocumentDataError(ctx, err)
+		return nil, nil, false
+	}
+	for path, value := range values {
+		patch.Set[path] = value
+	}
+	return patch.Update(), patch.Preconditions(), true
+}
+
+// Private
+// This method adds the preconditions of a patch (test operations and existent paths) to the filter of the update
+func withPreconditions(filter bson.M, preconditions []bson.M) bson.M {
+	if len(preconditions) > 0 {
+		filter["$and"] = preconditions
+	}
+	return filter
+}
+
+// Private
+// This method returns the error of an update with preconditions that matched no document
+// Output: 409 request error if the document of the requested version exists (a precondition failed),
+// otherwise the version conflict (see versionConflict)
+func preconditionConflict(ctx context.Context, collection store.Collection, filter bson.M, version int64) error {
+	count, err := collection.CountDocuments(ctx, withVersion(filter, version))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return versionConflict(ctx, collection, filter)
+	}
+
+	message := "A test operation of the patch failed, the document is not changed."
+	return newRequestError(http.StatusConflict, message, errors.New("failed test operation"))
+}
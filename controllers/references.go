@@ -0,0 +1,394 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/middleware"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Delete policies of the references
+// restrict: the referenced documents cannot be deleted (409)
+// cascade: the referencing documents are deleted with them
+// reassign: the referencing documents reference another document (reassignTo query parameter)
+const DELETE_POLICY_RESTRICT = "restrict"
+const DELETE_POLICY_CASCADE = "cascade"
+const DELETE_POLICY_REASSIGN = "reassign"
+
+// Query parameter with the id of the document that takes over the references of the deleted ones
+const REASSIGN_TO_PARAM = "reassignTo"
+
+// Query parameter of the hard deletions (superadmin only), the deletions are soft by default
+const HARD_DELETE_PARAM = "hard"
+
+// Reference of a child collection to the documents of a parent collection
+// The policy of every reference is configurable with its environment variable
+type documentReference struct {
+	collectionName       string
+	field                string
+	parentCollectionName string
+	policyEnv            string
+	defaultPolicy        string
+
+	// Fields of the parent that the children copy (parent field -> child field), updated on reassign
+	copiedFields map[string]string
+}
+
+// All the references between the collections
+var DOCUMENT_REFERENCES = []documentReference{
+	{
+		collectionName:       db.DB_TABLE_LICENCES,
+		field:                "categoryId",
+		parentCollectionName: db.DB_TABLE_LICENSES_CATEGORIES,
+		policyEnv:            "DELETE_POLICY_LICENSES_CATEGORY",
+		defaultPolicy:        DELETE_POLICY_CASCADE,
+		copiedFields:         map[string]string{"title": "categoryTitle", "categoryType": "categoryType"},
+	},
+	{
+		collectionName:       db.DB_TABLE_LICENCES,
+		field:                "userHolderId",
+		parentCollectionName: db.DB_TABLE_USERS,
+		policyEnv:            "DELETE_POLICY_LICENSES_USER_HOLDER",
+		defaultPolicy:        DELETE_POLICY_RESTRICT,
+	},
+}
+
+// Result of a deletion with the policies of the references
+type deleteReport struct {
+	deletedCount    int64
+	cascadedCounts  map[string]int64 // deleted documents per child collection
+	reassignedCount map[string]int64 // reassigned documents per child collection
+}
+
+// Options of a deletion
+// The soft deletions mark the documents (deletedAt, deletedBy), the hard deletions remove them
+type deletion struct {
+	hard       bool
+	deletedAt  time.Time
+	deletedBy  primitive.ObjectID // nil object id for the jobs
+	reassignTo primitive.ObjectID
+}
+
+// This method returns the configured delete policy of the reference
+func (reference documentReference) policy() (string, error) {
+	policy := os.Getenv(reference.policyEnv)
+	switch policy {
+	case "":
+		return reference.defaultPolicy, nil
+	case DELETE_POLICY_RESTRICT, DELETE_POLICY_CASCADE, DELETE_POLICY_REASSIGN:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%s: not supported delete policy %q (restrict, cascade or reassign)", reference.policyEnv, policy)
+	}
+}
+
+// This method checks the configured delete policies of all the references
+func CheckDeletePolicies() error {
+	for _, reference := range DOCUMENT_REFERENCES {
+		if _, err := reference.policy(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Private
+// This method reads the options of the deletion from the query parameters and the principal of the request
+// The hard deletions (hard=true) are allowed only for the superadmin users
+func deletionOptions(ctx *gin.Context) (deletion, error) {
+	options := deletion{deletedAt: utils.NowUTC()}
+
+	// Target of the references of the deleted documents (reassign policy)
+	if value := ctx.Query(REASSIGN_TO_PARAM); utils.CheckStringNotEmpty(value) {
+		reassignTo, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return options, newRequestError(http.StatusBadRequest, "cannot convert the '"+REASSIGN_TO_PARAM+"' hex id to bson id.", err)
+		}
+		options.reassignTo = reassignTo
+	}
+
+	principal, found := middleware.GetPrincipal(ctx)
+	if found {
+		if deletedBy, err := primitive.ObjectIDFromHex(principal.UserID); err == nil {
+			options.deletedBy = deletedBy
+		}
+	}
+
+	// Hard deletion
+	if value := ctx.Query(HARD_DELETE_PARAM); utils.CheckStringNotEmpty(value) {
+		hard, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, newRequestError(http.StatusBadRequest, "the '"+HARD_DELETE_PARAM+"' query parameter must be true or false.", err)
+		}
+		if hard && (!found || !principal.IsSuperAdmin()) {
+			return options, newRequestError(http.StatusForbidden, "Hard delete is allowed only for superadmin users.", errors.New("hard delete by not superadmin user"))
+		}
+		options.hard = hard
+	}
+	return options, nil
+}
+
+// Private
+// This method returns the ids of the matching documents
+func findDocumentIDs(ctx context.Context, collection store.Collection, filter bson.M, limit int64) ([]primitive.ObjectID, error) {
+	type documentID struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	documents := []documentID{}
+	err := collection.Find(ctx, filter, &store.FindOptions{Limit: limit, Projection: bson.M{"_id": 1}}, &documents)
+	if err != nil {
+		return nil, err
+	}
+
+	objectIDs := []primitive.ObjectID{}
+	for _, document := range documents {
+		objectIDs = append(objectIDs, document.ID)
+	}
+	return objectIDs, nil
+}
+
+// Private
+// This method deletes the matching documents: the soft deletion marks them, the hard deletion removes them
+// Output: the deleted count
+func removeDocuments(ctx context.Context, collection store.Collection, filter bson.M, options deletion) (int64, error) {
+	if options.hard {
+		return collection.DeleteMany(ctx, filter)
+	}
+
+	result, err := collection.UpdateMany(ctx, utils.NotDeleted(filter), softDeleteUpdate(options))
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+// Private
+// This method returns the update statement of a soft deletion: the soft delete markers and the next version
+func softDeleteUpdate(options deletion) bson.M {
+	marker := bson.M{utils.DELETED_AT_FIELD: options.deletedAt}
+	if !options.deletedBy.IsZero() {
+		marker[utils.DELETED_BY_FIELD] = options.deletedBy
+	}
+	return utils.IncrementVersion(bson.M{"$set": marker})
+}
+
+// Private
+// This method deletes the matching documents with the policies of their references, with the context of the transaction
+// The soft deletions consider only the documents that are not deleted yet, the hard deletions the trash as well
+// The restricted references and the not valid reassignments abort the transaction with a request error
+func deleteWithReferences(ctx context.Context, dataStore store.Store, collectionName string, filter bson.M, limit int64, options deletion) (*deleteReport, error) {
+	collection := dataStore.Collection(collectionName)
+	report := &deleteReport{cascadedCounts: map[string]int64{}, reassignedCount: map[string]int64{}}
+
+	// The ids of the documents to delete
+	if !options.hard {
+		filter = utils.NotDeleted(filter)
+	}
+	objectIDs, err := findDocumentIDs(ctx, collection, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(objectIDs) == 0 {
+		return report, nil
+	}
+
+	// References first, then the documents
+	for _, reference := range DOCUMENT_REFERENCES {
+		if reference.parentCollectionName != collectionName {
+			continue
+		}
+
+		policy, err := reference.policy()
+		if err != nil {
+			return nil, err
+		}
+
+		children := dataStore.Collection(reference.collectionName)
+		referencesFilter := bson.M{reference.field: bson.M{"$in": objectIDs}}
+		if !options.hard {
+			referencesFilter = utils.NotDeleted(referencesFilter)
+		}
+		referencesCount, err := children.CountDocuments(ctx, referencesFilter)
+		if err != nil {
+			return nil, err
+		}
+		if referencesCount == 0 {
+			continue
+		}
+
+		switch policy {
+		case DELETE_POLICY_RESTRICT:
+			message := fmt.Sprintf("The document is referenced by { %d } documents of the collection { %s } ('%s'). Delete them first.", referencesCount, reference.collectionName, reference.field)
+			return nil, newRequestError(http.StatusConflict, message, errors.New("delete restricted by the references of "+reference.collectionName+"."+reference.field))
+
+		case DELETE_POLICY_CASCADE:
+			deletedCount, err := removeDocuments(ctx, children, referencesFilter, options)
+			if err != nil {
+				return nil, err
+			}
+			report.cascadedCounts[reference.collectionName] += deletedCount
+
+		case DELETE_POLICY_REASSIGN:
+			reassignedCount, err := reassignReferences(ctx, dataStore, reference, objectIDs, referencesFilter, options.reassignTo)
+			if err != nil {
+				return nil, err
+			}
+			report.reassignedCount[reference.collectionName] += reassignedCount
+		}
+	}
+
+	report.deletedCount, err = removeDocuments(ctx, collection, bson.M{"_id": bson.M{"$in": objectIDs}}, options)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Private
+// This method makes the documents that reference the deleted ones reference the target document
+// The copied fields of the parent (e.g. the category title of the licenses) are taken from the target
+func reassignReferences(ctx context.Context, dataStore store.Store, reference documentReference, deletedIDs []primitive.ObjectID, referencesFilter bson.M, reassignTo primitive.ObjectID) (int64, error) {
+	if reassignTo.IsZero() {
+		message := "The delete policy of '" + reference.collectionName + "." + reference.field + "' is reassign. Provide the '" + REASSIGN_TO_PARAM + "' query parameter."
+		return 0, newRequestError(http.StatusBadRequest, message, errors.New("missing reassign target"))
+	}
+	for _, deletedID := range deletedIDs {
+		if deletedID == reassignTo {
+			message := "The references cannot be reassigned to a deleted document."
+			return 0, newRequestError(http.StatusBadRequest, message, errors.New("reassign target is deleted"))
+		}
+	}
+
+	// The target (not deleted) and the fields that the children copy
+	projection := bson.M{"_id": 1}
+	for parentField := range reference.copiedFields {
+		projection[parentField] = 1
+	}
+	target := map[string]interface{}{}
+	targetFilter := utils.NotDeleted(bson.M{"_id": reassignTo})
+	err := dataStore.Collection(reference.parentCollectionName).FindOne(ctx, targetFilter, &store.FindOptions{Projection: projection}, &target)
+	if err != nil {
+		return 0, newRequestError(http.StatusNotFound, "cannot retrieve the document to reassign the references to.", err)
+	}
+
+	updatedFields := bson.M{reference.field: reassignTo, "lastUpdatedDt": utils.NowUTC()}
+	for parentField, childField := range reference.copiedFields {
+		updatedFields[childField] = target[parentField]
+	}
+
+	// Update every referencing document (the collections have no update of many documents)
+	children := dataStore.Collection(reference.collectionName)
+	childIDs, err := findDocumentIDs(ctx, children, referencesFilter, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	var reassignedCount int64
+	for _, childID := range childIDs {
+		result, err := children.UpdateOne(ctx, bson.M{"_id": childID}, utils.IncrementVersion(bson.M{"$set": updatedFields}))
+		if err != nil {
+			return 0, err
+		}
+		reassignedCount += result.MatchedCount
+	}
+	return reassignedCount, nil
+}
+
+// GET ORPHAN REPORT -------
+// -------------------------
+// This method lists the documents with references to not existent or deleted documents (dangling references)
+func GetOrphanReport(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		var orphansCount int
+		referencesReport := []gin.H{}
+		for _, reference := range DOCUMENT_REFERENCES {
+			policy, err := reference.policy()
+			if err != nil {
+				utils.HandleError(ctx, http.StatusInternalServerError, "Not valid delete policy configuration.", err.Error())
+				return
+			}
+
+			orphans, err := findOrphans(context.TODO(), dataStore, reference)
+			if err != nil {
+				utils.HandleError(ctx, http.StatusInternalServerError, "Error finding the orphan documents of '"+reference.collectionName+"."+reference.field+"'.", err.Error())
+				return
+			}
+			orphansCount += len(orphans)
+
+			referencesReport = append(referencesReport, gin.H{
+				"collection":           reference.collectionName,
+				"field":                reference.field,
+				"referencedCollection": reference.parentCollectionName,
+				"deletePolicy":         policy,
+				"count":                len(orphans),
+				"documents":            orphans,
+			})
+		}
+
+		// Send the response with the orphans of every reference
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":      "Found {" + fmt.Sprint(orphansCount) + "} documents with dangling references.",
+			"orphansCount": orphansCount,
+			"data":         referencesReport,
+		})
+	}
+}
+
+// Private
+// This method finds the documents of the reference whose referenced document does not exist or is deleted (trash)
+// Output: the _id and the reference of every orphan document
+func findOrphans(ctx context.Context, dataStore store.Store, reference documentReference) ([]map[string]interface{}, error) {
+	children := []map[string]interface{}{}
+	filter := utils.NotDeleted(bson.M{reference.field: bson.M{"$exists": true}})
+	err := dataStore.Collection(reference.collectionName).Find(ctx, filter, &store.FindOptions{Projection: bson.M{"_id": 1, reference.field: 1}}, &children)
+	if err != nil {
+		return nil, err
+	}
+
+	// The referenced ids (the values that are not object ids cannot reference any document)
+	referencedIDs := []primitive.ObjectID{}
+	for _, child := range children {
+		if referencedID, isObjectID := child[reference.field].(primitive.ObjectID); isObjectID {
+			referencedIDs = append(referencedIDs, referencedID)
+		}
+	}
+
+	type documentID struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	parents := []documentID{}
+	if len(referencedIDs) > 0 {
+		parentsFilter := utils.NotDeleted(bson.M{"_id": bson.M{"$in": referencedIDs}})
+		err = dataStore.Collection(reference.parentCollectionName).Find(ctx, parentsFilter, &store.FindOptions{Projection: bson.M{"_id": 1}}, &parents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	existentIDs := map[primitive.ObjectID]bool{}
+	for _, parent := range parents {
+		existentIDs[parent.ID] = true
+	}
+
+	orphans := []map[string]interface{}{}
+	for _, child := range children {
+		referencedID, isObjectID := child[reference.field].(primitive.ObjectID)
+		if child[reference.field] == nil || (isObjectID && existentIDs[referencedID]) {
+			continue
+		}
+		orphans = append(orphans, map[string]interface{}{"_id": child["_id"], reference.field: child[reference.field]})
+	}
+	return orphans, nil
+}
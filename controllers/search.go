@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/middleware"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Query parameter of the searched text and its max length
+const SEARCH_TEXT_PARAM = "q"
+const MAX_SEARCH_TEXT_LENGTH = 256
+
+// Query parameter of the searched collections (comma separated, default: all the collections)
+const SEARCH_COLLECTIONS_PARAM = "collections"
+
+// Searchable collection
+// The not admin users only find the documents that they own (ownerField = their user id)
+type searchCollection struct {
+	name       string
+	ownerField string
+	search     func(collection store.Collection, searchText string, filter bson.M, pageRequested int64, limit int64) (gin.H, error)
+}
+
+// Searchable collections, in the order of the results
+var SEARCH_COLLECTIONS = []searchCollection{
+	{name: db.DB_TABLE_USERS, ownerField: registry.USERS.OwnerField, search: searchDocuments[models.User]},
+	{name: db.DB_TABLE_LICENSES_CATEGORIES, search: searchDocuments[models.LicenseCategory]},
+	{name: db.DB_TABLE_LICENCES, ownerField: registry.LICENSES.OwnerField, search: searchDocuments[models.License]},
+}
+
+// SEARCH ------------------
+// -------------------------
+// This method searches the text (q) in the users, the license categories and the licenses
+// The results are grouped by collection and paginated per collection (page, limit), the most recent first
+// The not admin users only find their own user and their own licenses
+func Search(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// Text of the search
+		searchText := strings.TrimSpace(ctx.Query(SEARCH_TEXT_PARAM))
+		if searchText == "" || len(searchText) > MAX_SEARCH_TEXT_LENGTH {
+			errorMsg := fmt.Sprintf("The search text '%s' must have 1 to %d characters.", SEARCH_TEXT_PARAM, MAX_SEARCH_TEXT_LENGTH)
+			utils.HandleError(ctx, http.StatusBadRequest, errorMsg, errorMsg)
+			return
+		}
+
+		// PAGING - SKIP AND LIMIT RESULTS - LIMIT
+		pageRequested, limit, err := pageAndLimit(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), err.Error())
+			return
+		}
+
+		// Searched collections
+		collections, err := searchedCollections(ctx.Query(SEARCH_COLLECTIONS_PARAM))
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Invalid collections of the search.", err.Error())
+			return
+		}
+
+		// Caller of the search
+		principal, found := middleware.GetPrincipal(ctx)
+		if !found {
+			utils.HandleError(ctx, http.StatusUnauthorized, "Unauthorized user.", errors.New("not authorized user").Error())
+			return
+		}
+		ownerID, err := primitive.ObjectIDFromHex(principal.UserID)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusUnauthorized, "Unauthorized user.", err.Error())
+			return
+		}
+
+		results := gin.H{}
+		var totalNumbersDocuments int64
+		for _, collection := range collections {
+
+			// Only the own documents of the not admin users
+			filter := bson.M{}
+			if collection.ownerField != "" && !principal.IsAdmin() {
+				filter[collection.ownerField] = ownerID
+			}
+
+			result, err := collection.search(dataStore.Collection(collection.name), searchText, utils.NotDeleted(filter), pageRequested, limit)
+			if err != nil {
+				utils.HandleError(ctx, http.StatusInternalServerError, "Error searching the documents of the Collection { "+collection.name+" }.", err.Error())
+				return
+			}
+			results[collection.name] = result
+			totalNumbersDocuments += result["totalNumbersDocuments"].(int64)
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":               "Found {" + fmt.Sprint(totalNumbersDocuments) + "} Documents for the search.",
+			"query":                 searchText,
+			"data":                  results,
+			"currentPage":           pageRequested,
+			"totalNumbersDocuments": totalNumbersDocuments,
+		})
+	}
+}
+
+// Private
+// This method returns the searched collections of the comma separated names (all the collections if empty)
+func searchedCollections(names string) ([]searchCollection, error) {
+	if strings.TrimSpace(names) == "" {
+		return SEARCH_COLLECTIONS, nil
+	}
+
+	collections := []searchCollection{}
+	for _, name := range strings.Split(names, ",") {
+		found := false
+		for _, collection := range SEARCH_COLLECTIONS {
+			if collection.name == strings.TrimSpace(name) {
+				collections = append(collections, collection)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the collection '%s' is not searchable", name)
+		}
+	}
+	return collections, nil
+}
+
+// Private
+// This method returns the page of the documents of the model that match the search text (see models.SearchFilter)
+// and the filter, without the fields with the projection:"never" tag (e.g. the password)
+func searchDocuments[T any](collection store.Collection, searchText string, filter bson.M, pageRequested int64, limit int64) (gin.H, error) {
+	for key, condition := range models.SearchFilter[T](searchText) {
+		filter[key] = condition
+	}
+
+	countDocumentsResult, err := collection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sortKeys, err := models.CompileSort[T]("", models.DEFAULT_SORT_MOST_RECENT)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := models.CompileProjection[T]("")
+	if err != nil {
+		return nil, err
+	}
+
+	documentsList := []T{}
+	searchOpts := &store.FindOptions{Skip: (pageRequested - 1) * limit, Limit: limit, Sort: sortKeys, Projection: projection.Query}
+	if err = collection.Find(context.TODO(), filter, searchOpts, &documentsList); err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"data":                  documentsList,
+		"rows":                  len(documentsList),
+		"totalPages":            math.Ceil(float64(countDocumentsResult) / float64(limit)),
+		"totalNumbersDocuments": countDocumentsResult,
+	}, nil
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"errors"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error of a step inside a transaction, with its HTTP response
+// The transaction function returns it, so the transaction is aborted and the handler responds with it
+type requestError struct {
+	status  int
+	message string
+	err     error
+}
+
+func (requestErr *requestError) Error() string {
+	return requestErr.err.Error()
+}
+
+func (requestErr *requestError) Unwrap() error {
+	return requestErr.err
+}
+
+// Private
+// This method creates a new request error
+func newRequestError(status int, message string, err error) error {
+	return &requestError{status: status, message: message, err: err}
+}
+
+// Private
+// This method responds with the error of an aborted transaction (or any request error)
+// The duplicate keys (e.g. a second active license of the user) are conflicts
+func handleTransactionError(ctx *gin.Context, err error, defaultMessage string) {
+	var requestErr *requestError
+	switch {
+	case errors.As(err, &requestErr):
+		utils.HandleError(ctx, requestErr.status, requestErr.message, requestErr.err.Error())
+	case store.IsDuplicateKey(err):
+		utils.HandleError(ctx, http.StatusConflict, defaultMessage+" The data conflicts with an existent document.", err.Error())
+	default:
+		utils.HandleError(ctx, http.StatusInternalServerError, defaultMessage, err.Error())
+	}
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	driverbson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Result of a restore with the documents that were deleted together with it (cascade)
+type restoreReport struct {
+	restoredCount  int64
+	cascadedCounts map[string]int64 // restored documents per child collection
+}
+
+// Private
+// This method checks that the document data has no soft delete markers (set only by the delete APIs)
+func checkNoDeleteMarkers(requestBody map[string]interface{}) error {
+	for _, field := range []string{utils.DELETED_AT_FIELD, utils.DELETED_BY_FIELD} {
+		if _, found := requestBody[field]; found {
+			return errors.New("field '" + field + "' is not allowed in the request body.")
+		}
+	}
+	return nil
+}
+
+// Private
+// This method returns the collection of the trash APIs from the path parameter
+func trashCollectionName(ctx *gin.Context) (string, error) {
+	collectionName := ctx.Param("collection")
+	for _, softDeleteCollection := range db.DB_TABLES_SOFT_DELETE {
+		if collectionName == softDeleteCollection {
+			return collectionName, nil
+		}
+	}
+	return "", errors.New("not found collection with trash: " + collectionName)
+}
+
+// GET TRASH ---------------
+// -------------------------
+// This method lists the soft deleted documents of the collection, the most recently deleted first
+func GetTrash(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// Set the collection
+		collectionName, err := trashCollectionName(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), err.Error())
+			return
+		}
+
+		// PAGING - SKIP AND LIMIT RESULTS - LIMIT
+		pageRequested, limit, err := pageAndLimit(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), err.Error())
+			return
+		}
+
+		// Count the soft deleted documents
+		collection := dataStore.Collection(collectionName)
+		countDocumentsResult, err := collection.CountDocuments(context.TODO(), utils.OnlyDeleted(bson.M{}))
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error counting the documents in the trash.", err.Error())
+			return
+		}
+
+		// The hidden fields of the model (projection:"never", e.g. the password of the users) are never returned
+		resource, found := registry.Lookup(collectionName)
+		if !found {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Not registered collection with trash.", errors.New("not registered collection: "+collectionName).Error())
+			return
+		}
+		searchOpts := &store.FindOptions{
+			Skip:  (pageRequested - 1) * limit,
+			Limit: limit,
+			Sort:  driverbson.D{{Key: utils.DELETED_AT_FIELD, Value: -1}, {Key: "_id", Value: 1}},
+		}
+		if len(resource.HiddenFields) > 0 {
+			projection := bson.M{}
+			for _, field := range resource.HiddenFields {
+				projection[field] = 0
+			}
+			searchOpts.Projection = projection
+		}
+
+		documentsList := []map[string]interface{}{}
+		if err = collection.Find(context.TODO(), utils.OnlyDeleted(bson.M{}), searchOpts, &documentsList); err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error retrieving the documents of the trash.", err.Error())
+			return
+		}
+
+		// Send the response with the deleted documents
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":               "Retrieved {" + fmt.Sprint(len(documentsList)) + "} deleted Documents from the trash of Collection { " + collectionName + " }.",
+			"data":                  documentsList,
+			"rows":                  len(documentsList),
+			"currentPage":           pageRequested,
+			"totalPages":            math.Ceil(float64(countDocumentsResult) / float64(limit)),
+			"totalNumbersDocuments": countDocumentsResult,
+		})
+	}
+}
+
+// RESTORE DOCUMENT --------
+// -------------------------
+// This method restores a soft deleted document with the documents that were deleted together with it (cascade)
+func RestoreDocument(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// Set the collection
+		collectionName, err := trashCollectionName(ctx)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), err.Error())
+			return
+		}
+
+		// String id not object id
+		oID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
+			return
+		}
+
+		// Restore the document and its cascades in a transaction
+		var report *restoreReport
+		err = dataStore.WithTransaction(context.TODO(), func(txCtx context.Context) error {
+			var err error
+			report, err = restoreWithReferences(txCtx, dataStore, collectionName, oID)
+			return err
+		})
+		if err != nil {
+			handleTransactionError(ctx, err, "restore of the document failed.")
+			return
+		}
+
+		// Return response
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":           "Document restored successfully.",
+			"documentsRestored": report.restoredCount,
+			"cascadesRestored":  report.cascadedCounts,
+		})
+	}
+}
+
+// Private
+// This method restores the soft deleted document, with the context of the transaction
+// The referenced documents must not be deleted, and the documents of the cascades that were deleted
+// at the same time are restored as well
+func restoreWithReferences(ctx context.Context, dataStore store.Store, collectionName string, oID primitive.ObjectID) (*restoreReport, error) {
+	collection := dataStore.Collection(collectionName)
+	report := &restoreReport{cascadedCounts: map[string]int64{}}
+
+	document := map[string]interface{}{}
+	err := collection.FindOne(ctx, utils.OnlyDeleted(bson.M{"_id": oID}), &store.FindOptions{Projection: bson.M{"password": 0}}, &document)
+	if err != nil {
+		return nil, newRequestError(http.StatusNotFound, "cannot retrieve the deleted document from the trash.", err)
+	}
+	deletedAt := document[utils.DELETED_AT_FIELD]
+
+	for _, reference := range DOCUMENT_REFERENCES {
+
+		// The referenced document of the restored one must exist
+		if reference.collectionName == collectionName && document[reference.field] != nil {
+			count, err := dataStore.Collection(reference.parentCollectionName).CountDocuments(ctx, utils.NotDeleted(bson.M{"_id": document[reference.field]}))
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				message := "The referenced document of '" + reference.field + "' is deleted or not existent. Restore it first."
+				return nil, newRequestError(http.StatusConflict, message, errors.New("restore of a document with a deleted reference"))
+			}
+		}
+
+		// The referencing documents that were deleted with the restored one
+		if reference.parentCollectionName == collectionName {
+			children := dataStore.Collection(reference.collectionName)
+			restoredCount, err := restoreDocuments(ctx, children, bson.M{reference.field: oID, utils.DELETED_AT_FIELD: deletedAt})
+			if err != nil {
+				return nil, err
+			}
+			report.cascadedCounts[reference.collectionName] += restoredCount
+		}
+	}
+
+	report.restoredCount, err = restoreDocuments(ctx, collection, bson.M{"_id": oID})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Private
+// This method removes the soft delete markers of the matching documents
+// Output: the restored count
+func restoreDocuments(ctx context.Context, collection store.Collection, filter bson.M) (int64, error) {
+	update := utils.IncrementVersion(bson.M{
+		"$set":   bson.M{utils.DELETED_AT_FIELD: nil, "lastUpdatedDt": utils.NowUTC()},
+		"$unset": bson.M{utils.DELETED_BY_FIELD: ""},
+	})
+
+	result, err := collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
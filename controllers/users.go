@@ -2,182 +2,285 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go-essentials/go-mongodb-rest-api/db"
 	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
 	"go-essentials/go-mongodb-rest-api/utils"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"gopkg.in/mgo.v2/bson"
 )
 
 // This method signups the user
-func Register(ctx *gin.Context) {
-
-	// User Data
-	var user models.User
-	err := ctx.ShouldBindJSON(&user)
-
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing user data.", err.Error())
-		return
+func Register(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// User Data
+		var user models.User
+		err := ctx.ShouldBindJSON(&user)
+
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing user data.", err.Error())
+			return
+		}
+
+		// Store the new user
+		insertedID, err := insertNormalUser(dataStore, user)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error storing the new user in the database.", err.Error())
+			return
+		}
+
+		// Success response
+		ctx.JSON(http.StatusCreated, gin.H{
+			"message": "User created successfully.",
+			"data": []map[string]any{
+				{
+					"_id": insertedID,
+				},
+			},
+		})
 	}
+}
+
+// Private
+// This method hashes the password and stores a new normal user in the database
+// It is shared by the register API and the OpenID Connect login
+func insertNormalUser(dataStore store.Store, user models.User) (interface{}, error) {
 
 	// Hash the user password
 	hashedPassword, err := utils.HashPassword(user.Password)
 	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error hashing the user password.", err.Error())
-		return
+		return nil, err
 	}
 
-	// Update the user password
+	// Update the user password and the email in lower case (the emails match whatever their case)
 	user.Password = hashedPassword
+	user.Email = utils.NormalizeEmail(user.Email)
 
-	// Created dt and last update dt (YYYY-MM-DD HH:MM:SS)
-	NOW_TIME := time.Now().Format("2006-01-02 15:04:05")
+	// Created dt and last update dt (native dates in UTC)
+	NOW_TIME := utils.NowUTC()
 	user.CreatedDt = NOW_TIME
 	user.LastUpdatedDt = NOW_TIME
 
 	// Set the fields: isAdmin, isActive, role
 	// All the users that are using this API are created as normal users
 	user.Role = "user"
-	user.IsAdmin = "0"
-	user.IsActive = "1"
+	user.IsAdmin = false
+	user.IsActive = true
+	user.DeletedAt = nil
+	user.Version = utils.INITIAL_VERSION
 
 	// Print the data to insert
 	fmt.Println("User data to insert: ", user)
 
 	// Insert the user into the database
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_USERS)
-	result, err := collection.InsertOne(context.TODO(), user)
+	collection := dataStore.Collection(db.DB_TABLE_USERS)
+	insertedID, err := collection.InsertOne(context.TODO(), user)
 	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error storing the new user in the database.", err.Error())
-		return
+		return nil, err
 	}
 
 	// Print the insert result
-	fmt.Println("Insert Result:", result)
-
-	// Success response
-	ctx.JSON(http.StatusCreated, gin.H{
-		"message": "User created successfully.",
-		"data": []map[string]any{
-			{
-				"_id": result.InsertedID,
-			},
-		},
-	})
+	fmt.Println("Insert Result:", insertedID)
+	return insertedID, nil
 }
 
 // This method logs in the user
-func Login(ctx *gin.Context) {
-
-	// User Data for login
-	var user models.User
-	err := ctx.ShouldBindJSON(&user)
-
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing user data for login.", err.Error())
-		return
+func Login(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// User Data for login
+		var user models.User
+		err := ctx.ShouldBindJSON(&user)
+
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing user data for login.", err.Error())
+			return
+		}
+
+		// Login try
+		collection := dataStore.Collection(db.DB_TABLE_USERS)
+		var result models.User
+		err = collection.FindOne(context.TODO(), utils.NotDeleted(bson.M{"email": utils.NormalizeEmail(user.Email)}), nil, &result)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusUnauthorized, "invalid credentials", err.Error())
+			return
+		}
+
+		// Set the retrieved password
+		var retrievedPassword string = result.Password
+
+		// Compare the stored hashed password with the given password
+		validPassword := utils.CheckPasswordHash(user.Password, retrievedPassword)
+		if !validPassword {
+			utils.HandleError(ctx, http.StatusUnauthorized, "invalid credentials", errors.New("invalid credentials").Error())
+			return
+		}
+
+		// Set the user data
+		user = result
+
+		// Create the JWT token for the user
+		token, err := utils.GenerateToken(user.Email, user.ID, utils.AUTH_METHOD_PASSWORD)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error logging in the user.", err.Error())
+			return
+		}
+
+		// Success response
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Login successfull.",
+			"token":   token,
+		})
 	}
-
-	// Login try
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_USERS)
-	var result models.User
-	err = collection.FindOne(context.TODO(), bson.M{"email": user.Email}).Decode(&result)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusUnauthorized, "invalid credentials", err.Error())
-		return
-	}
-
-	// Set the retrieved password
-	var retrievedPassword string = result.Password
-
-	// Compare the stored hashed password with the given password
-	validPassword := utils.CheckPasswordHash(user.Password, retrievedPassword)
-	if !validPassword {
-		utils.HandleError(ctx, http.StatusUnauthorized, "invalid credentials", err.Error())
-		return
-	}
-
-	// Set the user data
-	user = result
-
-	// Create the JWT token for the user
-	token, err := utils.GenerateToken(user.Email, user.ID)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error logging in the user.", err.Error())
-		return
-	}
-
-	// Success response
-	ctx.JSON(http.StatusOK, gin.H{
-		"message": "Login successfull.",
-		"token":   token,
-	})
 }
 
 // This method creates and ADMIN or SUPERADMIN user
-func CreateAdmin[T any](ctx *gin.Context) {
-
-	// User Data
-	var user models.User
-	err := ctx.ShouldBindJSON(&user)
-
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "Error parsing user data.", err.Error())
-		return
-	}
-
-	// Check the role of the user
-	if !utils.CheckAllowedRole(user.Role) {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error creating user.", errors.New("the provided role is not supported").Error())
-		return
-	}
-
-	// Hash the user password
-	hashedPassword, err := utils.HashPassword(user.Password)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error hashing the user password.", err.Error())
-		return
+func CreateAdmin[T any](dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// User Data
+		var user models.User
+		err := ctx.ShouldBindJSON(&user)
+
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing user data.", err.Error())
+			return
+		}
+
+		// Check the role of the user
+		if !utils.CheckAllowedRole(user.Role) {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error creating user.", errors.New("the provided role is not supported").Error())
+			return
+		}
+
+		// Hash the user password
+		hashedPassword, err := utils.HashPassword(user.Password)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error hashing the user password.", err.Error())
+			return
+		}
+
+		// Update the user password and the email in lower case (the emails match whatever their case)
+		user.Password = hashedPassword
+		user.Email = utils.NormalizeEmail(user.Email)
+
+		// Created dt and last update dt (native dates in UTC)
+		NOW_TIME := utils.NowUTC()
+		user.CreatedDt = NOW_TIME
+		user.LastUpdatedDt = NOW_TIME
+
+		// Set the fields: isAdmin, isActive
+		user.IsAdmin = true
+		user.IsActive = true
+		user.DeletedAt = nil
+		user.Version = utils.INITIAL_VERSION
+
+		// Print the data to insert
+		fmt.Println("User admin data to insert: ", user)
+
+		// Insert the user into the database
+		collection := dataStore.Collection(db.DB_TABLE_USERS)
+		insertedID, err := collection.InsertOne(context.TODO(), user)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "Error storing the new admin user in the database.", err.Error())
+			return
+		}
+
+		// Print the insert result
+		fmt.Println("Insert Result:", insertedID)
+
+		// Success response
+		ctx.JSON(http.StatusCreated, gin.H{
+			"message": "Admin User created successfully.",
+			"data": []map[string]any{
+				{
+					"_id": insertedID,
+				},
+			},
+		})
 	}
+}
 
-	// Update the user password
-	user.Password = hashedPassword
-
-	// Created dt and last update dt (YYYY-MM-DD HH:MM:SS)
-	NOW_TIME := time.Now().Format("2006-01-02 15:04:05")
-	user.CreatedDt = NOW_TIME
-	user.LastUpdatedDt = NOW_TIME
-
-	// Set the fields: isAdmin, isActive
-	user.IsAdmin = "1"
-	user.IsActive = "1"
-
-	// Print the data to insert
-	fmt.Println("User admin data to insert: ", user)
-
-	// Insert the user into the database
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_USERS)
-	result, err := collection.InsertOne(context.TODO(), user)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "Error storing the new admin user in the database.", err.Error())
-		return
+// Fields of the users that only the admins change (readonly for the generic update and the bulk writes)
+var USER_ACCESS_FIELDS = []string{"role", "isAdmin", "isActive"}
+
+// UPDATE USER ACCESS ------
+// -------------------------
+// This method changes the role, the admin flag and the active flag of a user (ADMIN or SUPERADMIN users only)
+// Like the other updates, the version of the user is required (If-Match)
+func UpdateUserAccess(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// String id not object id
+		userID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
+			return
+		}
+
+		// The user must exist (not deleted) before its version is checked
+		collection := dataStore.Collection(db.DB_TABLE_USERS)
+		filter := utils.NotDeleted(bson.M{"_id": userID})
+		if err = documentExists(context.TODO(), collection, filter); err != nil {
+			handleTransactionError(ctx, err, "update of the user access failed")
+			return
+		}
+
+		// Version of the user that the admin updates (If-Match)
+		version, err := requiredVersion(ctx)
+		if err != nil {
+			handleTransactionError(ctx, err, "Not valid version of the user.")
+			return
+		}
+
+		// Access fields of the request body
+		requestBody := map[string]interface{}{}
+		decoderData := json.NewDecoder(ctx.Request.Body)
+		decoderData.UseNumber()
+		if err = decoderData.Decode(&requestBody); err != nil || len(requestBody) == 0 {
+			message := "empty access data"
+			if err != nil {
+				message = err.Error()
+			}
+			utils.HandleError(ctx, http.StatusBadRequest, "Error parsing and decoding access data.", message)
+			return
+		}
+
+		// Only the access fields, with the values of the model (e.g. a supported role)
+		if err = models.ValidateFieldValues[models.User](requestBody, USER_ACCESS_FIELDS); err == nil {
+			err = convertDocumentValues[models.User](requestBody, "Invalid date in the access data.", "Invalid value in the access data.")
+		}
+		if err != nil {
+			handleDocumentDataError(ctx, err)
+			return
+		}
+		requestBody["lastUpdatedDt"] = utils.NowUTC()
+
+		// Only the requested version of the user is updated
+		result, err := collection.UpdateOne(context.TODO(), withVersion(filter, version), utils.IncrementVersion(bson.M{"$set": requestBody}))
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "update of the user access failed", err.Error())
+			return
+		}
+
+		// Not existent user, or a user that another request updated
+		if result.MatchedCount == 0 {
+			err = versionConflict(context.TODO(), collection, filter)
+			handleTransactionError(ctx, err, "update of the user access failed")
+			return
+		}
+		setETag(ctx, version+1)
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":          "User access updated successfully.",
+			"documentsUpdated": result.ModifiedCount,
+		})
 	}
-
-	// Print the insert result
-	fmt.Println("Insert Result:", result)
-
-	// Success response
-	ctx.JSON(http.StatusCreated, gin.H{
-		"message": "Admin User created successfully.",
-		"data": []map[string]any{
-			{
-				"_id": result.InsertedID,
-			},
-		},
-	})
 }
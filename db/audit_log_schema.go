@@ -0,0 +1,76 @@
+package db
+
+import "gopkg.in/mgo.v2/bson"
+
+// This method creates the 'audit_log' schema
+func CreateAuditLogSchema() bson.M {
+
+	// Create a "audit_log" collection with a JSON schema validator
+	jsonSchema := bson.M{
+		"bsonType": "object",
+		"required": []string{"ip", "method", "route", "path", "action", "documentIds", "status", "createdDt"},
+		"properties": bson.M{
+			"actorId": bson.M{
+				"bsonType":    "string",
+				"description": "(Optional) the id of the authenticated user of the request",
+			},
+			"actorEmail": bson.M{
+				"bsonType":    "string",
+				"description": "(Optional) the email of the authenticated user of the request",
+			},
+			"actorRoles": bson.M{
+				"bsonType":    "array",
+				"description": "(Optional) the roles of the authenticated user of the request",
+				"items":       bson.M{"bsonType": "string"},
+			},
+			"ip": bson.M{
+				"bsonType":    "string",
+				"description": "the client IP of the request, which is required and must be a string",
+			},
+			"method": bson.M{
+				"bsonType":    "string",
+				"description": "the HTTP method of the request, which is required and must be a string",
+			},
+			"route": bson.M{
+				"bsonType":    "string",
+				"description": "the route of the request (e.g. /api/v1/licenses/:id), which is required and must be a string",
+			},
+			"path": bson.M{
+				"bsonType":    "string",
+				"description": "the path of the request, which is required and must be a string",
+			},
+			"query": bson.M{
+				"bsonType":    "string",
+				"description": "(Optional) the query parameters of the request",
+			},
+			"collection": bson.M{
+				"bsonType":    "string",
+				"description": "(Optional) the target collection of the request",
+			},
+			"action": bson.M{
+				"bsonType":    "string",
+				"description": "the action of the request (e.g. create, update, delete), which is required and must be a string",
+			},
+			"documentIds": bson.M{
+				"bsonType":    "array",
+				"description": "the ids of the target documents, which is required and must be an array of strings",
+				"items":       bson.M{"bsonType": "string"},
+			},
+			"requestBody": bson.M{
+				"bsonType":    "object",
+				"description": "(Optional) the request body, with the secrets redacted",
+			},
+			"status": bson.M{
+				"bsonType":    []string{"int", "long"},
+				"description": "the HTTP status of the response, which is required and must be an integer",
+			},
+			"createdDt": bson.M{
+				"bsonType":    "date",
+				"description": "the date of the request, which is required and must be a date (UTC)",
+			},
+		},
+	}
+
+	// Return the schema
+	return jsonSchema
+}
@@ -8,7 +8,7 @@ func CreateLicenseCategoriesSchema() bson.M {
 	// Create a "licensecategories" collection with a JSON schema validator
 	jsonSchema := bson.M{
 		"bsonType": "object",
-		"required": []string{"title", "description", "priceEurosMonthly", "priceEurosThreeMonths", "priceEurosSixMonths", "priceEurosTwelveMonths", "categoryType", "textsQntAllowed", "imagesQntAllowed", "createdDt", "lastUpdatedDt", "isActive"},
+		"required": []string{"title", "description", "priceEurosMonthly", "priceEurosThreeMonths", "priceEurosSixMonths", "priceEurosTwelveMonths", "categoryType", "textsQntAllowed", "imagesQntAllowed", "createdDt", "lastUpdatedDt", "version", "isActive", "deletedAt"},
 		"properties": bson.M{
 			"title": bson.M{
 				"bsonType":    "string",
@@ -39,12 +39,12 @@ func CreateLicenseCategoriesSchema() bson.M {
 				"description": "the twelve months price in euros of the license category, which is required and must be a integer",
 			},
 			"categoryType": bson.M{
-				"bsonType":    "string",
-				"description": "the type of the license category, which is required and must be a string",
+				"bsonType":    "long",
+				"description": "the type of the license category, which is required and must be an integer",
 			},
 			"isActive": bson.M{
-				"bsonType":    "string",
-				"description": "A string value indicating that the license category is active or not, which is required and must be a string",
+				"bsonType":    "bool",
+				"description": "A boolean value indicating that the license category is active or not, which is required and must be a boolean",
 			},
 			"textsQntAllowed": bson.M{
 				"bsonType":    "long",
@@ -57,17 +57,29 @@ func CreateLicenseCategoriesSchema() bson.M {
 				"description": "the allowed number of images of the license category, which is required and must be a integer",
 			},
 			"createdDt": bson.M{
-				"bsonType":    "string",
-				"description": "the created date of the license category, which is required and must be a string (yyyy-MM-dd HH:mm:ss)",
+				"bsonType":    "date",
+				"description": "the created date of the license category, which is required and must be a date (UTC)",
 			},
 			"lastUpdatedDt": bson.M{
-				"bsonType":    "string",
-				"description": "the last updated date of the license category, which is required and must be a string (yyyy-MM-dd HH:mm:ss)",
+				"bsonType":    "date",
+				"description": "the last updated date of the license category, which is required and must be a date (UTC)",
 			},
 			"comments": bson.M{
 				"bsonType":    "string",
 				"description": "(Optional) comments of the license category",
 			},
+			"version": bson.M{
+				"bsonType":    "long",
+				"description": "the version of the license category (optimistic concurrency control), which is required and must be an integer",
+			},
+			"deletedAt": bson.M{
+				"bsonType":    []string{"date", "null"},
+				"description": "the deletion date of the license category (soft delete), which is required and must be null or a date (UTC)",
+			},
+			"deletedBy": bson.M{
+				"bsonType":    "objectId",
+				"description": "(Optional) the id of the user that deleted the license category",
+			},
 		},
 	}
 
@@ -8,19 +8,19 @@ func CreateLicensesSchema() bson.M {
 	// Create a "licenses" collection with a JSON schema validator
 	jsonSchema := bson.M{
 		"bsonType": "object",
-		"required": []string{"licenseKey", "expiration_dt", "begin_dt", "userHolderId", "userFullName", "categoryId", "categoryType", "categoryTitle", "activatedOnDevice", "timeSpanType", "createdDt", "lastUpdatedDt", "isActive", "isExpired"},
+		"required": []string{"licenseKey", "expiration_dt", "begin_dt", "userHolderId", "userFullName", "categoryId", "categoryType", "categoryTitle", "activatedOnDevice", "timeSpanType", "createdDt", "lastUpdatedDt", "version", "isActive", "isExpired", "deletedAt"},
 		"properties": bson.M{
 			"licenseKey": bson.M{
 				"bsonType":    "string",
 				"description": "the license κey of the license, which is required and must be a string",
 			},
 			"begin_dt": bson.M{
-				"bsonType":    "string",
-				"description": "the begin date of the license, which is required and must be a string (yyyy-MM-dd HH:mm:ss)",
+				"bsonType":    "date",
+				"description": "the begin date of the license, which is required and must be a date (UTC)",
 			},
 			"expiration_dt": bson.M{
-				"bsonType":    "string",
-				"description": "the expiration date of the license, which is required and must be a string (yyyy-MM-dd HH:mm:ss)",
+				"bsonType":    "date",
+				"description": "the expiration date of the license, which is required and must be a date (UTC)",
 			},
 			"userHolderId": bson.M{
 				"bsonType":    "objectId",
@@ -35,8 +35,8 @@ func CreateLicensesSchema() bson.M {
 				"description": "the id of the category of the license, which is required and must be an object ID",
 			},
 			"categoryType": bson.M{
-				"bsonType":    "string",
-				"description": "the category type of the license, which is required and must be a string",
+				"bsonType":    "long",
+				"description": "the category type of the license, which is required and must be an integer",
 			},
 			"categoryTitle": bson.M{
 				"bsonType":    "string",
@@ -52,24 +52,36 @@ func CreateLicensesSchema() bson.M {
 				"description": "the time span type of the license, which is required and must be IN the [1, 3, 6, 12] range",
 			},
 			"createdDt": bson.M{
-				"bsonType":    "string",
-				"description": "the created date of the license category, which is required and must be a string (yyyy-MM-dd HH:mm:ss)",
+				"bsonType":    "date",
+				"description": "the created date of the license category, which is required and must be a date (UTC)",
 			},
 			"lastUpdatedDt": bson.M{
-				"bsonType":    "string",
-				"description": "the last updated date of the license category, which is required and must be a string (yyyy-MM-dd HH:mm:ss)",
+				"bsonType":    "date",
+				"description": "the last updated date of the license category, which is required and must be a date (UTC)",
 			},
 			"comments": bson.M{
 				"bsonType":    "string",
 				"description": "(Optional) comments of the license category",
 			},
 			"isActive": bson.M{
-				"bsonType":    "string",
-				"description": "A string value indicating that the license category is ACTIVE or not, which is required and must be a string",
+				"bsonType":    "bool",
+				"description": "A boolean value indicating that the license is ACTIVE or not, which is required and must be a boolean",
 			},
 			"isExpired": bson.M{
-				"bsonType":    "string",
-				"description": "A string value indicating that the license category is EXPIRED or not, which is required and must be a string",
+				"bsonType":    "bool",
+				"description": "A boolean value indicating that the license is EXPIRED or not, which is required and must be a boolean",
+			},
+			"version": bson.M{
+				"bsonType":    "long",
+				"description": "the version of the license (optimistic concurrency control), which is required and must be an integer",
+			},
+			"deletedAt": bson.M{
+				"bsonType":    []string{"date", "null"},
+				"description": "the deletion date of the license (soft delete), which is required and must be null or a date (UTC)",
+			},
+			"deletedBy": bson.M{
+				"bsonType":    "objectId",
+				"description": "(Optional) the id of the user that deleted the license",
 			},
 		},
 	}
@@ -19,6 +19,13 @@ const DB_TABLE_USERS = "users"
 const DB_TABLE_LICENSES_CATEGORIES = "licensecategories"
 const DB_TABLE_LICENCES = "licenses"
 
+// AUDIT LOG (APPEND-ONLY)
+const DB_TABLE_AUDIT_LOG = "audit_log"
+
+// TABLES WITH SOFT DELETE (TRASH)
+// The referencing tables come first, so the purge removes the children before their parents
+var DB_TABLES_SOFT_DELETE = []string{DB_TABLE_LICENCES, DB_TABLE_LICENSES_CATEGORIES, DB_TABLE_USERS}
+
 // Global Database Client for all the DB Interactions
 var MongoClient *mongo.Client
 
@@ -52,11 +59,7 @@ func InitDB() *mongo.Client {
 		panic("Could not list all the collections in the database: " + err.Error())
 	}
 
-	// Create all the necessary collections
-	err = CreateAllCollections(cNames)
-	if err != nil {
-		panic("Could not create all necessary collections in the database: " + err.Error())
-	}
+	// The collections, the validators and the indexes are created by the migrations (migrations package)
 
 	fmt.Println("----------------------------------------------------------------")
 	fmt.Println("Connection with MongoDB successful")
@@ -66,152 +69,3 @@ func InitDB() *mongo.Client {
 	// Return the mongodb client
 	return MongoClient
 }
-
-// This method creates all the necessary collections in the database
-func CreateAllCollections(alreadyExistentCollectionNames []string) error {
-
-	// 1. Create 'users' Collection
-	err := CreateUsersCollection(alreadyExistentCollectionNames)
-
-	if err != nil {
-		fmt.Println("Error creating 'users' collection: ", err)
-		return err
-	}
-
-	// 2. Create 'licensecategories' Collection
-	err = CreateLicenseCategoriesCollection(alreadyExistentCollectionNames)
-
-	if err != nil {
-		fmt.Println("Error creating 'licensecategories' collection: ", err)
-		return err
-	}
-
-	// 3. Create 'licenses' Collection
-	err = CreateLicensesCollection(alreadyExistentCollectionNames)
-
-	if err != nil {
-		fmt.Println("Error creating 'licenses' collection: ", err)
-		return err
-	}
-
-	// All operations successfull
-	return nil
-}
-
-// This method creates the 'users' collection in the database
-func CreateUsersCollection(alreadyExistentCollectionNames []string) error {
-
-	// Check if 'users' collection is already created and if YES then return nil error
-	for _, value := range alreadyExistentCollectionNames {
-		if value == DB_TABLE_USERS {
-			return nil
-		}
-	}
-
-	// Create a "users" collection with a JSON schema validator
-	jsonSchema := CreateUsersSchema()
-
-	// Set the validator
-	validator := bson.M{
-		"$jsonSchema": jsonSchema,
-	}
-	opts := options.CreateCollection().SetValidator(validator)
-
-	// Create the collection
-	err := MongoClient.Database(DB_NAME).CreateCollection(context.TODO(), DB_TABLE_USERS, opts)
-	if err != nil {
-		return err
-	}
-
-	// Create the unique 'email' index on the collection
-	collection := MongoClient.Database(DB_NAME).Collection(DB_TABLE_USERS)
-	keysMap := make(map[string]int64, 1)
-	keysMap["email"] = 1
-	indexModel := mongo.IndexModel{
-		Keys:    keysMap,
-		Options: options.Index().SetUnique(true),
-	}
-
-	ind, err := collection.Indexes().CreateOne(context.TODO(), indexModel)
-	fmt.Println("Index on email created:", ind)
-	return err
-}
-
-// This method creates the 'licensecategories' collection in the database
-func CreateLicenseCategoriesCollection(alreadyExistentCollectionNames []string) error {
-
-	// Check if 'licensecategories' collection is already created and if YES then return nil error
-	for _, value := range alreadyExistentCollectionNames {
-		if value == DB_TABLE_LICENSES_CATEGORIES {
-			return nil
-		}
-	}
-
-	// Create a "licensecategories" collection with a JSON schema validator
-	jsonSchema := CreateLicenseCategoriesSchema()
-
-	// Set the validator
-	validator := bson.M{
-		"$jsonSchema": jsonSchema,
-	}
-	opts := options.CreateCollection().SetValidator(validator)
-
-	// Create the collection
-	err := MongoClient.Database(DB_NAME).CreateCollection(context.TODO(), DB_TABLE_LICENSES_CATEGORIES, opts)
-	if err != nil {
-		return err
-	}
-
-	// Create the unique 'categoryType' index on the collection
-	collection := MongoClient.Database(DB_NAME).Collection(DB_TABLE_LICENSES_CATEGORIES)
-	keysMap := make(map[string]int64, 1)
-	keysMap["categoryType"] = 1
-	indexModel := mongo.IndexModel{
-		Keys:    keysMap,
-		Options: options.Index().SetUnique(true),
-	}
-
-	ind, err := collection.Indexes().CreateOne(context.TODO(), indexModel)
-	fmt.Println("Index on categoryType created:", ind)
-	return err
-}
-
-// This method creates the 'licenses' collection in the database
-func CreateLicensesCollection(alreadyExistentCollectionNames []string) error {
-
-	// Check if 'licenses' collection is already created and if YES then return nil error
-	for _, value := range alreadyExistentCollectionNames {
-		if value == DB_TABLE_LICENCES {
-			return nil
-		}
-	}
-
-	// Create a "licenses" collection with a JSON schema validator
-	jsonSchema := CreateLicensesSchema()
-
-	// Set the validator
-	validator := bson.M{
-		"$jsonSchema": jsonSchema,
-	}
-	opts := options.CreateCollection().SetValidator(validator)
-	opts.SetValidationAction("error")
-
-	// Create the collection
-	err := MongoClient.Database(DB_NAME).CreateCollection(context.TODO(), DB_TABLE_LICENCES, opts)
-	if err != nil {
-		return err
-	}
-
-	// Create the unique 'licenseKey' index on the collection
-	collection := MongoClient.Database(DB_NAME).Collection(DB_TABLE_LICENCES)
-	keysMap := make(map[string]int64, 1)
-	keysMap["licenseKey"] = 1
-	indexModel := mongo.IndexModel{
-		Keys:    keysMap,
-		Options: options.Index().SetUnique(true),
-	}
-
-	ind, err := collection.Indexes().CreateOne(context.TODO(), indexModel)
-	fmt.Println("Index on licenseKey created:", ind)
-	return err
-}
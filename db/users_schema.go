@@ -8,7 +8,7 @@ func CreateUsersSchema() bson.M {
 	// Create a "users" collection with a JSON schema validator
 	jsonSchema := bson.M{
 		"bsonType": "object",
-		"required": []string{"firstName", "lastName", "role", "isAdmin", "isActive", "email", "password", "createdDt", "lastUpdatedDt"},
+		"required": []string{"firstName", "lastName", "role", "isAdmin", "isActive", "email", "password", "createdDt", "lastUpdatedDt", "version", "deletedAt"},
 		"properties": bson.M{
 			"firstName": bson.M{
 				"bsonType":    "string",
@@ -23,12 +23,12 @@ func CreateUsersSchema() bson.M {
 				"description": "the role of the user, which is required and must be a string",
 			},
 			"isAdmin": bson.M{
-				"bsonType":    "string",
-				"description": "A 0 | 1 value indicating whether the user is admin or not, which is required and must be a string",
+				"bsonType":    "bool",
+				"description": "A boolean value indicating whether the user is admin or not, which is required and must be a boolean",
 			},
 			"isActive": bson.M{
-				"bsonType":    "string",
-				"description": "A 0 | 1 value indicating whether the user is active or not, which is required and must be a string",
+				"bsonType":    "bool",
+				"description": "A boolean value indicating whether the user is active or not, which is required and must be a boolean",
 			},
 			"email": bson.M{
 				"bsonType":    "string",
@@ -39,12 +39,37 @@ func CreateUsersSchema() bson.M {
 				"description": "the password of the user, which is required and must be a string",
 			},
 			"createdDt": bson.M{
-				"bsonType":    "string",
-				"description": "the created date of the user, which is required and must be a string (yyyy-MM-dd HH:mm:ss)",
+				"bsonType":    "date",
+				"description": "the created date of the user, which is required and must be a date (UTC)",
 			},
 			"lastUpdatedDt": bson.M{
-				"bsonType":    "string",
-				"description": "the last updated date of the user, which is required and must be a string (yyyy-MM-dd HH:mm:ss)",
+				"bsonType":    "date",
+				"description": "the last updated date of the user, which is required and must be a date (UTC)",
+			},
+			"externalIdentities": bson.M{
+				"bsonType":    "array",
+				"description": "(Optional) the linked identities of the user on external OpenID Connect providers",
+				"items": bson.M{
+					"bsonType": "object",
+					"required": []string{"provider", "subject"},
+					"properties": bson.M{
+						"provider": bson.M{"bsonType": "string"},
+						"subject":  bson.M{"bsonType": "string"},
+						"linkedDt": bson.M{"bsonType": "date"},
+					},
+				},
+			},
+			"version": bson.M{
+				"bsonType":    "long",
+				"description": "the version of the user (optimistic concurrency control), which is required and must be an integer",
+			},
+			"deletedAt": bson.M{
+				"bsonType":    []string{"date", "null"},
+				"description": "the deletion date of the user (soft delete), which is required and must be null or a date (UTC)",
+			},
+			"deletedBy": bson.M{
+				"bsonType":    "objectId",
+				"description": "(Optional) the id of the user that deleted the user",
 			},
 		},
 	}
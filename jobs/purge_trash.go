@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"os"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Days that the soft deleted documents stay in the trash, before the purge deletes them for good
+const TRASH_RETENTION_DAYS_ENV = "TRASH_RETENTION_DAYS"
+const DEFAULT_TRASH_RETENTION_DAYS = 30
+
+// Time of the daily purge of the trash (HH:MM, UTC)
+const TRASH_PURGE_AT_ENV = "TRASH_PURGE_AT"
+const DEFAULT_TRASH_PURGE_AT = "03:00"
+
+// This method hard deletes the documents that were soft deleted before the given date
+// The referencing collections are purged first (db.DB_TABLES_SOFT_DELETE)
+// Output: the purged count per collection
+func PurgeTrash(ctx context.Context, dataStore store.Store, deletedBefore time.Time) (map[string]int64, error) {
+	purgedCounts := map[string]int64{}
+	for _, collectionName := range db.DB_TABLES_SOFT_DELETE {
+		filter := bson.M{utils.DELETED_AT_FIELD: bson.M{"$lt": deletedBefore}}
+		purgedCount, err := dataStore.Collection(collectionName).DeleteMany(ctx, filter)
+		if err != nil {
+			return purgedCounts, fmt.Errorf("purge of %s: %w", collectionName, err)
+		}
+		purgedCounts[collectionName] = purgedCount
+	}
+	return purgedCounts, nil
+}
+
+// This method starts the scheduler of the daily purge of the trash, with the retention of the environment
+func StartTrashPurge(dataStore store.Store) (*gocron.Scheduler, error) {
+	retentionDays := int64(DEFAULT_TRASH_RETENTION_DAYS)
+	if utils.CheckStringNotEmpty(os.Getenv(TRASH_RETENTION_DAYS_ENV)) {
+		days, err := utils.TransformStringToInteger64(os.Getenv(TRASH_RETENTION_DAYS_ENV))
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("%s must be a positive number of days", TRASH_RETENTION_DAYS_ENV)
+		}
+		retentionDays = days
+	}
+
+	purgeAt := DEFAULT_TRASH_PURGE_AT
+	if utils.CheckStringNotEmpty(os.Getenv(TRASH_PURGE_AT_ENV)) {
+		purgeAt = os.Getenv(TRASH_PURGE_AT_ENV)
+	}
+
+	scheduler := gocron.NewScheduler(time.UTC)
+	_, err := scheduler.Every(1).Day().At(purgeAt).Do(func() {
+		deletedBefore := utils.NowUTC().AddDate(0, 0, -int(retentionDays))
+		purgedCounts, err := PurgeTrash(context.Background(), dataStore, deletedBefore)
+		if err != nil {
+			fmt.Println("Trash purge error:", err)
+			return
+		}
+		fmt.Println("Trash purge of the documents deleted before", deletedBefore.Format(time.RFC3339), "Purged:", purgedCounts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", TRASH_PURGE_AT_ENV, err)
+	}
+
+	scheduler.StartAsync()
+	return scheduler, nil
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/store"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestPurgeTrashDeletesOnlyTheExpiredDocuments(t *testing.T) {
+	dataStore := store.NewMemoryStore()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	licenses := dataStore.Collection(db.DB_TABLE_LICENCES)
+	for _, deletedAt := range []interface{}{nil, now.AddDate(0, 0, -40), now.AddDate(0, 0, -5)} {
+		if _, err := licenses.InsertOne(context.TODO(), bson.M{"licenseKey": "key", "deletedAt": deletedAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dataStore.Collection(db.DB_TABLE_USERS).InsertOne(context.TODO(), bson.M{"email": "old@example.com", "deletedAt": now.AddDate(-1, 0, 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	purgedCounts, err := PurgeTrash(context.TODO(), dataStore, now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purgedCounts[db.DB_TABLE_LICENCES] != 1 || purgedCounts[db.DB_TABLE_USERS] != 1 || purgedCounts[db.DB_TABLE_LICENSES_CATEGORIES] != 0 {
+		t.Fatalf("unexpected purged counts %v", purgedCounts)
+	}
+
+	// The live license and the recently deleted license remain
+	remaining, err := licenses.CountDocuments(context.TODO(), bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 2 {
+		t.Fatalf("expected 2 remaining licenses, got %d", remaining)
+	}
+}
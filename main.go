@@ -1,8 +1,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/controllers"
 	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/jobs"
+	"go-essentials/go-mongodb-rest-api/migrations"
 	"go-essentials/go-mongodb-rest-api/routes"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,13 +20,66 @@ import (
 func main() {
 
 	// Create and initialize the MongoDB database
-	db.InitDB()
+	// The handlers use the database through the data store
+	mongoClient := db.InitDB()
+	database := mongoClient.Database(db.DB_NAME)
+	dataStore := store.NewMongoStore(database)
+
+	// MIGRATIONS ----------------------------
+	// ---------------------------------------
+	migrator := migrations.NewMigrator(database)
+
+	// Subcommand: go run main.go migrate up | down [steps] | status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrations.RunCommand(context.Background(), migrator, os.Args[2:], os.Stdout)
+		if err != nil {
+			fmt.Println("Migration error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Apply the pending migrations on start (AUTO_MIGRATE=false to run them only with the subcommand)
+	if os.Getenv("AUTO_MIGRATE") != "false" {
+		_, err := migrator.Up(context.Background())
+		if err != nil {
+			panic("Could not apply the database migrations: " + err.Error())
+		}
+	}
+
+	// Load the JWT signing keys and settings
+	err := utils.InitJWTKeys()
+	if err != nil {
+		panic("Could not load the JWT signing keys: " + err.Error())
+	}
+	err = utils.InitJWTSettings()
+	if err != nil {
+		panic("Could not load the JWT settings: " + err.Error())
+	}
+
+	// Load the OpenID Connect login providers
+	err = utils.InitOIDCProviders()
+	if err != nil {
+		panic("Could not load the OIDC providers: " + err.Error())
+	}
+
+	// Check the delete policies of the references
+	err = controllers.CheckDeletePolicies()
+	if err != nil {
+		panic("Could not load the delete policies: " + err.Error())
+	}
+
+	// Daily purge of the soft deleted documents after the retention days
+	_, err = jobs.StartTrashPurge(dataStore)
+	if err != nil {
+		panic("Could not start the purge of the trash: " + err.Error())
+	}
 
 	// Create and initialize the pre configured SERVER
 	server := gin.Default()
 
 	// Routing and Handling
-	routes.RegisterRoutes(server)
+	routes.RegisterRoutes(server, dataStore)
 
 	// Start the server in order to listen for incoming requests
 	// localhost + :8082 (PORT) -> Development
@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Actions of the audit log entries
+const AUDIT_ACTION_CREATE = "create"
+const AUDIT_ACTION_UPDATE = "update"
+const AUDIT_ACTION_DELETE = "delete"
+const AUDIT_ACTION_DELETE_MULTIPLE = "deleteMultiple"
+const AUDIT_ACTION_DELETE_ALL = "deleteAll"
+const AUDIT_ACTION_BULK = "bulk"
+const AUDIT_ACTION_RENEW = "renew"
+const AUDIT_ACTION_UPGRADE = "upgrade"
+const AUDIT_ACTION_UPDATE_ACCESS = "updateAccess"
+const AUDIT_ACTION_RESTORE = "restore"
+const AUDIT_ACTION_REGISTER = "register"
+const AUDIT_ACTION_LOGIN = "login"
+
+// Fields of the request bodies that are never stored in the audit log (case insensitive parts of the names)
+var AUDIT_REDACTED_FIELDS = []string{"password", "secret", "token"}
+
+// Value of the redacted fields
+const AUDIT_REDACTED_VALUE = "[REDACTED]"
+
+// Max bytes of the request body that are kept for the audit log (the handlers still read the whole body)
+const AUDIT_MAX_REQUEST_BYTES = 1024 * 1024
+
+// Max bytes of the response that are read for the ids of the created documents
+const AUDIT_MAX_RESPONSE_BYTES = 64 * 1024
+
+// Target of an audited route: the collection and the action
+// An empty collection is read from the ':collection' path parameter (e.g. the trash routes)
+type AuditTarget struct {
+	Collection string
+	Action     string
+}
+
+// Response writer that keeps (the start of) the response for the audit log
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *auditResponseWriter) Write(data []byte) (int, error) {
+	if writer.body.Len() < AUDIT_MAX_RESPONSE_BYTES {
+		writer.body.Write(data)
+	}
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *auditResponseWriter) WriteString(data string) (int, error) {
+	if writer.body.Len() < AUDIT_MAX_RESPONSE_BYTES {
+		writer.body.WriteString(data)
+	}
+	return writer.ResponseWriter.WriteString(data)
+}
+
+// This method returns the key of a route in the audit targets, e.g. "DELETE /api/v1/licenses/:id"
+func AuditRouteKey(method string, fullPath string) string {
+	return method + " " + fullPath
+}
+
+// This method appends an entry to the audit log for every mutating request (POST, PUT, PATCH, DELETE)
+// The entry is written after the request, with the actor of Authenticate and the status of the response
+// Errors of the audit log do not change the (already sent) response
+func AuditLog(dataStore store.Store, targets map[string]AuditTarget) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// Only the mutating requests are audited
+		switch ctx.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			ctx.Next()
+			return
+		}
+
+		// Read the start of the request body and give the whole body back to the handlers
+		// The bodies larger than AUDIT_MAX_REQUEST_BYTES are not stored (only marked as truncated)
+		var requestBody []byte
+		bodyTruncated := false
+		if ctx.Request.Body != nil {
+			requestBody, _ = io.ReadAll(io.LimitReader(ctx.Request.Body, AUDIT_MAX_REQUEST_BYTES+1))
+			ctx.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(requestBody), ctx.Request.Body), ctx.Request.Body}
+			if len(requestBody) > AUDIT_MAX_REQUEST_BYTES {
+				requestBody, bodyTruncated = nil, true
+			}
+		}
+
+		// Keep the response for the ids of the created documents
+		writer := &auditResponseWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+
+		ctx.Next()
+
+		entry := newAuditLogEntry(ctx, targets, requestBody, writer.body.Bytes())
+		entry.RequestBodyTruncated = bodyTruncated
+		if _, err := dataStore.Collection(db.DB_TABLE_AUDIT_LOG).InsertOne(context.TODO(), entry); err != nil {
+			fmt.Println("Error storing the audit log entry:", err)
+		}
+	}
+}
+
+// Private
+// This method creates the audit log entry of the finished request
+func newAuditLogEntry(ctx *gin.Context, targets map[string]AuditTarget, requestBody []byte, responseBody []byte) models.AuditLogEntry {
+	route := ctx.FullPath()
+	if route == "" {
+		route = ctx.Request.URL.Path
+	}
+
+	// Target collection and action of the route
+	target, found := targets[AuditRouteKey(ctx.Request.Method, route)]
+	if !found {
+		target.Action = map[string]string{
+			http.MethodPost:   AUDIT_ACTION_CREATE,
+			http.MethodPut:    AUDIT_ACTION_UPDATE,
+			http.MethodPatch:  AUDIT_ACTION_UPDATE,
+			http.MethodDelete: AUDIT_ACTION_DELETE,
+		}[ctx.Request.Method]
+	}
+	if target.Collection == "" {
+		target.Collection = ctx.Param("collection")
+	}
+
+	entry := models.AuditLogEntry{
+		IP:          ctx.ClientIP(),
+		Method:      ctx.Request.Method,
+		Route:       route,
+		Path:        ctx.Request.URL.Path,
+		Query:       ctx.Request.URL.RawQuery,
+		Collection:  target.Collection,
+		Action:      target.Action,
+		DocumentIDs: auditDocumentIDs(ctx.Param("id"), requestBody, responseBody),
+		Status:      ctx.Writer.Status(),
+		CreatedDt:   utils.NowUTC(),
+	}
+	entry.RequestBody, entry.RequestItems = redactRequestBody(requestBody)
+
+	// Actor of the authenticated requests
+	if principal, found := GetPrincipal(ctx); found {
+		entry.ActorID = principal.UserID
+		entry.ActorEmail = principal.Email
+		entry.ActorRoles = principal.Roles
+	}
+	return entry
+}
+
+// Private
+// This method returns the ids of the target documents: the id of the path, the listOfIds of the body
+// and the ids of the documents in the response data (e.g. the created documents)
+func auditDocumentIDs(pathID string, requestBody []byte, responseBody []byte) []string {
+	documentIDs := []string{}
+	addID := func(id interface{}) {
+		idString, ok := id.(string)
+		if !ok || !utils.CheckStringNotEmpty(idString) {
+			return
+		}
+		for _, existentID := range documentIDs {
+			if existentID == idString {
+				return
+			}
+		}
+		documentIDs = append(documentIDs, idString)
+	}
+
+	addID(pathID)
+
+	var requestData struct {
+		ListOfIds []interface{} `json:"listOfIds"`
+	}
+	if json.Unmarshal(requestBody, &requestData) == nil {
+		for _, id := range requestData.ListOfIds {
+			addID(id)
+		}
+	}
+
+	var responseData struct {
+		Data interface{} `json:"data"`
+	}
+	if json.Unmarshal(responseBody, &responseData) == nil {
+		if documents, ok := responseData.Data.([]interface{}); ok {
+			for _, document := range documents {
+				if fields, ok := document.(map[string]interface{}); ok {
+					addID(fields["_id"])
+				}
+			}
+		}
+	}
+	return documentIDs
+}
+
+// Private
+// This method decodes the request body and redacts the secrets (e.g. passwords) at any depth
+// Output: the JSON object body or the items of the JSON array body (e.g. JSON Patch), nil for an empty or not JSON body
+func redactRequestBody(requestBody []byte) (map[string]interface{}, []map[string]interface{}) {
+	var body interface{}
+	if len(bytes.TrimSpace(requestBody)) == 0 || json.Unmarshal(requestBody, &body) != nil {
+		return nil, nil
+	}
+
+	switch typedBody := redactValue(body).(type) {
+	case map[string]interface{}:
+		return typedBody, nil
+	case []interface{}:
+		items := make([]map[string]interface{}, len(typedBody))
+		for position, item := range typedBody {
+			fields, ok := item.(map[string]interface{})
+			if !ok {
+				fields = map[string]interface{}{"value": item}
+			}
+
+			// The values of the JSON Patch operations on the secrets (e.g. "path": "/password")
+			if path, isPath := fields["path"].(string); isPath && isRedactedField(path) {
+				if _, found := fields["value"]; found {
+					fields["value"] = AUDIT_REDACTED_VALUE
+				}
+			}
+			items[position] = fields
+		}
+		return nil, items
+	}
+	return nil, nil
+}
+
+// Private
+// This method redacts the secret fields of the decoded JSON value
+func redactValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, fieldValue := range typedValue {
+			if isRedactedField(key) {
+				typedValue[key] = AUDIT_REDACTED_VALUE
+			} else {
+				typedValue[key] = redactValue(fieldValue)
+			}
+		}
+	case []interface{}:
+		for position, item := range typedValue {
+			typedValue[position] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// Private
+// This method checks if the field is a secret of the audit log
+func isRedactedField(name string) bool {
+	name = strings.ToLower(name)
+	for _, redactedField := range AUDIT_REDACTED_FIELDS {
+		if strings.Contains(name, redactedField) {
+			return true
+		}
+	}
+	return false
+}
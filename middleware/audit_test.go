@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestAuditLogOfMutatingRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataStore := store.NewMemoryStore()
+	targets := map[string]AuditTarget{
+		AuditRouteKey(http.MethodPost, "/users/:id"): {Collection: db.DB_TABLE_USERS, Action: AUDIT_ACTION_UPDATE},
+	}
+
+	server := gin.New()
+	server.Use(AuditLog(dataStore, targets))
+	handler := func(ctx *gin.Context) {
+		SetPrincipal(ctx, NewPrincipal(models.User{ID: "65a0f6189894531666000001", Email: "admin@example.com", Role: "admin", IsAdmin: true}, "password", "jti"))
+
+		// The handler still reads the whole body
+		body, _ := io.ReadAll(ctx.Request.Body)
+		ctx.JSON(http.StatusCreated, gin.H{"data": []gin.H{{"_id": "65a0f6189894531666000003"}}, "length": len(body)})
+	}
+	server.POST("/users/:id", handler)
+	server.GET("/users/:id", handler)
+
+	requestBody := `{"email": "example@gmail.com", "password": "test123", "credentials": {"clientSecret": "s3cr3t"}, "listOfIds": ["65a0f6189894531666000002"]}`
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		request := httptest.NewRequest(method, "/users/65a0f6189894531666000001?hard=true", strings.NewReader(requestBody))
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		if !strings.Contains(recorder.Body.String(), `"length":`) || strings.Contains(recorder.Body.String(), `"length":0`) {
+			t.Fatalf("the handler did not read the body: %s", recorder.Body.String())
+		}
+	}
+
+	// Only the mutating request is audited
+	entries := []models.AuditLogEntry{}
+	if err := dataStore.Collection(db.DB_TABLE_AUDIT_LOG).Find(context.TODO(), bson.M{}, nil, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit log entry, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	expectedIDs := []string{"65a0f6189894531666000001", "65a0f6189894531666000002", "65a0f6189894531666000003"}
+	if entry.ActorEmail != "admin@example.com" || entry.Action != AUDIT_ACTION_UPDATE || entry.Collection != db.DB_TABLE_USERS ||
+		entry.Route != "/users/:id" || entry.Query != "hard=true" || entry.Status != http.StatusCreated || !reflect.DeepEqual(entry.DocumentIDs, expectedIDs) {
+		t.Fatalf("unexpected audit log entry %+v", entry)
+	}
+
+	credentials, _ := entry.RequestBody["credentials"].(map[string]interface{})
+	if entry.RequestBody["password"] != AUDIT_REDACTED_VALUE || credentials["clientSecret"] != AUDIT_REDACTED_VALUE || entry.RequestBody["email"] != "example@gmail.com" {
+		t.Fatalf("the secrets are not redacted: %v", entry.RequestBody)
+	}
+}
+
+func TestAuditLogOfArrayAndLargeBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataStore := store.NewMemoryStore()
+
+	server := gin.New()
+	server.Use(AuditLog(dataStore, map[string]AuditTarget{}))
+	server.PATCH("/licenses/:id", func(ctx *gin.Context) {
+		body, _ := io.ReadAll(ctx.Request.Body)
+		ctx.JSON(http.StatusOK, gin.H{"length": len(body)})
+	})
+
+	// JSON Patch body (array) with a secret, and a body larger than the audit log keeps
+	patchBody := `[{"op": "replace", "path": "/comments", "value": "x"}, {"op": "test", "path": "/password", "value": "s3cr3t", "token": "t"}, 5]`
+	largeBody := `{"comments": "` + strings.Repeat("a", AUDIT_MAX_REQUEST_BYTES) + `"}`
+	for _, requestBody := range []string{patchBody, largeBody} {
+		request := httptest.NewRequest(http.MethodPatch, "/licenses/65a0f6189894531666000001", strings.NewReader(requestBody))
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		// The handlers always read the whole body
+		if expected := `{"length":` + strconv.Itoa(len(requestBody)) + `}`; recorder.Body.String() != expected {
+			t.Fatalf("expected %s, got %s", expected, recorder.Body.String())
+		}
+	}
+
+	entries := []models.AuditLogEntry{}
+	if err := dataStore.Collection(db.DB_TABLE_AUDIT_LOG).Find(context.TODO(), bson.M{}, nil, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit log entries, got %d", len(entries))
+	}
+
+	expectedItems := []map[string]interface{}{
+		{"op": "replace", "path": "/comments", "value": "x"},
+		{"op": "test", "path": "/password", "value": AUDIT_REDACTED_VALUE, "token": AUDIT_REDACTED_VALUE},
+		{"value": float64(5)},
+	}
+	if entries[0].RequestBody != nil || !reflect.DeepEqual(entries[0].RequestItems, expectedItems) || entries[0].RequestBodyTruncated {
+		t.Fatalf("unexpected request items %+v", entries[0])
+	}
+	if entries[1].RequestBody != nil || entries[1].RequestItems != nil || !entries[1].RequestBodyTruncated {
+		t.Fatalf("expected a truncated request body, got %+v", entries[1])
+	}
+}
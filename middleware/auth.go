@@ -2,78 +2,85 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"go-essentials/go-mongodb-rest-api/db"
 	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
 	"go-essentials/go-mongodb-rest-api/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/mgo.v2/bson"
 )
 
 // This method authenticates the token and the user
-func Authenticate(ctx *gin.Context) {
+func Authenticate(dataStore store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 
-	// Extract the token and check authorization
-	token := ctx.Request.Header.Get("Authorization")
-	if token == "" {
+		// Extract the bearer token and check authorization
+		token, err := utils.ExtractBearerToken(ctx.Request.Header.Get("Authorization"))
+		if err != nil {
 
-		// Not existent token
-		// ABORT NOW the current request
-		utils.HandleError(ctx, http.StatusUnauthorized, "Unauthorized user.", errors.New("not authorized user").Error())
-		return
-	}
+			// Not existent or malformed token
+			// ABORT NOW the current request
+			ctx.Header("WWW-Authenticate", `Bearer realm="`+utils.JWTConfig.Audience+`"`)
+			utils.HandleError(ctx, http.StatusUnauthorized, "Unauthorized user.", errors.New("not authorized user").Error())
+			return
+		}
 
-	// Check the received token
-	userID, err := utils.VerifyToken(token)
-	if err != nil {
-		// ABORT NOW the current request
-		utils.HandleError(ctx, http.StatusUnauthorized, "Unauthorized user.", errors.New("not authorized user").Error())
-		return
-	}
+		// Check the received token
+		claims, err := utils.VerifyToken(token)
+		if err != nil {
+			// ABORT NOW the current request
+			ctx.Header("WWW-Authenticate", `Bearer realm="`+utils.JWTConfig.Audience+`", error="invalid_token"`)
+			utils.HandleError(ctx, http.StatusUnauthorized, "Unauthorized user.", errors.New("not authorized user").Error())
+			return
+		}
 
-	fmt.Println("User ID: ", userID)
+		userID := claims.UserID
+		fmt.Println("User ID: ", userID)
 
-	// String id not object id
-	oID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
-		return
-	}
+		// String id not object id
+		oID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "cannot convert hex id to bson id.", errors.New("cannot convert hex id to bson id").Error())
+			return
+		}
 
-	// Get the type to retrieve
-	var userRetrieve models.User
+		// Get the type to retrieve
+		var userRetrieve models.User
 
-	// Check user existence in the database and find the user by id
-	// Retrieve a specific document
-	collection := db.MongoClient.Database(db.DB_NAME).Collection(db.DB_TABLE_USERS)
+		// Check user existence in the database and find the user by id
+		// Retrieve a specific document
+		collection := dataStore.Collection(db.DB_TABLE_USERS)
 
-	// Remove password from the documents if is is the "users" collection
-	removePasswordOption := bson.M{"password": 0}
-	err = collection.FindOne(context.TODO(), bson.M{"_id": oID}, options.FindOne().SetProjection(removePasswordOption)).Decode(&userRetrieve)
+		// Remove password from the documents if is is the "users" collection
+		// The soft deleted users are not allowed to use the API
+		removePasswordOption := bson.M{"password": 0}
+		err = collection.FindOne(context.TODO(), utils.NotDeleted(bson.M{"_id": oID}), &store.FindOptions{Projection: removePasswordOption}, &userRetrieve)
 
-	// Check the error
-	if err != nil {
-		utils.HandleError(ctx, http.StatusNotFound, "cannot retrieve specific user.", err.Error())
-		return
-	}
+		// Check the error
+		if err != nil {
+			utils.HandleError(ctx, http.StatusNotFound, "cannot retrieve specific user.", err.Error())
+			return
+		}
 
-	// Add the user id to the request context for NEXT method
-	ctx.Set("userId", userID)
+		// Inactive users are not allowed to use the API
+		if !userRetrieve.IsActive {
+			utils.HandleError(ctx, http.StatusUnauthorized, "Inactive user.", errors.New("not active user").Error())
+			return
+		}
 
-	// Add all the user data to the request context
-	jsonData, err := json.Marshal(userRetrieve)
-	if err != nil {
-		utils.HandleError(ctx, http.StatusNotFound, "Error converting to JSON the user data.", err.Error())
-		return
-	}
-	ctx.Set("user", string(jsonData))
+		// Add the typed principal to the request context for NEXT method
+		authMethod := claims.AuthMethod
+		if !utils.CheckStringNotEmpty(authMethod) {
+			authMethod = utils.AUTH_METHOD_PASSWORD
+		}
+		SetPrincipal(ctx, NewPrincipal(userRetrieve, authMethod, claims.Id))
 
-	// Call the NEXT function to continue with the request
-	ctx.Next()
+		// Call the NEXT function to continue with the request
+		ctx.Next()
+	}
 }
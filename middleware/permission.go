@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// This method returns the key of a route in the permissions of the routes, e.g. "DELETE /api/v1/licenses/:id"
+func PermissionRouteKey(method string, fullPath string) string {
+	return method + " " + fullPath
+}
+
+// This method is a MIDDLEWARE and checks that the principal of the request has every permission of the route
+// (see ROLE_PERMISSIONS), after Authenticate. The routes without permissions are not checked
+func RequirePermissions(routePermissions map[string][]string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// Permissions of the route
+		permissions := routePermissions[PermissionRouteKey(ctx.Request.Method, ctx.FullPath())]
+		if len(permissions) == 0 {
+			ctx.Next()
+			return
+		}
+
+		// Retrieve the principal from the context request
+		principal, found := GetPrincipal(ctx)
+		if !found {
+			utils.HandleError(ctx, http.StatusUnauthorized, "Unauthorized user.", errors.New("not authorized user").Error())
+			return
+		}
+
+		for _, permission := range permissions {
+			if !principal.HasPermission(permission) {
+				utils.HandleError(ctx, http.StatusForbidden, "The user has no permission for this request.", errors.New("missing permission "+permission).Error())
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
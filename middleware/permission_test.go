@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"go-essentials/go-mongodb-rest-api/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequirePermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routePermissions := map[string][]string{
+		PermissionRouteKey(http.MethodDelete, "/licenses/:id"): {PERMISSION_DELETE_DOCUMENTS},
+	}
+
+	testCases := []struct {
+		name     string
+		method   string
+		user     *models.User
+		expected int
+	}{
+		{"admin", http.MethodDelete, &models.User{Role: "admin", IsAdmin: true}, http.StatusOK},
+		{"user without the permission", http.MethodDelete, &models.User{Role: "user"}, http.StatusForbidden},
+		{"route without permissions", http.MethodGet, &models.User{Role: "user"}, http.StatusOK},
+		{"without principal", http.MethodDelete, nil, http.StatusUnauthorized},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				if testCase.user != nil {
+					SetPrincipal(ctx, NewPrincipal(*testCase.user, "password", "jti"))
+				}
+			}, RequirePermissions(routePermissions))
+			handler := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+			server.DELETE("/licenses/:id", handler)
+			server.GET("/licenses/:id", handler)
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(testCase.method, "/licenses/65a8d750f00cf0007816e841", nil))
+			if recorder.Code != testCase.expected {
+				t.Errorf("expected status %d, got %d", testCase.expected, recorder.Code)
+			}
+		})
+	}
+}
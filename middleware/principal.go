@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"go-essentials/go-mongodb-rest-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Key of the authenticated principal in the request context
+const PRINCIPAL_CONTEXT_KEY = "principal"
+
+// Permissions
+const PERMISSION_READ_DOCUMENTS = "documents:read"
+const PERMISSION_UPDATE_DOCUMENTS = "documents:update"
+const PERMISSION_DELETE_OWN_DOCUMENTS = "documents:deleteOwn"
+const PERMISSION_CREATE_LICENSES = "licenses:create"
+const PERMISSION_CREATE_DOCUMENTS = "documents:create"
+const PERMISSION_DELETE_DOCUMENTS = "documents:delete"
+const PERMISSION_MANAGE_USERS = "users:manage"
+
+// Roles of every user role (higher roles include the lower roles)
+var ROLE_HIERARCHY = map[string][]string{
+	"user":       {"user"},
+	"admin":      {"admin", "user"},
+	"superadmin": {"superadmin", "admin", "user"},
+}
+
+// Permissions of every role
+var ROLE_PERMISSIONS = map[string][]string{
+	"user":       {PERMISSION_READ_DOCUMENTS, PERMISSION_UPDATE_DOCUMENTS, PERMISSION_DELETE_OWN_DOCUMENTS, PERMISSION_CREATE_LICENSES},
+	"admin":      {PERMISSION_CREATE_DOCUMENTS, PERMISSION_DELETE_DOCUMENTS, PERMISSION_MANAGE_USERS},
+	"superadmin": {},
+}
+
+// Authenticated user of the request
+type Principal struct {
+	UserID      string
+	Email       string
+	Roles       []string
+	Permissions []string
+	AuthMethod  string
+	TokenID     string
+	User        models.User
+}
+
+// This method creates the principal of the given user
+// The admin roles are only given to the users with the isAdmin flag
+func NewPrincipal(user models.User, authMethod string, tokenID string) *Principal {
+
+	// Effective role of the user
+	role := user.Role
+	if !user.IsAdmin || ROLE_HIERARCHY[role] == nil {
+		role = "user"
+	}
+
+	// Roles and permissions
+	roles := ROLE_HIERARCHY[role]
+	permissions := []string{}
+	for _, specRole := range roles {
+		permissions = append(permissions, ROLE_PERMISSIONS[specRole]...)
+	}
+
+	return &Principal{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Roles:       roles,
+		Permissions: permissions,
+		AuthMethod:  authMethod,
+		TokenID:     tokenID,
+		User:        user,
+	}
+}
+
+// This method checks if the principal has the given role
+func (principal *Principal) HasRole(role string) bool {
+	for _, specRole := range principal.Roles {
+		if specRole == role {
+			return true
+		}
+	}
+	return false
+}
+
+// This method checks if the principal has the given permission
+func (principal *Principal) HasPermission(permission string) bool {
+	for _, specPermission := range principal.Permissions {
+		if specPermission == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// This method checks if the principal is an ADMIN or SUPERADMIN user
+func (principal *Principal) IsAdmin() bool {
+	return principal.HasRole("admin")
+}
+
+// This method checks if the principal is a SUPERADMIN user
+func (principal *Principal) IsSuperAdmin() bool {
+	return principal.HasRole("superadmin")
+}
+
+// This method adds the principal to the request context
+func SetPrincipal(ctx *gin.Context, principal *Principal) {
+	ctx.Set(PRINCIPAL_CONTEXT_KEY, principal)
+}
+
+// This method returns the principal of the request context
+func GetPrincipal(ctx *gin.Context) (*Principal, bool) {
+	value, found := ctx.Get(PRINCIPAL_CONTEXT_KEY)
+	if !found {
+		return nil, false
+	}
+
+	principal, ok := value.(*Principal)
+	return principal, ok && principal != nil
+}
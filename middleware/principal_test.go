@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"go-essentials/go-mongodb-rest-api/models"
+	"testing"
+)
+
+func TestNewPrincipalRolesAndPermissions(t *testing.T) {
+	testCases := []struct {
+		name         string
+		user         models.User
+		isAdmin      bool
+		isSuperAdmin bool
+	}{
+		{"normal user", models.User{Role: "user", IsAdmin: false}, false, false},
+		{"admin", models.User{Role: "admin", IsAdmin: true}, true, false},
+		{"superadmin", models.User{Role: "superadmin", IsAdmin: true}, true, true},
+		{"admin role without the isAdmin flag", models.User{Role: "admin", IsAdmin: false}, false, false},
+		{"unknown role", models.User{Role: "root", IsAdmin: true}, false, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			principal := NewPrincipal(testCase.user, "password", "jti")
+			if principal.IsAdmin() != testCase.isAdmin || principal.IsSuperAdmin() != testCase.isSuperAdmin {
+				t.Fatalf("unexpected roles %v", principal.Roles)
+			}
+			if !principal.HasRole("user") || !principal.HasPermission(PERMISSION_READ_DOCUMENTS) {
+				t.Fatalf("every principal is a user: %v %v", principal.Roles, principal.Permissions)
+			}
+			if principal.HasPermission(PERMISSION_DELETE_DOCUMENTS) != testCase.isAdmin {
+				t.Fatalf("unexpected permissions %v", principal.Permissions)
+			}
+		})
+	}
+}
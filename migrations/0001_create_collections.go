@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 0001: The collections with their JSON schema validators and unique indexes
+// Existent deployments (created before the migrations) get the validators of this version with collMod
+var createCollectionsMigration = Migration{
+	Version: 1,
+	Name:    "create_collections",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		collections := []struct {
+			name        string
+			uniqueField string
+		}{
+			{db.DB_TABLE_USERS, "email"},
+			{db.DB_TABLE_LICENSES_CATEGORIES, "categoryType"},
+			{db.DB_TABLE_LICENCES, "licenseKey"},
+		}
+
+		for _, collection := range collections {
+
+			// The schema as of this version, the later migrations apply their own changes
+			jsonSchema, err := SchemaAt(1, collection.name)
+			if err != nil {
+				return err
+			}
+
+			err = EnsureCollection(ctx, database, collection.name, jsonSchema)
+			if err != nil {
+				return err
+			}
+
+			index := mongo.IndexModel{
+				Keys:    bson.D{{Key: collection.uniqueField, Value: 1}},
+				Options: options.Index().SetUnique(true),
+			}
+			if err = CreateIndex(ctx, database, collection.name, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+
+		// The collections and their documents are kept, only the validators and the indexes are removed
+		indexes := map[string]string{
+			db.DB_TABLE_USERS:               "email_1",
+			db.DB_TABLE_LICENSES_CATEGORIES: "categoryType_1",
+			db.DB_TABLE_LICENCES:            "licenseKey_1",
+		}
+
+		for collectionName, indexName := range indexes {
+			err := DropIndex(ctx, database, collectionName, indexName)
+			if err != nil {
+				return err
+			}
+
+			err = SetValidator(ctx, database, collectionName, nil)
+			if err != nil && !hasErrorCode(err, NAMESPACE_NOT_FOUND_CODE) {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 0002: Indexes for the license lookups per user (create license) and per category (count per category)
+var licenseLookupIndexesMigration = Migration{
+	Version: 2,
+	Name:    "license_lookup_indexes",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		for _, field := range []string{"userHolderId", "categoryId"} {
+			index := mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}}
+			if err := CreateIndex(ctx, database, db.DB_TABLE_LICENCES, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		for _, indexName := range []string{"userHolderId_1", "categoryId_1"} {
+			if err := DropIndex(ctx, database, db.DB_TABLE_LICENCES, indexName); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
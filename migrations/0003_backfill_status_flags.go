@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 0003: Backfill of the status flags that the schemas require
+// Documents stored before the validators (or with validation turned off) may miss them
+var backfillStatusFlagsMigration = Migration{
+	Version: 3,
+	Name:    "backfill_status_flags",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		backfills := []struct {
+			collectionName string
+			field          string
+			value          string
+		}{
+			{db.DB_TABLE_USERS, "isActive", "1"},
+			{db.DB_TABLE_USERS, "isAdmin", "0"},
+			{db.DB_TABLE_LICENSES_CATEGORIES, "isActive", "1"},
+			{db.DB_TABLE_LICENCES, "isActive", "1"},
+			{db.DB_TABLE_LICENCES, "isExpired", "0"},
+		}
+
+		for _, backfill := range backfills {
+			filter := bson.M{backfill.field: bson.M{"$exists": false}}
+			update := bson.M{"$set": bson.M{backfill.field: backfill.value}}
+			_, err := database.Collection(backfill.collectionName).UpdateMany(ctx, filter, update)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+
+		// The backfilled values cannot be told apart from the stored ones, nothing to revert
+		return nil
+	},
+}
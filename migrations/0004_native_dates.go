@@ -0,0 +1,189 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Time zone of the legacy "YYYY-MM-DD HH:MM:SS" dates (the local time of the server that stored them)
+// Any IANA name, e.g. "Europe/Athens". Default: the local time zone
+const LEGACY_DATES_TIMEZONE_ENV = "LEGACY_DATES_TIMEZONE"
+
+// Date fields per collection (dotted paths go through the arrays)
+var NATIVE_DATE_FIELDS = map[string][]string{
+	db.DB_TABLE_USERS:               {"createdDt", "lastUpdatedDt", "externalIdentities.linkedDt"},
+	db.DB_TABLE_LICENSES_CATEGORIES: {"createdDt", "lastUpdatedDt"},
+	db.DB_TABLE_LICENCES:            {"begin_dt", "expiration_dt", "createdDt", "lastUpdatedDt"},
+}
+
+// 0004: Native BSON dates (UTC) instead of "YYYY-MM-DD HH:MM:SS" strings
+var nativeDatesMigration = Migration{
+	Version: 4,
+	Name:    "native_dates",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		location, err := legacyDatesLocation()
+		if err != nil {
+			return err
+		}
+		return convertDateFields(ctx, database, 4, "string", func(value interface{}) (interface{}, error) {
+			return legacyStringToDate(value, location)
+		})
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		location, err := legacyDatesLocation()
+		if err != nil {
+			return err
+		}
+		return convertDateFields(ctx, database, 3, "date", func(value interface{}) (interface{}, error) {
+			return dateToLegacyString(value, location), nil
+		})
+	},
+}
+
+// Private
+// This method converts the date fields of all the collections and applies the JSON schemas of the target version
+// Only the documents with a field of the source BSON type are updated, so the conversion can run again
+func convertDateFields(ctx context.Context, database *mongo.Database, targetVersion int, sourceType string, convert func(interface{}) (interface{}, error)) error {
+	for collectionName, fields := range NATIVE_DATE_FIELDS {
+		err := ConvertCollection(ctx, database, collectionName, targetVersion, func(collection *mongo.Collection) error {
+			for _, field := range fields {
+				topField := strings.Split(field, ".")[0]
+				filter := bson.M{field: bson.M{"$type": sourceType}}
+
+				rows, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{topField: 1}))
+				if err != nil {
+					return err
+				}
+
+				documents := []bson.M{}
+				if err = rows.All(ctx, &documents); err != nil {
+					return err
+				}
+
+				for _, document := range documents {
+					converted, err := convertPath(document[topField], strings.Split(field, ".")[1:], convert)
+					if err != nil {
+						return fmt.Errorf("%s %v %s: %w", collectionName, document["_id"], field, err)
+					}
+
+					update := bson.M{"$set": bson.M{topField: converted}}
+					if _, err = collection.UpdateOne(ctx, bson.M{"_id": document["_id"]}, update); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Private
+// This method converts the value at the given path (remaining parts) of the value, through arrays and documents
+func convertPath(value interface{}, parts []string, convert func(interface{}) (interface{}, error)) (interface{}, error) {
+	if len(parts) == 0 {
+		return convert(value)
+	}
+
+	switch typedValue := value.(type) {
+	case bson.A:
+		converted := bson.A{}
+		for _, item := range typedValue {
+			convertedItem, err := convertPath(item, parts, convert)
+			if err != nil {
+				return nil, err
+			}
+			converted = append(converted, convertedItem)
+		}
+		return converted, nil
+
+	case bson.M:
+		if _, found := typedValue[parts[0]]; !found {
+			return typedValue, nil
+		}
+		convertedField, err := convertPath(typedValue[parts[0]], parts[1:], convert)
+		if err != nil {
+			return nil, err
+		}
+		typedValue[parts[0]] = convertedField
+		return typedValue, nil
+
+	default:
+		return value, nil
+	}
+}
+
+// Private
+// This method parses a legacy date string in the given location, other values are returned as they are
+func legacyStringToDate(value interface{}, location *time.Location) (interface{}, error) {
+	dateString, isString := value.(string)
+	if !isString {
+		return value, nil
+	}
+
+	date, err := time.ParseInLocation(utils.LEGACY_DATE_LAYOUT, strings.TrimSpace(dateString), location)
+	if err != nil {
+		// Dates that were already stored in an accepted layout (e.g. RFC 3339)
+		if date, err = utils.ParseDate(dateString); err != nil {
+			return nil, err
+		}
+	}
+	return date.UTC(), nil
+}
+
+// Private
+// This method formats a date as a legacy date string in the given location, other values are returned as they are
+func dateToLegacyString(value interface{}, location *time.Location) interface{} {
+	switch date := value.(type) {
+	case primitive.DateTime:
+		return date.Time().In(location).Format(utils.LEGACY_DATE_LAYOUT)
+	case time.Time:
+		return date.In(location).Format(utils.LEGACY_DATE_LAYOUT)
+	default:
+		return value
+	}
+}
+
+// Private
+// This method returns the time zone of the legacy date strings
+func legacyDatesLocation() (*time.Location, error) {
+	name := os.Getenv(LEGACY_DATES_TIMEZONE_ENV)
+	if name == "" {
+		return time.Local, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", LEGACY_DATES_TIMEZONE_ENV, err)
+	}
+	return location, nil
+}
+
+// Private
+// This method reverts the native dates on the JSON schema of the collection (dates as strings)
+func revertNativeDatesSchema(collectionName string, jsonSchema bson.M) error {
+	for _, field := range NATIVE_DATE_FIELDS[collectionName] {
+		property, err := schemaProperty(jsonSchema, field)
+		if err != nil {
+			return err
+		}
+		property["bsonType"] = "string"
+		if description, found := property["description"].(string); found {
+			property["description"] = strings.Replace(description, "must be a date (UTC)", "must be a string (yyyy-MM-dd HH:mm:ss)", 1)
+		}
+	}
+	return nil
+}
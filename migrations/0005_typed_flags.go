@@ -0,0 +1,121 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Boolean flags per collection (stored as "0" / "1" strings before this migration)
+var TYPED_FLAG_FIELDS = map[string][]string{
+	db.DB_TABLE_USERS:               {"isActive", "isAdmin"},
+	db.DB_TABLE_LICENSES_CATEGORIES: {"isActive"},
+	db.DB_TABLE_LICENCES:            {"isActive", "isExpired"},
+}
+
+// Collections with the integer category type (stored as a numeric string before this migration)
+var TYPED_CATEGORY_TYPE_COLLECTIONS = []string{db.DB_TABLE_LICENSES_CATEGORIES, db.DB_TABLE_LICENCES}
+
+// 0005: Boolean flags (isActive, isAdmin, isExpired) and integer category types instead of strings
+var typedFlagsMigration = Migration{
+	Version: 5,
+	Name:    "typed_flags",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		for collectionName, flags := range TYPED_FLAG_FIELDS {
+			err := ConvertCollection(ctx, database, collectionName, 5, func(collection *mongo.Collection) error {
+				for _, flag := range flags {
+					for value, legacyValues := range map[bool][]string{true: {"1", "true"}, false: {"0", "false", ""}} {
+						filter := bson.M{flag: bson.M{"$in": legacyValues}}
+						if _, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{flag: value}}); err != nil {
+							return err
+						}
+					}
+
+					// Any other string cannot be told true or false
+					count, err := collection.CountDocuments(ctx, bson.M{flag: bson.M{"$type": "string"}})
+					if err != nil {
+						return err
+					}
+					if count > 0 {
+						return fmt.Errorf("%s: %d documents with a %s that is not 0 or 1", collectionName, count, flag)
+					}
+				}
+
+				if !containsString(TYPED_CATEGORY_TYPE_COLLECTIONS, collectionName) {
+					return nil
+				}
+				return convertCategoryTypes(ctx, collection, "string", "$toLong")
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		for collectionName, flags := range TYPED_FLAG_FIELDS {
+			err := ConvertCollection(ctx, database, collectionName, 4, func(collection *mongo.Collection) error {
+				for _, flag := range flags {
+					for value, legacyValue := range map[bool]string{true: "1", false: "0"} {
+						filter := bson.M{flag: value}
+						if _, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{flag: legacyValue}}); err != nil {
+							return err
+						}
+					}
+				}
+
+				if !containsString(TYPED_CATEGORY_TYPE_COLLECTIONS, collectionName) {
+					return nil
+				}
+				return convertCategoryTypes(ctx, collection, "number", "$toString")
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// Private
+// This method converts the category types of the source BSON type with the conversion operator (pipeline update)
+func convertCategoryTypes(ctx context.Context, collection *mongo.Collection, sourceType string, operator string) error {
+	filter := bson.M{"categoryType": bson.M{"$type": sourceType}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{{Key: "categoryType", Value: bson.D{{Key: operator, Value: "$categoryType"}}}}}},
+	}
+	_, err := collection.UpdateMany(ctx, filter, pipeline)
+	return err
+}
+
+// Private
+// This method reverts the typed flags and category type on the JSON schema of the collection (strings)
+func revertTypedFlagsSchema(collectionName string, jsonSchema bson.M) error {
+	fields := append([]string{}, TYPED_FLAG_FIELDS[collectionName]...)
+	if containsString(TYPED_CATEGORY_TYPE_COLLECTIONS, collectionName) {
+		fields = append(fields, "categoryType")
+	}
+
+	for _, field := range fields {
+		property, err := schemaProperty(jsonSchema, field)
+		if err != nil {
+			return err
+		}
+		property["bsonType"] = "string"
+	}
+	return nil
+}
+
+// Private
+// This method checks if the list contains the value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
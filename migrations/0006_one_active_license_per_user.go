@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Name of the unique partial index with one active license per user
+const ONE_ACTIVE_LICENSE_INDEX = "one_active_license_per_user"
+
+// 0006: Unique partial index that allows one active license per user
+// The index has the isActive field in its keys, so it does not clash with the userHolderId_1 lookup index
+var oneActiveLicensePerUserMigration = Migration{
+	Version: 6,
+	Name:    "one_active_license_per_user",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+
+		// The users with more than one active license must be fixed by hand (the index cannot be built)
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"isActive": true}}},
+			{{Key: "$group", Value: bson.M{"_id": "$userHolderId", "count": bson.M{"$sum": 1}}}},
+			{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		}
+		rows, err := database.Collection(db.DB_TABLE_LICENCES).Aggregate(ctx, pipeline)
+		if err != nil {
+			return err
+		}
+
+		duplicates := []bson.M{}
+		if err = rows.All(ctx, &duplicates); err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			userIDs := []interface{}{}
+			for _, duplicate := range duplicates {
+				userIDs = append(userIDs, duplicate["_id"])
+			}
+			return fmt.Errorf("%d users have more than one active license, deactivate all but one first: %v", len(duplicates), userIDs)
+		}
+
+		return CreateIndex(ctx, database, db.DB_TABLE_LICENCES, oneActiveLicenseIndex(bson.M{"isActive": true}))
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		return DropIndex(ctx, database, db.DB_TABLE_LICENCES, ONE_ACTIVE_LICENSE_INDEX)
+	},
+}
+
+// Private
+// This method returns the unique index of the active licenses per user, with the given partial filter
+func oneActiveLicenseIndex(partialFilter bson.M) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys: bson.D{{Key: "userHolderId", Value: 1}, {Key: "isActive", Value: 1}},
+		Options: options.Index().
+			SetName(ONE_ACTIVE_LICENSE_INDEX).
+			SetUnique(true).
+			SetPartialFilterExpression(partialFilter),
+	}
+}
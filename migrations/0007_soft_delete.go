@@ -0,0 +1,101 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Name of the index of the soft deleted documents (trash and purge)
+const DELETED_AT_INDEX = "deletedAt_1"
+
+// 0007: Soft delete markers (deletedAt, deletedBy)
+// The documents that are not deleted have a null deletedAt, so the unique partial index of the active licenses
+// ignores the deleted licenses (the partial indexes support $type but not $exists: false)
+var softDeleteMigration = Migration{
+	Version: 7,
+	Name:    "soft_delete",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		for _, collectionName := range db.DB_TABLES_SOFT_DELETE {
+			err := ConvertCollection(ctx, database, collectionName, 7, func(collection *mongo.Collection) error {
+				_, err := collection.UpdateMany(ctx, bson.M{"deletedAt": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"deletedAt": nil}})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			index := mongo.IndexModel{Keys: bson.D{{Key: "deletedAt", Value: 1}}, Options: options.Index().SetName(DELETED_AT_INDEX)}
+			if err = CreateIndex(ctx, database, collectionName, index); err != nil {
+				return err
+			}
+		}
+
+		// The active licenses of the trash do not count
+		if err := DropIndex(ctx, database, db.DB_TABLE_LICENCES, ONE_ACTIVE_LICENSE_INDEX); err != nil {
+			return err
+		}
+		partialFilter := bson.M{"isActive": true, "deletedAt": bson.M{"$type": "null"}}
+		return CreateIndex(ctx, database, db.DB_TABLE_LICENCES, oneActiveLicenseIndex(partialFilter))
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+
+		// The deleted documents would be live documents again
+		for _, collectionName := range db.DB_TABLES_SOFT_DELETE {
+			count, err := database.Collection(collectionName).CountDocuments(ctx, bson.M{"deletedAt": bson.M{"$type": "date"}})
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%s: %d soft deleted documents, restore or purge them first", collectionName, count)
+			}
+		}
+
+		if err := DropIndex(ctx, database, db.DB_TABLE_LICENCES, ONE_ACTIVE_LICENSE_INDEX); err != nil {
+			return err
+		}
+		if err := CreateIndex(ctx, database, db.DB_TABLE_LICENCES, oneActiveLicenseIndex(bson.M{"isActive": true})); err != nil {
+			return err
+		}
+
+		for _, collectionName := range db.DB_TABLES_SOFT_DELETE {
+			if err := DropIndex(ctx, database, collectionName, DELETED_AT_INDEX); err != nil {
+				return err
+			}
+			err := ConvertCollection(ctx, database, collectionName, 6, func(collection *mongo.Collection) error {
+				_, err := collection.UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"deletedAt": "", "deletedBy": ""}})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// Private
+// This method reverts the soft delete markers on the JSON schema of the collection
+func revertSoftDeleteSchema(collectionName string, jsonSchema bson.M) error {
+	if !containsString(db.DB_TABLES_SOFT_DELETE, collectionName) {
+		return nil
+	}
+
+	properties, _ := jsonSchema["properties"].(bson.M)
+	delete(properties, "deletedAt")
+	delete(properties, "deletedBy")
+
+	required := bson.A{}
+	requiredFields, _ := jsonSchema["required"].(bson.A)
+	for _, field := range requiredFields {
+		if field != "deletedAt" {
+			required = append(required, field)
+		}
+	}
+	jsonSchema["required"] = required
+	return nil
+}
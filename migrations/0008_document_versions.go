@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 0008: Version of the documents (optimistic concurrency control with ETags)
+// The existent documents start from the first version
+var documentVersionsMigration = Migration{
+	Version: 8,
+	Name:    "document_versions",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		for _, collectionName := range db.DB_TABLES_SOFT_DELETE {
+			err := ConvertCollection(ctx, database, collectionName, 8, func(collection *mongo.Collection) error {
+				_, err := collection.UpdateMany(ctx, bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": int64(1)}})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		for _, collectionName := range db.DB_TABLES_SOFT_DELETE {
+			err := ConvertCollection(ctx, database, collectionName, 7, func(collection *mongo.Collection) error {
+				_, err := collection.UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"version": ""}})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// Private
+// This method reverts the version on the JSON schema of the collection
+func revertDocumentVersionsSchema(collectionName string, jsonSchema bson.M) error {
+	if !containsString(db.DB_TABLES_SOFT_DELETE, collectionName) {
+		return nil
+	}
+
+	properties, _ := jsonSchema["properties"].(bson.M)
+	delete(properties, "version")
+
+	required := bson.A{}
+	requiredFields, _ := jsonSchema["required"].(bson.A)
+	for _, field := range requiredFields {
+		if field != "version" {
+			required = append(required, field)
+		}
+	}
+	jsonSchema["required"] = required
+	return nil
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Names of the indexes of the audit log queries (actor, collection, action and time range)
+const AUDIT_LOG_CREATED_INDEX = "createdDt_-1"
+const AUDIT_LOG_ACTOR_INDEX = "actorId_1_createdDt_-1"
+const AUDIT_LOG_COLLECTION_INDEX = "collection_1_action_1_createdDt_-1"
+
+// 0009: Audit log (append-only) of the mutating requests
+var auditLogMigration = Migration{
+	Version: 9,
+	Name:    "audit_log",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		jsonSchema, err := SchemaAt(9, db.DB_TABLE_AUDIT_LOG)
+		if err != nil {
+			return err
+		}
+		if err = EnsureCollection(ctx, database, db.DB_TABLE_AUDIT_LOG, jsonSchema); err != nil {
+			return err
+		}
+
+		indexes := []mongo.IndexModel{
+			{Keys: bson.D{{Key: "createdDt", Value: -1}}, Options: options.Index().SetName(AUDIT_LOG_CREATED_INDEX)},
+			{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "createdDt", Value: -1}}, Options: options.Index().SetName(AUDIT_LOG_ACTOR_INDEX)},
+			{Keys: bson.D{{Key: "collection", Value: 1}, {Key: "action", Value: 1}, {Key: "createdDt", Value: -1}}, Options: options.Index().SetName(AUDIT_LOG_COLLECTION_INDEX)},
+		}
+		for _, index := range indexes {
+			if err = CreateIndex(ctx, database, db.DB_TABLE_AUDIT_LOG, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+
+		// The entries of the audit log are kept, only the validator and the indexes are removed
+		for _, indexName := range []string{AUDIT_LOG_CREATED_INDEX, AUDIT_LOG_ACTOR_INDEX, AUDIT_LOG_COLLECTION_INDEX} {
+			if err := DropIndex(ctx, database, db.DB_TABLE_AUDIT_LOG, indexName); err != nil {
+				return err
+			}
+		}
+
+		err := SetValidator(ctx, database, db.DB_TABLE_AUDIT_LOG, nil)
+		if err != nil && !hasErrorCode(err, NAMESPACE_NOT_FOUND_CODE) {
+			return err
+		}
+		return nil
+	},
+}
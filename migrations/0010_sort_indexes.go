@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Name of the index of the most recent first sort (getMostRecent and sort=-createdDt) with the _id tiebreaker
+const MOST_RECENT_SORT_INDEX = "createdDt_-1__id_-1"
+
+// 0010: Indexes of the default sorts of the list endpoints
+var sortIndexesMigration = Migration{
+	Version: 10,
+	Name:    "sort_indexes",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		index := mongo.IndexModel{
+			Keys:    bson.D{{Key: "createdDt", Value: -1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName(MOST_RECENT_SORT_INDEX),
+		}
+		for _, collectionName := range []string{db.DB_TABLE_USERS, db.DB_TABLE_LICENSES_CATEGORIES, db.DB_TABLE_LICENCES} {
+			if err := CreateIndex(ctx, database, collectionName, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		for _, collectionName := range []string{db.DB_TABLE_USERS, db.DB_TABLE_LICENSES_CATEGORIES, db.DB_TABLE_LICENCES} {
+			if err := DropIndex(ctx, database, collectionName, MOST_RECENT_SORT_INDEX); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Name of the text index of the searches (one per collection)
+const SEARCH_TEXT_INDEX = "search_text"
+
+// Fields of the text indexes (the search:"text" tags of the models) and of the prefix searches (search:"text,prefix")
+// The prefix fields need an index, since the searches combine them with the text index in a $or
+// (the email of the users and the key of the licenses have their unique indexes)
+var searchIndexes = []struct {
+	collectionName string
+	textFields     []string
+	prefixFields   []string
+}{
+	{db.DB_TABLE_USERS, []string{"email", "firstName", "lastName"}, []string{}},
+	{db.DB_TABLE_LICENSES_CATEGORIES, []string{"description", "title"}, []string{"title"}},
+	{db.DB_TABLE_LICENCES, []string{"categoryTitle", "licenseKey", "userFullName"}, []string{"userFullName"}},
+}
+
+// 0011: Text indexes of the searches
+var searchIndexesMigration = Migration{
+	Version: 11,
+	Name:    "search_indexes",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		for _, searchIndex := range searchIndexes {
+			keys := bson.D{}
+			for _, field := range searchIndex.textFields {
+				keys = append(keys, bson.E{Key: field, Value: "text"})
+			}
+
+			index := mongo.IndexModel{Keys: keys, Options: options.Index().SetName(SEARCH_TEXT_INDEX)}
+			if err := CreateIndex(ctx, database, searchIndex.collectionName, index); err != nil {
+				return err
+			}
+
+			for _, field := range searchIndex.prefixFields {
+				index := mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}}
+				if err := CreateIndex(ctx, database, searchIndex.collectionName, index); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		for _, searchIndex := range searchIndexes {
+			if err := DropIndex(ctx, database, searchIndex.collectionName, SEARCH_TEXT_INDEX); err != nil {
+				return err
+			}
+			for _, field := range searchIndex.prefixFields {
+				if err := DropIndex(ctx, database, searchIndex.collectionName, field+"_1"); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
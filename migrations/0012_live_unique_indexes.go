@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Unique fields of the collections that only the live documents must not share
+// (a deleted user does not keep its email, a deleted category does not keep its type)
+var liveUniqueIndexes = []struct {
+	collectionName string
+	field          string
+}{
+	{db.DB_TABLE_USERS, "email"},
+	{db.DB_TABLE_LICENSES_CATEGORIES, "categoryType"},
+}
+
+// 0012: Unique indexes of the live documents
+// The unique indexes of 0001 are recreated with the partial filter of the live documents (like the active licenses of 0007),
+// so the soft deleted documents do not block their values. The restore of a document with a taken value is a conflict
+var liveUniqueIndexesMigration = Migration{
+	Version: 12,
+	Name:    "live_unique_indexes",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		for _, uniqueIndex := range liveUniqueIndexes {
+			if err := DropIndex(ctx, database, uniqueIndex.collectionName, uniqueIndex.field+"_1"); err != nil {
+				return err
+			}
+			partialFilter := bson.M{"deletedAt": bson.M{"$type": "null"}}
+			if err := CreateIndex(ctx, database, uniqueIndex.collectionName, uniqueFieldIndex(uniqueIndex.field, partialFilter)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+
+		// The deleted documents would break the unique indexes of all the documents
+		for _, uniqueIndex := range liveUniqueIndexes {
+			pipeline := mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{"_id": "$" + uniqueIndex.field, "count": bson.M{"$sum": 1}}}},
+				{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+				{{Key: "$count", Value: "values"}},
+			}
+			cursor, err := database.Collection(uniqueIndex.collectionName).Aggregate(ctx, pipeline)
+			if err != nil {
+				return err
+			}
+			duplicates := []bson.M{}
+			if err = cursor.All(ctx, &duplicates); err != nil {
+				return err
+			}
+			if len(duplicates) > 0 {
+				return fmt.Errorf("%s: %v values of '%s' are shared with soft deleted documents, purge them first", uniqueIndex.collectionName, duplicates[0]["values"], uniqueIndex.field)
+			}
+		}
+
+		for _, uniqueIndex := range liveUniqueIndexes {
+			if err := DropIndex(ctx, database, uniqueIndex.collectionName, uniqueIndex.field+"_1"); err != nil {
+				return err
+			}
+			if err := CreateIndex(ctx, database, uniqueIndex.collectionName, uniqueFieldIndex(uniqueIndex.field, nil)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// Private
+// This method returns the unique index of the field, with the given partial filter (nil for all the documents)
+func uniqueFieldIndex(field string, partialFilter bson.M) mongo.IndexModel {
+	indexOptions := options.Index().SetName(field + "_1").SetUnique(true)
+	if partialFilter != nil {
+		indexOptions.SetPartialFilterExpression(partialFilter)
+	}
+	return mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}, Options: indexOptions}
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 0013: Emails of the users in lower case
+// The APIs store the emails in lower case, so the logins and the links of the OpenID Connect identities
+// match the emails whatever their case. The live users whose emails differ only in their case must be merged first
+var lowercaseEmailsMigration = Migration{
+	Version: 13,
+	Name:    "lowercase_emails",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		collection := database.Collection(db.DB_TABLE_USERS)
+
+		// The unique index of the live emails would reject the update
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"deletedAt": bson.M{"$type": "null"}}}},
+			{{Key: "$group", Value: bson.M{"_id": bson.M{"$toLower": "$email"}, "count": bson.M{"$sum": 1}}}},
+			{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		}
+		cursor, err := collection.Aggregate(ctx, pipeline)
+		if err != nil {
+			return err
+		}
+		duplicates := []bson.M{}
+		if err = cursor.All(ctx, &duplicates); err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			emails := []interface{}{}
+			for _, duplicate := range duplicates {
+				emails = append(emails, duplicate["_id"])
+			}
+			return fmt.Errorf("%s: the emails %v belong to more than one user in different cases, merge the users first", db.DB_TABLE_USERS, emails)
+		}
+
+		// Only the emails with upper case letters
+		filter := bson.M{"$expr": bson.M{"$ne": bson.A{"$email", bson.M{"$toLower": "$email"}}}}
+		update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"email": bson.M{"$toLower": "$email"}}}}}
+		_, err = collection.UpdateMany(ctx, filter, update)
+		return err
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+
+		// The original case of the emails is not kept, nothing to revert
+		return nil
+	},
+}
@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Usage of the migrate subcommand
+const MIGRATE_USAGE = "usage: migrate up | down [steps] | status"
+
+// This method runs the migrate subcommand (up, down [steps], status) and writes the result
+func RunCommand(ctx context.Context, migrator *Migrator, args []string, output io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(MIGRATE_USAGE)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(output, "applied  %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(output, "no pending migrations")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsedSteps, err := strconv.Atoi(args[1])
+			if err != nil || parsedSteps <= 0 {
+				return errors.New("the steps must be a number greater than zero. " + MIGRATE_USAGE)
+			}
+			steps = parsedSteps
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(output, "reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Fprintln(output, "no applied migrations")
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedDt := "pending"
+			if status.Applied {
+				appliedDt = "applied " + status.AppliedDt.Format("2006-01-02T15:04:05Z07:00")
+			}
+			fmt.Fprintf(output, "%04d_%-30s %s\n", status.Version, status.Name, appliedDt)
+		}
+		return nil
+
+	default:
+		return errors.New("unknown migrate command '" + args[0] + "'. " + MIGRATE_USAGE)
+	}
+}
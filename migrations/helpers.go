@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDB error codes that the idempotent helpers ignore
+const NAMESPACE_NOT_FOUND_CODE = 26
+const INDEX_NOT_FOUND_CODE = 27
+const NAMESPACE_EXISTS_CODE = 48
+
+// This method creates the collection with the JSON schema validator, or updates the validator
+// of the existent collection with collMod
+func EnsureCollection(ctx context.Context, database *mongo.Database, name string, jsonSchema interface{}) error {
+	opts := options.CreateCollection().
+		SetValidator(bson.M{"$jsonSchema": jsonSchema}).
+		SetValidationLevel("strict").
+		SetValidationAction("error")
+
+	err := database.CreateCollection(ctx, name, opts)
+	if err == nil {
+		return nil
+	}
+	if !hasErrorCode(err, NAMESPACE_EXISTS_CODE) {
+		return err
+	}
+
+	return SetValidator(ctx, database, name, jsonSchema)
+}
+
+// This method replaces the JSON schema validator of the collection (collMod)
+// A nil schema removes the validator
+func SetValidator(ctx context.Context, database *mongo.Database, name string, jsonSchema interface{}) error {
+	validator := bson.M{}
+	if jsonSchema != nil {
+		validator = bson.M{"$jsonSchema": jsonSchema}
+	}
+
+	command := bson.D{
+		{Key: "collMod", Value: name},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: "strict"},
+		{Key: "validationAction", Value: "error"},
+	}
+	return database.RunCommand(ctx, command).Err()
+}
+
+// This method converts the documents of the collection with the validator removed, then applies the
+// JSON schema of the target version (every converted document passes from one type to the other)
+func ConvertCollection(ctx context.Context, database *mongo.Database, collectionName string, targetVersion int, convert func(collection *mongo.Collection) error) error {
+	if err := SetValidator(ctx, database, collectionName, nil); err != nil {
+		return err
+	}
+
+	if err := convert(database.Collection(collectionName)); err != nil {
+		return err
+	}
+
+	jsonSchema, err := SchemaAt(targetVersion, collectionName)
+	if err != nil {
+		return err
+	}
+	return SetValidator(ctx, database, collectionName, jsonSchema)
+}
+
+// This method creates the index (no action if the same index exists)
+func CreateIndex(ctx context.Context, database *mongo.Database, collectionName string, index mongo.IndexModel) error {
+	_, err := database.Collection(collectionName).Indexes().CreateOne(ctx, index)
+	return err
+}
+
+// This method drops the index by name (no action if the index or the collection does not exist)
+func DropIndex(ctx context.Context, database *mongo.Database, collectionName string, indexName string) error {
+	_, err := database.Collection(collectionName).Indexes().DropOne(ctx, indexName)
+	if hasErrorCode(err, INDEX_NOT_FOUND_CODE) || hasErrorCode(err, NAMESPACE_NOT_FOUND_CODE) {
+		return nil
+	}
+	return err
+}
+
+// Private
+// This method checks if the error is a MongoDB server error with the given code
+func hasErrorCode(err error, code int) bool {
+	var serverError mongo.ServerError
+	if errors.As(err, &serverError) {
+		return serverError.HasErrorCode(code)
+	}
+	return false
+}
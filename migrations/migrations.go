@@ -0,0 +1,235 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection with the applied migrations
+const SCHEMA_MIGRATIONS_COLLECTION = "schema_migrations"
+
+// Single versioned migration
+// Up and Down must be idempotent, so a migration that failed in the middle can run again
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, database *mongo.Database) error
+	Down    func(ctx context.Context, database *mongo.Database) error
+}
+
+// Applied migration, as stored in the schema_migrations collection
+type AppliedMigration struct {
+	Version   int       `bson:"_id" json:"version"`
+	Name      string    `bson:"name" json:"name"`
+	AppliedDt time.Time `bson:"appliedDt" json:"appliedDt"`
+}
+
+// Status of a migration
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedDt time.Time
+}
+
+// All the migrations of the API, in order
+// New migrations are added at the end with the next version
+var Migrations = []Migration{
+	createCollectionsMigration,
+	licenseLookupIndexesMigration,
+	backfillStatusFlagsMigration,
+	nativeDatesMigration,
+	typedFlagsMigration,
+	oneActiveLicensePerUserMigration,
+	softDeleteMigration,
+	documentVersionsMigration,
+	auditLogMigration,
+	sortIndexesMigration,
+	searchIndexesMigration,
+	liveUniqueIndexesMigration,
+	lowercaseEmailsMigration,
+}
+
+// Runner of the migrations over a MongoDB database
+type Migrator struct {
+	Database   *mongo.Database
+	Migrations []Migration
+}
+
+// This method creates a new migrator with all the migrations of the API
+func NewMigrator(database *mongo.Database) *Migrator {
+	return &Migrator{Database: database, Migrations: Migrations}
+}
+
+// This method applies all the pending migrations in order
+// Output: the applied migrations
+func (migrator *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := migrator.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := PlanUp(migrator.Migrations, applied)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := migrator.Database.Collection(SCHEMA_MIGRATIONS_COLLECTION)
+	for position, migration := range pending {
+		fmt.Printf("Applying migration %04d_%s\n", migration.Version, migration.Name)
+		if err = migration.Up(ctx, migrator.Database); err != nil {
+			return pending[:position], fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+
+		// Record the migration (upsert, the migration may have been recorded by a previous run)
+		record := AppliedMigration{Version: migration.Version, Name: migration.Name, AppliedDt: time.Now().UTC()}
+		_, err = collection.ReplaceOne(ctx, bson.M{"_id": migration.Version}, record, options.Replace().SetUpsert(true))
+		if err != nil {
+			return pending[:position], err
+		}
+	}
+	return pending, nil
+}
+
+// This method reverts the last applied migrations (steps), in reverse order
+// Output: the reverted migrations
+func (migrator *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := migrator.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	toRevert, err := PlanDown(migrator.Migrations, applied, steps)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := migrator.Database.Collection(SCHEMA_MIGRATIONS_COLLECTION)
+	for position, migration := range toRevert {
+		fmt.Printf("Reverting migration %04d_%s\n", migration.Version, migration.Name)
+		if err = migration.Down(ctx, migrator.Database); err != nil {
+			return toRevert[:position], fmt.Errorf("revert of migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+
+		if _, err = collection.DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return toRevert[:position], err
+		}
+	}
+	return toRevert, nil
+}
+
+// This method returns the status of all the migrations
+func (migrator *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := migrator.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ValidateMigrations(migrator.Migrations); err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migration := range migrator.Migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, found := applied[migration.Version]; found {
+			status.Applied = true
+			status.AppliedDt = record.AppliedDt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Private
+// This method returns the applied migrations per version
+func (migrator *Migrator) applied(ctx context.Context) (map[int]AppliedMigration, error) {
+	rows, err := migrator.Database.Collection(SCHEMA_MIGRATIONS_COLLECTION).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	records := []AppliedMigration{}
+	if err = rows.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]AppliedMigration{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// This method checks that the versions are positive, unique and ascending and that every migration can run
+func ValidateMigrations(migrations []Migration) error {
+	previousVersion := 0
+	for _, migration := range migrations {
+		if migration.Version <= previousVersion {
+			return fmt.Errorf("migration %04d_%s: the versions must be unique and ascending", migration.Version, migration.Name)
+		}
+		if migration.Name == "" || migration.Up == nil || migration.Down == nil {
+			return fmt.Errorf("migration %04d: name, up and down are required", migration.Version)
+		}
+		previousVersion = migration.Version
+	}
+	return nil
+}
+
+// This method returns the pending migrations in order
+func PlanUp(migrations []Migration, applied map[int]AppliedMigration) ([]Migration, error) {
+	if err := ValidateMigrations(migrations); err != nil {
+		return nil, err
+	}
+
+	// An applied migration that is unknown to this build means a newer deployment
+	known := map[int]bool{}
+	for _, migration := range migrations {
+		known[migration.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("the database has the unknown migration %04d, update the API first", version)
+		}
+	}
+
+	pending := []Migration{}
+	for _, migration := range migrations {
+		if _, found := applied[migration.Version]; !found {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// This method returns the last applied migrations (steps) in reverse order
+func PlanDown(migrations []Migration, applied map[int]AppliedMigration, steps int) ([]Migration, error) {
+	if err := ValidateMigrations(migrations); err != nil {
+		return nil, err
+	}
+	if steps <= 0 {
+		return nil, errors.New("the steps to revert must be greater than zero")
+	}
+
+	appliedMigrations := []Migration{}
+	for _, migration := range migrations {
+		if _, found := applied[migration.Version]; found {
+			appliedMigrations = append(appliedMigrations, migration)
+		}
+	}
+
+	sort.SliceStable(appliedMigrations, func(i, j int) bool {
+		return appliedMigrations[i].Version > appliedMigrations[j].Version
+	})
+	if steps > len(appliedMigrations) {
+		steps = len(appliedMigrations)
+	}
+	return appliedMigrations[:steps], nil
+}
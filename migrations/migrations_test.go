@@ -0,0 +1,275 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// This method creates a migration that does nothing
+func noopMigration(version int, name string) Migration {
+	noop := func(ctx context.Context, database *mongo.Database) error { return nil }
+	return Migration{Version: version, Name: name, Up: noop, Down: noop}
+}
+
+// This method returns the versions of the migrations
+func versions(migrations []Migration) []int {
+	result := []int{}
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
+
+func TestAPIMigrationsAreValid(t *testing.T) {
+	if err := ValidateMigrations(Migrations); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateMigrations(t *testing.T) {
+	testCases := []struct {
+		name       string
+		migrations []Migration
+		isValid    bool
+	}{
+		{"ascending", []Migration{noopMigration(1, "a"), noopMigration(2, "b"), noopMigration(5, "c")}, true},
+		{"duplicate version", []Migration{noopMigration(1, "a"), noopMigration(1, "b")}, false},
+		{"not ascending", []Migration{noopMigration(2, "a"), noopMigration(1, "b")}, false},
+		{"zero version", []Migration{noopMigration(0, "a")}, false},
+		{"missing down", []Migration{{Version: 1, Name: "a", Up: noopMigration(1, "a").Up}}, false},
+	}
+
+	for _, testCase := range testCases {
+		err := ValidateMigrations(testCase.migrations)
+		if testCase.isValid && err != nil {
+			t.Errorf("%s: expected valid migrations: %v", testCase.name, err)
+		}
+		if !testCase.isValid && err == nil {
+			t.Errorf("%s: expected an error", testCase.name)
+		}
+	}
+}
+
+func TestPlanUpAndDown(t *testing.T) {
+	migrations := []Migration{noopMigration(1, "a"), noopMigration(2, "b"), noopMigration(3, "c")}
+	applied := map[int]AppliedMigration{1: {Version: 1}, 2: {Version: 2}}
+
+	pending, err := PlanUp(migrations, applied)
+	if err != nil || !reflect.DeepEqual(versions(pending), []int{3}) {
+		t.Fatalf("expected pending [3], got %v: %v", versions(pending), err)
+	}
+
+	// Reverse order and limited to the applied migrations
+	toRevert, err := PlanDown(migrations, applied, 1)
+	if err != nil || !reflect.DeepEqual(versions(toRevert), []int{2}) {
+		t.Fatalf("expected to revert [2], got %v: %v", versions(toRevert), err)
+	}
+	toRevert, err = PlanDown(migrations, applied, 10)
+	if err != nil || !reflect.DeepEqual(versions(toRevert), []int{2, 1}) {
+		t.Fatalf("expected to revert [2 1], got %v: %v", versions(toRevert), err)
+	}
+	if _, err = PlanDown(migrations, applied, 0); err == nil {
+		t.Fatal("expected an error for zero steps")
+	}
+
+	// The database has a migration of a newer version of the API
+	if _, err = PlanUp(migrations, map[int]AppliedMigration{4: {Version: 4}}); err == nil {
+		t.Fatal("expected an error for an unknown applied migration")
+	}
+}
+
+func TestRunCommandArguments(t *testing.T) {
+	migrator := &Migrator{Migrations: Migrations}
+
+	for _, args := range [][]string{{}, {"sideways"}, {"down", "zero"}, {"down", "-1"}} {
+		if err := RunCommand(context.TODO(), migrator, args, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestSchemaAtRevertsTheNativeDates(t *testing.T) {
+	for version, expectedType := range map[int]string{3: "string", 4: "date"} {
+		licensesSchema, err := SchemaAt(version, db.DB_TABLE_LICENCES)
+		if err != nil {
+			t.Fatal(err)
+		}
+		usersSchema, err := SchemaAt(version, db.DB_TABLE_USERS)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, check := range []struct {
+			jsonSchema bson.M
+			path       string
+		}{
+			{licensesSchema, "begin_dt"},
+			{licensesSchema, "createdDt"},
+			{usersSchema, "externalIdentities.linkedDt"},
+		} {
+			property, err := schemaProperty(check.jsonSchema, check.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if property["bsonType"] != expectedType {
+				t.Errorf("version %d %s: expected %s, got %v", version, check.path, expectedType, property["bsonType"])
+			}
+		}
+	}
+
+	if _, err := SchemaAt(1, "unknown"); err == nil {
+		t.Fatal("expected an error for an unknown collection")
+	}
+}
+
+func TestConvertDatePaths(t *testing.T) {
+	athens, err := time.LoadLocation("Europe/Athens")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	toDate := func(value interface{}) (interface{}, error) { return legacyStringToDate(value, athens) }
+	toString := func(value interface{}) (interface{}, error) { return dateToLegacyString(value, athens), nil }
+
+	expected := time.Date(2024, 1, 18, 7, 35, 36, 0, time.UTC)
+	identities := bson.A{
+		bson.M{"provider": "google", "linkedDt": "2024-01-18 09:35:36"},
+		bson.M{"provider": "microsoft"},
+	}
+
+	converted, err := convertPath(identities, []string{"linkedDt"}, toDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := converted.(bson.A)
+	if linkedDt, _ := items[0].(bson.M)["linkedDt"].(time.Time); !linkedDt.Equal(expected) {
+		t.Fatalf("expected %v, got %v", expected, items[0])
+	}
+	if _, found := items[1].(bson.M)["linkedDt"]; found {
+		t.Fatal("a missing date was added")
+	}
+
+	// Back to the legacy string, as stored by the server
+	reverted, err := convertPath(primitive.NewDateTimeFromTime(expected), nil, toString)
+	if err != nil || reverted != "2024-01-18 09:35:36" {
+		t.Fatalf("expected the legacy string, got %v: %v", reverted, err)
+	}
+
+	if _, err = convertPath("18/01/2024", nil, toDate); err == nil {
+		t.Fatal("expected an error for an invalid legacy date")
+	}
+}
+
+func TestSchemaAtRevertsTheTypedFlags(t *testing.T) {
+	for version, expectedTypes := range map[int][2]string{4: {"string", "string"}, 5: {"bool", "long"}} {
+		licensesSchema, err := SchemaAt(version, db.DB_TABLE_LICENCES)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for field, expectedType := range map[string]string{"isExpired": expectedTypes[0], "categoryType": expectedTypes[1], "begin_dt": "date"} {
+			property, err := schemaProperty(licensesSchema, field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if property["bsonType"] != expectedType {
+				t.Errorf("version %d %s: expected %s, got %v", version, field, expectedType, property["bsonType"])
+			}
+		}
+	}
+}
+
+func TestSchemaAtRevertsTheSoftDelete(t *testing.T) {
+	for _, collectionName := range db.DB_TABLES_SOFT_DELETE {
+		for version, expected := range map[int]bool{6: false, 7: true} {
+			jsonSchema, err := SchemaAt(version, collectionName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, hasProperty := jsonSchema["properties"].(bson.M)["deletedAt"]
+			isRequired := false
+			for _, field := range jsonSchema["required"].(bson.A) {
+				isRequired = isRequired || field == "deletedAt"
+			}
+			if hasProperty != expected || isRequired != expected {
+				t.Errorf("%s version %d: expected deletedAt %v, got property %v required %v", collectionName, version, expected, hasProperty, isRequired)
+			}
+		}
+	}
+}
+
+func TestSchemaAtRevertsTheDocumentVersions(t *testing.T) {
+	for _, collectionName := range db.DB_TABLES_SOFT_DELETE {
+		for version, expected := range map[int]bool{7: false, 8: true} {
+			jsonSchema, err := SchemaAt(version, collectionName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, hasProperty := jsonSchema["properties"].(bson.M)["version"]
+			isRequired := false
+			for _, field := range jsonSchema["required"].(bson.A) {
+				isRequired = isRequired || field == "version"
+			}
+			if hasProperty != expected || isRequired != expected {
+				t.Errorf("%s version %d: expected version %v, got property %v required %v", collectionName, version, expected, hasProperty, isRequired)
+			}
+		}
+	}
+}
+
+func TestSearchIndexesMatchTheModels(t *testing.T) {
+	modelFields := map[string][]string{
+		db.DB_TABLE_USERS:               models.SearchTextFields[models.User](),
+		db.DB_TABLE_LICENSES_CATEGORIES: models.SearchTextFields[models.LicenseCategory](),
+		db.DB_TABLE_LICENCES:            models.SearchTextFields[models.License](),
+	}
+
+	for _, searchIndex := range searchIndexes {
+		if !reflect.DeepEqual(searchIndex.textFields, modelFields[searchIndex.collectionName]) {
+			t.Errorf("%s: the text index has %v, the model %v", searchIndex.collectionName, searchIndex.textFields, modelFields[searchIndex.collectionName])
+		}
+	}
+}
+
+func TestRegisteredIndexesAreCreatedByTheMigrations(t *testing.T) {
+	createdIndexes := map[string][]string{
+		db.DB_TABLE_USERS:               {"email_1", DELETED_AT_INDEX, MOST_RECENT_SORT_INDEX, SEARCH_TEXT_INDEX},
+		db.DB_TABLE_LICENSES_CATEGORIES: {"categoryType_1", DELETED_AT_INDEX, MOST_RECENT_SORT_INDEX, SEARCH_TEXT_INDEX, "title_1"},
+		db.DB_TABLE_LICENCES:            {"licenseKey_1", "userHolderId_1", "categoryId_1", ONE_ACTIVE_LICENSE_INDEX, DELETED_AT_INDEX, MOST_RECENT_SORT_INDEX, SEARCH_TEXT_INDEX, "userFullName_1"},
+		db.DB_TABLE_AUDIT_LOG:           {AUDIT_LOG_CREATED_INDEX, AUDIT_LOG_ACTOR_INDEX, AUDIT_LOG_COLLECTION_INDEX},
+	}
+
+	for _, resource := range registry.Resources() {
+		if !reflect.DeepEqual(resource.Indexes, createdIndexes[resource.Name]) {
+			t.Errorf("%s: registered the indexes %v, the migrations create %v", resource.Name, resource.Indexes, createdIndexes[resource.Name])
+		}
+		if _, err := LatestSchema(resource.Name); err != nil {
+			t.Errorf("%s: %v", resource.Name, err)
+		}
+	}
+}
+
+func TestLiveUniqueIndexesIgnoreTheDeletedDocuments(t *testing.T) {
+	for _, uniqueIndex := range liveUniqueIndexes {
+
+		// Same name as the unique index of 0001, so the registered indexes do not change
+		index := uniqueFieldIndex(uniqueIndex.field, bson.M{"deletedAt": bson.M{"$type": "null"}})
+		if *index.Options.Name != uniqueIndex.field+"_1" || !*index.Options.Unique || index.Options.PartialFilterExpression == nil {
+			t.Errorf("%s: expected the unique partial index %s_1, got %v", uniqueIndex.collectionName, uniqueIndex.field, index.Options)
+		}
+		if index = uniqueFieldIndex(uniqueIndex.field, nil); index.Options.PartialFilterExpression != nil {
+			t.Errorf("%s: expected the unique index of all the documents for the revert", uniqueIndex.collectionName)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Changes of the migrations on the JSON schemas, per version
+// Each function reverts the change on the schema of the collection, so the schema of every version
+// is derived from the latest schema (db package) and the Down of a migration restores the previous one
+var schemaReverts = map[int]func(collectionName string, jsonSchema bson.M) error{
+	4: revertNativeDatesSchema,
+	5: revertTypedFlagsSchema,
+	7: revertSoftDeleteSchema,
+	8: revertDocumentVersionsSchema,
+}
+
+// This method returns the latest JSON schema of the collection (registered resource), as a plain (driver) bson.M
+func LatestSchema(collectionName string) (bson.M, error) {
+	resource, found := registry.Lookup(collectionName)
+	if !found || resource.Schema == nil {
+		return nil, fmt.Errorf("no JSON schema for the collection %s", collectionName)
+	}
+	schema := resource.Schema()
+
+	// Deep copy with the driver types (the schemas of the db package are mgo bson.M)
+	data, err := bson.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	jsonSchema := bson.M{}
+	err = bson.Unmarshal(data, &jsonSchema)
+	return jsonSchema, err
+}
+
+// This method returns the JSON schema of the collection once the migrations up to the version are applied
+func SchemaAt(version int, collectionName string) (bson.M, error) {
+	jsonSchema, err := LatestSchema(collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Revert the changes of the later migrations, newest first
+	versions := []int{}
+	for schemaVersion := range schemaReverts {
+		if schemaVersion > version {
+			versions = append(versions, schemaVersion)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	for _, schemaVersion := range versions {
+		if err = schemaReverts[schemaVersion](collectionName, jsonSchema); err != nil {
+			return nil, fmt.Errorf("schema of migration %04d: %w", schemaVersion, err)
+		}
+	}
+	return jsonSchema, nil
+}
+
+// This method returns the schema of the property with the given dotted path
+// The path goes through the items of the array properties (e.g. externalIdentities.linkedDt)
+func schemaProperty(jsonSchema bson.M, path string) (bson.M, error) {
+	node := jsonSchema
+	parts := strings.Split(path, ".")
+	for position, part := range parts {
+		properties, _ := node["properties"].(bson.M)
+		property, found := properties[part].(bson.M)
+		if !found {
+			return nil, fmt.Errorf("the schema has no property %s", path)
+		}
+		node = property
+
+		if position < len(parts)-1 && node["bsonType"] == "array" {
+			if node, found = node["items"].(bson.M); !found {
+				return nil, fmt.Errorf("the schema has no items for %s", path)
+			}
+		}
+	}
+	return node, nil
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Entry of the audit log (append-only) for every mutating request
+// The JSON array request bodies (e.g. JSON Patch) are kept in the request items, the items that are not objects
+// as {"value": item}, and the request bodies larger than the audit log keeps are only marked as truncated
+type AuditLogEntry struct {
+	ID                   string                   `bson:"_id,omitempty" json:"_id"`
+	ActorID              string                   `bson:"actorId,omitempty" json:"actorId"`
+	ActorEmail           string                   `bson:"actorEmail,omitempty" json:"actorEmail"`
+	ActorRoles           []string                 `bson:"actorRoles,omitempty" json:"actorRoles"`
+	IP                   string                   `bson:"ip" json:"ip"`
+	Method               string                   `bson:"method" json:"method"`
+	Route                string                   `bson:"route" json:"route"`
+	Path                 string                   `bson:"path" json:"path"`
+	Query                string                   `bson:"query,omitempty" json:"query"`
+	Collection           string                   `bson:"collection,omitempty" json:"collection"`
+	Action               string                   `bson:"action" json:"action"`
+	DocumentIDs          []string                 `bson:"documentIds" json:"documentIds"`
+	RequestBody          map[string]interface{}   `bson:"requestBody,omitempty" json:"requestBody"`
+	RequestItems         []map[string]interface{} `bson:"requestItems,omitempty" json:"requestItems,omitempty"`
+	RequestBodyTruncated bool                     `bson:"requestBodyTruncated,omitempty" json:"requestBodyTruncated,omitempty"`
+	Status               int                      `bson:"status" json:"status"`
+	CreatedDt            time.Time                `bson:"createdDt" json:"createdDt"`
+}
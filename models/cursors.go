@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// This method returns the signature of the sort (e.g. "-createdDt,_id"), so a cursor is only used with the same sort
+func SortSignature(sortKeys bson.D) string {
+	fields := []string{}
+	for _, sortKey := range sortKeys {
+		if sortDirection(sortKey) < 0 {
+			fields = append(fields, SORT_DESCENDING_PREFIX+sortKey.Key)
+		} else {
+			fields = append(fields, sortKey.Key)
+		}
+	}
+	return strings.Join(fields, SORT_SEPARATOR)
+}
+
+// This method returns the sort with the opposite directions (the pages before a cursor)
+func ReverseSort(sortKeys bson.D) bson.D {
+	reversed := bson.D{}
+	for _, sortKey := range sortKeys {
+		reversed = append(reversed, bson.E{Key: sortKey.Key, Value: -sortDirection(sortKey)})
+	}
+	return reversed
+}
+
+// This method returns the values of the sort keys of the document, the position of a cursor
+// The object ids (filterType tag) are returned as primitive.ObjectID, like they are stored
+func SortValues[T any](document T, sortKeys bson.D) ([]interface{}, error) {
+	documentValue := reflect.ValueOf(document)
+	if documentValue.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("not supported document type %T", document)
+	}
+
+	values := []interface{}{}
+	for _, sortKey := range sortKeys {
+		field, found := fieldByBSONName(documentValue.Type(), sortKey.Key)
+		if !found {
+			return nil, fmt.Errorf("field '%s' is not a field of %T", sortKey.Key, document)
+		}
+
+		value := documentValue.FieldByIndex(field.Index).Interface()
+		if field.Tag.Get("filterType") == FILTER_TYPE_OBJECT_ID {
+			objectID, err := primitive.ObjectIDFromHex(fmt.Sprint(value))
+			if err != nil {
+				return nil, fmt.Errorf("field '%s': %w", sortKey.Key, err)
+			}
+			value = objectID
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// This method compiles the keyset filter of the documents after the values of the sort keys
+// (or before them, backwards), e.g. for {createdDt: -1, _id: -1}:
+// createdDt < v1 OR (createdDt = v1 AND _id < v2)
+func KeysetFilter(sortKeys bson.D, values []interface{}, backwards bool) (map[string]interface{}, error) {
+	if len(values) != len(sortKeys) || len(sortKeys) == 0 {
+		return nil, fmt.Errorf("expected %d values of the sort keys, got %d", len(sortKeys), len(values))
+	}
+
+	conditions := []interface{}{}
+	for position, sortKey := range sortKeys {
+		operator := "$gt"
+		if (sortDirection(sortKey) < 0) != backwards {
+			operator = "$lt"
+		}
+
+		// The previous keys are equal, the current key is after the value
+		condition := map[string]interface{}{}
+		for previous := 0; previous < position; previous++ {
+			condition[sortKeys[previous].Key] = map[string]interface{}{"$eq": values[previous]}
+		}
+		condition[sortKey.Key] = map[string]interface{}{operator: values[position]}
+		conditions = append(conditions, condition)
+	}
+	return map[string]interface{}{"$or": conditions}, nil
+}
+
+// Private
+// This method returns the direction of the sort key (1 or -1)
+func sortDirection(sortKey bson.E) int {
+	switch direction := sortKey.Value.(type) {
+	case int:
+		return direction
+	case int32:
+		return int(direction)
+	case int64:
+		return int(direction)
+	}
+	return 1
+}
+
+// Private
+// This method returns the field of the struct with the given bson name
+func fieldByBSONName(structType reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if strings.Split(field.Tag.Get("bson"), ",")[0] == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// This method returns the fields of the sort keys
+func SortFields(sortKeys bson.D) []string {
+	fields := []string{}
+	for _, sortKey := range sortKeys {
+		fields = append(fields, sortKey.Key)
+	}
+	return fields
+}
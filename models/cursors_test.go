@@ -0,0 +1,61 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSortValuesAndSignature(t *testing.T) {
+	objectID, _ := primitive.ObjectIDFromHex("65a251c101cef849fc779521")
+	createdDt := time.Date(2024, 1, 18, 9, 35, 36, 0, time.UTC)
+	category := LicenseCategory{ID: objectID.Hex(), Title: "Silver", CreatedDt: createdDt}
+
+	sortKeys, _ := CompileSort[LicenseCategory]("-createdDt,title", DEFAULT_SORT)
+	if signature := SortSignature(sortKeys); signature != "-createdDt,title,_id" {
+		t.Errorf("unexpected signature %s", signature)
+	}
+	if signature := SortSignature(ReverseSort(sortKeys)); signature != "createdDt,-title,-_id" {
+		t.Errorf("unexpected signature of the reversed sort %s", signature)
+	}
+
+	values, err := SortValues(category, sortKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{createdDt, "Silver", objectID}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	if _, err = SortValues(LicenseCategory{ID: "not-an-id"}, bson.D{{Key: "_id", Value: 1}}); err == nil {
+		t.Error("expected an error for a not valid object id")
+	}
+}
+
+func TestKeysetFilter(t *testing.T) {
+	sortKeys := bson.D{{Key: "createdDt", Value: -1}, {Key: "_id", Value: -1}}
+	values := []interface{}{"v1", "v2"}
+
+	expected := map[string]interface{}{"$or": []interface{}{
+		map[string]interface{}{"createdDt": map[string]interface{}{"$lt": "v1"}},
+		map[string]interface{}{"createdDt": map[string]interface{}{"$eq": "v1"}, "_id": map[string]interface{}{"$lt": "v2"}},
+	}}
+	if filter, err := KeysetFilter(sortKeys, values, false); err != nil || !reflect.DeepEqual(filter, expected) {
+		t.Errorf("after: expected %v, got %v (%v)", expected, filter, err)
+	}
+
+	expectedBackwards := map[string]interface{}{"$or": []interface{}{
+		map[string]interface{}{"createdDt": map[string]interface{}{"$gt": "v1"}},
+		map[string]interface{}{"createdDt": map[string]interface{}{"$eq": "v1"}, "_id": map[string]interface{}{"$gt": "v2"}},
+	}}
+	if filter, err := KeysetFilter(sortKeys, values, true); err != nil || !reflect.DeepEqual(filter, expectedBackwards) {
+		t.Errorf("before: expected %v, got %v (%v)", expectedBackwards, filter, err)
+	}
+
+	if _, err := KeysetFilter(sortKeys, []interface{}{"v1"}, false); err == nil {
+		t.Error("expected an error for missing values")
+	}
+}
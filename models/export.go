@@ -0,0 +1,44 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// This method returns the columns of an export (JSON names of the fields): the fields of the projection,
+// or all the returned fields of the model in the order of the model (never the excluded fields, e.g. password)
+func ExportColumns[T any](projection Projection) []string {
+	if len(projection.Fields) > 0 {
+		return append([]string{}, projection.Fields...)
+	}
+
+	columns := []string{}
+	for _, field := range projectionFieldListOf[T]() {
+		if !field.never {
+			columns = append(columns, field.jsonName)
+		}
+	}
+	return columns
+}
+
+// This method returns the values of the columns of the document, as they are in the JSON responses
+// (e.g. RFC 3339 dates, true / false flags), nil for the missing fields
+func ExportRow(document interface{}, columns []string) ([]interface{}, error) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedDocument := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&encodedDocument); err != nil {
+		return nil, err
+	}
+
+	row := make([]interface{}, len(columns))
+	for position, column := range columns {
+		row[position] = encodedDocument[column]
+	}
+	return row, nil
+}
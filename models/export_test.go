@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExportColumns(t *testing.T) {
+	columns := ExportColumns[User](Projection{})
+	expected := []string{"_id", "firstName", "lastName", "role", "isAdmin", "isActive", "email", "createdDt", "lastUpdatedDt", "version", "externalIdentities"}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("expected %v, got %v", expected, columns)
+	}
+
+	projection, _ := CompileProjection[User]("email,password,role")
+	if columns := ExportColumns[User](projection); !reflect.DeepEqual(columns, []string{"_id", "email", "role"}) {
+		t.Errorf("unexpected columns of the projection %v", columns)
+	}
+}
+
+func TestExportRow(t *testing.T) {
+	user := User{ID: "65a251c101cef849fc779521", Email: "ada@example.com", Password: "secret", Version: 9007199254740993}
+	row, err := ExportRow(user, []string{"_id", "email", "version", "missing"})
+	if err != nil {
+		t.Fatalf("expected a row, got %v", err)
+	}
+
+	expected := []interface{}{"65a251c101cef849fc779521", "ada@example.com", json.Number("9007199254740993"), nil}
+	if !reflect.DeepEqual(row, expected) {
+		t.Errorf("expected %v, got %v", expected, row)
+	}
+}
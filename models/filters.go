@@ -0,0 +1,217 @@
+package models
+
+import (
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Query parameter of the filters: filter[field]=value (eq) or filter[field][operator]=value
+const FILTER_PARAM = "filter"
+
+// Separator of the values of the in / nin operators, and their max number
+const FILTER_LIST_SEPARATOR = ","
+const MAX_FILTER_LIST_VALUES = 100
+
+// Filter type of the string fields that store object ids (filterType tag)
+const FILTER_TYPE_OBJECT_ID = "objectId"
+
+// Operators of the filters and the corresponding MongoDB operators
+// Only these operators are compiled and the values are always typed scalars, so no operator can be injected
+var FILTER_OPERATORS = map[string]string{
+	"eq":       "$eq",
+	"ne":       "$ne",
+	"gt":       "$gt",
+	"gte":      "$gte",
+	"lt":       "$lt",
+	"lte":      "$lte",
+	"in":       "$in",
+	"nin":      "$nin",
+	"contains": "$regex",
+}
+
+// Syntax of the filter query parameters
+var filterParamPattern = regexp.MustCompile(`^` + FILTER_PARAM + `\[([A-Za-z0-9_]+)\](?:\[([A-Za-z]+)\])?$`)
+
+// Filterable field of a model, as declared by its filter tag (e.g. `filter:"eq,ne,in"`)
+type filterField struct {
+	fieldType  reflect.Type
+	operators  []string
+	isObjectID bool
+}
+
+// This method returns the filterable fields of the model (bson name -> allowed operators)
+func FilterableFields[T any]() map[string][]string {
+	filterableFields := map[string][]string{}
+	for name, field := range filterFieldsOf[T]() {
+		filterableFields[name] = field.operators
+	}
+	return filterableFields
+}
+
+// This method compiles the filter query parameters to a MongoDB filter, according to the filter tags of the model
+// The fields without a filter tag and the not allowed operators are rejected
+// Output: empty filter if no filter parameter is given
+func CompileFilters[T any](query url.Values) (map[string]interface{}, error) {
+	fields := filterFieldsOf[T]()
+	filter := map[string]interface{}{}
+
+	// Sorted parameters, so the errors are the same for the same query
+	keys := []string{}
+	for key := range query {
+		if key == FILTER_PARAM || strings.HasPrefix(key, FILTER_PARAM+"[") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		matches := filterParamPattern.FindStringSubmatch(key)
+		if matches == nil {
+			return nil, fmt.Errorf("not valid filter '%s', expected filter[field] or filter[field][operator]", key)
+		}
+		if len(query[key]) != 1 {
+			return nil, fmt.Errorf("filter '%s' is given more than once", key)
+		}
+
+		name, operator := matches[1], matches[2]
+		if operator == "" {
+			operator = "eq"
+		}
+
+		field, found := fields[name]
+		if !found {
+			return nil, fmt.Errorf("field '%s' is not filterable", name)
+		}
+		if !containsValue(field.operators, operator) {
+			return nil, fmt.Errorf("operator '%s' is not allowed for the field '%s', allowed: %s", operator, name, strings.Join(field.operators, ", "))
+		}
+
+		value, err := compileFilterValue(field, operator, query[key][0])
+		if err != nil {
+			return nil, fmt.Errorf("filter '%s': %w", key, err)
+		}
+
+		conditions, _ := filter[name].(map[string]interface{})
+		if conditions == nil {
+			conditions = map[string]interface{}{}
+			filter[name] = conditions
+		}
+		for mongoOperator, mongoValue := range value {
+			conditions[mongoOperator] = mongoValue
+		}
+	}
+	return filter, nil
+}
+
+// Private
+// This method returns the filterable fields of the model, with their types and operators
+func filterFieldsOf[T any]() map[string]filterField {
+	var model T
+	modelType := reflect.TypeOf(model)
+	fields := map[string]filterField{}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		operators := field.Tag.Get("filter")
+		name := strings.Split(field.Tag.Get("bson"), ",")[0]
+		if operators == "" || name == "" {
+			continue
+		}
+
+		fields[name] = filterField{
+			fieldType:  field.Type,
+			operators:  strings.Split(operators, ","),
+			isObjectID: field.Tag.Get("filterType") == FILTER_TYPE_OBJECT_ID,
+		}
+	}
+	return fields
+}
+
+// Private
+// This method compiles the value of a filter to its MongoDB conditions (e.g. {"$gte": date})
+func compileFilterValue(field filterField, operator string, value string) (map[string]interface{}, error) {
+	switch operator {
+	case "in", "nin":
+		values := strings.Split(value, FILTER_LIST_SEPARATOR)
+		if len(values) > MAX_FILTER_LIST_VALUES {
+			return nil, fmt.Errorf("more than %d values", MAX_FILTER_LIST_VALUES)
+		}
+
+		parsedValues := []interface{}{}
+		for _, listValue := range values {
+			parsed, err := parseFilterValue(field, listValue)
+			if err != nil {
+				return nil, err
+			}
+			parsedValues = append(parsedValues, parsed)
+		}
+		return map[string]interface{}{FILTER_OPERATORS[operator]: parsedValues}, nil
+
+	case "contains":
+		if field.fieldType.Kind() != reflect.String || field.isObjectID {
+			return nil, fmt.Errorf("operator 'contains' needs a text field")
+		}
+
+		// The value is matched as plain text, never as a regular expression
+		return map[string]interface{}{"$regex": regexp.QuoteMeta(value), "$options": "i"}, nil
+	}
+
+	parsed, err := parseFilterValue(field, value)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{FILTER_OPERATORS[operator]: parsed}, nil
+}
+
+// Private
+// This method parses the value of a filter to the type of the field
+func parseFilterValue(field filterField, value string) (interface{}, error) {
+	value = strings.TrimSpace(value)
+	if field.isObjectID {
+		return primitive.ObjectIDFromHex(value)
+	}
+
+	switch field.fieldType {
+	case reflect.TypeOf(Flag(false)):
+		return ParseFlag(value)
+	case reflect.TypeOf(CategoryType(0)):
+		return ParseCategoryType(value)
+	case reflect.TypeOf(time.Time{}):
+		return utils.ParseDate(value)
+	}
+
+	switch field.fieldType.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer '%s'", value)
+		}
+		return parsed, nil
+	case reflect.String:
+		return value, nil
+	}
+	return nil, fmt.Errorf("not supported filter type %s", field.fieldType)
+}
+
+// Private
+// This method checks if the value is in the list (e.g. an operator in the allowed operators)
+func containsValue(values []string, value string) bool {
+	for _, listValue := range values {
+		if listValue == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCompileFilters(t *testing.T) {
+	categoryID, _ := primitive.ObjectIDFromHex("65a251c101cef849fc779521")
+	createdFrom := time.Date(2024, 1, 18, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected map[string]interface{}
+	}{
+		{"no filters", "page=2&limit=10", map[string]interface{}{}},
+		{"equal with the deprecated flag", "filter[isActive]=1", map[string]interface{}{"isActive": map[string]interface{}{"$eq": true}}},
+		{"object id", "filter[categoryId]=65a251c101cef849fc779521", map[string]interface{}{"categoryId": map[string]interface{}{"$eq": categoryID}}},
+		{"date range", "filter[createdDt][gte]=2024-01-18&filter[createdDt][lt]=2024-01-19T00:00:00Z", map[string]interface{}{"createdDt": map[string]interface{}{"$gte": createdFrom, "$lt": createdFrom.AddDate(0, 0, 1)}}},
+		{"list of integers", "filter[timeSpanType][in]=1,3", map[string]interface{}{"timeSpanType": map[string]interface{}{"$in": []interface{}{int64(1), int64(3)}}}},
+		{"text as plain text", "filter[categoryTitle][contains]=go.d%2B", map[string]interface{}{"categoryTitle": map[string]interface{}{"$regex": `go\.d\+`, "$options": "i"}}},
+		{"text value with an operator", "filter[categoryTitle]={\"$ne\":null}", map[string]interface{}{"categoryTitle": map[string]interface{}{"$eq": `{"$ne":null}`}}},
+	}
+
+	for _, testCase := range testCases {
+		query, _ := url.ParseQuery(testCase.query)
+		filter, err := CompileFilters[License](query)
+		if err != nil {
+			t.Errorf("%s: %v", testCase.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(filter, testCase.expected) {
+			t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, filter)
+		}
+	}
+}
+
+func TestCompileFiltersRejectsNotAllowedFilters(t *testing.T) {
+	queries := []string{
+		"filter[password]=secret",
+		"filter[deletedAt][ne]=null",
+		"filter[role][$ne]=user",
+		"filter[role][regex]=^a",
+		"filter[isActive][gt]=1",
+		"filter[isActive]=maybe",
+		"filter[createdDt][gte]=yesterday",
+		"filter[email][contains]=a&filter[email][contains]=b",
+		"filter[role]][eq]=user",
+		"filter=role",
+	}
+
+	for _, rawQuery := range queries {
+		query, _ := url.ParseQuery(rawQuery)
+		if filter, err := CompileFilters[User](query); err == nil {
+			t.Errorf("%s: expected an error, got %v", rawQuery, filter)
+		}
+	}
+}
@@ -1,20 +1,30 @@
 package models
 
+import "time"
+
 type License struct {
-	ID                string `bson:"_id,omitempty" json:"_id"`
-	LicenseKey        string `bson:"licenseKey,omitempty" json:"licenseKey"`
-	Begin_dt          string `bson:"begin_dt,omitempty" json:"begin_dt"`
-	Expiration_dt     string `bson:"expiration_dt,omitempty" json:"expiration_dt"`
-	UserHolderId      string `bson:"userHolderId,omitempty" json:"userHolderId"`
-	UserFullName      string `bson:"userFullName,omitempty" json:"userFullName"`
-	CategoryId        string `bson:"categoryId,omitempty" json:"categoryId"`
-	CategoryType      string `bson:"categoryType,omitempty" json:"categoryType"`
-	CategoryTitle     string `bson:"categoryTitle,omitempty" json:"categoryTitle"`
-	ActivatedOnDevice string `bson:"activatedOnDevice,omitempty" json:"activatedOnDevice"`
-	TimeSpanType      int64  `bson:"timeSpanType,omitempty" json:"timeSpanType"`
-	Comments          string `bson:"comments,omitempty" json:"comments"`
-	IsActive          string `bson:"isActive,omitempty" json:"isActive"`
-	IsExpired         string `bson:"isExpired,omitempty" json:"isExpired"`
-	CreatedDt         string `bson:"createdDt,omitempty" json:"createdDt"`
-	LastUpdatedDt     string `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt"`
+	ID                string       `bson:"_id,omitempty" json:"_id" filter:"eq,ne,in,nin" filterType:"objectId" sort:"true" validate:"readonly"`
+	LicenseKey        string       `bson:"licenseKey,omitempty" json:"licenseKey" filter:"eq,in" sort:"true" search:"text,prefix" validate:"readonly"`
+	Begin_dt          time.Time    `bson:"begin_dt,omitempty" json:"begin_dt" filter:"gt,gte,lt,lte" sort:"true"`
+	Expiration_dt     time.Time    `bson:"expiration_dt,omitempty" json:"expiration_dt" filter:"gt,gte,lt,lte" sort:"true"`
+	UserHolderId      string       `bson:"userHolderId,omitempty" json:"userHolderId" filter:"eq,ne,in,nin" filterType:"objectId" validate:"required,immutable"`
+	UserFullName      string       `bson:"userFullName,omitempty" json:"userFullName" filter:"eq,ne,in,contains" sort:"true" search:"text,prefix" validate:"max=200"`
+	CategoryId        string       `bson:"categoryId,omitempty" json:"categoryId" filter:"eq,ne,in,nin" filterType:"objectId" validate:"required"`
+	CategoryType      CategoryType `bson:"categoryType" json:"categoryType" filter:"eq,ne,in,nin,gt,gte,lt,lte" sort:"true" validate:"min=0"`
+	CategoryTitle     string       `bson:"categoryTitle,omitempty" json:"categoryTitle" filter:"eq,ne,in,contains" sort:"true" search:"text" validate:"max=100"`
+	ActivatedOnDevice string       `bson:"activatedOnDevice,omitempty" json:"activatedOnDevice" validate:"max=200"`
+	TimeSpanType      int64        `bson:"timeSpanType,omitempty" json:"timeSpanType" filter:"eq,ne,in,nin" validate:"enum=1|3|6|12"`
+	Comments          string       `bson:"comments,omitempty" json:"comments" validate:"max=2000"`
+	IsActive          Flag         `bson:"isActive" json:"isActive" filter:"eq,ne"`
+	IsExpired         Flag         `bson:"isExpired" json:"isExpired" filter:"eq,ne"`
+	CreatedDt         time.Time    `bson:"createdDt,omitempty" json:"createdDt" filter:"gt,gte,lt,lte" sort:"true" validate:"readonly"`
+	LastUpdatedDt     time.Time    `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt" filter:"gt,gte,lt,lte" sort:"true" validate:"readonly"`
+	Version           int64        `bson:"version" json:"version" validate:"readonly"`
+	DeletedAt         *time.Time   `bson:"deletedAt" json:"-" validate:"readonly"`
+	DeletedBy         string       `bson:"deletedBy,omitempty" json:"-" validate:"readonly"`
+}
+
+// This method returns the version of the license (optimistic concurrency control)
+func (license License) DocumentVersion() int64 {
+	return license.Version
 }
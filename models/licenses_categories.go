@@ -1,18 +1,28 @@
 package models
 
+import "time"
+
 type LicenseCategory struct {
-	ID                     string `bson:"_id,omitempty" json:"_id"`
-	Title                  string `bson:"title,omitempty" json:"title"`
-	Description            string `bson:"description,omitempty" json:"description"`
-	PriceEurosMonthly      int64  `bson:"priceEurosMonthly,omitempty" json:"priceEurosMonthly"`
-	PriceEurosThreeMonths  int64  `bson:"priceEurosThreeMonths,omitempty" json:"priceEurosThreeMonths"`
-	PriceEurosSixMonths    int64  `bson:"priceEurosSixMonths,omitempty" json:"priceEurosSixMonths"`
-	PriceEurosTwelveMonths int64  `bson:"priceEurosTwelveMonths,omitempty" json:"priceEurosTwelveMonths"`
-	CategoryType           string `bson:"categoryType,omitempty" json:"categoryType"`
-	Comments               string `bson:"comments,omitempty" json:"comments"`
-	IsActive               string `bson:"isActive,omitempty" json:"isActive"`
-	TextsQntAllowed        int64  `bson:"textsQntAllowed,omitempty" json:"textsQntAllowed"`
-	ImagesQntAllowed       int64  `bson:"imagesQntAllowed,omitempty" json:"imagesQntAllowed"`
-	CreatedDt              string `bson:"createdDt,omitempty" json:"createdDt"`
-	LastUpdatedDt          string `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt"`
+	ID                     string       `bson:"_id,omitempty" json:"_id" filter:"eq,ne,in,nin" filterType:"objectId" sort:"true" validate:"readonly"`
+	Title                  string       `bson:"title,omitempty" json:"title" filter:"eq,ne,in,contains" sort:"true" search:"text,prefix" validate:"required,max=100"`
+	Description            string       `bson:"description,omitempty" json:"description" search:"text" validate:"max=2000"`
+	PriceEurosMonthly      int64        `bson:"priceEurosMonthly,omitempty" json:"priceEurosMonthly" filter:"eq,ne,in,nin,gt,gte,lt,lte" sort:"true" validate:"min=0"`
+	PriceEurosThreeMonths  int64        `bson:"priceEurosThreeMonths,omitempty" json:"priceEurosThreeMonths" filter:"eq,ne,in,nin,gt,gte,lt,lte" sort:"true" validate:"min=0"`
+	PriceEurosSixMonths    int64        `bson:"priceEurosSixMonths,omitempty" json:"priceEurosSixMonths" filter:"eq,ne,in,nin,gt,gte,lt,lte" sort:"true" validate:"min=0"`
+	PriceEurosTwelveMonths int64        `bson:"priceEurosTwelveMonths,omitempty" json:"priceEurosTwelveMonths" filter:"eq,ne,in,nin,gt,gte,lt,lte" sort:"true" validate:"min=0"`
+	CategoryType           CategoryType `bson:"categoryType" json:"categoryType" filter:"eq,ne,in,nin,gt,gte,lt,lte" sort:"true" validate:"required,min=0"`
+	Comments               string       `bson:"comments,omitempty" json:"comments" validate:"max=2000"`
+	IsActive               Flag         `bson:"isActive" json:"isActive" filter:"eq,ne"`
+	TextsQntAllowed        int64        `bson:"textsQntAllowed,omitempty" json:"textsQntAllowed" filter:"eq,ne,in,nin,gt,gte,lt,lte" validate:"min=0"`
+	ImagesQntAllowed       int64        `bson:"imagesQntAllowed,omitempty" json:"imagesQntAllowed" filter:"eq,ne,in,nin,gt,gte,lt,lte" validate:"min=0"`
+	CreatedDt              time.Time    `bson:"createdDt,omitempty" json:"createdDt" filter:"gt,gte,lt,lte" sort:"true" validate:"readonly"`
+	LastUpdatedDt          time.Time    `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt" filter:"gt,gte,lt,lte" sort:"true" validate:"readonly"`
+	Version                int64        `bson:"version" json:"version" validate:"readonly"`
+	DeletedAt              *time.Time   `bson:"deletedAt" json:"-" validate:"readonly"`
+	DeletedBy              string       `bson:"deletedBy,omitempty" json:"-" validate:"readonly"`
+}
+
+// This method returns the version of the license category (optimistic concurrency control)
+func (category LicenseCategory) DocumentVersion() int64 {
+	return category.Version
 }
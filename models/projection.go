@@ -0,0 +1,203 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Query parameter of the sparse fieldsets: fields=title,priceEurosMonthly
+const FIELDS_PARAM = "fields"
+const FIELDS_SEPARATOR = ","
+
+// Tag of the fields that the API never returns, even if they are requested (e.g. `projection:"never"` of the password)
+const PROJECTION_TAG = "projection"
+const PROJECTION_NEVER = "never"
+
+// Projection of the fields of a model
+type Projection struct {
+
+	// Projection of the MongoDB queries (nil: all the fields)
+	Query map[string]interface{}
+
+	// JSON names of the fields of the response (empty: all the fields)
+	Fields []string
+}
+
+// Selectable field of a model: the bson name, the JSON name of the response and if it is never returned
+type projectionField struct {
+	bsonName string
+	jsonName string
+	never    bool
+}
+
+// This method returns the selectable fields of the model (bson names of the fields that are returned in the responses)
+func SelectableFields[T any]() []string {
+	selectableFields := []string{}
+	for name, field := range projectionFieldsOf[T]() {
+		if !field.never {
+			selectableFields = append(selectableFields, name)
+		}
+	}
+	sort.Strings(selectableFields)
+	return selectableFields
+}
+
+// This method returns the hidden fields of the model (bson names of the fields with the projection:"never" tag)
+func HiddenFields[T any]() []string {
+	hiddenFields := []string{}
+	for name, field := range projectionFieldsOf[T]() {
+		if field.never {
+			hiddenFields = append(hiddenFields, name)
+		}
+	}
+	sort.Strings(hiddenFields)
+	return hiddenFields
+}
+
+// This method compiles the fields query parameter to the projection of the model
+// The fields with the projection:"never" tag are always excluded, and the _id is always included
+// Output: the projection of the excluded fields if the parameter is empty
+func CompileProjection[T any](value string) (Projection, error) {
+	fields := projectionFieldsOf[T]()
+
+	// All the fields except the excluded ones
+	if strings.TrimSpace(value) == "" {
+		projection := Projection{}
+		for name, field := range fields {
+			if field.never {
+				if projection.Query == nil {
+					projection.Query = map[string]interface{}{}
+				}
+				projection.Query[name] = 0
+			}
+		}
+		return projection, nil
+	}
+
+	projection := Projection{Query: map[string]interface{}{"_id": 1}, Fields: []string{fields["_id"].jsonName}}
+	for _, name := range strings.Split(value, FIELDS_SEPARATOR) {
+		name = strings.TrimSpace(name)
+		field, found := fields[name]
+		if name == "" {
+			return Projection{}, fmt.Errorf("not valid fields '%s', expected comma separated fields, e.g. title,priceEurosMonthly", value)
+		}
+		if !found {
+			return Projection{}, fmt.Errorf("field '%s' is not selectable, allowed: %s", name, strings.Join(SelectableFields[T](), ", "))
+		}
+
+		// The excluded fields are not returned even if they are requested
+		if field.never || projection.Query[name] != nil {
+			continue
+		}
+		projection.Query[name] = 1
+		projection.Fields = append(projection.Fields, field.jsonName)
+	}
+	return projection, nil
+}
+
+// This method returns the projection with more fields in the MongoDB query, that the server needs but the response does not
+// return (e.g. the sort keys of the cursors or the version of the ETag)
+func (projection Projection) With(fields ...string) Projection {
+	if len(projection.Fields) == 0 {
+		return projection
+	}
+
+	query := map[string]interface{}{}
+	for name, value := range projection.Query {
+		query[name] = value
+	}
+	for _, name := range fields {
+		query[name] = 1
+	}
+	return Projection{Query: query, Fields: projection.Fields}
+}
+
+// This method returns the documents with only the fields of the projection (the documents if all the fields are returned)
+func SelectFields[T any](documents []T, projection Projection) (interface{}, error) {
+	if len(projection.Fields) == 0 {
+		return documents, nil
+	}
+
+	selectedDocuments := []map[string]interface{}{}
+	for _, document := range documents {
+		selectedDocument, err := selectDocumentFields(document, projection.Fields)
+		if err != nil {
+			return nil, err
+		}
+		selectedDocuments = append(selectedDocuments, selectedDocument)
+	}
+	return selectedDocuments, nil
+}
+
+// This method returns the document with only the fields of the projection (the document if all the fields are returned)
+func SelectDocumentFields[T any](document T, projection Projection) (interface{}, error) {
+	if len(projection.Fields) == 0 {
+		return document, nil
+	}
+	return selectDocumentFields(document, projection.Fields)
+}
+
+// Private
+// This method encodes the document to JSON and keeps the given fields
+func selectDocumentFields(document interface{}, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedDocument := map[string]interface{}{}
+	if err = json.Unmarshal(data, &encodedDocument); err != nil {
+		return nil, err
+	}
+
+	selectedDocument := map[string]interface{}{}
+	for _, name := range fields {
+		if value, found := encodedDocument[name]; found {
+			selectedDocument[name] = value
+		}
+	}
+	return selectedDocument, nil
+}
+
+// Private
+// This method returns the fields of the model that are in the responses (no json:"-" tag), by bson name
+func projectionFieldsOf[T any]() map[string]projectionField {
+	fields := map[string]projectionField{}
+	for _, field := range projectionFieldListOf[T]() {
+		fields[field.bsonName] = field
+	}
+	return fields
+}
+
+// Private
+// This method returns the fields of the model that are in the responses (no json:"-" tag), in the order of the model
+func projectionFieldListOf[T any]() []projectionField {
+	var model T
+	modelType := reflect.TypeOf(model)
+	fields := []projectionField{}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		bsonName := strings.Split(field.Tag.Get("bson"), ",")[0]
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if bsonName == "" || bsonName == "-" || jsonName == "-" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+
+		fields = append(fields, projectionField{
+			bsonName: bsonName,
+			jsonName: jsonName,
+			never:    field.Tag.Get(PROJECTION_TAG) == PROJECTION_NEVER,
+		})
+	}
+	return fields
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompileProjection(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected Projection
+	}{
+		{"all the fields except the password", "", Projection{Query: map[string]interface{}{"password": 0}}},
+		{"requested fields", "email, role", Projection{Query: map[string]interface{}{"_id": 1, "email": 1, "role": 1}, Fields: []string{"_id", "email", "role"}}},
+		{"requested password", "email,password", Projection{Query: map[string]interface{}{"_id": 1, "email": 1}, Fields: []string{"_id", "email"}}},
+		{"repeated field", "email,email", Projection{Query: map[string]interface{}{"_id": 1, "email": 1}, Fields: []string{"_id", "email"}}},
+	}
+
+	for _, testCase := range testCases {
+		projection, err := CompileProjection[User](testCase.value)
+		if err != nil {
+			t.Errorf("%s: %v", testCase.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(projection, testCase.expected) {
+			t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, projection)
+		}
+	}
+
+	// No excluded fields
+	if projection, err := CompileProjection[LicenseCategory](""); err != nil || projection.Query != nil || len(projection.Fields) != 0 {
+		t.Errorf("expected all the fields of the categories, got %v (%v)", projection, err)
+	}
+
+	for _, value := range []string{"deletedAt", "email,,role", "$where", "externalIdentities.subject"} {
+		if projection, err := CompileProjection[User](value); err == nil {
+			t.Errorf("%s: expected an error, got %v", value, projection)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	projection, _ := CompileProjection[LicenseCategory]("title,priceEurosMonthly")
+	if query := projection.With("createdDt").Query; !reflect.DeepEqual(query, map[string]interface{}{"_id": 1, "title": 1, "priceEurosMonthly": 1, "createdDt": 1}) {
+		t.Errorf("unexpected query projection with the sort keys %v", query)
+	}
+
+	categories := []LicenseCategory{{ID: "65a251c101cef849fc779521", Title: "Silver", PriceEurosMonthly: 5, Description: "Not requested"}}
+	selected, err := SelectFields(categories, projection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{{"_id": "65a251c101cef849fc779521", "title": "Silver", "priceEurosMonthly": float64(5)}}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Errorf("expected %v, got %v", expected, selected)
+	}
+
+	// Without fields the documents are returned as they are
+	allFields, _ := CompileProjection[LicenseCategory]("")
+	if selected, _ := SelectDocumentFields(categories[0], allFields); !reflect.DeepEqual(selected, categories[0]) {
+		t.Errorf("expected the whole document, got %v", selected)
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tag of the searchable fields: `search:"text"` (words of the text index) or `search:"text,prefix"` (also the start of the value)
+const SEARCH_TAG = "search"
+const SEARCH_TEXT = "text"
+const SEARCH_PREFIX = "prefix"
+
+// This method returns the fields of the text index of the model
+func SearchTextFields[T any]() []string {
+	return searchFieldsOf[T](SEARCH_TEXT)
+}
+
+// This method compiles the search of the text to a MongoDB filter: the words of the text index
+// or the start of the prefix fields (e.g. the key of a license), case insensitive
+// The text is never read as a regular expression
+func SearchFilter[T any](text string) map[string]interface{} {
+	conditions := []interface{}{
+		map[string]interface{}{"$text": map[string]interface{}{"$search": text}},
+	}
+	for _, field := range searchFieldsOf[T](SEARCH_PREFIX) {
+		conditions = append(conditions, map[string]interface{}{
+			field: map[string]interface{}{"$regex": "^" + regexp.QuoteMeta(text), "$options": "i"},
+		})
+	}
+	return map[string]interface{}{"$or": conditions}
+}
+
+// Private
+// This method returns the bson names of the fields with the search option (text or prefix)
+func searchFieldsOf[T any](option string) []string {
+	var model T
+	modelType := reflect.TypeOf(model)
+	fields := []string{}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		name := strings.Split(field.Tag.Get("bson"), ",")[0]
+		if name != "" && containsValue(strings.Split(field.Tag.Get(SEARCH_TAG), ","), option) {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchFilter(t *testing.T) {
+	if fields := SearchTextFields[License](); !reflect.DeepEqual(fields, []string{"categoryTitle", "licenseKey", "userFullName"}) {
+		t.Errorf("unexpected text fields of the licenses %v", fields)
+	}
+
+	expected := map[string]interface{}{"$or": []interface{}{
+		map[string]interface{}{"$text": map[string]interface{}{"$search": "AB.1"}},
+		map[string]interface{}{"licenseKey": map[string]interface{}{"$regex": `^AB\.1`, "$options": "i"}},
+		map[string]interface{}{"userFullName": map[string]interface{}{"$regex": `^AB\.1`, "$options": "i"}},
+	}}
+	if filter := SearchFilter[License]("AB.1"); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected %v, got %v", expected, filter)
+	}
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Query parameter of the sorting: sort=-createdDt,title (the '-' prefix sorts descending)
+const SORT_PARAM = "sort"
+const SORT_SEPARATOR = ","
+const SORT_DESCENDING_PREFIX = "-"
+const MAX_SORT_FIELDS = 5
+
+// Unique field that is the last key of every sort, so the order (and the pages) are stable
+const SORT_TIEBREAKER_FIELD = "_id"
+
+// Default sorts of the list endpoints: insertion order and most recent first
+const DEFAULT_SORT = SORT_TIEBREAKER_FIELD
+const DEFAULT_SORT_MOST_RECENT = SORT_DESCENDING_PREFIX + "createdDt"
+
+// This method returns the sortable fields of the model (bson names with the sort tag, e.g. `sort:"true"`)
+func SortableFields[T any]() []string {
+	var model T
+	modelType := reflect.TypeOf(model)
+	sortableFields := []string{}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return sortableFields
+	}
+
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		name := strings.Split(field.Tag.Get("bson"), ",")[0]
+		if field.Tag.Get("sort") == "true" && name != "" {
+			sortableFields = append(sortableFields, name)
+		}
+	}
+	sort.Strings(sortableFields)
+	return sortableFields
+}
+
+// This method compiles the sort query parameter to a MongoDB sort, according to the sort tags of the model
+// The _id tiebreaker is appended with the direction of the last field, unless it is already sorted
+// Output: the default sort if the parameter is empty
+func CompileSort[T any](value string, defaultSort string) (bson.D, error) {
+	if strings.TrimSpace(value) == "" {
+		value = defaultSort
+	}
+
+	sortableFields := SortableFields[T]()
+	sortKeys := bson.D{}
+	sortedFields := map[string]bool{}
+
+	for _, item := range strings.Split(value, SORT_SEPARATOR) {
+
+		// The '+' prefix of the ascending fields is decoded as a space in the query
+		item = strings.TrimPrefix(strings.TrimSpace(item), "+")
+		direction := 1
+		if strings.HasPrefix(item, SORT_DESCENDING_PREFIX) {
+			item, direction = strings.TrimPrefix(item, SORT_DESCENDING_PREFIX), -1
+		}
+
+		if item == "" {
+			return nil, fmt.Errorf("not valid sort '%s', expected comma separated fields, e.g. -createdDt,title", value)
+		}
+		if !containsValue(sortableFields, item) {
+			return nil, fmt.Errorf("field '%s' is not sortable, allowed: %s", item, strings.Join(sortableFields, ", "))
+		}
+		if sortedFields[item] {
+			return nil, fmt.Errorf("field '%s' is sorted more than once", item)
+		}
+
+		sortedFields[item] = true
+		sortKeys = append(sortKeys, bson.E{Key: item, Value: direction})
+	}
+
+	if len(sortKeys) > MAX_SORT_FIELDS {
+		return nil, fmt.Errorf("more than %d sort fields", MAX_SORT_FIELDS)
+	}
+
+	// Stable tiebreaker
+	if !sortedFields[SORT_TIEBREAKER_FIELD] {
+		sortKeys = append(sortKeys, bson.E{Key: SORT_TIEBREAKER_FIELD, Value: sortKeys[len(sortKeys)-1].Value})
+	}
+	return sortKeys, nil
+}
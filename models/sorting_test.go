@@ -0,0 +1,48 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCompileSort(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       string
+		defaultSort string
+		expected    bson.D
+	}{
+		{"default insertion order", "", DEFAULT_SORT, bson.D{{Key: "_id", Value: 1}}},
+		{"default most recent", "", DEFAULT_SORT_MOST_RECENT, bson.D{{Key: "createdDt", Value: -1}, {Key: "_id", Value: -1}}},
+		{"multiple fields", "-createdDt,title", DEFAULT_SORT, bson.D{{Key: "createdDt", Value: -1}, {Key: "title", Value: 1}, {Key: "_id", Value: 1}}},
+		{"ascending prefix decoded as space", " title", DEFAULT_SORT, bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}},
+		{"explicit tiebreaker", "-_id", DEFAULT_SORT, bson.D{{Key: "_id", Value: -1}}},
+	}
+
+	for _, testCase := range testCases {
+		sortKeys, err := CompileSort[LicenseCategory](testCase.value, testCase.defaultSort)
+		if err != nil {
+			t.Errorf("%s: %v", testCase.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(sortKeys, testCase.expected) {
+			t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, sortKeys)
+		}
+	}
+}
+
+func TestCompileSortRejectsNotSortableFields(t *testing.T) {
+	values := []string{"password", "-deletedAt", "title,,createdDt", "-", "title,-title", "$natural", "firstName,lastName,email,role,createdDt,lastUpdatedDt"}
+
+	for _, value := range values {
+		if sortKeys, err := CompileSort[User](value, DEFAULT_SORT); err == nil {
+			t.Errorf("%s: expected an error, got %v", value, sortKeys)
+		}
+	}
+
+	if fields := SortableFields[User](); containsValue(fields, "password") || !containsValue(fields, "createdDt") {
+		t.Errorf("unexpected sortable fields of the users: %v", fields)
+	}
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Boolean flag of the models (isActive, isAdmin, isExpired)
+// DEPRECATED INPUT: the legacy "0" / "1" strings are still accepted in the JSON requests
+type Flag bool
+
+// Integer category type of the licenses and the license categories
+// DEPRECATED INPUT: the legacy numeric strings (e.g. "1") are still accepted in the JSON requests
+type CategoryType int64
+
+// Normalization of the email fields (normalize tag)
+const NORMALIZE_EMAIL = "email"
+
+// Model with the version of the optimistic concurrency control (ETag of the document)
+type Versioned interface {
+	DocumentVersion() int64
+}
+
+// This method decodes a JSON flag: true | false, or the deprecated "0" | "1" | "true" | "false" and 0 | 1
+func (flag *Flag) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, err := ParseFlag(value)
+	if err != nil {
+		return err
+	}
+	*flag = Flag(parsed)
+	return nil
+}
+
+// This method decodes a JSON category type: an integer, or a deprecated numeric string
+func (categoryType *CategoryType) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	parsed, err := ParseCategoryType(value)
+	if err != nil {
+		return err
+	}
+	*categoryType = CategoryType(parsed)
+	return nil
+}
+
+// This method parses a flag from a decoded JSON value (bool, or the deprecated strings and numbers)
+func ParseFlag(value interface{}) (bool, error) {
+	switch typedValue := value.(type) {
+	case bool:
+		return typedValue, nil
+	case Flag:
+		return bool(typedValue), nil
+	case string:
+		switch strings.TrimSpace(typedValue) {
+		case "1", "true":
+			return true, nil
+		case "0", "false":
+			return false, nil
+		}
+	case json.Number:
+		return ParseFlag(typedValue.String())
+	case float64:
+		return ParseFlag(strconv.FormatFloat(typedValue, 'f', -1, 64))
+	}
+	return false, fmt.Errorf("invalid flag %v, expected true or false", value)
+}
+
+// This method parses a category type from a decoded JSON value (integer, or a deprecated numeric string)
+func ParseCategoryType(value interface{}) (int64, error) {
+	switch typedValue := value.(type) {
+	case CategoryType:
+		return int64(typedValue), nil
+	case int64:
+		return typedValue, nil
+	case json.Number:
+		return ParseCategoryType(typedValue.String())
+	case float64:
+		if typedValue == float64(int64(typedValue)) {
+			return int64(typedValue), nil
+		}
+	case string:
+		if parsed, err := strconv.ParseInt(strings.TrimSpace(typedValue), 10, 64); err == nil {
+			return parsed, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid category type %v, expected an integer", value)
+}
+
+// This method parses an object id from a decoded JSON value (hex text of 24 characters)
+func ParseObjectID(value interface{}) (primitive.ObjectID, error) {
+	switch typedValue := value.(type) {
+	case primitive.ObjectID:
+		return typedValue, nil
+	case string:
+		if objectID, err := primitive.ObjectIDFromHex(typedValue); err == nil {
+			return objectID, nil
+		}
+	}
+	return primitive.NilObjectID, fmt.Errorf("invalid object id %v, expected 24 hexadecimal characters", value)
+}
+
+// This method converts the flags, the category type and the object ids (filterType:"objectId") of a request body (map)
+// to their types, according to the fields of the model, so the deprecated values and the id texts are never stored
+// The emails (normalize:"email") are stored in lower case
+func ConvertTypedFields[T any](document map[string]interface{}) error {
+	var model T
+	modelType := reflect.TypeOf(model)
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		name := strings.Split(field.Tag.Get("bson"), ",")[0]
+		value, found := document[name]
+		if name == "" || !found {
+			continue
+		}
+
+		var err error
+		switch field.Type {
+		case reflect.TypeOf(Flag(false)):
+			document[name], err = ParseFlag(value)
+		case reflect.TypeOf(CategoryType(0)):
+			document[name], err = ParseCategoryType(value)
+		default:
+			if field.Tag.Get("filterType") == FILTER_TYPE_OBJECT_ID {
+				document[name], err = ParseObjectID(value)
+			}
+			if email, isText := value.(string); isText && field.Tag.Get("normalize") == NORMALIZE_EMAIL {
+				document[name] = utils.NormalizeEmail(email)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("field '%s': %w", name, err)
+		}
+	}
+	return nil
+}
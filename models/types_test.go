@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFlagAcceptsTheDeprecatedStrings(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Flag
+		isValid  bool
+	}{
+		{`{"isActive": true}`, true, true},
+		{`{"isActive": false}`, false, true},
+		{`{"isActive": "1"}`, true, true},
+		{`{"isActive": "0"}`, false, true},
+		{`{"isActive": 1}`, true, true},
+		{`{"isActive": "yes"}`, false, false},
+		{`{"isActive": 2}`, false, false},
+	}
+
+	for _, testCase := range testCases {
+		var category LicenseCategory
+		err := json.Unmarshal([]byte(testCase.input), &category)
+		if testCase.isValid != (err == nil) || (err == nil && category.IsActive != testCase.expected) {
+			t.Errorf("%s: expected %v (valid %v), got %v: %v", testCase.input, testCase.expected, testCase.isValid, category.IsActive, err)
+		}
+	}
+}
+
+func TestCategoryTypeAcceptsTheDeprecatedStrings(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected CategoryType
+		isValid  bool
+	}{
+		{`{"categoryType": 3}`, 3, true},
+		{`{"categoryType": "3"}`, 3, true},
+		{`{"categoryType": 0}`, 0, true},
+		{`{"categoryType": 1.5}`, 0, false},
+		{`{"categoryType": "gold"}`, 0, false},
+	}
+
+	for _, testCase := range testCases {
+		var license License
+		err := json.Unmarshal([]byte(testCase.input), &license)
+		if testCase.isValid != (err == nil) || (err == nil && license.CategoryType != testCase.expected) {
+			t.Errorf("%s: expected %v (valid %v), got %v: %v", testCase.input, testCase.expected, testCase.isValid, license.CategoryType, err)
+		}
+	}
+}
+
+func TestConvertTypedFields(t *testing.T) {
+	document := map[string]interface{}{"isActive": "1", "categoryType": json.Number("2"), "title": "1"}
+	if err := ConvertTypedFields[LicenseCategory](document); err != nil {
+		t.Fatal(err)
+	}
+	if document["isActive"] != true || document["categoryType"] != int64(2) || document["title"] != "1" {
+		t.Fatalf("unexpected converted document %v", document)
+	}
+
+	// The references are converted to object ids
+	license := map[string]interface{}{"categoryId": "65a251c101cef849fc779521", "comments": "65a251c101cef849fc779521"}
+	if err := ConvertTypedFields[License](license); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := license["categoryId"].(primitive.ObjectID); !ok || license["comments"] != "65a251c101cef849fc779521" {
+		t.Fatalf("expected the object id of the category, got %v", license)
+	}
+	if err := ConvertTypedFields[License](map[string]interface{}{"categoryId": "bronze"}); err == nil {
+		t.Fatal("expected an error for a not valid object id")
+	}
+
+	if err := ConvertTypedFields[User](map[string]interface{}{"isAdmin": "maybe"}); err == nil {
+		t.Fatal("expected an error for a not valid flag")
+	}
+}
+
+func TestConvertTypedFieldsNormalizesTheEmails(t *testing.T) {
+	user := map[string]interface{}{"email": " Ada@Example.COM ", "firstName": "Ada"}
+	if err := ConvertTypedFields[User](user); err != nil {
+		t.Fatal(err)
+	}
+	if user["email"] != "ada@example.com" || user["firstName"] != "Ada" {
+		t.Fatalf("expected the email in lower case, got %v", user)
+	}
+}
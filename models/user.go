@@ -1,14 +1,33 @@
 package models
 
+import "time"
+
 type User struct {
-	ID            string `bson:"_id,omitempty" json:"_id"`
-	FirstName     string `bson:"firstName,omitempty" json:"firstName"`
-	LastName      string `bson:"lastName,omitempty" json:"lastName"`
-	Role          string `bson:"role,omitempty" json:"role"`
-	IsAdmin       string `bson:"isAdmin,omitempty" json:"isAdmin"`
-	IsActive      string `bson:"isActive,omitempty" json:"isActive"`
-	Email         string `bson:"email,omitempty" json:"email"`
-	Password      string `bson:"password,omitempty" json:"password"`
-	CreatedDt     string `bson:"createdDt,omitempty" json:"createdDt"`
-	LastUpdatedDt string `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt"`
+	ID            string     `bson:"_id,omitempty" json:"_id" filter:"eq,ne,in,nin" filterType:"objectId" sort:"true" validate:"readonly"`
+	FirstName     string     `bson:"firstName,omitempty" json:"firstName" filter:"eq,ne,in,contains" sort:"true" search:"text" validate:"required,max=100"`
+	LastName      string     `bson:"lastName,omitempty" json:"lastName" filter:"eq,ne,in,contains" sort:"true" search:"text" validate:"required,max=100"`
+	Role          string     `bson:"role,omitempty" json:"role" filter:"eq,ne,in,nin" sort:"true" validate:"readonly,enum=user|admin|superadmin"`
+	IsAdmin       Flag       `bson:"isAdmin" json:"isAdmin" filter:"eq,ne" validate:"readonly"`
+	IsActive      Flag       `bson:"isActive" json:"isActive" filter:"eq,ne" validate:"readonly"`
+	Email         string     `bson:"email,omitempty" json:"email" filter:"eq,ne,in,contains" sort:"true" search:"text,prefix" validate:"required,max=254" normalize:"email"`
+	Password      string     `bson:"password,omitempty" json:"password" projection:"never" validate:"readonly"`
+	CreatedDt     time.Time  `bson:"createdDt,omitempty" json:"createdDt" filter:"gt,gte,lt,lte" sort:"true" validate:"readonly"`
+	LastUpdatedDt time.Time  `bson:"lastUpdatedDt,omitempty" json:"lastUpdatedDt" filter:"gt,gte,lt,lte" sort:"true" validate:"readonly"`
+	Version       int64      `bson:"version" json:"version" validate:"readonly"`
+	DeletedAt     *time.Time `bson:"deletedAt" json:"-" validate:"readonly"`
+	DeletedBy     string     `bson:"deletedBy,omitempty" json:"-" validate:"readonly"`
+
+	ExternalIdentities []ExternalIdentity `bson:"externalIdentities,omitempty" json:"externalIdentities,omitempty" validate:"readonly"`
+}
+
+// This method returns the version of the user (optimistic concurrency control)
+func (user User) DocumentVersion() int64 {
+	return user.Version
+}
+
+// Identity of the user on an external OpenID Connect provider
+type ExternalIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"subject"`
+	LinkedDt time.Time `bson:"linkedDt,omitempty" json:"linkedDt"`
 }
@@ -0,0 +1,422 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tag of the validation rules of the model fields (e.g. `validate:"required,max=100"`)
+const VALIDATE_TAG = "validate"
+
+// Rules of the validate tag
+// required: the field is given on create, and it is never empty
+// readonly: the field is set only by the server (e.g. _id, createdDt), it is never given
+// immutable: the field is given only on create, it is never updated
+// min=N, max=N: range of the integers, or number of characters of the texts
+// enum=a|b|c: allowed values of the field
+const VALIDATE_RULE_REQUIRED = "required"
+const VALIDATE_RULE_READONLY = "readonly"
+const VALIDATE_RULE_IMMUTABLE = "immutable"
+const VALIDATE_RULE_MIN = "min"
+const VALIDATE_RULE_MAX = "max"
+const VALIDATE_RULE_ENUM = "enum"
+
+// Rules of the field errors that are not declared by the tags
+const VALIDATE_RULE_TYPE = "type"
+const VALIDATE_RULE_UNKNOWN = "unknown"
+
+// Separator of the values of the enum rule
+const VALIDATE_ENUM_SEPARATOR = "|"
+
+// Error of a field of the document data
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors of all the not valid fields of the document data
+type ValidationErrors []FieldError
+
+// This method returns the field errors as text, e.g. "title: is required; price: must be at least 0"
+func (validationErrors ValidationErrors) Error() string {
+	messages := []string{}
+	for _, fieldError := range validationErrors {
+		messages = append(messages, fieldError.Field+": "+fieldError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validated field of a model, as declared by its type and its validate tag
+type validationField struct {
+	name      string
+	fieldType reflect.Type
+	required  bool
+	readonly  bool
+	immutable bool
+	min       *int64
+	max       *int64
+	enum      []string
+	objectID  bool
+}
+
+// This method validates the document data (request body) against the fields and the validate tags of the model
+// On create (partial false) the required fields must be given, on update (partial true) only the given fields
+// are validated and the immutable fields are rejected
+// Output: ValidationErrors with every not valid field, or nil if the document data is valid
+func ValidateDocument[T any](document map[string]interface{}, partial bool) error {
+	fields, err := validationFieldsOf[T]()
+	if err != nil {
+		return err
+	}
+
+	fieldErrors := ValidationErrors{}
+	known := map[string]bool{}
+	for _, field := range fields {
+		known[field.name] = true
+		value, found := document[field.name]
+
+		switch {
+		case field.readonly && found:
+			fieldErrors = append(fieldErrors, FieldError{field.name, VALIDATE_RULE_READONLY, "is set by the server and cannot be given"})
+		case field.immutable && found && partial:
+			fieldErrors = append(fieldErrors, FieldError{field.name, VALIDATE_RULE_IMMUTABLE, "cannot be updated"})
+		case !found:
+			if field.required && !partial {
+				fieldErrors = append(fieldErrors, FieldError{field.name, VALIDATE_RULE_REQUIRED, "is required"})
+			}
+		default:
+			if fieldError := validateFieldValue(field, value); fieldError != nil {
+				fieldErrors = append(fieldErrors, *fieldError)
+			}
+		}
+	}
+
+	// The fields that are not in the model are never stored
+	unknownFields := []string{}
+	for name := range document {
+		if !known[name] {
+			unknownFields = append(unknownFields, name)
+		}
+	}
+	sort.Strings(unknownFields)
+	for _, name := range unknownFields {
+		fieldErrors = append(fieldErrors, FieldError{name, VALIDATE_RULE_UNKNOWN, "is not a field of the document"})
+	}
+
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	return nil
+}
+
+// This method validates the values of the given fields of the document data (types and rules), also of the readonly
+// fields that only the server APIs change (e.g. the role of a user, which only the admins change)
+// Every other field of the document data is rejected
+// Output: ValidationErrors with every not valid field, or nil if the document data is valid
+func ValidateFieldValues[T any](document map[string]interface{}, names []string) error {
+	fields, err := validationFieldsOf[T]()
+	if err != nil {
+		return err
+	}
+
+	fieldErrors := ValidationErrors{}
+	for _, field := range fields {
+		if value, found := document[field.name]; found && containsValue(names, field.name) {
+			if fieldError := validateFieldValue(field, value); fieldError != nil {
+				fieldErrors = append(fieldErrors, *fieldError)
+			}
+		}
+	}
+
+	otherFields := []string{}
+	for name := range document {
+		if !containsValue(names, name) {
+			otherFields = append(otherFields, name)
+		}
+	}
+	sort.Strings(otherFields)
+	for _, name := range otherFields {
+		fieldErrors = append(fieldErrors, FieldError{name, VALIDATE_RULE_UNKNOWN, "cannot be changed here, allowed: " + strings.Join(names, ", ")})
+	}
+
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	return nil
+}
+
+// Private
+// This method returns the fields of the model with their validation rules, in the order of the model
+func validationFieldsOf[T any]() ([]validationField, error) {
+	var model T
+	modelType := reflect.TypeOf(model)
+	fields := []validationField{}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return fields, nil
+	}
+
+	for i := 0; i < modelType.NumField(); i++ {
+		structField := modelType.Field(i)
+		name := strings.Split(structField.Tag.Get("bson"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		field := validationField{name: name, fieldType: structField.Type, objectID: structField.Tag.Get("filterType") == FILTER_TYPE_OBJECT_ID}
+		rules := structField.Tag.Get(VALIDATE_TAG)
+		if rules == "" {
+			fields = append(fields, field)
+			continue
+		}
+
+		for _, rule := range strings.Split(rules, ",") {
+			ruleName, ruleValue, _ := strings.Cut(rule, "=")
+			switch ruleName {
+			case VALIDATE_RULE_REQUIRED:
+				field.required = true
+			case VALIDATE_RULE_READONLY:
+				field.readonly = true
+			case VALIDATE_RULE_IMMUTABLE:
+				field.immutable = true
+			case VALIDATE_RULE_MIN, VALIDATE_RULE_MAX:
+				bound, err := strconv.ParseInt(ruleValue, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("not valid rule '%s' of the field '%s'", rule, name)
+				}
+				if ruleName == VALIDATE_RULE_MIN {
+					field.min = &bound
+				} else {
+					field.max = &bound
+				}
+			case VALIDATE_RULE_ENUM:
+				field.enum = strings.Split(ruleValue, VALIDATE_ENUM_SEPARATOR)
+			default:
+				return nil, fmt.Errorf("not supported rule '%s' of the field '%s'", rule, name)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Private
+// This method validates the type, the emptiness, the range and the allowed values of a given field
+// Output: nil if the value is valid
+func validateFieldValue(field validationField, value interface{}) *FieldError {
+	typeError := &FieldError{field.name, VALIDATE_RULE_TYPE, "must be " + describeFieldType(field.fieldType)}
+
+	// Only the pointers (e.g. deletedAt) can be null
+	if value == nil {
+		if field.required {
+			return &FieldError{field.name, VALIDATE_RULE_REQUIRED, "cannot be empty"}
+		}
+		if field.fieldType.Kind() == reflect.Pointer {
+			return nil
+		}
+		return typeError
+	}
+
+	// Value of the range and the enum rules: the integer, or the number of characters of the text
+	var number int64
+	var text string
+	switch field.fieldType {
+	case reflect.TypeOf(Flag(false)):
+		parsed, err := ParseFlag(value)
+		if err != nil {
+			return typeError
+		}
+		text = strconv.FormatBool(parsed)
+	case reflect.TypeOf(CategoryType(0)):
+		parsed, err := ParseCategoryType(value)
+		if err != nil {
+			return typeError
+		}
+		number, text = parsed, strconv.FormatInt(parsed, 10)
+	case reflect.TypeOf(time.Time{}), reflect.TypeOf(&time.Time{}):
+		date, ok := value.(string)
+		if !ok {
+			return typeError
+		}
+		if _, err := utils.ParseDate(date); err != nil {
+			return typeError
+		}
+		text = date
+	default:
+		switch field.fieldType.Kind() {
+		case reflect.String:
+			stringValue, ok := value.(string)
+			if !ok {
+				return typeError
+			}
+			number, text = int64(utf8.RuneCountInString(stringValue)), stringValue
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			jsonNumber, ok := value.(json.Number)
+			if !ok {
+				return typeError
+			}
+			parsed, err := strconv.ParseInt(jsonNumber.String(), 10, 64)
+			if err != nil {
+				return typeError
+			}
+			number, text = parsed, strconv.FormatInt(parsed, 10)
+		case reflect.Slice:
+			if _, ok := value.([]interface{}); !ok {
+				return typeError
+			}
+		case reflect.Struct:
+			if _, ok := value.(map[string]interface{}); !ok {
+				return typeError
+			}
+		}
+	}
+
+	if field.required && text == "" && field.fieldType.Kind() == reflect.String {
+		return &FieldError{field.name, VALIDATE_RULE_REQUIRED, "cannot be empty"}
+	}
+
+	// The references are stored as object ids
+	if field.objectID && !primitive.IsValidObjectID(text) {
+		return &FieldError{field.name, VALIDATE_RULE_TYPE, "must be an object id (24 hexadecimal characters)"}
+	}
+
+	isText := field.fieldType.Kind() == reflect.String
+	if field.min != nil && number < *field.min {
+		if isText {
+			return &FieldError{field.name, VALIDATE_RULE_MIN, fmt.Sprintf("must have at least %d characters", *field.min)}
+		}
+		return &FieldError{field.name, VALIDATE_RULE_MIN, fmt.Sprintf("must be at least %d", *field.min)}
+	}
+	if field.max != nil && number > *field.max {
+		if isText {
+			return &FieldError{field.name, VALIDATE_RULE_MAX, fmt.Sprintf("must have at most %d characters", *field.max)}
+		}
+		return &FieldError{field.name, VALIDATE_RULE_MAX, fmt.Sprintf("must be at most %d", *field.max)}
+	}
+	if len(field.enum) > 0 && !containsValue(field.enum, text) {
+		return &FieldError{field.name, VALIDATE_RULE_ENUM, "must be one of: " + strings.Join(field.enum, ", ")}
+	}
+	return nil
+}
+
+// Private
+// This method describes the expected JSON value of a field type (for the messages of the type errors)
+func describeFieldType(fieldType reflect.Type) string {
+	switch fieldType {
+	case reflect.TypeOf(Flag(false)):
+		return "a boolean"
+	case reflect.TypeOf(CategoryType(0)):
+		return "an integer"
+	case reflect.TypeOf(time.Time{}), reflect.TypeOf(&time.Time{}):
+		return "an RFC 3339 date (e.g. 2024-01-18T09:35:36Z)"
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String:
+		return "a text"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Slice:
+		return "a list"
+	}
+	return "an object"
+}
+
+// Fields of an update without a top level value of the model type (JSON Merge Patch, JSON Patch)
+type PatchedFields struct {
+
+	// Dotted paths of the values inside the fields (e.g. externalIdentities.0.provider)
+	Nested []string
+
+	// Fields of the lists with added items
+	Items []string
+
+	// Removed fields
+	Removed []string
+
+	// Dotted paths of the test operations
+	Tested []string
+}
+
+// This method validates the fields of a patch that are not validated with their values (see ValidateDocument)
+// The changed and the removed fields are not readonly or immutable, the removed fields are not required
+// and only the returned fields of the responses can be tested (e.g. never the password)
+// Output: ValidationErrors with every not valid field, or nil if the fields are valid
+func ValidatePatchedFields[T any](patched PatchedFields) error {
+	fields, err := validationFieldsOf[T]()
+	if err != nil {
+		return err
+	}
+	fieldsByName := map[string]validationField{}
+	for _, field := range fields {
+		fieldsByName[field.name] = field
+	}
+
+	fieldErrors := ValidationErrors{}
+	checkField := func(path string, check func(field validationField) *FieldError) {
+		name := strings.Split(path, ".")[0]
+		field, found := fieldsByName[name]
+		switch {
+		case !found:
+			fieldErrors = append(fieldErrors, FieldError{path, VALIDATE_RULE_UNKNOWN, "is not a field of the document"})
+		case field.readonly:
+			fieldErrors = append(fieldErrors, FieldError{path, VALIDATE_RULE_READONLY, "is set by the server and cannot be given"})
+		case field.immutable:
+			fieldErrors = append(fieldErrors, FieldError{path, VALIDATE_RULE_IMMUTABLE, "cannot be updated"})
+		default:
+			if fieldError := check(field); fieldError != nil {
+				fieldErrors = append(fieldErrors, *fieldError)
+			}
+		}
+	}
+
+	for _, path := range patched.Nested {
+		checkField(path, func(field validationField) *FieldError {
+			switch field.fieldType.Kind() {
+			case reflect.Struct, reflect.Slice, reflect.Map:
+				if field.fieldType != reflect.TypeOf(time.Time{}) {
+					return nil
+				}
+			}
+			return &FieldError{path, VALIDATE_RULE_TYPE, "has no fields, the field '" + field.name + "' must be " + describeFieldType(field.fieldType)}
+		})
+	}
+	for _, path := range patched.Items {
+		checkField(path, func(field validationField) *FieldError {
+			if field.fieldType.Kind() != reflect.Slice {
+				return &FieldError{path, VALIDATE_RULE_TYPE, "has no items, it must be " + describeFieldType(field.fieldType)}
+			}
+			return nil
+		})
+	}
+	for _, path := range patched.Removed {
+		checkField(path, func(field validationField) *FieldError {
+			if field.required {
+				return &FieldError{path, VALIDATE_RULE_REQUIRED, "is required and cannot be removed"}
+			}
+			return nil
+		})
+	}
+
+	// The hidden fields are never tested, so their values are not revealed
+	selectableFields := SelectableFields[T]()
+	for _, path := range patched.Tested {
+		if !containsValue(selectableFields, strings.Split(path, ".")[0]) {
+			fieldErrors = append(fieldErrors, FieldError{path, VALIDATE_RULE_UNKNOWN, "is not a field of the document"})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	return nil
+}
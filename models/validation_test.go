@@ -0,0 +1,158 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateDocument(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		partial  bool
+		expected []string // field:rule of the expected errors, in order
+	}{
+		{"valid create", `{"title": "Gold", "categoryType": "2", "priceEurosMonthly": 10, "isActive": "1"}`, false, nil},
+		{"valid update", `{"priceEurosMonthly": 0, "description": "Gold"}`, true, nil},
+		{"required on create", `{"description": "Gold"}`, false, []string{"title:required", "categoryType:required"}},
+		{"empty required on update", `{"title": ""}`, true, []string{"title:required"}},
+		{"types", `{"title": 5, "priceEurosMonthly": 1.5, "isActive": "maybe", "lastUpdatedDt": null}`, true, []string{"title:type", "priceEurosMonthly:type", "isActive:type", "lastUpdatedDt:readonly"}},
+		{"ranges", `{"title": "` + strings.Repeat("a", 101) + `", "imagesQntAllowed": -1}`, true, []string{"title:max", "imagesQntAllowed:min"}},
+		{"server fields and unknown fields", `{"_id": "65a251c101cef849fc779521", "version": 2, "zeta": 1, "alpha": 2}`, true, []string{"_id:readonly", "version:readonly", "alpha:unknown", "zeta:unknown"}},
+	}
+
+	for _, testCase := range testCases {
+		document := map[string]interface{}{}
+		decoder := json.NewDecoder(strings.NewReader(testCase.body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&document); err != nil {
+			t.Fatalf("%s: %v", testCase.name, err)
+		}
+
+		err := ValidateDocument[LicenseCategory](document, testCase.partial)
+		var fieldErrors ValidationErrors
+		if err != nil && !errors.As(err, &fieldErrors) {
+			t.Errorf("%s: expected field errors, got %v", testCase.name, err)
+			continue
+		}
+
+		var got []string
+		for _, fieldError := range fieldErrors {
+			got = append(got, fieldError.Field+":"+fieldError.Rule)
+		}
+		if !reflect.DeepEqual(got, testCase.expected) {
+			t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, got)
+		}
+	}
+}
+
+func TestValidateDocumentImmutableAndEnumFields(t *testing.T) {
+	document := map[string]interface{}{"userHolderId": "65a251c101cef849fc779521", "timeSpanType": json.Number("6")}
+	if err := ValidateDocument[License](document, false); err == nil || !strings.Contains(err.Error(), "categoryId: is required") {
+		t.Errorf("expected the required categoryId on create, got %v", err)
+	}
+
+	document["timeSpanType"] = json.Number("2")
+	err := ValidateDocument[License](document, true)
+	expected := "userHolderId: cannot be updated; timeSpanType: must be one of: 1, 3, 6, 12"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestValidateDocumentObjectIDs(t *testing.T) {
+	document := map[string]interface{}{"categoryId": "65a251c101cef849fc779521"}
+	if err := ValidateDocument[License](document, true); err != nil {
+		t.Errorf("expected a valid category id, got %v", err)
+	}
+
+	for _, value := range []interface{}{"bronze", "65a251c101cef849fc77952z", json.Number("1")} {
+		err := ValidateDocument[License](map[string]interface{}{"categoryId": value}, true)
+		var fieldErrors ValidationErrors
+		if !errors.As(err, &fieldErrors) || fieldErrors[0].Field != "categoryId" || fieldErrors[0].Rule != VALIDATE_RULE_TYPE {
+			t.Errorf("%v: expected a type error of the category id, got %v", value, err)
+		}
+	}
+}
+
+func TestValidateUserAccessFields(t *testing.T) {
+	document := map[string]interface{}{"role": "superadmin", "isAdmin": true, "isActive": "1"}
+	err := ValidateDocument[User](document, true)
+	expected := "role: is set by the server and cannot be given; isAdmin: is set by the server and cannot be given; isActive: is set by the server and cannot be given"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+
+	names := []string{"role", "isAdmin", "isActive"}
+	if err := ValidateFieldValues[User](document, names); err != nil {
+		t.Errorf("expected valid access fields, got %v", err)
+	}
+
+	document = map[string]interface{}{"role": "owner", "isActive": "maybe", "email": "a@example.com"}
+	err = ValidateFieldValues[User](document, names)
+	expected = "role: must be one of: user, admin, superadmin; isActive: must be a boolean; email: cannot be changed here, allowed: role, isAdmin, isActive"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestValidationTagsOfTheModels(t *testing.T) {
+	for name, validate := range map[string]func() error{
+		"User":            func() error { _, err := validationFieldsOf[User](); return err },
+		"LicenseCategory": func() error { _, err := validationFieldsOf[LicenseCategory](); return err },
+		"License":         func() error { _, err := validationFieldsOf[License](); return err },
+	} {
+		if err := validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// Model with updatable nested fields and lists
+type patchedModel struct {
+	Title    string            `bson:"title" json:"title" validate:"required"`
+	Tags     []string          `bson:"tags" json:"tags"`
+	Settings map[string]string `bson:"settings" json:"settings"`
+	Secret   string            `bson:"secret" json:"-" projection:"never"`
+	Owner    string            `bson:"owner" json:"owner" validate:"immutable"`
+	Version  int64             `bson:"version" json:"version" validate:"readonly"`
+}
+
+func TestValidatePatchedFields(t *testing.T) {
+	testCases := []struct {
+		name     string
+		patched  PatchedFields
+		expected []string // field:rule of the expected errors, in order
+	}{
+		{"valid", PatchedFields{Nested: []string{"settings.theme", "tags.0"}, Items: []string{"tags"}, Removed: []string{"settings"}, Tested: []string{"title", "version"}}, nil},
+		{"nested values of a plain field", PatchedFields{Nested: []string{"title.first", "version.major", "owner.name"}}, []string{"title.first:type", "version.major:readonly", "owner.name:immutable"}},
+		{"items of a plain field", PatchedFields{Items: []string{"title", "settings"}}, []string{"title:type", "settings:type"}},
+		{"removed required and unknown fields", PatchedFields{Removed: []string{"title", "nickname"}}, []string{"title:required", "nickname:unknown"}},
+		{"hidden fields are never tested", PatchedFields{Tested: []string{"secret", "tags.0"}}, []string{"secret:unknown"}},
+	}
+
+	for _, testCase := range testCases {
+		err := ValidatePatchedFields[patchedModel](testCase.patched)
+		var fieldErrors ValidationErrors
+		if err != nil && !errors.As(err, &fieldErrors) {
+			t.Errorf("%s: expected field errors, got %v", testCase.name, err)
+			continue
+		}
+
+		var got []string
+		for _, fieldError := range fieldErrors {
+			got = append(got, fieldError.Field+":"+fieldError.Rule)
+		}
+		if !reflect.DeepEqual(got, testCase.expected) {
+			t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, got)
+		}
+	}
+
+	// The password of the users is never tested
+	if err := ValidatePatchedFields[User](PatchedFields{Tested: []string{"password"}}); err == nil {
+		t.Errorf("expected the password not to be tested")
+	}
+}
@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/models"
+	"reflect"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Operations of the standard CRUD set of a resource
+type Operation string
+
+const OPERATION_CREATE Operation = "create"
+const OPERATION_LIST Operation = "list"
+const OPERATION_MOST_RECENT Operation = "mostRecent"
+const OPERATION_COUNT Operation = "count"
+const OPERATION_EXPORT Operation = "export"
+const OPERATION_GET Operation = "get"
+const OPERATION_UPDATE Operation = "update"
+const OPERATION_DELETE Operation = "delete"
+const OPERATION_DELETE_MULTIPLE Operation = "deleteMultiple"
+const OPERATION_DELETE_ALL Operation = "deleteAll"
+const OPERATION_BULK Operation = "bulk"
+
+// All the operations of the standard CRUD set, in the order of the routes
+var OPERATIONS = []Operation{
+	OPERATION_CREATE,
+	OPERATION_LIST,
+	OPERATION_MOST_RECENT,
+	OPERATION_COUNT,
+	OPERATION_EXPORT,
+	OPERATION_GET,
+	OPERATION_UPDATE,
+	OPERATION_DELETE,
+	OPERATION_DELETE_MULTIPLE,
+	OPERATION_DELETE_ALL,
+	OPERATION_BULK,
+}
+
+// Access levels of the operations (the access groups of the routes)
+const ACCESS_PUBLIC = "public"
+const ACCESS_AUTHENTICATED = "authenticated"
+const ACCESS_ADMIN = "admin"
+
+// Policy of a resource: the access level of every allowed operation
+// The operations without an access level are not mounted (e.g. a resource with its own create API)
+type Policy map[Operation]string
+
+// Resource of the API: a model and its collection
+type Resource struct {
+
+	// Name of the collection
+	Name string
+
+	// JSON schema validator of the collection (db package)
+	Schema func() bson.M
+
+	// Names of the indexes of the collection that the queries rely on (created by the migrations)
+	Indexes []string
+
+	// Access levels of the standard CRUD operations
+	Policy Policy
+
+	// Field of the id of the owning user (e.g. "_id" of the users)
+	// The users without the permission to delete all the documents only delete their own documents
+	OwnerField string
+
+	// Derived from the model on registration: its type, the fields that are never returned (projection:"never"),
+	// the filterable fields (filter tags) and the fields of the text searches (search tags)
+	Model            reflect.Type
+	HiddenFields     []string
+	FilterableFields map[string][]string
+	SearchFields     []string
+}
+
+// Registered resources, in the order of the registration
+var resources = []Resource{}
+
+// This method registers the resource of the model T
+// The model and the collection are registered once, a second registration is a programming error (panic)
+func Register[T any](resource Resource) Resource {
+	var model T
+	resource.Model = reflect.TypeOf(model)
+	resource.HiddenFields = models.HiddenFields[T]()
+	resource.FilterableFields = models.FilterableFields[T]()
+	resource.SearchFields = models.SearchTextFields[T]()
+
+	for _, registered := range resources {
+		if registered.Name == resource.Name || registered.Model == resource.Model {
+			panic(fmt.Sprintf("the resource %s (%s) is already registered", resource.Name, resource.Model))
+		}
+	}
+	resources = append(resources, resource)
+	return resource
+}
+
+// This method returns all the registered resources, in the order of the registration
+func Resources() []Resource {
+	return append([]Resource{}, resources...)
+}
+
+// This method returns the registered resource of the collection
+func Lookup(name string) (Resource, bool) {
+	for _, resource := range resources {
+		if resource.Name == name {
+			return resource, true
+		}
+	}
+	return Resource{}, false
+}
+
+// This method returns the registered resource of the model T
+func ResourceOf[T any]() (Resource, error) {
+	var model T
+	modelType := reflect.TypeOf(model)
+	for _, resource := range resources {
+		if resource.Model == modelType {
+			return resource, nil
+		}
+	}
+	return Resource{}, errors.New("not found corresponding model to collection name: " + fmt.Sprint(modelType))
+}
+
+// This method returns the collection name of the model T
+func CollectionOf[T any]() (string, error) {
+	resource, err := ResourceOf[T]()
+	return resource.Name, err
+}
@@ -0,0 +1,84 @@
+package registry
+
+import (
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/models"
+	"reflect"
+	"testing"
+)
+
+func TestCollectionOfTheModels(t *testing.T) {
+	collections := map[string]func() (string, error){
+		db.DB_TABLE_USERS:               CollectionOf[models.User],
+		db.DB_TABLE_LICENSES_CATEGORIES: CollectionOf[models.LicenseCategory],
+		db.DB_TABLE_LICENCES:            CollectionOf[models.License],
+		db.DB_TABLE_AUDIT_LOG:           CollectionOf[models.AuditLogEntry],
+	}
+	for expected, collectionOf := range collections {
+		if name, err := collectionOf(); err != nil || name != expected {
+			t.Errorf("expected the collection %s, got %s (%v)", expected, name, err)
+		}
+	}
+
+	if name, err := CollectionOf[models.ExternalIdentity](); err == nil {
+		t.Errorf("expected an error for a not registered model, got %s", name)
+	}
+}
+
+func TestRegisteredResourcesDeriveTheFieldsOfTheModels(t *testing.T) {
+	if !reflect.DeepEqual(USERS.HiddenFields, []string{"password"}) {
+		t.Errorf("expected the hidden password of the users, got %v", USERS.HiddenFields)
+	}
+	if !reflect.DeepEqual(LICENSES.FilterableFields["userHolderId"], []string{"eq", "ne", "in", "nin"}) {
+		t.Errorf("expected the filters of the license holder, got %v", LICENSES.FilterableFields["userHolderId"])
+	}
+	if resource, found := Lookup(db.DB_TABLE_LICENSES_CATEGORIES); !found || !reflect.DeepEqual(resource.SearchFields, []string{"description", "title"}) {
+		t.Errorf("expected the search fields of the categories, got %v", resource.SearchFields)
+	}
+}
+
+func TestPoliciesOfTheResources(t *testing.T) {
+	for _, resource := range Resources() {
+		if resource.Schema == nil {
+			t.Errorf("%s: no JSON schema", resource.Name)
+		}
+
+		for operation, access := range resource.Policy {
+			if !containsOperation(OPERATIONS, operation) {
+				t.Errorf("%s: not supported operation %s", resource.Name, operation)
+			}
+			if access != ACCESS_PUBLIC && access != ACCESS_AUTHENTICATED && access != ACCESS_ADMIN {
+				t.Errorf("%s: not supported access %s of the operation %s", resource.Name, access, operation)
+			}
+		}
+
+		// The users only delete their own documents
+		if resource.Policy[OPERATION_DELETE] == ACCESS_AUTHENTICATED && resource.OwnerField == "" {
+			t.Errorf("%s: deleted by every authenticated user without an owner field", resource.Name)
+		}
+	}
+
+	// The users are created only by the register and the createAdmin APIs
+	if _, found := USERS.Policy[OPERATION_CREATE]; found {
+		t.Errorf("expected no generic create of the users")
+	}
+}
+
+func TestRegisterTwiceIsRejected(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for the second registration of the users")
+		}
+	}()
+	Register[models.User](Resource{Name: "users_copy"})
+}
+
+// This method checks if the operation is in the list
+func containsOperation(operations []Operation, operation Operation) bool {
+	for _, listOperation := range operations {
+		if listOperation == operation {
+			return true
+		}
+	}
+	return false
+}
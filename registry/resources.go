@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/models"
+)
+
+// RESOURCES -----------------------------
+// ---------------------------------------
+// A new resource is a model with its tags, a JSON schema (db package), the migration of its collection
+// and its registration here. The routes mount its standard CRUD set from the policy (routes package)
+
+// USERS
+// Created only by the register and the createAdmin APIs
+var USERS = Register[models.User](Resource{
+	Name:       db.DB_TABLE_USERS,
+	Schema:     db.CreateUsersSchema,
+	Indexes:    []string{"email_1", "deletedAt_1", "createdDt_-1__id_-1", "search_text"},
+	OwnerField: "_id",
+	Policy: Policy{
+		OPERATION_GET:             ACCESS_AUTHENTICATED,
+		OPERATION_UPDATE:          ACCESS_AUTHENTICATED,
+		OPERATION_DELETE:          ACCESS_AUTHENTICATED,
+		OPERATION_LIST:            ACCESS_ADMIN,
+		OPERATION_MOST_RECENT:     ACCESS_ADMIN,
+		OPERATION_COUNT:           ACCESS_ADMIN,
+		OPERATION_EXPORT:          ACCESS_ADMIN,
+		OPERATION_DELETE_MULTIPLE: ACCESS_ADMIN,
+		OPERATION_DELETE_ALL:      ACCESS_ADMIN,
+	},
+})
+
+// LICENSE CATEGORIES
+var LICENSE_CATEGORIES = Register[models.LicenseCategory](Resource{
+	Name:    db.DB_TABLE_LICENSES_CATEGORIES,
+	Schema:  db.CreateLicenseCategoriesSchema,
+	Indexes: []string{"categoryType_1", "deletedAt_1", "createdDt_-1__id_-1", "search_text", "title_1"},
+	Policy: Policy{
+		OPERATION_LIST:            ACCESS_AUTHENTICATED,
+		OPERATION_MOST_RECENT:     ACCESS_AUTHENTICATED,
+		OPERATION_EXPORT:          ACCESS_AUTHENTICATED,
+		OPERATION_GET:             ACCESS_AUTHENTICATED,
+		OPERATION_CREATE:          ACCESS_ADMIN,
+		OPERATION_UPDATE:          ACCESS_ADMIN,
+		OPERATION_DELETE:          ACCESS_ADMIN,
+		OPERATION_COUNT:           ACCESS_ADMIN,
+		OPERATION_DELETE_MULTIPLE: ACCESS_ADMIN,
+		OPERATION_DELETE_ALL:      ACCESS_ADMIN,
+		OPERATION_BULK:            ACCESS_ADMIN,
+	},
+})
+
+// LICENSES
+// Created only by the create license API (license key, holder and category checks)
+var LICENSES = Register[models.License](Resource{
+	Name:       db.DB_TABLE_LICENCES,
+	Schema:     db.CreateLicensesSchema,
+	Indexes:    []string{"licenseKey_1", "userHolderId_1", "categoryId_1", "one_active_license_per_user", "deletedAt_1", "createdDt_-1__id_-1", "search_text", "userFullName_1"},
+	OwnerField: "userHolderId",
+	Policy: Policy{
+		OPERATION_GET:             ACCESS_AUTHENTICATED,
+		OPERATION_UPDATE:          ACCESS_AUTHENTICATED,
+		OPERATION_DELETE:          ACCESS_AUTHENTICATED,
+		OPERATION_LIST:            ACCESS_ADMIN,
+		OPERATION_MOST_RECENT:     ACCESS_ADMIN,
+		OPERATION_COUNT:           ACCESS_ADMIN,
+		OPERATION_EXPORT:          ACCESS_ADMIN,
+		OPERATION_DELETE_MULTIPLE: ACCESS_ADMIN,
+		OPERATION_DELETE_ALL:      ACCESS_ADMIN,
+		OPERATION_BULK:            ACCESS_ADMIN,
+	},
+})
+
+// AUDIT LOG
+// Append-only, listed by its own API (no CRUD operations)
+var AUDIT_LOG = Register[models.AuditLogEntry](Resource{
+	Name:    db.DB_TABLE_AUDIT_LOG,
+	Schema:  db.CreateAuditLogSchema,
+	Indexes: []string{"createdDt_-1", "actorId_1_createdDt_-1", "collection_1_action_1_createdDt_-1"},
+})
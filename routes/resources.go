@@ -0,0 +1,159 @@
+package routes
+
+import (
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/controllers"
+	"go-essentials/go-mongodb-rest-api/middleware"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"go-essentials/go-mongodb-rest-api/store"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Standard CRUD routes of a registered resource
+type ResourceRoutes struct {
+	Resource registry.Resource
+	BasePath string
+	handlers func(dataStore store.Store) map[registry.Operation]gin.HandlerFunc
+}
+
+// Method, path (after the base path of the resource) and audit action of every operation of the standard CRUD set
+var OPERATION_ROUTES = map[registry.Operation]struct {
+	Method      string
+	Path        string
+	AuditAction string
+}{
+	registry.OPERATION_CREATE:          {http.MethodPost, "", middleware.AUDIT_ACTION_CREATE},
+	registry.OPERATION_LIST:            {http.MethodGet, "", ""},
+	registry.OPERATION_MOST_RECENT:     {http.MethodGet, GET_MOST_RECENT, ""},
+	registry.OPERATION_COUNT:           {http.MethodGet, COUNT_URL, ""},
+	registry.OPERATION_EXPORT:          {http.MethodGet, EXPORT_URL, ""},
+	registry.OPERATION_GET:             {http.MethodGet, ID_OBJECT_BASIC, ""},
+	registry.OPERATION_UPDATE:          {http.MethodPatch, ID_OBJECT_BASIC, middleware.AUDIT_ACTION_UPDATE},
+	registry.OPERATION_DELETE:          {http.MethodDelete, ID_OBJECT_BASIC, middleware.AUDIT_ACTION_DELETE},
+	registry.OPERATION_DELETE_MULTIPLE: {http.MethodPost, DELETE_MULTIPLE_DOCUMENTS, middleware.AUDIT_ACTION_DELETE_MULTIPLE},
+	registry.OPERATION_DELETE_ALL:      {http.MethodDelete, DELETE_ALL_DOCUMENTS, middleware.AUDIT_ACTION_DELETE_ALL},
+	registry.OPERATION_BULK:            {http.MethodPost, BULK_URL, middleware.AUDIT_ACTION_BULK},
+}
+
+// Permissions of every operation of the standard CRUD set (see middleware.ROLE_PERMISSIONS)
+// The deletions that the policies give to every authenticated user need only PERMISSION_DELETE_OWN_DOCUMENTS:
+// the users without PERMISSION_DELETE_DOCUMENTS only delete their own documents (see registry.Resource.OwnerField)
+var OPERATION_PERMISSIONS = map[registry.Operation][]string{
+	registry.OPERATION_CREATE:          {middleware.PERMISSION_CREATE_DOCUMENTS},
+	registry.OPERATION_LIST:            {middleware.PERMISSION_READ_DOCUMENTS},
+	registry.OPERATION_MOST_RECENT:     {middleware.PERMISSION_READ_DOCUMENTS},
+	registry.OPERATION_COUNT:           {middleware.PERMISSION_READ_DOCUMENTS},
+	registry.OPERATION_EXPORT:          {middleware.PERMISSION_READ_DOCUMENTS},
+	registry.OPERATION_GET:             {middleware.PERMISSION_READ_DOCUMENTS},
+	registry.OPERATION_UPDATE:          {middleware.PERMISSION_UPDATE_DOCUMENTS},
+	registry.OPERATION_DELETE:          {middleware.PERMISSION_DELETE_DOCUMENTS},
+	registry.OPERATION_DELETE_MULTIPLE: {middleware.PERMISSION_DELETE_DOCUMENTS},
+	registry.OPERATION_DELETE_ALL:      {middleware.PERMISSION_DELETE_DOCUMENTS},
+	registry.OPERATION_BULK:            {middleware.PERMISSION_CREATE_DOCUMENTS, middleware.PERMISSION_UPDATE_DOCUMENTS, middleware.PERMISSION_DELETE_DOCUMENTS},
+}
+
+// RESOURCES WITH THE STANDARD CRUD SET -------
+// --------------------------------------------
+// One declaration per registered resource: the operations and their access groups are the policy of the resource
+var RESOURCE_ROUTES = []ResourceRoutes{
+	resourceRoutes[models.User](USERS_BASIC_URL),
+	resourceRoutes[models.LicenseCategory](LICENSES_CATEGORIES_BASIC_URL),
+	resourceRoutes[models.License](LICENSES_BASIC_URL),
+}
+
+// Private
+// This method declares the standard CRUD routes of the registered resource of the model T under the base path
+// A not registered model is a programming error (panic)
+func resourceRoutes[T any](basePath string) ResourceRoutes {
+	resource, err := registry.ResourceOf[T]()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return ResourceRoutes{
+		Resource: resource,
+		BasePath: basePath,
+		handlers: func(dataStore store.Store) map[registry.Operation]gin.HandlerFunc {
+			return map[registry.Operation]gin.HandlerFunc{
+				registry.OPERATION_CREATE:          controllers.CreateDocument[T](dataStore),
+				registry.OPERATION_LIST:            controllers.GetAllDocuments[T](dataStore),
+				registry.OPERATION_MOST_RECENT:     controllers.GetLastXDocuments[T](dataStore),
+				registry.OPERATION_COUNT:           controllers.CountAllDocuments[T](dataStore),
+				registry.OPERATION_EXPORT:          controllers.ExportDocuments[T](dataStore),
+				registry.OPERATION_GET:             controllers.GetDocumentByID[T](dataStore),
+				registry.OPERATION_UPDATE:          controllers.UpdateDocument[T](dataStore),
+				registry.OPERATION_DELETE:          controllers.DeleteDocument[T](dataStore),
+				registry.OPERATION_DELETE_MULTIPLE: controllers.DeleteMultipleDocuments[T](dataStore),
+				registry.OPERATION_DELETE_ALL:      controllers.DeleteAllDocuments[T](dataStore),
+				registry.OPERATION_BULK:            controllers.BulkWriteDocuments[T](dataStore),
+			}
+		},
+	}
+}
+
+// Private
+// This method returns the standard CRUD routes of all the resources that the policies give to the access group
+func resourceRoutesOf(dataStore store.Store, group string) []Route {
+	routes := []Route{}
+	for _, resourceRoutes := range RESOURCE_ROUTES {
+		handlers := resourceRoutes.handlers(dataStore)
+		for _, operation := range registry.OPERATIONS {
+			if resourceRoutes.Resource.Policy[operation] != group {
+				continue
+			}
+
+			operationRoute := OPERATION_ROUTES[operation]
+			routes = append(routes, Route{operationRoute.Method, resourceRoutes.BasePath + operationRoute.Path, handlers[operation]})
+		}
+	}
+	return routes
+}
+
+// Private
+// This method adds the audit targets of the mutating standard CRUD routes of the resources to the targets
+func withResourceAuditTargets(targets map[string]middleware.AuditTarget) map[string]middleware.AuditTarget {
+	for _, resourceRoutes := range RESOURCE_ROUTES {
+		for _, operation := range registry.OPERATIONS {
+			operationRoute := OPERATION_ROUTES[operation]
+			if _, allowed := resourceRoutes.Resource.Policy[operation]; !allowed || operationRoute.AuditAction == "" {
+				continue
+			}
+
+			key := auditKey(operationRoute.Method, resourceRoutes.BasePath+operationRoute.Path)
+			if _, found := targets[key]; found {
+				panic(fmt.Sprintf("the audit target %s is declared twice", key))
+			}
+			targets[key] = middleware.AuditTarget{Collection: resourceRoutes.Resource.Name, Action: operationRoute.AuditAction}
+		}
+	}
+	return targets
+}
+
+// Private
+// This method adds the permissions of the standard CRUD routes of the resources, from their policies, to the permissions
+func withResourcePermissions(permissions map[string][]string) map[string][]string {
+	for _, resourceRoutes := range RESOURCE_ROUTES {
+		for _, operation := range registry.OPERATIONS {
+			access, allowed := resourceRoutes.Resource.Policy[operation]
+			if !allowed || access == registry.ACCESS_PUBLIC {
+				continue
+			}
+
+			operationPermissions := OPERATION_PERMISSIONS[operation]
+			if operation == registry.OPERATION_DELETE && access == registry.ACCESS_AUTHENTICATED {
+				operationPermissions = []string{middleware.PERMISSION_DELETE_OWN_DOCUMENTS}
+			}
+
+			operationRoute := OPERATION_ROUTES[operation]
+			key := permissionKey(operationRoute.Method, resourceRoutes.BasePath+operationRoute.Path)
+			if _, found := permissions[key]; found {
+				panic(fmt.Sprintf("the permissions of the route %s are declared twice", key))
+			}
+			permissions[key] = operationPermissions
+		}
+	}
+	return permissions
+}
@@ -2,8 +2,12 @@ package routes
 
 import (
 	"go-essentials/go-mongodb-rest-api/controllers"
+	"go-essentials/go-mongodb-rest-api/db"
 	"go-essentials/go-mongodb-rest-api/middleware"
 	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"go-essentials/go-mongodb-rest-api/store"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,11 +15,16 @@ import (
 // ROUTING PATHS -------------------------
 // ---------------------------------------
 
+// API VERSION PREFIX
+const API_V1_PREFIX = "/api/v1"
+
 // GENERAL HELPFUL
 const ID_OBJECT_BASIC = ":id"
 const COUNT_URL = "count"
+const EXPORT_URL = "export"
 const DELETE_ALL_DOCUMENTS = "deleteAll"
 const DELETE_MULTIPLE_DOCUMENTS = "deleteMultiple"
+const BULK_URL = "bulk"
 const GET_MOST_RECENT = "getMostRecent"
 
 // USERS AND AUTHENTICATION
@@ -23,6 +32,10 @@ const SIGNUP_URL = "/register"
 const LOGIN_URL = "/login"
 const USERS_BASIC_URL = "/users/"
 const CREATE_ADMIN_USER = "createAdmin"
+const USER_ACCESS_URL = "access/"
+const JWKS_URL = "/.well-known/jwks.json"
+const OIDC_LOGIN_URL = "/auth/oidc/:provider/login"
+const OIDC_CALLBACK_URL = "/auth/oidc/:provider/callback"
 
 // LICENSES CATEGORIES
 const LICENSES_CATEGORIES_BASIC_URL = "/categoriesLicenses/"
@@ -33,68 +46,161 @@ const RENEW_LICENSE_URL = "renew/"
 const UPGRADE_LICENSE_URL = "upgrade/"
 const COUNT_PER_CATEGORY_URL = "countPerCategory"
 
+// REFERENCES
+const ORPHAN_REPORT_URL = "/references/orphans"
+
+// TRASH (SOFT DELETED DOCUMENTS)
+const TRASH_URL = "/trash/:collection"
+const RESTORE_URL = "/restore"
+
+// AUDIT LOG
+const AUDIT_LOG_URL = "/auditLog"
+
+// SEARCH
+const SEARCH_URL = "/search"
+
+// Access groups of the routes
+// (the access levels of the policies of the registered resources)
+const PUBLIC_GROUP = registry.ACCESS_PUBLIC
+const AUTHENTICATED_GROUP = registry.ACCESS_AUTHENTICATED
+const ADMIN_GROUP = registry.ACCESS_ADMIN
+
+// Single route of a group
+type Route struct {
+	Method  string
+	Path    string
+	Handler gin.HandlerFunc
+}
+
+// Group of routes that share the same middleware chain
+type RouteGroup struct {
+	Name       string
+	Middleware gin.HandlersChain
+	Routes     []Route
+}
+
+// Targets of the audit log entries of the mutating routes (collection and action)
+// The targets of the standard CRUD routes of the resources are added from their policies
+// The trash routes have the collection as a path parameter
+var AUDIT_TARGETS = withResourceAuditTargets(map[string]middleware.AuditTarget{
+	auditKey(http.MethodPost, SIGNUP_URL): {Collection: db.DB_TABLE_USERS, Action: middleware.AUDIT_ACTION_REGISTER},
+	auditKey(http.MethodPost, LOGIN_URL):  {Collection: db.DB_TABLE_USERS, Action: middleware.AUDIT_ACTION_LOGIN},
+
+	// USERS
+	auditKey(http.MethodPost, USERS_BASIC_URL+CREATE_ADMIN_USER):                {Collection: db.DB_TABLE_USERS, Action: middleware.AUDIT_ACTION_CREATE},
+	auditKey(http.MethodPatch, USERS_BASIC_URL+USER_ACCESS_URL+ID_OBJECT_BASIC): {Collection: db.DB_TABLE_USERS, Action: middleware.AUDIT_ACTION_UPDATE_ACCESS},
+
+	// LICENSES
+	auditKey(http.MethodPost, LICENSES_BASIC_URL):                                      {Collection: db.DB_TABLE_LICENCES, Action: middleware.AUDIT_ACTION_CREATE},
+	auditKey(http.MethodPatch, LICENSES_BASIC_URL+RENEW_LICENSE_URL+ID_OBJECT_BASIC):   {Collection: db.DB_TABLE_LICENCES, Action: middleware.AUDIT_ACTION_RENEW},
+	auditKey(http.MethodPatch, LICENSES_BASIC_URL+UPGRADE_LICENSE_URL+ID_OBJECT_BASIC): {Collection: db.DB_TABLE_LICENCES, Action: middleware.AUDIT_ACTION_UPGRADE},
+
+	// TRASH
+	auditKey(http.MethodPost, TRASH_URL+"/"+ID_OBJECT_BASIC+RESTORE_URL): {Action: middleware.AUDIT_ACTION_RESTORE},
+})
+
+// Permissions of the protected routes (see middleware.ROLE_PERMISSIONS)
+// The permissions of the standard CRUD routes of the resources are added from their policies (see OPERATION_PERMISSIONS)
+var ROUTE_PERMISSIONS = withResourcePermissions(map[string][]string{
+
+	// USERS
+	permissionKey(http.MethodPost, USERS_BASIC_URL+CREATE_ADMIN_USER):                {middleware.PERMISSION_MANAGE_USERS},
+	permissionKey(http.MethodPatch, USERS_BASIC_URL+USER_ACCESS_URL+ID_OBJECT_BASIC): {middleware.PERMISSION_MANAGE_USERS},
+
+	// LICENSES
+	permissionKey(http.MethodPost, LICENSES_BASIC_URL): {middleware.PERMISSION_CREATE_LICENSES},
+})
+
+// Private
+// This method returns the key of the versioned route in the audit targets
+func auditKey(method string, path string) string {
+	return middleware.AuditRouteKey(method, API_V1_PREFIX+path)
+}
+
+// Private
+// This method returns the key of the versioned route in the route permissions
+func permissionKey(method string, path string) string {
+	return middleware.PermissionRouteKey(method, API_V1_PREFIX+path)
+}
+
+// This method returns all the supported routes of the API, grouped by access level
+// The standard CRUD routes of the registered resources come first (see RESOURCE_ROUTES)
+// The handlers receive the data store through dependency injection
+func RouteGroups(dataStore store.Store) []RouteGroup {
+	return []RouteGroup{
+
+		// PUBLIC ROUTES -------------------------------------------------------------------
+		// ------------------------------------------------------------------------------------
+		{
+			Name:       PUBLIC_GROUP,
+			Middleware: gin.HandlersChain{},
+			Routes: []Route{
+				{http.MethodPost, SIGNUP_URL, controllers.Register(dataStore)},
+				{http.MethodPost, LOGIN_URL, controllers.Login(dataStore)},
+				{http.MethodGet, OIDC_LOGIN_URL, controllers.OIDCLogin},
+				{http.MethodGet, OIDC_CALLBACK_URL, controllers.OIDCCallback(dataStore)},
+			},
+		},
+
+		// PROTECTED ROUTES FOR GENERAL USAGE -------------------------------------------------
+		// ------------------------------------------------------------------------------------
+		{
+			Name:       AUTHENTICATED_GROUP,
+			Middleware: gin.HandlersChain{middleware.Authenticate(dataStore), middleware.RequirePermissions(ROUTE_PERMISSIONS)},
+			Routes: append(resourceRoutesOf(dataStore, AUTHENTICATED_GROUP), []Route{
+
+				// LICENSES
+				{http.MethodPost, LICENSES_BASIC_URL, controllers.CreateLicense(dataStore)},
+				{http.MethodPatch, LICENSES_BASIC_URL + RENEW_LICENSE_URL + ID_OBJECT_BASIC, controllers.RenewLicense(dataStore)},
+				{http.MethodPatch, LICENSES_BASIC_URL + UPGRADE_LICENSE_URL + ID_OBJECT_BASIC, controllers.UpgradeLicense(dataStore)},
+
+				// SEARCH
+				{http.MethodGet, SEARCH_URL, controllers.Search(dataStore)},
+			}...),
+		},
+
+		// PROTECTED ROUTES - FOR ADMINS|SUPERADMINS ONLY -------------------------------------
+		// ------------------------------------------------------------------------------------
+		{
+			Name:       ADMIN_GROUP,
+			Middleware: gin.HandlersChain{middleware.Authenticate(dataStore), middleware.CheckAdminUser, middleware.RequirePermissions(ROUTE_PERMISSIONS)},
+			Routes: append(resourceRoutesOf(dataStore, ADMIN_GROUP), []Route{
+
+				// USERS
+				{http.MethodPost, USERS_BASIC_URL + CREATE_ADMIN_USER, controllers.CreateAdmin[models.User](dataStore)},
+				{http.MethodPatch, USERS_BASIC_URL + USER_ACCESS_URL + ID_OBJECT_BASIC, controllers.UpdateUserAccess(dataStore)},
+
+				// LICENSES
+				{http.MethodGet, LICENSES_BASIC_URL + COUNT_PER_CATEGORY_URL, controllers.CountLicensesPerCategory(dataStore)},
+
+				// REFERENCES
+				{http.MethodGet, ORPHAN_REPORT_URL, controllers.GetOrphanReport(dataStore)},
+
+				// AUDIT LOG
+				{http.MethodGet, AUDIT_LOG_URL, controllers.GetAuditLog(dataStore)},
+
+				// TRASH
+				{http.MethodGet, TRASH_URL, controllers.GetTrash(dataStore)},
+				{http.MethodPost, TRASH_URL + "/" + ID_OBJECT_BASIC + RESTORE_URL, controllers.RestoreDocument(dataStore)},
+			}...),
+		},
+	}
+}
+
 // This method registers all possible and supported routes
-func RegisterRoutes(server *gin.Engine) {
-
-	// Not protected routes
-	server.POST(SIGNUP_URL, controllers.Register)
-	server.POST(LOGIN_URL, controllers.Login)
-
-	// Protected routes
-	protectedEventsRoutes := server.Group("/").Use(middleware.Authenticate)
-
-	// PROTECTED ROUTES FOR GENERAL USAGE -------------------------------------------------
-	// ------------------------------------------------------------------------------------
-	// ------------------------------------------------------------------------------------
-
-	// USERS -------------------------------------------------
-	// -------------------------------------------------------
-
-	// Protected routes
-	protectedEventsRoutes.GET(USERS_BASIC_URL+ID_OBJECT_BASIC, controllers.GetDocumentByID[models.User])
-	protectedEventsRoutes.PATCH(USERS_BASIC_URL+ID_OBJECT_BASIC, controllers.UpdateDocument[models.User])
-	protectedEventsRoutes.DELETE(USERS_BASIC_URL+ID_OBJECT_BASIC, controllers.DeleteDocument[models.User])
-
-	// LICENSES CATEGORIES -----------------------------------
-	// -------------------------------------------------------
-	protectedEventsRoutes.GET(LICENSES_CATEGORIES_BASIC_URL, controllers.GetAllDocuments[models.LicenseCategory])
-	protectedEventsRoutes.GET(LICENSES_CATEGORIES_BASIC_URL+GET_MOST_RECENT, controllers.GetLastXDocuments[models.LicenseCategory])
-	protectedEventsRoutes.GET(LICENSES_CATEGORIES_BASIC_URL+ID_OBJECT_BASIC, controllers.GetDocumentByID[models.LicenseCategory])
-
-	// LICENSES ----------------------------------------------
-	// -------------------------------------------------------
-	protectedEventsRoutes.POST(LICENSES_BASIC_URL, controllers.CreateLicense)
-	protectedEventsRoutes.GET(LICENSES_BASIC_URL+ID_OBJECT_BASIC, controllers.GetDocumentByID[models.License])
-	protectedEventsRoutes.PATCH(LICENSES_BASIC_URL+ID_OBJECT_BASIC, controllers.UpdateDocument[models.License])
-	protectedEventsRoutes.DELETE(LICENSES_BASIC_URL+ID_OBJECT_BASIC, controllers.DeleteDocument[models.License])
-	protectedEventsRoutes.PATCH(LICENSES_BASIC_URL+RENEW_LICENSE_URL+ID_OBJECT_BASIC, controllers.RenewLicense)
-	protectedEventsRoutes.PATCH(LICENSES_BASIC_URL+UPGRADE_LICENSE_URL+ID_OBJECT_BASIC, controllers.UpgradeLicense)
-
-	// PROTECTED ROUTES - FOR ADMINS|SUPERADMINS ONLY -------------------------------------
-	// ------------------------------------------------------------------------------------
-	// ------------------------------------------------------------------------------------
-
-	// Admin || SuperAdmin Routes - USERS
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).POST(USERS_BASIC_URL+CREATE_ADMIN_USER, controllers.CreateAdmin[models.User])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).GET(USERS_BASIC_URL, controllers.GetAllDocuments[models.User])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).GET(USERS_BASIC_URL+GET_MOST_RECENT, controllers.GetLastXDocuments[models.User])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).GET(USERS_BASIC_URL+COUNT_URL, controllers.CountAllDocuments[models.User])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).DELETE(USERS_BASIC_URL+DELETE_ALL_DOCUMENTS, controllers.DeleteAllDocuments[models.User])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).POST(USERS_BASIC_URL+DELETE_MULTIPLE_DOCUMENTS, controllers.DeleteMultipleDocuments[models.User])
-
-	// Admin || SuperAdmin Routes - LICENSE CATEGORIES
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).POST(LICENSES_CATEGORIES_BASIC_URL, controllers.CreateDocument[models.LicenseCategory])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).PATCH(LICENSES_CATEGORIES_BASIC_URL+ID_OBJECT_BASIC, controllers.UpdateDocument[models.LicenseCategory])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).DELETE(LICENSES_CATEGORIES_BASIC_URL+ID_OBJECT_BASIC, controllers.DeleteDocument[models.LicenseCategory])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).GET(LICENSES_CATEGORIES_BASIC_URL+COUNT_URL, controllers.CountAllDocuments[models.LicenseCategory])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).DELETE(LICENSES_CATEGORIES_BASIC_URL+DELETE_ALL_DOCUMENTS, controllers.DeleteAllDocuments[models.LicenseCategory])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).POST(LICENSES_CATEGORIES_BASIC_URL+DELETE_MULTIPLE_DOCUMENTS, controllers.DeleteMultipleDocuments[models.LicenseCategory])
-
-	// Admin || SuperAdmin Routes - LICENSES
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).GET(LICENSES_BASIC_URL, controllers.GetAllDocuments[models.License])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).GET(LICENSES_BASIC_URL+GET_MOST_RECENT, controllers.GetLastXDocuments[models.License])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).GET(LICENSES_BASIC_URL+COUNT_URL, controllers.CountAllDocuments[models.License])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).GET(LICENSES_BASIC_URL+COUNT_PER_CATEGORY_URL, controllers.CountLicensesPerCategory)
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).DELETE(LICENSES_BASIC_URL+DELETE_ALL_DOCUMENTS, controllers.DeleteAllDocuments[models.License])
-	protectedEventsRoutes.Use(middleware.CheckAdminUser).POST(LICENSES_BASIC_URL+DELETE_MULTIPLE_DOCUMENTS, controllers.DeleteMultipleDocuments[models.License])
+// Every group gets its own middleware chain, so the order of the routes does not matter
+func RegisterRoutes(server *gin.Engine, dataStore store.Store) {
+
+	// Well known routes (not versioned)
+	server.GET(JWKS_URL, controllers.GetJWKS)
+
+	// Versioned API routes
+	// Every mutating request is appended to the audit log
+	apiV1 := server.Group(API_V1_PREFIX, middleware.AuditLog(dataStore, AUDIT_TARGETS))
+	for _, routeGroup := range RouteGroups(dataStore) {
+		group := apiV1.Group("", routeGroup.Middleware...)
+		for _, route := range routeGroup.Routes {
+			group.Handle(route.Method, route.Path, route.Handler)
+		}
+	}
 }
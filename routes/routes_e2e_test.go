@@ -0,0 +1,633 @@
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-essentials/go-mongodb-rest-api/db"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/registry"
+	"go-essentials/go-mongodb-rest-api/store"
+	"go-essentials/go-mongodb-rest-api/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Folder with the .http scenarios
+const API_TEST_FOLDER = "../api-test"
+
+// Host of the .http scenarios
+const API_TEST_HOST = "http://localhost:8082"
+
+// Credentials of the seeded admin user
+const E2E_ADMIN_EMAIL = "admin@example.com"
+const E2E_ADMIN_PASSWORD = "admin123"
+
+// Request of a .http scenario
+type httpScenario struct {
+	Method  string
+	Path    string
+	Query   url.Values
+	Body    map[string]interface{}
+	RawBody string // body that is not a JSON object (e.g. the operations of a JSON Patch)
+}
+
+// Step of the end-to-end suite
+// The steps run in order against the same store, so every step can use the ids saved by the previous ones
+type e2eStep struct {
+	name     string
+	scenario string                 // .http file in the api-test folder
+	request  string                 // "METHOD path" of routes without a .http file ("{name}" path segments are saved ids)
+	as       string                 // token of the request: "", "user", "admin" or "invalid"
+	id       string                 // saved id that replaces the id of the path
+	query    map[string]string      // query parameters that replace the ones of the scenario ("{name}" is a saved id)
+	env      map[string]string      // environment variables of the step (e.g. the delete policies)
+	headers  map[string]string      // request headers of the step ("{name}" is a saved value, e.g. an ETag)
+	body     map[string]interface{} // body fields that replace the ones of the scenario ("{name}" is a saved id)
+	rawBody  string                 // not JSON body
+	status   int
+	expect   map[string]interface{} // dotted response path (or "header:Name") -> expected value
+	absent   []string               // dotted response paths that must not exist (e.g. the not requested fields)
+	contains []string               // texts of the response body ("{name}" is a saved value, e.g. the rows of an export)
+	excludes []string               // texts that the response body must not contain (e.g. the excluded fields of an export)
+	save     map[string]string      // saved name -> dotted response path (or "header:Name")
+}
+
+// This method parses a .http scenario (request line, headers, JSON body with // comments)
+func loadScenario(t *testing.T, fileName string) httpScenario {
+	file, err := os.Open(filepath.Join(API_TEST_FOLDER, fileName))
+	if err != nil {
+		t.Fatalf("could not open the scenario %s: %v", fileName, err)
+	}
+	defer file.Close()
+
+	scenario := httpScenario{Query: url.Values{}}
+	requestLine := ""
+	bodyLines := []string{}
+	inBody := false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		switch {
+		case requestLine == "":
+			requestLine = line
+		case line == "":
+			inBody = true
+		case inBody:
+			bodyLines = append(bodyLines, line)
+		}
+	}
+
+	// METHOD URL (the query parameters may contain spaces)
+	method, rawURL, _ := strings.Cut(requestLine, " ")
+	scenario.Method = method
+	rawURL = strings.TrimPrefix(strings.TrimSpace(rawURL), API_TEST_HOST)
+	path, rawQuery, _ := strings.Cut(rawURL, "?")
+	scenario.Path = path
+	if rawQuery != "" {
+		scenario.Query, err = url.ParseQuery(rawQuery)
+		if err != nil {
+			t.Fatalf("not valid query in the scenario %s: %v", fileName, err)
+		}
+	}
+
+	if len(bodyLines) > 0 && strings.HasPrefix(bodyLines[0], "[") {
+		scenario.RawBody = strings.Join(bodyLines, "\n")
+	} else if len(bodyLines) > 0 {
+		if err = json.Unmarshal([]byte(strings.Join(bodyLines, "\n")), &scenario.Body); err != nil {
+			t.Fatalf("not valid body in the scenario %s: %v", fileName, err)
+		}
+	}
+	return scenario
+}
+
+// This method replaces the "{name}" strings with the saved ids (at any depth)
+func resolveSaved(value interface{}, saved map[string]string) interface{} {
+	switch typed := value.(type) {
+	case string:
+		if strings.HasPrefix(typed, "{") && strings.HasSuffix(typed, "}") {
+			return saved[strings.Trim(typed, "{}")]
+		}
+	case []interface{}:
+		resolved := []interface{}{}
+		for _, item := range typed {
+			resolved = append(resolved, resolveSaved(item, saved))
+		}
+		return resolved
+	case map[string]interface{}:
+		resolved := map[string]interface{}{}
+		for key, item := range typed {
+			resolved[key] = resolveSaved(item, saved)
+		}
+		return resolved
+	}
+	return value
+}
+
+// This method returns the value of a dotted path (map keys and array indexes) or a header ("header:Name") of the response
+func responseValue(response interface{}, path string, header http.Header) (interface{}, bool) {
+	if name, isHeader := strings.CutPrefix(path, "header:"); isHeader {
+		value := header.Get(name)
+		return value, value != ""
+	}
+
+	current := response
+	for _, segment := range strings.Split(path, ".") {
+		switch typed := current.(type) {
+		case map[string]interface{}:
+			value, found := typed[segment]
+			if !found {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(typed) {
+				return nil, false
+			}
+			current = typed[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// This method creates the server over a memory store with the indexes of the database and a seeded admin user
+func newE2EServer(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	keyRing, err := utils.NewEphemeralKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.SetKeyRing(keyRing)
+
+	dataStore := store.NewMemoryStore()
+	dataStore.CreateUniqueIndex(db.DB_TABLE_USERS, store.UniqueIndex{Fields: []string{"email"}, PartialFilter: bson.M{"deletedAt": bson.M{"$type": "null"}}})
+	dataStore.CreateUniqueIndex(db.DB_TABLE_LICENSES_CATEGORIES, store.UniqueIndex{Fields: []string{"categoryType"}, PartialFilter: bson.M{"deletedAt": bson.M{"$type": "null"}}})
+	dataStore.CreateUniqueIndex(db.DB_TABLE_LICENCES, store.UniqueIndex{Fields: []string{"licenseKey"}})
+	dataStore.CreateUniqueIndex(db.DB_TABLE_LICENCES, store.UniqueIndex{Fields: []string{"userHolderId", "isActive"}, PartialFilter: bson.M{"isActive": true, "deletedAt": bson.M{"$type": "null"}}})
+	for _, resource := range registry.Resources() {
+		if len(resource.SearchFields) > 0 {
+			dataStore.CreateTextIndex(resource.Name, store.TextIndex{Fields: resource.SearchFields})
+		}
+	}
+
+	// Admin user
+	hashedPassword, err := utils.HashPassword(E2E_ADMIN_PASSWORD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := models.User{
+		FirstName:     "Admin",
+		LastName:      "Test",
+		Role:          "admin",
+		IsAdmin:       true,
+		IsActive:      true,
+		Email:         E2E_ADMIN_EMAIL,
+		Password:      hashedPassword,
+		CreatedDt:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		LastUpdatedDt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Version:       utils.INITIAL_VERSION,
+	}
+	if _, err = dataStore.Collection(db.DB_TABLE_USERS).InsertOne(context.TODO(), admin); err != nil {
+		t.Fatal(err)
+	}
+
+	server := gin.New()
+	RegisterRoutes(server, dataStore)
+	return server
+}
+
+// The steps of the .http scenarios, in order
+var e2eSteps = []e2eStep{
+
+	// WELL KNOWN ----------------------------------------------------
+	{name: "jwks", scenario: "users/get-jwks.http", status: http.StatusOK, expect: map[string]interface{}{"keys.0.use": "sig"}},
+	{name: "oidc login of not configured provider", scenario: "users/oidc-login.http", status: http.StatusNotFound},
+
+	// REGISTER AND LOGIN --------------------------------------------
+	{name: "register", scenario: "users/register.http", status: http.StatusCreated, save: map[string]string{"userId": "data.0._id"}},
+	{name: "register with existent email", scenario: "users/register.http", status: http.StatusInternalServerError},
+	{name: "register with not valid body", scenario: "users/register.http", rawBody: "not json", status: http.StatusBadRequest},
+	{name: "login", scenario: "users/login.http", status: http.StatusOK, save: map[string]string{"token:user": "token"}},
+	{name: "login with email in other case", scenario: "users/login.http", body: map[string]interface{}{"email": "Example6@Gmail.com"}, status: http.StatusOK},
+	{name: "register with existent email in other case", scenario: "users/register.http", body: map[string]interface{}{"email": "EXAMPLE6@gmail.com"}, status: http.StatusInternalServerError},
+	{name: "login with wrong password", scenario: "users/login.http", body: map[string]interface{}{"password": "wrong"}, status: http.StatusUnauthorized},
+	{name: "login with not existent email", scenario: "users/login.http", body: map[string]interface{}{"email": "nobody@example.com"}, status: http.StatusUnauthorized},
+	{name: "login admin", scenario: "users/login.http", body: map[string]interface{}{"email": E2E_ADMIN_EMAIL, "password": E2E_ADMIN_PASSWORD}, status: http.StatusOK, save: map[string]string{"token:admin": "token"}},
+
+	// AUTHORIZATION -------------------------------------------------
+	{name: "users list without token", scenario: "users/get-users.http", status: http.StatusUnauthorized},
+	{name: "users list with not valid token", scenario: "users/get-users.http", as: "invalid", status: http.StatusUnauthorized},
+	{name: "users list as user", scenario: "users/get-users.http", as: "user", status: http.StatusUnauthorized},
+	{name: "create admin as user", scenario: "users/create-admin.http", as: "user", status: http.StatusUnauthorized},
+	{name: "create category as user", scenario: "license-categories/create-category.http", as: "user", status: http.StatusUnauthorized},
+	{name: "licenses per category as user", scenario: "licenses/count-licenses-per-category.http", as: "user", status: http.StatusUnauthorized},
+
+	// USERS ---------------------------------------------------------
+	{name: "users list", scenario: "users/get-users.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"rows": 2, "totalPages": 1, "data.0.password": ""}},
+	{name: "users list not existent page", scenario: "users/get-users.http", as: "admin", query: map[string]string{"page": "4", "limit": "1"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 0, "currentPage": 4, "totalPages": 2}},
+	{name: "users list with fields", scenario: "users/get-users.http", as: "admin", query: map[string]string{"fields": "email,password"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 2, "data.0.email": E2E_ADMIN_EMAIL}, absent: []string{"data.0.password", "data.0.role"}},
+	{name: "users list with not valid fields", scenario: "users/get-users.http", as: "admin", query: map[string]string{"fields": "email,deletedAt"}, status: http.StatusBadRequest},
+	{name: "users export", scenario: "users/export-users.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"header:Content-Type": "text/csv; charset=utf-8", "header:Content-Disposition": `attachment; filename="users.csv"`}, contains: []string{"_id,firstName,lastName,role,isAdmin,isActive,email,createdDt,lastUpdatedDt,version,externalIdentities\n", E2E_ADMIN_EMAIL, "example6@gmail.com"}, excludes: []string{"password", "$2a$"}},
+	{name: "users export with fields and filter", scenario: "users/export-users.http", as: "admin", query: map[string]string{"format": "ndjson", "fields": "email,password", "filter[role]": "admin"}, status: http.StatusOK, expect: map[string]interface{}{"header:Content-Type": "application/x-ndjson"}, contains: []string{`"email":"` + E2E_ADMIN_EMAIL + `"}` + "\n"}, excludes: []string{"password", "$2a$", "example6@gmail.com", "firstName"}},
+	{name: "users export as user", scenario: "users/export-users.http", as: "user", status: http.StatusUnauthorized},
+	{name: "users export with not valid format", scenario: "users/export-users.http", as: "admin", query: map[string]string{"format": "pdf"}, status: http.StatusBadRequest, expect: map[string]interface{}{"message": "Invalid format."}},
+	{name: "users export with not valid fields", scenario: "users/export-users.http", as: "admin", query: map[string]string{"fields": "email,deletedAt"}, status: http.StatusBadRequest},
+	{name: "users list over max limit", scenario: "users/get-users.http", as: "admin", query: map[string]string{"limit": "3"}, env: map[string]string{"MAX_PAGE_LIMIT": "2"}, status: http.StatusBadRequest},
+	{name: "users list not valid limit", scenario: "users/get-users.http", as: "admin", query: map[string]string{"limit": "0"}, status: http.StatusBadRequest},
+	{name: "users list filtered by role", scenario: "users/get-users.http", as: "admin", query: map[string]string{"filter[role]": "admin"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "totalNumbersDocuments": 1, "data.0.email": E2E_ADMIN_EMAIL}},
+	{name: "users list filtered by email text", scenario: "users/get-users.http", as: "admin", query: map[string]string{"filter[email][contains]": ".*"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 0}},
+	{name: "users list filtered by password", scenario: "users/get-users.http", as: "admin", query: map[string]string{"filter[password]": "secret"}, status: http.StatusBadRequest},
+	{name: "users list filtered by operator injection", scenario: "users/get-users.http", as: "admin", query: map[string]string{"filter[role][$ne]": "user"}, status: http.StatusBadRequest},
+	{name: "specific user", scenario: "users/get-specific-user.http", as: "user", id: "userId", status: http.StatusOK, expect: map[string]interface{}{"header:ETag": `"1"`, "data.version": 1, "data.email": "example6@gmail.com", "data.password": ""}, save: map[string]string{"userETag": "header:ETag"}},
+	{name: "specific user not modified", scenario: "users/get-specific-user.http", as: "user", id: "userId", headers: map[string]string{"If-None-Match": "{userETag}"}, status: http.StatusNotModified},
+	{name: "not existent user", scenario: "users/get-specific-user.http", as: "user", status: http.StatusNotFound},
+	{name: "user with not valid id", scenario: "users/get-specific-user.http", as: "user", id: "not-an-id", status: http.StatusBadRequest},
+	{name: "update user", scenario: "users/update-user.http", as: "user", id: "userId", body: map[string]interface{}{"email": "example6@gmail.com"}, headers: map[string]string{"If-Match": "{userETag}"}, status: http.StatusOK, expect: map[string]interface{}{"header:ETag": `"2"`, "documentsUpdated": 1}, save: map[string]string{"userETag": "header:ETag"}},
+	{name: "update user without version", scenario: "users/update-user.http", as: "user", id: "userId", status: http.StatusPreconditionRequired},
+	{name: "update user with old version", scenario: "users/update-user.http", as: "user", id: "userId", headers: map[string]string{"If-Match": `"1"`}, status: http.StatusPreconditionFailed},
+	{name: "update user with not valid version", scenario: "users/update-user.http", as: "user", id: "userId", headers: map[string]string{"If-Match": "2"}, status: http.StatusBadRequest},
+	{name: "update user version field", scenario: "users/update-user.http", as: "user", id: "userId", headers: map[string]string{"If-Match": "{userETag}"}, body: map[string]interface{}{"version": 5}, status: http.StatusBadRequest},
+	{name: "specific user modified", scenario: "users/get-specific-user.http", as: "user", id: "userId", headers: map[string]string{"If-None-Match": `"1"`}, status: http.StatusOK, expect: map[string]interface{}{"header:ETag": `"2"`, "data.version": 2}},
+	{name: "update user password", scenario: "users/update-user.http", as: "user", id: "userId", body: map[string]interface{}{"password": "new123"}, headers: map[string]string{"If-Match": "{userETag}"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "password", "errors.0.rule": "readonly"}},
+	{name: "json patch user testing the password", scenario: "users/update-user.http", as: "user", id: "userId", rawBody: `[{"op": "test", "path": "/password", "value": "123456"}, {"op": "replace", "path": "/firstName", "value": "Test"}]`, headers: map[string]string{"If-Match": "{userETag}", "Content-Type": "application/json-patch+json"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "password", "errors.0.rule": "unknown"}},
+	{name: "update user role", scenario: "users/update-user.http", as: "user", id: "userId", body: map[string]interface{}{"role": "superadmin", "isAdmin": true}, headers: map[string]string{"If-Match": "{userETag}"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "role", "errors.0.rule": "readonly", "errors.1.field": "isAdmin", "errors.1.rule": "readonly"}},
+	{name: "json patch user admin flag", scenario: "users/update-user.http", as: "user", id: "userId", rawBody: `[{"op": "replace", "path": "/isAdmin", "value": true}]`, headers: map[string]string{"If-Match": "{userETag}", "Content-Type": "application/json-patch+json"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "isAdmin", "errors.0.rule": "readonly"}},
+	{name: "update user access as user", scenario: "users/update-user-access.http", as: "user", id: "userId", headers: map[string]string{"If-Match": "{userETag}"}, status: http.StatusUnauthorized},
+	{name: "update user access not valid role", scenario: "users/update-user-access.http", as: "admin", id: "userId", body: map[string]interface{}{"role": "owner", "email": "other@example.com"}, headers: map[string]string{"If-Match": "{userETag}"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "role", "errors.0.rule": "enum", "errors.1.field": "email", "errors.1.rule": "unknown"}},
+	{name: "update user access", scenario: "users/update-user-access.http", as: "admin", id: "userId", headers: map[string]string{"If-Match": "{userETag}"}, status: http.StatusOK, expect: map[string]interface{}{"header:ETag": `"3"`, "documentsUpdated": 1}, save: map[string]string{"userETag": "header:ETag"}},
+	{name: "update user access with old version", scenario: "users/update-user-access.http", as: "admin", id: "userId", headers: map[string]string{"If-Match": `"2"`}, status: http.StatusPreconditionFailed},
+	{name: "update access of not existent user", scenario: "users/update-user-access.http", as: "admin", id: "65a251c101cef849fc779521", headers: map[string]string{"If-Match": `"1"`}, status: http.StatusNotFound},
+	{name: "update access of not existent user without version", scenario: "users/update-user-access.http", as: "admin", id: "65a251c101cef849fc779521", status: http.StatusNotFound},
+	{name: "most recent users", scenario: "users/get-last-X-users.http", as: "admin", query: map[string]string{"page": "1"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 1}},
+	{name: "most recent users not existent page", scenario: "users/get-last-X-users.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"rows": 0}},
+	{name: "count users", scenario: "users/count-users.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"rows": 2}},
+	{name: "create admin", scenario: "users/create-admin.http", as: "admin", status: http.StatusCreated, save: map[string]string{"createdAdminId": "data.0._id"}},
+	{name: "create admin with not valid role", scenario: "users/create-admin.http", as: "admin", body: map[string]interface{}{"role": "owner", "email": "other@example.com"}, status: http.StatusInternalServerError},
+	{name: "delete other user as user", scenario: "users/delete-user.http", as: "user", id: "createdAdminId", status: http.StatusNotFound},
+	{name: "delete multiple users", scenario: "users/delete-multiple-users.http", as: "admin", body: map[string]interface{}{"listOfIds": []interface{}{"{createdAdminId}"}}, status: http.StatusOK, expect: map[string]interface{}{"documentsDeleted": 1}},
+	{name: "delete multiple users without ids", scenario: "users/delete-multiple-users.http", as: "admin", body: map[string]interface{}{"listOfIds": []interface{}{}}, status: http.StatusBadRequest},
+
+	// LICENSE CATEGORIES --------------------------------------------
+	{name: "create category", scenario: "license-categories/create-category.http", as: "admin", status: http.StatusCreated, save: map[string]string{"bronzeId": "data.0._id"}},
+	{name: "create category with existent type", scenario: "license-categories/create-category.http", as: "admin", status: http.StatusInternalServerError},
+	{name: "create category with empty body", scenario: "license-categories/create-category.http", as: "admin", rawBody: "{}", status: http.StatusBadRequest},
+	{name: "create category with not valid fields", scenario: "license-categories/create-category.http", as: "admin", body: map[string]interface{}{"_id": "65a251c101cef849fc779521", "title": "", "priceEurosMonthly": -3, "priceEurosSixMonths": "15", "createdDt": "2024-01-18T09:35:36Z", "owner": "me"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "_id", "errors.0.rule": "readonly", "errors.1.field": "title", "errors.1.rule": "required", "errors.2.field": "priceEurosMonthly", "errors.2.rule": "min", "errors.3.field": "priceEurosSixMonths", "errors.3.rule": "type", "errors.4.field": "createdDt", "errors.4.rule": "readonly", "errors.5.field": "owner", "errors.5.rule": "unknown"}},
+	{name: "create category without required fields", scenario: "license-categories/create-category.http", as: "admin", rawBody: `{"description": "No title"}`, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "title", "errors.1.field": "categoryType", "errors.1.rule": "required"}},
+	{name: "create second category", scenario: "license-categories/create-category.http", as: "admin", body: map[string]interface{}{"title": "Silver", "categoryType": "1"}, status: http.StatusCreated, save: map[string]string{"silverId": "data.0._id"}},
+	{name: "categories list", scenario: "license-categories/get-categories.http", as: "user", status: http.StatusOK, expect: map[string]interface{}{"rows": 2, "data.0.title": "Bronze"}},
+	{name: "categories list sorted by title descending", scenario: "license-categories/get-categories.http", as: "user", query: map[string]string{"sort": "-title"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 2, "data.0.title": "Silver", "data.1.title": "Bronze"}},
+	{name: "categories list sorted by type and title", scenario: "license-categories/get-categories.http", as: "user", query: map[string]string{"sort": "-categoryType,title"}, status: http.StatusOK, expect: map[string]interface{}{"data.0.title": "Silver"}},
+	{name: "categories list sorted by not sortable field", scenario: "license-categories/get-categories.http", as: "user", query: map[string]string{"sort": "description"}, status: http.StatusBadRequest},
+	{name: "categories first cursor page", scenario: "license-categories/get-categories.http", as: "user", query: map[string]string{"after": "", "limit": "1"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "data.0.title": "Bronze", "prev": nil}, save: map[string]string{"categoriesNext": "next"}},
+	{name: "categories next cursor page", request: "GET {categoriesNext}", as: "user", status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "data.0.title": "Silver", "next": nil}, save: map[string]string{"categoriesPrev": "prev"}},
+	{name: "categories previous cursor page", request: "GET {categoriesPrev}", as: "user", status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "data.0.title": "Bronze", "prev": nil}},
+	{name: "categories cursor page with count", scenario: "license-categories/get-categories.http", as: "user", query: map[string]string{"after": "", "count": "true", "sort": "-title"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 2, "data.0.title": "Silver", "totalNumbersDocuments": 2, "next": nil}},
+	{name: "categories cursor of another sort", request: "GET {categoriesNext}", as: "user", query: map[string]string{"sort": "-title"}, status: http.StatusBadRequest},
+	{name: "categories not valid cursor", scenario: "license-categories/get-categories.http", as: "user", query: map[string]string{"after": "not-a-cursor"}, status: http.StatusBadRequest},
+	{name: "categories list with fields", scenario: "license-categories/get-categories-fields.http", as: "user", query: map[string]string{"after": "", "limit": "1", "sort": "-createdDt"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "data.0.title": "Silver", "data.0._id": "{silverId}"}, absent: []string{"data.0.createdDt", "data.0.description"}, save: map[string]string{"categoriesFieldsNext": "next"}},
+	{name: "categories next page with fields", request: "GET {categoriesFieldsNext}", as: "user", status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "data.0.title": "Bronze", "data.0.priceEurosMonthly": 3}, absent: []string{"data.0.createdDt"}},
+	{name: "categories export", scenario: "license-categories/export-categories.http", as: "user", status: http.StatusOK, expect: map[string]interface{}{"header:Content-Type": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "header:Content-Disposition": `attachment; filename="licensecategories.xlsx"`}, contains: []string{"PK", "xl/worksheets/sheet1.xml"}},
+	{name: "categories export to csv", scenario: "license-categories/export-categories.http", as: "user", query: map[string]string{"format": "csv"}, status: http.StatusOK, contains: []string{"_id,title,priceEurosMonthly\n", "{silverId}", ",Bronze,3\n"}, excludes: []string{"description"}},
+	{name: "specific category with fields", scenario: "license-categories/get-specific-category.http", as: "user", id: "bronzeId", query: map[string]string{"fields": "title"}, status: http.StatusOK, expect: map[string]interface{}{"data.title": "Bronze", "header:ETag": `"1"`}, absent: []string{"data.version", "data.priceEurosMonthly"}},
+	{name: "specific category", scenario: "license-categories/get-specific-category.http", as: "user", id: "bronzeId", status: http.StatusOK, expect: map[string]interface{}{"data.title": "Bronze", "data.priceEurosMonthly": 3, "data.categoryType": 0, "data.isActive": true}},
+	{name: "most recent categories", scenario: "license-categories/get-last-X-categories.http", as: "user", query: map[string]string{"created_dtFrom": "", "created_dtTo": "", "limit": "2"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 2, "data.0.title": "Silver", "data.1.title": "Bronze"}},
+	{name: "most recent categories sorted by title", scenario: "license-categories/get-last-X-categories.http", as: "user", query: map[string]string{"created_dtFrom": "", "created_dtTo": "", "sort": "title"}, status: http.StatusOK, expect: map[string]interface{}{"data.0.title": "Bronze"}},
+	{name: "most recent categories of past dates", scenario: "license-categories/get-last-X-categories.http", as: "user", status: http.StatusOK, expect: map[string]interface{}{"rows": 0}},
+	{name: "update category", scenario: "license-categories/update-category.http", as: "admin", id: "silverId", headers: map[string]string{"If-Match": `"1"`}, status: http.StatusOK, expect: map[string]interface{}{"documentsUpdated": 1}, save: map[string]string{"silverETag": "header:ETag"}},
+	{name: "update not existent category", scenario: "license-categories/update-category.http", as: "admin", id: "65a251c101cef849fc779521", headers: map[string]string{"If-Match": `"1"`}, status: http.StatusNotFound},
+	{name: "update not existent category without version", scenario: "license-categories/update-category.http", as: "admin", id: "65a251c101cef849fc779521", status: http.StatusNotFound},
+	{name: "update category as user", scenario: "license-categories/update-category.http", as: "user", id: "silverId", status: http.StatusUnauthorized},
+	{name: "updated category", scenario: "license-categories/get-specific-category.http", as: "user", id: "silverId", status: http.StatusOK, expect: map[string]interface{}{"data.priceEurosMonthly": 100, "data.textsQntAllowed": 2000}},
+	{name: "deactivate category with deprecated flag", scenario: "license-categories/update-category.http", as: "admin", id: "silverId", body: map[string]interface{}{"isActive": "0"}, headers: map[string]string{"If-Match": "{silverETag}"}, status: http.StatusOK, expect: map[string]interface{}{"documentsUpdated": 1}, save: map[string]string{"silverETag": "header:ETag"}},
+	{name: "deactivated category", scenario: "license-categories/get-specific-category.http", as: "user", id: "silverId", status: http.StatusOK, expect: map[string]interface{}{"data.isActive": false, "data.categoryType": 1}},
+	{name: "update category with not valid flag", scenario: "license-categories/update-category.http", as: "admin", id: "silverId", body: map[string]interface{}{"isActive": "maybe"}, headers: map[string]string{"If-Match": "{silverETag}"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "isActive", "errors.0.rule": "type"}},
+	{name: "activate category", scenario: "license-categories/update-category.http", as: "admin", id: "silverId", body: map[string]interface{}{"isActive": true}, headers: map[string]string{"If-Match": "{silverETag}"}, status: http.StatusOK, expect: map[string]interface{}{"documentsUpdated": 1, "header:Accept-Patch": "application/json, application/merge-patch+json, application/json-patch+json"}, save: map[string]string{"silverETag": "header:ETag"}},
+	{name: "merge patch category", scenario: "license-categories/merge-patch-category.http", as: "admin", id: "silverId", headers: map[string]string{"If-Match": "{silverETag}", "Content-Type": "application/merge-patch+json"}, status: http.StatusOK, expect: map[string]interface{}{"documentsUpdated": 1}, save: map[string]string{"silverETag": "header:ETag"}},
+	{name: "merge patched category", scenario: "license-categories/get-specific-category.http", as: "user", id: "silverId", status: http.StatusOK, expect: map[string]interface{}{"data.comments": "Merged", "data.description": "", "data.title": "Silver"}},
+	{name: "merge patch category removing a required field", scenario: "license-categories/merge-patch-category.http", as: "admin", id: "silverId", rawBody: `{"title": null, "version": 9}`, headers: map[string]string{"If-Match": "{silverETag}", "Content-Type": "application/merge-patch+json"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "version", "errors.0.rule": "readonly", "errors.1.field": "title", "errors.1.rule": "required"}},
+	{name: "json patch category", scenario: "license-categories/json-patch-category.http", as: "admin", id: "silverId", headers: map[string]string{"If-Match": "{silverETag}", "Content-Type": "application/json-patch+json"}, status: http.StatusOK, expect: map[string]interface{}{"documentsUpdated": 1}, save: map[string]string{"silverETag": "header:ETag"}},
+	{name: "json patch category with failed test", scenario: "license-categories/json-patch-category.http", as: "admin", id: "silverId", rawBody: `[{"op": "test", "path": "/priceEurosMonthly", "value": 100}, {"op": "replace", "path": "/priceEurosMonthly", "value": 1}]`, headers: map[string]string{"If-Match": "{silverETag}", "Content-Type": "application/json-patch+json"}, status: http.StatusConflict},
+	{name: "json patch category removing a missing field", scenario: "license-categories/json-patch-category.http", as: "admin", id: "silverId", rawBody: `[{"op": "remove", "path": "/description"}]`, headers: map[string]string{"If-Match": "{silverETag}", "Content-Type": "application/json-patch+json"}, status: http.StatusConflict},
+	{name: "json patch category with old version", scenario: "license-categories/json-patch-category.http", as: "admin", id: "silverId", headers: map[string]string{"If-Match": `"1"`, "Content-Type": "application/json-patch+json"}, status: http.StatusPreconditionFailed},
+	{name: "json patch category with move", scenario: "license-categories/json-patch-category.http", as: "admin", id: "silverId", rawBody: `[{"op": "move", "from": "/comments", "path": "/description"}]`, headers: map[string]string{"If-Match": "{silverETag}", "Content-Type": "application/json-patch+json"}, status: http.StatusUnprocessableEntity},
+	{name: "json patch category with not valid path", scenario: "license-categories/json-patch-category.http", as: "admin", id: "silverId", rawBody: `[{"op": "replace", "path": "/$set", "value": 1}]`, headers: map[string]string{"If-Match": "{silverETag}", "Content-Type": "application/json-patch+json"}, status: http.StatusBadRequest},
+	{name: "json patched category", scenario: "license-categories/get-specific-category.http", as: "user", id: "silverId", status: http.StatusOK, expect: map[string]interface{}{"data.priceEurosMonthly": 120, "data.version": 6}},
+	{name: "bulk write categories", scenario: "license-categories/bulk-categories.http", as: "admin", body: map[string]interface{}{"operations": []interface{}{
+		map[string]interface{}{"op": "update", "_id": "{silverId}", "version": 6, "document": map[string]interface{}{"comments": "Bulk", "isActive": "0"}},
+		map[string]interface{}{"op": "update", "_id": "{silverId}", "version": 6, "document": map[string]interface{}{"comments": "Twice"}},
+		map[string]interface{}{"op": "create", "document": map[string]interface{}{"description": "No title"}},
+		map[string]interface{}{"op": "delete", "_id": "65a251c101cef849fc779522", "version": 1},
+		map[string]interface{}{"op": "replace", "_id": "65a251c101cef849fc779523"},
+		map[string]interface{}{"op": "update", "_id": "{bronzeId}", "version": 9, "document": map[string]interface{}{"priceEurosMonthly": 4}},
+		map[string]interface{}{"op": "update", "_id": "65a251c101cef849fc779521", "document": map[string]interface{}{"priceEurosMonthly": 4}},
+	}}, status: http.StatusOK, expect: map[string]interface{}{"ordered": false, "updated": 1, "failed": 6, "data.0.result": "updated", "data.0.version": 7, "data.1.status": 400, "data.2.status": 422, "data.2.errors.0.field": "title", "data.3.status": 422, "data.4.status": 400, "data.5.status": 412, "data.6.status": 428}},
+	{name: "bulk written category", scenario: "license-categories/get-specific-category.http", as: "user", id: "silverId", status: http.StatusOK, expect: map[string]interface{}{"header:ETag": `"7"`, "data.comments": "Bulk", "data.isActive": false}, save: map[string]string{"silverETag": "header:ETag"}},
+	{name: "ordered bulk write categories", scenario: "license-categories/bulk-categories.http", as: "admin", body: map[string]interface{}{"ordered": true, "operations": []interface{}{
+		map[string]interface{}{"op": "update", "_id": "{silverId}", "version": 7, "document": map[string]interface{}{"isActive": true}},
+		map[string]interface{}{"op": "create", "document": map[string]interface{}{"title": 5, "categoryType": 3}},
+		map[string]interface{}{"op": "create", "document": map[string]interface{}{"title": "Platinum", "categoryType": 3}},
+	}}, status: http.StatusOK, expect: map[string]interface{}{"ordered": true, "updated": 1, "failed": 1, "skipped": 1, "data.0.version": 8, "data.1.errors.0.rule": "type", "data.2.result": "skipped", "data.2.status": 424}, save: map[string]string{"silverETag": "data.0.version"}},
+	{name: "ordered bulk write categories with old version", scenario: "license-categories/bulk-categories.http", as: "admin", body: map[string]interface{}{"ordered": true, "operations": []interface{}{
+		map[string]interface{}{"op": "update", "_id": "{silverId}", "version": 7, "document": map[string]interface{}{"comments": "Old"}},
+		map[string]interface{}{"op": "create", "document": map[string]interface{}{"title": "Platinum", "categoryType": 3}},
+	}}, status: http.StatusOK, expect: map[string]interface{}{"updated": 0, "failed": 1, "skipped": 1, "data.0.status": 412, "data.1.result": "skipped", "data.1.status": 424}},
+	{name: "ordered bulk write categories with not existent id", scenario: "license-categories/bulk-categories.http", as: "admin", body: map[string]interface{}{"ordered": true, "operations": []interface{}{
+		map[string]interface{}{"op": "update", "_id": "65a251c101cef849fc779521", "version": 1, "document": map[string]interface{}{"comments": "Missing"}},
+		map[string]interface{}{"op": "update", "_id": "{silverId}", "version": 8, "document": map[string]interface{}{"comments": "Stopped"}},
+	}}, status: http.StatusOK, expect: map[string]interface{}{"failed": 1, "skipped": 1, "data.0.status": 404, "data.1.status": 424}},
+	{name: "category not written by the stopped bulk writes", scenario: "license-categories/get-specific-category.http", as: "user", id: "silverId", status: http.StatusOK, expect: map[string]interface{}{"header:ETag": `"8"`, "data.comments": "Bulk"}},
+	{name: "bulk write categories as user", scenario: "license-categories/bulk-categories.http", as: "user", status: http.StatusUnauthorized},
+	{name: "bulk write categories without operations", scenario: "license-categories/bulk-categories.http", as: "admin", rawBody: `{"operations": []}`, status: http.StatusBadRequest},
+	{name: "count categories", scenario: "license-categories/count-categories.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"rows": 2}},
+	{name: "count categories filtered by title", scenario: "license-categories/count-categories.http", as: "admin", query: map[string]string{"filter[title][in]": "Silver,Platinum"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 1}},
+
+	// LICENSES ------------------------------------------------------
+	{name: "create license", scenario: "licenses/create-license.http", as: "user", body: map[string]interface{}{"userHolderId": "{userId}", "categoryId": "{bronzeId}"}, status: http.StatusCreated, save: map[string]string{"licenseId": "data.0._id"}},
+	{name: "create second license of user", scenario: "licenses/create-license.http", as: "user", body: map[string]interface{}{"userHolderId": "{userId}", "categoryId": "{bronzeId}"}, status: http.StatusConflict},
+	{name: "delete user with licenses", scenario: "users/delete-user.http", as: "user", id: "userId", status: http.StatusConflict},
+	{name: "delete users with licenses", scenario: "users/delete-multiple-users.http", as: "admin", body: map[string]interface{}{"listOfIds": []interface{}{"{userId}"}}, status: http.StatusConflict},
+	{name: "create license with not valid time span", scenario: "licenses/create-license.http", as: "user", body: map[string]interface{}{"userHolderId": "{userId}", "categoryId": "{bronzeId}", "timeSpanType": 5}, status: http.StatusBadRequest},
+	{name: "create license of not existent user", scenario: "licenses/create-license.http", as: "user", body: map[string]interface{}{"categoryId": "{bronzeId}"}, status: http.StatusNotFound},
+	{name: "create license without token", scenario: "licenses/create-license.http", status: http.StatusUnauthorized},
+	{name: "specific license", scenario: "licenses/get-specific-license.http", as: "user", id: "licenseId", status: http.StatusOK, expect: map[string]interface{}{"data.categoryTitle": "Bronze", "data.categoryType": 0, "data.isActive": true, "data.isExpired": false, "data.userHolderId": "{userId}"}, save: map[string]string{"licenseETag": "header:ETag"}},
+	{name: "licenses export", scenario: "licenses/export-licenses.http", as: "admin", query: map[string]string{"filter[userHolderId]": "{userId}"}, status: http.StatusOK, expect: map[string]interface{}{"header:Content-Type": "application/x-ndjson", "header:Content-Disposition": `attachment; filename="licenses.ndjson"`}, contains: []string{`{"_id":"`, "{licenseId}", `"categoryTitle":"Bronze"`}},
+	{name: "licenses export as user", scenario: "licenses/export-licenses.http", as: "user", status: http.StatusUnauthorized},
+	{name: "search by holder name", scenario: "search/search.http", as: "user", status: http.StatusOK, expect: map[string]interface{}{"data.licenses.rows": 1, "data.licenses.data.0._id": "{licenseId}", "data.users.rows": 1, "data.users.data.0.password": "", "data.licensecategories.rows": 0}, save: map[string]string{"licenseKey": "data.licenses.data.0.licenseKey"}},
+	{name: "search by key", scenario: "search/search.http", as: "admin", query: map[string]string{"q": "{licenseKey}", "collections": "licenses"}, status: http.StatusOK, expect: map[string]interface{}{"totalNumbersDocuments": 1, "data.licenses.data.0._id": "{licenseId}"}, absent: []string{"data.users"}},
+	{name: "search of the own users", scenario: "search/search.http", as: "user", query: map[string]string{"q": "example", "collections": "users"}, status: http.StatusOK, expect: map[string]interface{}{"data.users.totalNumbersDocuments": 1, "data.users.data.0._id": "{userId}"}},
+	{name: "search of all the users as admin", scenario: "search/search.http", as: "admin", query: map[string]string{"q": "example", "collections": "users"}, status: http.StatusOK, expect: map[string]interface{}{"data.users.totalNumbersDocuments": 2}},
+	{name: "search of categories with regex characters", scenario: "search/search.http", as: "user", query: map[string]string{"q": "bro.*"}, status: http.StatusOK, expect: map[string]interface{}{"totalNumbersDocuments": 0}},
+	{name: "search of categories by title prefix", scenario: "search/search.http", as: "user", query: map[string]string{"q": "bro", "collections": "licensecategories"}, status: http.StatusOK, expect: map[string]interface{}{"data.licensecategories.data.0._id": "{bronzeId}"}},
+	{name: "search without text", scenario: "search/search.http", as: "user", query: map[string]string{"q": " "}, status: http.StatusBadRequest},
+	{name: "search of not searchable collection", scenario: "search/search.http", as: "user", query: map[string]string{"collections": "audit_log"}, status: http.StatusBadRequest},
+	{name: "search without token", scenario: "search/search.http", status: http.StatusUnauthorized},
+	{name: "update license", scenario: "licenses/update-license.http", as: "user", id: "licenseId", body: map[string]interface{}{"categoryId": "{bronzeId}"}, headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusOK, expect: map[string]interface{}{"documentsUpdated": 1}, save: map[string]string{"licenseETag": "header:ETag"}},
+	{name: "updated license expiration date", scenario: "licenses/get-specific-license.http", as: "user", id: "licenseId", status: http.StatusOK, expect: map[string]interface{}{"data.expiration_dt": "2024-07-15T13:24:47Z"}},
+	{name: "update license immutable fields", scenario: "licenses/update-license.http", as: "user", id: "licenseId", body: map[string]interface{}{"licenseKey": "ABC", "userHolderId": "{userId}", "timeSpanType": 2}, headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "licenseKey", "errors.0.rule": "readonly", "errors.1.field": "userHolderId", "errors.1.rule": "immutable", "errors.2.field": "timeSpanType", "errors.2.rule": "enum"}},
+	{name: "update license with not valid date", scenario: "licenses/update-license.http", as: "user", id: "licenseId", body: map[string]interface{}{"expiration_dt": "15/07/2024"}, headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "expiration_dt", "errors.0.rule": "type"}},
+	{name: "licenses of the updated category id", scenario: "licenses/get-licenses.http", as: "admin", query: map[string]string{"filter[categoryId]": "{bronzeId}"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "data.0._id": "{licenseId}"}},
+	{name: "update license with not valid category id", scenario: "licenses/update-license.http", as: "user", id: "licenseId", body: map[string]interface{}{"categoryId": "bronze"}, headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "categoryId", "errors.0.rule": "type"}},
+	{name: "json patch license with not valid category id", scenario: "licenses/update-license.http", as: "user", id: "licenseId", rawBody: `[{"op": "replace", "path": "/categoryId", "value": "65a251c1"}]`, headers: map[string]string{"If-Match": "{licenseETag}", "Content-Type": "application/json-patch+json"}, status: http.StatusUnprocessableEntity, expect: map[string]interface{}{"errors.0.field": "categoryId", "errors.0.rule": "type"}},
+	{name: "renew license", scenario: "licenses/renew-license.http", as: "user", id: "licenseId", headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusOK, expect: map[string]interface{}{"data.0.version": 3, "data.0.expiration_dt": "2025-04-18T16:45:47Z", "data.0.timeSpanType": 3}, save: map[string]string{"licenseETag": "header:ETag"}},
+	{name: "renew license with old version", scenario: "licenses/renew-license.http", as: "user", id: "licenseId", headers: map[string]string{"If-Match": `"2"`}, status: http.StatusPreconditionFailed},
+	{name: "renew license without version", scenario: "licenses/renew-license.http", as: "user", id: "licenseId", status: http.StatusPreconditionRequired},
+	{name: "renew license with not valid time span", scenario: "licenses/renew-license.http", as: "user", id: "licenseId", body: map[string]interface{}{"timeSpanType": 2}, headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusBadRequest},
+	{name: "renew not existent license", scenario: "licenses/renew-license.http", as: "user", headers: map[string]string{"If-Match": `"1"`}, status: http.StatusNotFound},
+	{name: "upgrade license to not existent category", scenario: "licenses/upgrade-license.http", as: "user", id: "licenseId", body: map[string]interface{}{"categoryId": "{licenseId}"}, headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusNotFound},
+	{name: "upgrade license", scenario: "licenses/upgrade-license.http", as: "user", id: "licenseId", body: map[string]interface{}{"categoryId": "{silverId}", "categoryTitle": "Gold"}, headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusOK, expect: map[string]interface{}{"data.0.categoryTitle": "Silver", "data.0.categoryType": 1, "data.0.categoryId": "{silverId}"}, save: map[string]string{"licenseETag": "header:ETag"}},
+	{name: "upgrade license with old version", scenario: "licenses/upgrade-license.http", as: "user", id: "licenseId", headers: map[string]string{"If-Match": `"3"`}, body: map[string]interface{}{"categoryId": "{silverId}"}, status: http.StatusPreconditionFailed},
+	{name: "upgrade license without begin date", scenario: "licenses/upgrade-license.http", as: "user", id: "licenseId", body: map[string]interface{}{"categoryId": "{silverId}", "begin_dt": ""}, headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusBadRequest},
+	{name: "licenses list", scenario: "licenses/get-licenses.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "totalNumbersDocuments": 1}},
+	{name: "licenses list filtered by category", scenario: "licenses/get-licenses.http", as: "admin", query: map[string]string{"filter[categoryId]": "{silverId}"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "data.0.categoryId": "{silverId}"}},
+	{name: "licenses list filtered by date and keys", scenario: "licenses/get-filtered-licenses.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"rows": 0, "totalNumbersDocuments": 0}},
+	{name: "licenses list filtered by not valid category", scenario: "licenses/get-licenses.http", as: "admin", query: map[string]string{"filter[categoryId]": "not-an-id"}, status: http.StatusBadRequest},
+	{name: "licenses list with cursor", scenario: "licenses/get-licenses-cursor.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"rows": 1, "totalNumbersDocuments": 1, "next": nil, "prev": nil, "data.0._id": "{licenseId}"}},
+	{name: "licenses list as user", scenario: "licenses/get-licenses.http", as: "user", status: http.StatusUnauthorized},
+	{name: "most recent licenses", scenario: "licenses/get-last-X-licenses.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"rows": 1}},
+	{name: "most recent licenses filtered by creation", scenario: "licenses/get-last-X-licenses.http", as: "admin", query: map[string]string{"filter[createdDt][gte]": "2999-01-01"}, status: http.StatusOK, expect: map[string]interface{}{"rows": 0}},
+	{name: "most recent licenses of not valid date", scenario: "licenses/get-last-X-licenses.http", as: "admin", query: map[string]string{"created_dtFrom": "2024-13-45"}, status: http.StatusBadRequest},
+	{name: "count licenses", request: "GET /api/v1/licenses/count", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"rows": 1}},
+	{name: "licenses per category", scenario: "licenses/count-licenses-per-category.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"licensesCount": 1, "data.0.title": "Silver", "data.0.countLicenses": 1}},
+	{name: "licenses per category of future dates", scenario: "licenses/count-licenses-per-category.http", as: "admin", query: map[string]string{"created_dtFrom": "2999-01-01T00:00:00Z"}, status: http.StatusOK, expect: map[string]interface{}{"licensesCount": 0}},
+	{name: "licenses per category of not valid date", scenario: "licenses/count-licenses-per-category.http", as: "admin", query: map[string]string{"created_dtFrom": "yesterday"}, status: http.StatusBadRequest},
+	{name: "delete license", scenario: "licenses/delete-license.http", as: "user", id: "licenseId", status: http.StatusOK},
+	{name: "delete deleted license", scenario: "licenses/delete-license.http", as: "user", id: "licenseId", status: http.StatusInternalServerError},
+	{name: "create license again", scenario: "licenses/create-license.http", as: "user", body: map[string]interface{}{"userHolderId": "{userId}", "categoryId": "{bronzeId}"}, status: http.StatusCreated, save: map[string]string{"licenseId": "data.0._id"}},
+	{name: "delete multiple licenses", scenario: "licenses/delete-multiple-licenses.http", as: "admin", body: map[string]interface{}{"listOfIds": []interface{}{"{licenseId}"}}, status: http.StatusOK, expect: map[string]interface{}{"documentsDeleted": 1}},
+	{name: "delete multiple licenses with not valid id", scenario: "licenses/delete-multiple-licenses.http", as: "admin", body: map[string]interface{}{"listOfIds": []interface{}{"not-an-id"}}, status: http.StatusNotFound},
+	{name: "create license for the bulk write", scenario: "licenses/create-license.http", as: "user", body: map[string]interface{}{"userHolderId": "{userId}", "categoryId": "{bronzeId}"}, status: http.StatusCreated, save: map[string]string{"bulkLicenseId": "data.0._id"}},
+	{name: "bulk write licenses", scenario: "licenses/bulk-licenses.http", as: "admin", body: map[string]interface{}{"operations": []interface{}{
+		map[string]interface{}{"op": "delete", "_id": "{bulkLicenseId}", "version": 1},
+		map[string]interface{}{"op": "create", "document": map[string]interface{}{"userHolderId": "{userId}", "categoryId": "{bronzeId}"}},
+		map[string]interface{}{"op": "delete", "_id": "{licenseId}", "version": 1},
+	}}, status: http.StatusOK, expect: map[string]interface{}{"deleted": 1, "failed": 2, "data.0.result": "deleted", "data.0.version": 2, "data.1.status": 405, "data.2.status": 404}},
+	{name: "license deleted by the bulk write", scenario: "licenses/get-specific-license.http", as: "user", id: "bulkLicenseId", status: http.StatusNotFound},
+	{name: "update license deleted by the bulk write", scenario: "licenses/update-license.http", as: "user", id: "bulkLicenseId", body: map[string]interface{}{"categoryId": "{bronzeId}"}, headers: map[string]string{"If-Match": `"2"`}, status: http.StatusNotFound},
+	{name: "create license once more", scenario: "licenses/create-license.http", as: "user", body: map[string]interface{}{"userHolderId": "{userId}", "categoryId": "{bronzeId}"}, status: http.StatusCreated},
+	{name: "delete all licenses", scenario: "licenses/delete-all-licenses.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"documentsDeleted": 1}},
+
+	// DELETE CATEGORIES ---------------------------------------------
+	{name: "create license of deleted category", scenario: "licenses/create-license.http", as: "user", body: map[string]interface{}{"userHolderId": "{userId}", "categoryId": "{bronzeId}"}, status: http.StatusCreated, save: map[string]string{"licenseId": "data.0._id", "cascadedLicenseId": "data.0._id"}},
+	{name: "delete category with restrict policy", scenario: "license-categories/delete-category.http", as: "admin", id: "bronzeId", env: map[string]string{"DELETE_POLICY_LICENSES_CATEGORY": "restrict"}, status: http.StatusConflict},
+	{name: "delete category with not valid policy", scenario: "license-categories/delete-category.http", as: "admin", id: "bronzeId", env: map[string]string{"DELETE_POLICY_LICENSES_CATEGORY": "ignore"}, status: http.StatusInternalServerError},
+	{name: "delete category with reassign policy without target", scenario: "license-categories/delete-category.http", as: "admin", id: "bronzeId", env: map[string]string{"DELETE_POLICY_LICENSES_CATEGORY": "reassign"}, status: http.StatusBadRequest},
+	{name: "delete category reassigning to itself", scenario: "license-categories/delete-category.http", as: "admin", id: "bronzeId", env: map[string]string{"DELETE_POLICY_LICENSES_CATEGORY": "reassign"}, query: map[string]string{"reassignTo": "{bronzeId}"}, status: http.StatusBadRequest},
+	{name: "delete category reassigning to not existent category", scenario: "license-categories/delete-category.http", as: "admin", id: "bronzeId", env: map[string]string{"DELETE_POLICY_LICENSES_CATEGORY": "reassign"}, query: map[string]string{"reassignTo": "{userId}"}, status: http.StatusNotFound},
+	{name: "category not deleted by the failed policies", scenario: "license-categories/get-specific-category.http", as: "user", id: "bronzeId", status: http.StatusOK},
+	{name: "delete category", scenario: "license-categories/delete-category.http", as: "admin", id: "bronzeId", status: http.StatusOK, expect: map[string]interface{}{"cascadesDeleted.licenses": 1}},
+	{name: "license of deleted category", scenario: "licenses/get-specific-license.http", as: "user", id: "licenseId", status: http.StatusNotFound},
+	{name: "create third category", scenario: "license-categories/create-category.http", as: "admin", body: map[string]interface{}{"title": "Gold", "categoryType": 2}, status: http.StatusCreated, save: map[string]string{"goldId": "data.0._id"}},
+	{name: "create license of reassigned category", scenario: "licenses/create-license.http", as: "user", body: map[string]interface{}{"userHolderId": "{userId}", "categoryId": "{silverId}"}, status: http.StatusCreated, save: map[string]string{"licenseId": "data.0._id"}},
+	{name: "delete category with reassign policy", scenario: "license-categories/delete-category.http", as: "admin", id: "silverId", env: map[string]string{"DELETE_POLICY_LICENSES_CATEGORY": "reassign"}, query: map[string]string{"reassignTo": "{goldId}"}, status: http.StatusOK, expect: map[string]interface{}{"referencesReassigned.licenses": 1}},
+	{name: "license of reassigned category", scenario: "licenses/get-specific-license.http", as: "user", id: "licenseId", status: http.StatusOK, expect: map[string]interface{}{"header:ETag": `"2"`, "data.categoryId": "{goldId}", "data.categoryTitle": "Gold", "data.categoryType": 2}, save: map[string]string{"licenseETag": "header:ETag"}},
+	{name: "orphan report", scenario: "references/get-orphan-report.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"orphansCount": 0, "data.1.deletePolicy": "restrict"}},
+	{name: "orphan report as user", scenario: "references/get-orphan-report.http", as: "user", status: http.StatusUnauthorized},
+	{name: "orphan license of category", scenario: "licenses/update-license.http", as: "user", id: "licenseId", body: map[string]interface{}{"categoryId": "{silverId}"}, headers: map[string]string{"If-Match": "{licenseETag}"}, status: http.StatusOK},
+	{name: "orphan report with orphan license", scenario: "references/get-orphan-report.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"orphansCount": 1, "data.0.count": 1, "data.0.documents.0._id": "{licenseId}"}},
+	{name: "delete orphan license", scenario: "licenses/delete-license.http", as: "user", id: "licenseId", status: http.StatusOK},
+	{name: "delete category as user", scenario: "license-categories/delete-category.http", as: "user", id: "goldId", status: http.StatusUnauthorized},
+	{name: "delete multiple categories", scenario: "license-categories/delete-multiple-categories.http", as: "admin", body: map[string]interface{}{"listOfIds": []interface{}{"{goldId}"}}, status: http.StatusOK, expect: map[string]interface{}{"documentsDeleted": 1}},
+
+	// TRASH ---------------------------------------------------------
+	{name: "categories trash", scenario: "trash/get-trash.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"totalNumbersDocuments": 3, "data.0._id": "{goldId}"}},
+	{name: "categories trash as user", scenario: "trash/get-trash.http", as: "user", status: http.StatusUnauthorized},
+	{name: "trash of not existent collection", request: "GET /api/v1/trash/things", as: "admin", status: http.StatusNotFound},
+	{name: "restore license of deleted category", request: "POST /api/v1/trash/licenses/{cascadedLicenseId}/restore", as: "admin", status: http.StatusConflict},
+	{name: "restore category", request: "POST /api/v1/trash/licensecategories/{bronzeId}/restore", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"documentsRestored": 1, "cascadesRestored.licenses": 1}},
+	{name: "restored category", scenario: "license-categories/get-specific-category.http", as: "user", id: "bronzeId", status: http.StatusOK, expect: map[string]interface{}{"data.title": "Bronze"}},
+	{name: "restored license of category", scenario: "licenses/get-specific-license.http", as: "user", id: "cascadedLicenseId", status: http.StatusOK, expect: map[string]interface{}{"data.categoryTitle": "Bronze"}},
+	{name: "restore not deleted category", request: "POST /api/v1/trash/licensecategories/{bronzeId}/restore", as: "admin", status: http.StatusNotFound},
+	{name: "restore orphan license", request: "POST /api/v1/trash/licenses/{licenseId}/restore", as: "admin", status: http.StatusConflict},
+	{name: "hard delete category as admin", scenario: "license-categories/delete-category.http", as: "admin", id: "bronzeId", query: map[string]string{"hard": "true"}, status: http.StatusForbidden},
+	{name: "delete all categories", scenario: "license-categories/delete-all-categories.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"documentsDeleted": 1, "cascadesDeleted.licenses": 1}},
+
+	// AUDIT LOG -----------------------------------------------------
+	{name: "audit log of deleted categories", scenario: "audit-log/get-audit-log.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"totalNumbersDocuments": 1, "data.0.actorEmail": E2E_ADMIN_EMAIL, "data.0.method": "DELETE", "data.0.route": "/api/v1/categoriesLicenses/deleteAll", "data.0.status": 200}},
+	{name: "audit log of restored category", scenario: "audit-log/get-audit-log.http", as: "admin", query: map[string]string{"collection": "licensecategories", "action": "restore"}, status: http.StatusOK, expect: map[string]interface{}{"totalNumbersDocuments": 2, "data.0.status": 404, "data.1.documentIds.0": "{bronzeId}"}},
+	{name: "audit log of logins", scenario: "audit-log/get-audit-log.http", as: "admin", query: map[string]string{"collection": "users", "action": "login"}, status: http.StatusOK, expect: map[string]interface{}{"data.0.requestBody.password": "[REDACTED]", "data.0.requestBody.email": E2E_ADMIN_EMAIL}},
+	{name: "audit log of not valid date", scenario: "audit-log/get-audit-log.http", as: "admin", query: map[string]string{"created_dtFrom": "yesterday"}, status: http.StatusBadRequest},
+	{name: "audit log as user", scenario: "audit-log/get-audit-log.http", as: "user", status: http.StatusUnauthorized},
+
+	// DELETE USERS --------------------------------------------------
+	{name: "delete user", scenario: "users/delete-user.http", as: "user", id: "userId", status: http.StatusOK},
+	{name: "token of deleted user", scenario: "users/get-specific-user.http", as: "user", id: "userId", status: http.StatusNotFound},
+	{name: "login of deleted user", scenario: "users/login.http", status: http.StatusUnauthorized},
+	{name: "register with email of deleted user", scenario: "users/register.http", status: http.StatusCreated},
+	{name: "restore user with taken email", request: "POST /api/v1/trash/users/{userId}/restore", as: "admin", status: http.StatusConflict},
+	{name: "create superadmin", scenario: "users/create-admin.http", as: "admin", body: map[string]interface{}{"role": "superadmin", "email": "super@example.com"}, status: http.StatusCreated},
+	{name: "login superadmin", scenario: "users/login.http", body: map[string]interface{}{"email": "super@example.com", "password": "test123"}, status: http.StatusOK, save: map[string]string{"token:superadmin": "token"}},
+	{name: "users trash", request: "GET /api/v1/trash/users", as: "superadmin", status: http.StatusOK, expect: map[string]interface{}{"totalNumbersDocuments": 2, "data.0._id": "{userId}", "data.0.email": "example6@gmail.com"}, absent: []string{"data.0.password", "data.1.password"}},
+	{name: "hard delete user with deleted licenses", scenario: "users/hard-delete-user.http", as: "superadmin", id: "userId", status: http.StatusConflict},
+	{name: "hard delete all licenses", scenario: "licenses/delete-all-licenses.http", as: "superadmin", query: map[string]string{"hard": "true"}, status: http.StatusOK, expect: map[string]interface{}{"documentsDeleted": 6}},
+	{name: "hard delete user", scenario: "users/hard-delete-user.http", as: "superadmin", id: "userId", status: http.StatusOK},
+	{name: "users trash after hard delete", request: "GET /api/v1/trash/users", as: "superadmin", status: http.StatusOK, expect: map[string]interface{}{"totalNumbersDocuments": 1}},
+	{name: "delete all users", scenario: "users/delete-all-users.http", as: "admin", status: http.StatusOK, expect: map[string]interface{}{"documentsDeleted": 3}},
+	{name: "token of deleted admin", scenario: "users/count-users.http", as: "admin", status: http.StatusNotFound},
+}
+
+func TestEndToEndScenarios(t *testing.T) {
+	server := newE2EServer(t)
+	saved := map[string]string{"token:invalid": "not.a.token"}
+
+	for _, step := range e2eSteps {
+		ok := t.Run(step.name, func(t *testing.T) {
+
+			// Request of the scenario
+			scenario := httpScenario{Query: url.Values{}}
+			if step.scenario != "" {
+				scenario = loadScenario(t, step.scenario)
+			} else {
+				scenario.Method, scenario.Path, _ = strings.Cut(step.request, " ")
+			}
+
+			// Path id and saved ids of the path ("{name}" segments)
+			segments := strings.Split(scenario.Path, "/")
+			for position, segment := range segments {
+				segments[position] = fmt.Sprint(resolveSaved(segment, saved))
+			}
+			path := strings.Join(segments, "/")
+			if step.id != "" {
+				id, found := saved[step.id]
+				if !found {
+					id = step.id
+				}
+				path = path[:strings.LastIndex(path, "/")+1] + id
+			}
+
+			// Query parameters
+			for key, value := range step.query {
+				scenario.Query.Set(key, fmt.Sprint(resolveSaved(value, saved)))
+			}
+
+			// Environment of the step (restored after the step)
+			for key, value := range step.env {
+				t.Setenv(key, value)
+			}
+			if len(scenario.Query) > 0 {
+				if strings.Contains(path, "?") {
+					path += "&" + scenario.Query.Encode()
+				} else {
+					path += "?" + scenario.Query.Encode()
+				}
+			}
+
+			// Body
+			var body []byte
+			if step.rawBody != "" {
+				body = []byte(step.rawBody)
+			} else if scenario.RawBody != "" {
+				body = []byte(scenario.RawBody)
+			} else if scenario.Body != nil || step.body != nil {
+				if scenario.Body == nil {
+					scenario.Body = map[string]interface{}{}
+				}
+				for key, value := range step.body {
+					scenario.Body[key] = value
+				}
+				for key, value := range scenario.Body {
+					scenario.Body[key] = resolveSaved(value, saved)
+				}
+
+				var err error
+				if body, err = json.Marshal(scenario.Body); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Execute the request and follow the trailing slash redirects like the HTTP clients do
+			var recorder *httptest.ResponseRecorder
+			for redirects := 0; redirects < 2; redirects++ {
+				request := httptest.NewRequest(scenario.Method, path, bytes.NewReader(body))
+				request.Header.Set("Content-Type", "application/json")
+				if step.as != "" {
+					request.Header.Set("Authorization", "Bearer "+saved["token:"+step.as])
+				}
+				for key, value := range step.headers {
+					request.Header.Set(key, fmt.Sprint(resolveSaved(value, saved)))
+				}
+
+				recorder = httptest.NewRecorder()
+				server.ServeHTTP(recorder, request)
+
+				location := recorder.Header().Get("Location")
+				if (recorder.Code != http.StatusMovedPermanently && recorder.Code != http.StatusTemporaryRedirect) || location == "" {
+					break
+				}
+				path = location
+			}
+
+			if recorder.Code != step.status {
+				t.Fatalf("%s %s: expected status %d, got %d: %s", scenario.Method, path, step.status, recorder.Code, recorder.Body.String())
+			}
+
+			// Expectations of the response (the not modified responses have no body, the exports are not JSON)
+			var response interface{}
+			if recorder.Code != http.StatusNotModified && strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json") {
+				if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+					t.Fatalf("not valid JSON response: %v", err)
+				}
+			}
+
+			for responsePath, expected := range step.expect {
+				value, found := responseValue(response, responsePath, recorder.Header())
+				expected = resolveSaved(expected, saved)
+				if !found || fmt.Sprint(value) != fmt.Sprint(expected) {
+					t.Errorf("expected %s = %v, got %v", responsePath, expected, value)
+				}
+			}
+			for _, responsePath := range step.absent {
+				if value, found := responseValue(response, responsePath, recorder.Header()); found {
+					t.Errorf("expected no %s, got %v", responsePath, value)
+				}
+			}
+			for _, text := range step.contains {
+				text = fmt.Sprint(resolveSaved(text, saved))
+				if !strings.Contains(recorder.Body.String(), text) {
+					t.Errorf("expected the body to contain %q, got %q", text, recorder.Body.String())
+				}
+			}
+			for _, text := range step.excludes {
+				if strings.Contains(recorder.Body.String(), text) {
+					t.Errorf("expected the body not to contain %q, got %q", text, recorder.Body.String())
+				}
+			}
+
+			// Saved values for the next steps
+			for name, responsePath := range step.save {
+				value, found := responseValue(response, responsePath, recorder.Header())
+				if !found {
+					t.Fatalf("no %s in the response to save as %s", responsePath, name)
+				}
+				saved[name] = fmt.Sprint(value)
+			}
+		})
+
+		// The next steps depend on the state of this step
+		if !ok {
+			t.FailNow()
+		}
+	}
+}
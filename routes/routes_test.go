@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"go-essentials/go-mongodb-rest-api/middleware"
+	"go-essentials/go-mongodb-rest-api/models"
+	"go-essentials/go-mongodb-rest-api/store"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware chains of the access groups
+var expectedChains = map[string][]string{
+	PUBLIC_GROUP:        {},
+	AUTHENTICATED_GROUP: {"middleware.Authenticate", "middleware.RequirePermissions"},
+	ADMIN_GROUP:         {"middleware.Authenticate", "middleware.CheckAdminUser", "middleware.RequirePermissions"},
+}
+
+// Every endpoint of the API with its access group
+var expectedRoutes = map[string]string{
+	"POST /api/v1/register":                          PUBLIC_GROUP,
+	"POST /api/v1/login":                             PUBLIC_GROUP,
+	"GET /api/v1/auth/oidc/:provider/login":          PUBLIC_GROUP,
+	"GET /api/v1/auth/oidc/:provider/callback":       PUBLIC_GROUP,
+	"GET /.well-known/jwks.json":                     PUBLIC_GROUP,
+	"GET /api/v1/users/:id":                          AUTHENTICATED_GROUP,
+	"PATCH /api/v1/users/:id":                        AUTHENTICATED_GROUP,
+	"DELETE /api/v1/users/:id":                       AUTHENTICATED_GROUP,
+	"GET /api/v1/categoriesLicenses/":                AUTHENTICATED_GROUP,
+	"GET /api/v1/categoriesLicenses/getMostRecent":   AUTHENTICATED_GROUP,
+	"GET /api/v1/categoriesLicenses/export":          AUTHENTICATED_GROUP,
+	"GET /api/v1/categoriesLicenses/:id":             AUTHENTICATED_GROUP,
+	"POST /api/v1/licenses/":                         AUTHENTICATED_GROUP,
+	"GET /api/v1/licenses/:id":                       AUTHENTICATED_GROUP,
+	"PATCH /api/v1/licenses/:id":                     AUTHENTICATED_GROUP,
+	"DELETE /api/v1/licenses/:id":                    AUTHENTICATED_GROUP,
+	"PATCH /api/v1/licenses/renew/:id":               AUTHENTICATED_GROUP,
+	"PATCH /api/v1/licenses/upgrade/:id":             AUTHENTICATED_GROUP,
+	"GET /api/v1/search":                             AUTHENTICATED_GROUP,
+	"POST /api/v1/users/createAdmin":                 ADMIN_GROUP,
+	"PATCH /api/v1/users/access/:id":                 ADMIN_GROUP,
+	"GET /api/v1/users/":                             ADMIN_GROUP,
+	"GET /api/v1/users/getMostRecent":                ADMIN_GROUP,
+	"GET /api/v1/users/count":                        ADMIN_GROUP,
+	"GET /api/v1/users/export":                       ADMIN_GROUP,
+	"DELETE /api/v1/users/deleteAll":                 ADMIN_GROUP,
+	"POST /api/v1/users/deleteMultiple":              ADMIN_GROUP,
+	"POST /api/v1/categoriesLicenses/":               ADMIN_GROUP,
+	"PATCH /api/v1/categoriesLicenses/:id":           ADMIN_GROUP,
+	"DELETE /api/v1/categoriesLicenses/:id":          ADMIN_GROUP,
+	"GET /api/v1/categoriesLicenses/count":           ADMIN_GROUP,
+	"DELETE /api/v1/categoriesLicenses/deleteAll":    ADMIN_GROUP,
+	"POST /api/v1/categoriesLicenses/deleteMultiple": ADMIN_GROUP,
+	"POST /api/v1/categoriesLicenses/bulk":           ADMIN_GROUP,
+	"GET /api/v1/licenses/":                          ADMIN_GROUP,
+	"GET /api/v1/licenses/getMostRecent":             ADMIN_GROUP,
+	"GET /api/v1/licenses/count":                     ADMIN_GROUP,
+	"GET /api/v1/licenses/export":                    ADMIN_GROUP,
+	"GET /api/v1/licenses/countPerCategory":          ADMIN_GROUP,
+	"DELETE /api/v1/licenses/deleteAll":              ADMIN_GROUP,
+	"POST /api/v1/licenses/deleteMultiple":           ADMIN_GROUP,
+	"POST /api/v1/licenses/bulk":                     ADMIN_GROUP,
+	"GET /api/v1/references/orphans":                 ADMIN_GROUP,
+	"GET /api/v1/trash/:collection":                  ADMIN_GROUP,
+	"POST /api/v1/trash/:collection/:id/restore":     ADMIN_GROUP,
+	"GET /api/v1/auditLog":                           ADMIN_GROUP,
+}
+
+// This method returns the short name (package.Function) of a handler name
+func shortHandlerName(name string) string {
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	for strings.Contains(name, ".func") {
+		name = name[:strings.LastIndex(name, ".func")]
+	}
+	return name
+}
+
+// This method replaces the path parameters with sample values
+func samplePath(path string) string {
+	path = strings.ReplaceAll(path, ":id", "65a8d750f00cf0007816e841")
+	return strings.ReplaceAll(path, ":provider", "google")
+}
+
+func TestRouteTableMiddlewareChains(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+
+	// Recorder of the real handler chain of every request, that stops the request
+	// before any middleware of the API runs
+	var recordedChain []string
+	server.Use(func(ctx *gin.Context) {
+		recordedChain = ctx.HandlerNames()[1:]
+		ctx.AbortWithStatus(http.StatusNoContent)
+	})
+	RegisterRoutes(server, store.NewMemoryStore())
+
+	// Every registered route must be expected
+	registeredRoutes := server.Routes()
+	if len(registeredRoutes) != len(expectedRoutes) {
+		t.Errorf("expected %d routes, registered %d", len(expectedRoutes), len(registeredRoutes))
+	}
+
+	for _, route := range registeredRoutes {
+		routeKey := route.Method + " " + route.Path
+		group, found := expectedRoutes[routeKey]
+		if !found {
+			t.Errorf("not expected route %s", routeKey)
+			continue
+		}
+
+		// Execute the route and check the recorded chain
+		recordedChain = nil
+		request := httptest.NewRequest(route.Method, samplePath(route.Path), nil)
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		if len(recordedChain) == 0 {
+			t.Errorf("%s: no handler chain recorded", routeKey)
+			continue
+		}
+
+		middlewareChain := []string{}
+		for _, name := range recordedChain[:len(recordedChain)-1] {
+			middlewareChain = append(middlewareChain, shortHandlerName(name))
+		}
+
+		// The versioned routes are audited before the middleware of their group
+		expectedChain := expectedChains[group]
+		if strings.HasPrefix(route.Path, API_V1_PREFIX) {
+			expectedChain = append([]string{"middleware.AuditLog"}, expectedChain...)
+		}
+
+		if !reflect.DeepEqual(middlewareChain, expectedChain) {
+			t.Errorf("%s: expected middleware chain %v, got %v", routeKey, expectedChain, middlewareChain)
+		}
+	}
+}
+
+func TestAuditTargetsOfMutatingRoutes(t *testing.T) {
+	server := gin.New()
+	RegisterRoutes(server, store.NewMemoryStore())
+
+	// Every mutating route has the collection and the action of its audit log entries
+	mutatingRoutes := 0
+	for _, route := range server.Routes() {
+		if route.Method == http.MethodGet {
+			continue
+		}
+		mutatingRoutes++
+
+		target, found := AUDIT_TARGETS[route.Method+" "+route.Path]
+		if !found || target.Action == "" || (target.Collection == "" && !strings.Contains(route.Path, ":collection")) {
+			t.Errorf("%s %s: no audit target", route.Method, route.Path)
+		}
+	}
+
+	if mutatingRoutes != len(AUDIT_TARGETS) {
+		t.Errorf("expected %d audit targets, got %d", mutatingRoutes, len(AUDIT_TARGETS))
+	}
+}
+
+func TestRouteGroupsMiddleware(t *testing.T) {
+	for _, routeGroup := range RouteGroups(store.NewMemoryStore()) {
+		names := []string{}
+		for _, handler := range routeGroup.Middleware {
+			names = append(names, shortHandlerName(runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()))
+		}
+
+		if !reflect.DeepEqual(names, expectedChains[routeGroup.Name]) {
+			t.Errorf("group %s: expected middleware %v, got %v", routeGroup.Name, expectedChains[routeGroup.Name], names)
+		}
+	}
+}
+
+func TestRoutePermissionsOfGroups(t *testing.T) {
+
+	// Every permission of a protected route is given to the role of its group
+	roles := map[string]models.User{
+		AUTHENTICATED_GROUP: {Role: "user"},
+		ADMIN_GROUP:         {Role: "admin", IsAdmin: true},
+	}
+	checkedRoutes := 0
+	for _, routeGroup := range RouteGroups(store.NewMemoryStore()) {
+		user, found := roles[routeGroup.Name]
+		if !found {
+			continue
+		}
+
+		principal := middleware.NewPrincipal(user, "password", "jti")
+		for _, route := range routeGroup.Routes {
+			permissions, found := ROUTE_PERMISSIONS[middleware.PermissionRouteKey(route.Method, API_V1_PREFIX+route.Path)]
+			if !found {
+				continue
+			}
+			checkedRoutes++
+			for _, permission := range permissions {
+				if !principal.HasPermission(permission) {
+					t.Errorf("%s %s: the %s group has no permission %s", route.Method, route.Path, routeGroup.Name, permission)
+				}
+			}
+		}
+	}
+
+	if checkedRoutes != len(ROUTE_PERMISSIONS) {
+		t.Errorf("expected %d routes with permissions, checked %d", len(ROUTE_PERMISSIONS), checkedRoutes)
+	}
+}
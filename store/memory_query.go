@@ -0,0 +1,934 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Private
+// This method transforms any document, filter or update (struct, map, bson.D) to a bson.M
+// with the same value types that MongoDB stores (int32/int64/float64, DateTime, ObjectID, A, M)
+func normalizeDocument(value interface{}) (bson.M, error) {
+	if value == nil {
+		return bson.M{}, nil
+	}
+
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	document := bson.M{}
+	err = bson.Unmarshal(data, &document)
+	return document, err
+}
+
+// Private
+// This method returns a deep copy of the document
+func copyDocument(document bson.M) bson.M {
+	copied, err := normalizeDocument(document)
+	if err != nil {
+		panic("could not copy the document: " + err.Error())
+	}
+	return copied
+}
+
+// Private
+// This method decodes the document into the result (pointer)
+func decodeDocument(document bson.M, result interface{}) error {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, result)
+}
+
+// Private
+// This method decodes the documents into the results (pointer to slice)
+func decodeDocuments(documents []bson.M, results interface{}) error {
+	resultsValue := reflect.ValueOf(results)
+	if resultsValue.Kind() != reflect.Pointer || resultsValue.Elem().Kind() != reflect.Slice {
+		return errors.New("the results must be a pointer to a slice")
+	}
+
+	sliceValue := resultsValue.Elem()
+	sliceValue.Set(reflect.MakeSlice(sliceValue.Type(), 0, len(documents)))
+	for _, document := range documents {
+		element := reflect.New(sliceValue.Type().Elem())
+		if err := decodeDocument(document, element.Interface()); err != nil {
+			return err
+		}
+		sliceValue.Set(reflect.Append(sliceValue, element.Elem()))
+	}
+	return nil
+}
+
+// FILTERS ---------------------
+// -----------------------------
+
+// Private
+// This method checks if the document matches the filter
+func matchDocument(document bson.M, filter bson.M) (bool, error) {
+	for key, condition := range filter {
+		var matched bool
+		var err error
+
+		switch key {
+		case "$and", "$or", "$nor":
+			matched, err = matchLogical(document, key, condition)
+		case "$expr", "$where":
+			return false, errors.New("not supported operator " + key + " in the memory store")
+		default:
+			matched, err = matchField(document, key, condition)
+		}
+
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Private
+// This method evaluates the $and, $or and $nor operators
+func matchLogical(document bson.M, operator string, condition interface{}) (bool, error) {
+	subFilters, ok := condition.(bson.A)
+	if !ok || len(subFilters) == 0 {
+		return false, errors.New(operator + " must be a non empty array")
+	}
+
+	for _, subFilter := range subFilters {
+		subFilterDocument, ok := subFilter.(bson.M)
+		if !ok {
+			return false, errors.New(operator + " must contain documents")
+		}
+
+		matched, err := matchDocument(document, subFilterDocument)
+		if err != nil {
+			return false, err
+		}
+
+		if operator == "$and" && !matched {
+			return false, nil
+		}
+		if operator == "$or" && matched {
+			return true, nil
+		}
+		if operator == "$nor" && matched {
+			return false, nil
+		}
+	}
+
+	return operator != "$or", nil
+}
+
+// Private
+// This method checks if the document field matches the condition (value or operators document)
+func matchField(document bson.M, path string, condition interface{}) (bool, error) {
+	values, exists := lookupPath(document, path)
+
+	// Operators document
+	if operators, ok := condition.(bson.M); ok && isOperatorsDocument(operators) {
+		for operator, operand := range operators {
+			matched, err := matchOperator(values, exists, operator, operand, operators)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	// Regular expression
+	if regex, ok := condition.(primitive.Regex); ok {
+		return matchRegex(values, regex.Pattern, regex.Options)
+	}
+
+	// Equality (null matches also the not existent fields)
+	if condition == nil {
+		return !exists || containsEqual(values, nil), nil
+	}
+	return containsEqual(values, condition), nil
+}
+
+// Private
+// This method checks if all the keys of the document are operators
+func isOperatorsDocument(document bson.M) bool {
+	if len(document) == 0 {
+		return false
+	}
+	for key := range document {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// Private
+// This method evaluates a single query operator on the field values
+func matchOperator(values []interface{}, exists bool, operator string, operand interface{}, operators bson.M) (bool, error) {
+	switch operator {
+	case "$eq":
+		if operand == nil {
+			return !exists || containsEqual(values, nil), nil
+		}
+		return containsEqual(values, operand), nil
+
+	case "$ne":
+		if operand == nil {
+			return exists && !containsEqual(values, nil), nil
+		}
+		return !containsEqual(values, operand), nil
+
+	case "$gt", "$gte", "$lt", "$lte":
+		for _, value := range values {
+			comparison, comparable := compareValues(value, operand)
+			if !comparable {
+				continue
+			}
+			if (operator == "$gt" && comparison > 0) || (operator == "$gte" && comparison >= 0) ||
+				(operator == "$lt" && comparison < 0) || (operator == "$lte" && comparison <= 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "$in", "$nin":
+		items, ok := operand.(bson.A)
+		if !ok {
+			return false, errors.New(operator + " needs an array")
+		}
+		found := false
+		for _, item := range items {
+			if regex, ok := item.(primitive.Regex); ok {
+				matched, err := matchRegex(values, regex.Pattern, regex.Options)
+				if err != nil {
+					return false, err
+				}
+				found = found || matched
+				continue
+			}
+			if (item == nil && !exists) || containsEqual(values, item) {
+				found = true
+			}
+		}
+		if operator == "$in" {
+			return found, nil
+		}
+		return !found, nil
+
+	case "$exists":
+		expected, _ := operand.(bool)
+		return exists == expected, nil
+
+	case "$regex":
+		pattern, options := "", ""
+		switch regex := operand.(type) {
+		case string:
+			pattern = regex
+		case primitive.Regex:
+			pattern, options = regex.Pattern, regex.Options
+		default:
+			return false, errors.New("$regex needs a string")
+		}
+		if extraOptions, ok := operators["$options"].(string); ok {
+			options += extraOptions
+		}
+		return matchRegex(values, pattern, options)
+
+	case "$options":
+		// Used by $regex
+		return true, nil
+
+	case "$not":
+		subOperators, ok := operand.(bson.M)
+		if !ok {
+			if regex, ok := operand.(primitive.Regex); ok {
+				matched, err := matchRegex(values, regex.Pattern, regex.Options)
+				return !matched, err
+			}
+			return false, errors.New("$not needs an operators document")
+		}
+		for subOperator, subOperand := range subOperators {
+			matched, err := matchOperator(values, exists, subOperator, subOperand, subOperators)
+			if err != nil {
+				return false, err
+			}
+			if !matched {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "$elemMatch":
+		subFilter, ok := operand.(bson.M)
+		if !ok {
+			return false, errors.New("$elemMatch needs a document")
+		}
+		for _, value := range values {
+			array, ok := value.(bson.A)
+			if !ok {
+				continue
+			}
+			for _, element := range array {
+				elementDocument, isDocument := element.(bson.M)
+				var matched bool
+				var err error
+				if isOperatorsDocument(subFilter) {
+					matched, err = matchField(bson.M{"v": element}, "v", subFilter)
+				} else if isDocument {
+					matched, err = matchDocument(elementDocument, subFilter)
+				}
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+
+	case "$size":
+		size, ok := toFloat(operand)
+		if !ok {
+			return false, errors.New("$size needs a number")
+		}
+		for _, value := range values {
+			if array, ok := value.(bson.A); ok && float64(len(array)) == size {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "$type":
+		for _, value := range values {
+			if bsonTypeName(value) == operand {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	default:
+		return false, errors.New("not supported operator " + operator + " in the memory store")
+	}
+}
+
+// Private
+// This method checks if any of the string values matches the regular expression
+func matchRegex(values []interface{}, pattern string, options string) (bool, error) {
+	flags := ""
+	for _, option := range options {
+		if strings.ContainsRune("imsx", option) && option != 'x' {
+			flags += string(option)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	for _, value := range values {
+		if text, ok := value.(string); ok && regex.MatchString(text) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Private
+// This method returns the values of a dotted path
+// Arrays are traversed, so the values contain the array itself and its elements
+func lookupPath(document bson.M, path string) ([]interface{}, bool) {
+	current := []interface{}{document}
+	exists := true
+
+	for _, segment := range strings.Split(path, ".") {
+		next := []interface{}{}
+		segmentExists := false
+
+		for _, value := range current {
+			switch typed := value.(type) {
+			case bson.M:
+				if child, found := typed[segment]; found {
+					next = append(next, child)
+					segmentExists = true
+				}
+			case bson.A:
+				// Numeric segment: array index, otherwise the field of every element
+				if index, err := strconv.Atoi(segment); err == nil {
+					if index >= 0 && index < len(typed) {
+						next = append(next, typed[index])
+						segmentExists = true
+					}
+					continue
+				}
+				for _, element := range typed {
+					if elementDocument, ok := element.(bson.M); ok {
+						if child, found := elementDocument[segment]; found {
+							next = append(next, child)
+							segmentExists = true
+						}
+					}
+				}
+			}
+		}
+
+		current = next
+		exists = segmentExists
+		if !exists {
+			return nil, false
+		}
+	}
+
+	// Expand the arrays, a condition matches the array or any of its elements
+	values := []interface{}{}
+	for _, value := range current {
+		values = append(values, value)
+		if array, ok := value.(bson.A); ok {
+			values = append(values, array...)
+		}
+	}
+	return values, exists
+}
+
+// Private
+// This method checks if any of the values equals the expected value
+func containsEqual(values []interface{}, expected interface{}) bool {
+	for _, value := range values {
+		if valuesEqual(value, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// COMPARISON ------------------
+// -----------------------------
+
+// Private
+// This method checks if two normalized values are equal (numbers by value)
+func valuesEqual(a interface{}, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	comparison, comparable := compareValues(a, b)
+	if comparable {
+		return comparison == 0
+	}
+	return false
+}
+
+// Private
+// This method returns the MongoDB sort order class of the value type
+func typeOrder(value interface{}) int {
+	switch value.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int, int32, int64, float64, float32, primitive.Decimal128:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.M, bson.D:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.Binary, []byte:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime, time.Time:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	default:
+		return 12
+	}
+}
+
+// Private
+// This method compares two values of the same type class (numbers, strings, dates, ...)
+// Output: comparison result (-1, 0, 1) and whether the values are comparable
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	if typeOrder(a) != typeOrder(b) {
+		return 0, false
+	}
+
+	switch typedA := a.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 0, true
+
+	case string:
+		return strings.Compare(typedA, fmt.Sprint(b)), true
+
+	case primitive.ObjectID:
+		typedB := b.(primitive.ObjectID)
+		return bytes.Compare(typedA[:], typedB[:]), true
+
+	case bool:
+		typedB := b.(bool)
+		if typedA == typedB {
+			return 0, true
+		}
+		if !typedA {
+			return -1, true
+		}
+		return 1, true
+
+	case primitive.DateTime, time.Time:
+		return compareOrdered(toDateTime(a), toDateTime(b)), true
+
+	case bson.M, bson.A, bson.D:
+		if reflect.DeepEqual(a, b) {
+			return 0, true
+		}
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), true
+	}
+
+	// Numbers
+	numberA, okA := toFloat(a)
+	numberB, okB := toFloat(b)
+	if okA && okB {
+		return compareOrdered(numberA, numberB), true
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return 0, true
+	}
+	return 0, false
+}
+
+// Private
+// This method compares two ordered values
+func compareOrdered[V int64 | float64 | primitive.DateTime](a V, b V) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Private
+// This method transforms a number to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float32:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
+
+// Private
+// This method transforms a date to a primitive.DateTime
+func toDateTime(value interface{}) primitive.DateTime {
+	switch date := value.(type) {
+	case primitive.DateTime:
+		return date
+	case time.Time:
+		return primitive.NewDateTimeFromTime(date)
+	}
+	return 0
+}
+
+// Private
+// This method returns the $type alias of a normalized value
+func bsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil, primitive.Null:
+		return "null"
+	case string:
+		return "string"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case bool:
+		return "bool"
+	case primitive.DateTime:
+		return "date"
+	case primitive.ObjectID:
+		return "objectId"
+	case bson.M:
+		return "object"
+	case bson.A:
+		return "array"
+	}
+	return "unknown"
+}
+
+// UPDATES ---------------------
+// -----------------------------
+
+// Private
+// This method applies the update operators to the document
+// Output: whether the document was modified
+func applyUpdate(document bson.M, update bson.M) (bool, error) {
+	if len(update) == 0 {
+		return false, errors.New("empty update statement")
+	}
+
+	before := copyDocument(document)
+	for operator, fields := range update {
+		fieldsDocument, ok := fields.(bson.M)
+		if !ok {
+			return false, errors.New(operator + " needs a document")
+		}
+
+		for path, value := range fieldsDocument {
+			if path == "_id" && operator != "$setOnInsert" {
+				return false, errors.New("the field '_id' is immutable")
+			}
+
+			var err error
+			switch operator {
+			case "$set":
+				err = setPath(document, path, value)
+
+			case "$unset":
+				unsetPath(document, path)
+
+			case "$inc":
+				current, _ := getPath(document, path)
+				err = setPath(document, path, addNumbers(current, value))
+
+			case "$push", "$addToSet":
+				current, found := getPath(document, path)
+				array, isArray := current.(bson.A)
+				if found && !isArray && current != nil {
+					return false, errors.New(operator + " needs an array field: " + path)
+				}
+
+				// $each and $position modifiers
+				items := bson.A{value}
+				position := len(array)
+				if modifiers, ok := value.(bson.M); ok {
+					if each, ok := modifiers["$each"].(bson.A); ok {
+						items = each
+					}
+					if index, found := modifiers["$position"]; found && operator == "$push" {
+						if position, err = arrayPosition(index, len(array)); err != nil {
+							return false, err
+						}
+					}
+				}
+
+				added := bson.A{}
+				for _, item := range items {
+					if operator == "$addToSet" && (containsEqual(array, item) || containsEqual(added, item)) {
+						continue
+					}
+					added = append(added, item)
+				}
+				array = append(append(append(bson.A{}, array[:position]...), added...), array[position:]...)
+				err = setPath(document, path, array)
+
+			case "$pull":
+				current, found := getPath(document, path)
+				array, isArray := current.(bson.A)
+				if !found || !isArray {
+					continue
+				}
+
+				kept := bson.A{}
+				for _, element := range array {
+					var matched bool
+					if condition, ok := value.(bson.M); ok {
+						if isOperatorsDocument(condition) {
+							matched, err = matchField(bson.M{"v": element}, "v", condition)
+						} else if elementDocument, ok := element.(bson.M); ok {
+							matched, err = matchDocument(elementDocument, condition)
+						}
+					} else {
+						matched = valuesEqual(element, value)
+					}
+					if err != nil {
+						return false, err
+					}
+					if !matched {
+						kept = append(kept, element)
+					}
+				}
+				err = setPath(document, path, kept)
+
+			case "$setOnInsert":
+				// Only for upserts
+
+			default:
+				return false, errors.New("not supported update operator " + operator + " in the memory store")
+			}
+
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return !reflect.DeepEqual(before, document), nil
+}
+
+// Private
+// This method returns the insert position of the $position modifier (negative positions count from the end)
+func arrayPosition(index interface{}, length int) (int, error) {
+	var position int
+	switch typed := index.(type) {
+	case int32:
+		position = int(typed)
+	case int64:
+		position = int(typed)
+	default:
+		return 0, errors.New("$position needs an integer")
+	}
+
+	if position < 0 {
+		position += length
+	}
+	if position < 0 {
+		return 0, nil
+	}
+	if position > length {
+		return length, nil
+	}
+	return position, nil
+}
+
+// Private
+// This method returns the value of a dotted path (no array expansion)
+func getPath(document bson.M, path string) (interface{}, bool) {
+	var current interface{} = document
+	for _, segment := range strings.Split(path, ".") {
+		switch typed := current.(type) {
+		case bson.M:
+			child, found := typed[segment]
+			if !found {
+				return nil, false
+			}
+			current = child
+		case bson.A:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(typed) {
+				return nil, false
+			}
+			current = typed[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Private
+// This method sets the value of a dotted path, creating the missing documents
+func setPath(document bson.M, path string, value interface{}) error {
+	segments := strings.Split(path, ".")
+	var current interface{} = document
+
+	for position, segment := range segments {
+		isLast := position == len(segments)-1
+
+		switch typed := current.(type) {
+		case bson.M:
+			if isLast {
+				typed[segment] = value
+				return nil
+			}
+			child, found := typed[segment]
+			if !found || child == nil {
+				child = bson.M{}
+				typed[segment] = child
+			}
+			current = child
+
+		case bson.A:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 {
+				return errors.New("not valid array index in path: " + path)
+			}
+			if index >= len(typed) {
+				return errors.New("array index out of range in path: " + path)
+			}
+			if isLast {
+				typed[index] = value
+				return nil
+			}
+			current = typed[index]
+
+		default:
+			return errors.New("cannot create field in path: " + path)
+		}
+	}
+	return nil
+}
+
+// Private
+// This method removes the field of a dotted path
+func unsetPath(document bson.M, path string) {
+	segments := strings.Split(path, ".")
+	parent, found := getPath(document, strings.Join(segments[:len(segments)-1], "."))
+	if len(segments) == 1 {
+		parent, found = document, true
+	}
+	if !found {
+		return
+	}
+
+	lastSegment := segments[len(segments)-1]
+	switch typed := parent.(type) {
+	case bson.M:
+		delete(typed, lastSegment)
+	case bson.A:
+		// MongoDB sets the array element to null
+		if index, err := strconv.Atoi(lastSegment); err == nil && index >= 0 && index < len(typed) {
+			typed[index] = nil
+		}
+	}
+}
+
+// Private
+// This method adds two numbers keeping the integer types
+func addNumbers(current interface{}, increment interface{}) interface{} {
+	switch typedIncrement := increment.(type) {
+	case int32:
+		switch typedCurrent := current.(type) {
+		case int32:
+			return typedCurrent + typedIncrement
+		case int64:
+			return typedCurrent + int64(typedIncrement)
+		case nil:
+			return typedIncrement
+		}
+	case int64:
+		switch typedCurrent := current.(type) {
+		case int32:
+			return int64(typedCurrent) + typedIncrement
+		case int64:
+			return typedCurrent + typedIncrement
+		case nil:
+			return typedIncrement
+		}
+	}
+
+	currentNumber, _ := toFloat(current)
+	incrementNumber, _ := toFloat(increment)
+	return currentNumber + incrementNumber
+}
+
+// PROJECTION AND SORT ---------
+// -----------------------------
+
+// Private
+// This method applies an inclusion or exclusion projection (top level fields)
+func applyProjection(document bson.M, projection bson.M) bson.M {
+	if len(projection) == 0 {
+		return document
+	}
+
+	// Inclusion projection if any field (except _id) is included
+	isInclusion := false
+	for field, value := range projection {
+		if field != "_id" && isTruthy(value) {
+			isInclusion = true
+		}
+	}
+
+	projected := bson.M{}
+	if isInclusion {
+		for field, value := range projection {
+			if isTruthy(value) {
+				if fieldValue, found := document[field]; found {
+					projected[field] = fieldValue
+				}
+			}
+		}
+		if idValue, found := projection["_id"]; !found || isTruthy(idValue) {
+			projected["_id"] = document["_id"]
+		}
+		return projected
+	}
+
+	for field, value := range document {
+		if excluded, found := projection[field]; found && !isTruthy(excluded) {
+			continue
+		}
+		projected[field] = value
+	}
+	return projected
+}
+
+// Private
+// This method checks if a projection value includes the field
+func isTruthy(value interface{}) bool {
+	if boolean, ok := value.(bool); ok {
+		return boolean
+	}
+	number, ok := toFloat(value)
+	return ok && number != 0
+}
+
+// Private
+// This method sorts the documents by the sort keys (1 ascending, -1 descending)
+func sortDocuments(documents []bson.M, sortKeys bson.D) {
+	if len(sortKeys) == 0 {
+		return
+	}
+
+	sort.SliceStable(documents, func(i, j int) bool {
+		for _, sortKey := range sortKeys {
+			direction, _ := toFloat(sortKey.Value)
+			valueI, _ := getPath(documents[i], sortKey.Key)
+			valueJ, _ := getPath(documents[j], sortKey.Key)
+
+			comparison := compareForSort(valueI, valueJ)
+			if comparison == 0 {
+				continue
+			}
+			if direction < 0 {
+				return comparison > 0
+			}
+			return comparison < 0
+		}
+		return false
+	})
+}
+
+// Private
+// This method compares two values of any type for sorting (type class order first)
+func compareForSort(a interface{}, b interface{}) int {
+	orderA, orderB := typeOrder(a), typeOrder(b)
+	if orderA != orderB {
+		return compareOrdered(int64(orderA), int64(orderB))
+	}
+
+	comparison, _ := compareValues(a, b)
+	return comparison
+}
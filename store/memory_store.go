@@ -0,0 +1,514 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Unique index of the memory store
+// The partial filter limits the index to the matching documents
+type UniqueIndex struct {
+	Fields        []string
+	PartialFilter bson.M
+}
+
+// Store in memory, used by the tests (no MongoDB server)
+// It supports the subset of the MongoDB query language that the API uses
+type MemoryStore struct {
+	mutex       sync.Mutex
+	collections map[string][]bson.M
+	indexes     map[string][]UniqueIndex
+	textIndexes map[string]TextIndex
+
+	// The transactions run one at a time
+	transactionMutex sync.Mutex
+}
+
+// Collection of the memory store
+type memoryCollection struct {
+	store *MemoryStore
+	name  string
+}
+
+// Cursor over copies of the matching documents of the memory store
+type memoryCursor struct {
+	documents []bson.M
+	position  int
+}
+
+// This method creates a new empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		collections: map[string][]bson.M{},
+		indexes:     map[string][]UniqueIndex{},
+		textIndexes: map[string]TextIndex{},
+	}
+}
+
+// This method returns the collection with the given name
+func (memoryStore *MemoryStore) Collection(name string) Collection {
+	return &memoryCollection{store: memoryStore, name: name}
+}
+
+// This method runs the function in a transaction: on error the collections are restored
+// The transactions are serialized, while the operations outside of them are not isolated from them
+func (memoryStore *MemoryStore) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	memoryStore.transactionMutex.Lock()
+	defer memoryStore.transactionMutex.Unlock()
+
+	// Snapshot of the collections (the documents are replaced on update, never changed in place)
+	memoryStore.mutex.Lock()
+	snapshot := map[string][]bson.M{}
+	for name, documents := range memoryStore.collections {
+		snapshot[name] = append([]bson.M{}, documents...)
+	}
+	memoryStore.mutex.Unlock()
+
+	err := fn(ctx)
+	if err != nil {
+		memoryStore.mutex.Lock()
+		memoryStore.collections = snapshot
+		memoryStore.mutex.Unlock()
+	}
+	return err
+}
+
+// This method adds a unique index to the collection (like the unique indexes of MongoDB)
+func (memoryStore *MemoryStore) CreateUniqueIndex(collectionName string, index UniqueIndex) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	memoryStore.indexes[collectionName] = append(memoryStore.indexes[collectionName], index)
+}
+
+func (c *memoryCollection) InsertOne(ctx context.Context, document interface{}) (primitive.ObjectID, error) {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	return c.insert(document)
+}
+
+// Private
+// This method inserts a new document (with a new id if it has none) and returns its id
+func (c *memoryCollection) insert(document interface{}) (primitive.ObjectID, error) {
+	normalizedDocument, err := normalizeDocument(document)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	if _, found := normalizedDocument["_id"]; !found {
+		normalizedDocument["_id"] = primitive.NewObjectID()
+	}
+
+	if err = c.checkUniqueIndexes(normalizedDocument, -1); err != nil {
+		return primitive.NilObjectID, err
+	}
+	c.store.collections[c.name] = append(c.store.collections[c.name], normalizedDocument)
+
+	insertedID, ok := normalizedDocument["_id"].(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, errors.New("the inserted id is not an object id")
+	}
+	return insertedID, nil
+}
+
+func (c *memoryCollection) FindOne(ctx context.Context, filter interface{}, opts *FindOptions, result interface{}) error {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	findOptions := FindOptions{Limit: 1}
+	if opts != nil {
+		findOptions.Skip, findOptions.Sort, findOptions.Projection = opts.Skip, opts.Sort, opts.Projection
+	}
+
+	documents, err := c.find(filter, &findOptions)
+	if err != nil {
+		return err
+	}
+	if len(documents) == 0 {
+		return ErrNotFound
+	}
+	return decodeDocument(documents[0], result)
+}
+
+func (c *memoryCollection) Find(ctx context.Context, filter interface{}, opts *FindOptions, results interface{}) error {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	documents, err := c.find(filter, opts)
+	if err != nil {
+		return err
+	}
+	return decodeDocuments(documents, results)
+}
+
+func (c *memoryCollection) FindCursor(ctx context.Context, filter interface{}, opts *FindOptions) (Cursor, error) {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	documents, err := c.find(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryCursor{documents: documents, position: -1}, nil
+}
+
+func (c *memoryCollection) CountDocuments(ctx context.Context, filter interface{}) (int64, error) {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	positions, err := c.matchingPositions(filter)
+	return int64(len(positions)), err
+}
+
+func (c *memoryCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error) {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	position, modified, err := c.updateFirst(filter, update)
+	if err != nil || position < 0 {
+		return &UpdateResult{}, err
+	}
+
+	result := &UpdateResult{MatchedCount: 1}
+	if modified {
+		result.ModifiedCount = 1
+	}
+	return result, nil
+}
+
+func (c *memoryCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error) {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	positions, err := c.matchingPositions(filter)
+	if err != nil || len(positions) == 0 {
+		return &UpdateResult{}, err
+	}
+	normalizedUpdate, err := normalizeDocument(update)
+	if err != nil {
+		return &UpdateResult{}, err
+	}
+
+	result := &UpdateResult{}
+	for _, position := range positions {
+		modified, err := c.updatePosition(position, normalizedUpdate)
+		if err != nil {
+			return result, err
+		}
+		result.MatchedCount++
+		if modified {
+			result.ModifiedCount++
+		}
+	}
+	return result, nil
+}
+
+func (c *memoryCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, result interface{}) error {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	position, _, err := c.updateFirst(filter, update)
+	if err != nil {
+		return err
+	}
+	if position < 0 {
+		return ErrNotFound
+	}
+	return decodeDocument(c.store.collections[c.name][position], result)
+}
+
+func (c *memoryCollection) DeleteOne(ctx context.Context, filter interface{}) (int64, error) {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	positions, err := c.matchingPositions(filter)
+	if err != nil || len(positions) == 0 {
+		return 0, err
+	}
+
+	c.deletePositions(positions[:1])
+	return 1, nil
+}
+
+func (c *memoryCollection) DeleteMany(ctx context.Context, filter interface{}) (int64, error) {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	positions, err := c.matchingPositions(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	c.deletePositions(positions)
+	return int64(len(positions)), nil
+}
+
+func (c *memoryCollection) CountByField(ctx context.Context, filter interface{}, field string) ([]GroupCount, error) {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	documents, err := c.find(filter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Groups in the order of the first document of every value
+	groupCounts := []GroupCount{}
+	for _, document := range documents {
+		value, _ := getPath(document, field)
+
+		found := false
+		for position := range groupCounts {
+			if valuesEqual(groupCounts[position].Value, value) {
+				groupCounts[position].Count++
+				found = true
+				break
+			}
+		}
+		if !found {
+			groupCounts = append(groupCounts, GroupCount{Value: value, Count: 1})
+		}
+	}
+	return groupCounts, nil
+}
+
+func (c *memoryCollection) BulkWrite(ctx context.Context, operations []BulkOperation, ordered bool) (*BulkWriteResult, error) {
+	c.store.mutex.Lock()
+	defer c.store.mutex.Unlock()
+
+	// The kinds are checked before any write (like the write models of MongoDB)
+	for index, operation := range operations {
+		if operation.Kind != BULK_INSERT && operation.Kind != BULK_UPDATE && operation.Kind != BULK_DELETE {
+			return nil, fmt.Errorf("not supported kind %q of the bulk write %d", operation.Kind, index)
+		}
+	}
+
+	result := &BulkWriteResult{Errors: []BulkWriteError{}}
+	for index, operation := range operations {
+		var err error
+		switch operation.Kind {
+		case BULK_INSERT:
+			if _, err = c.insert(operation.Document); err == nil {
+				result.InsertedCount++
+			}
+		case BULK_UPDATE:
+			var position int
+			var modified bool
+			if position, modified, err = c.updateFirst(operation.Filter, operation.Update); err == nil && position >= 0 {
+				result.MatchedCount++
+				if modified {
+					result.ModifiedCount++
+				}
+			}
+		case BULK_DELETE:
+			var positions []int
+			if positions, err = c.matchingPositions(operation.Filter); err == nil && len(positions) > 0 {
+				c.deletePositions(positions[:1])
+				result.DeletedCount++
+			}
+		}
+
+		if err != nil {
+			result.Errors = append(result.Errors, BulkWriteError{Index: index, Err: err})
+			if ordered {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (cursor *memoryCursor) Next(ctx context.Context) bool {
+	if cursor.position+1 >= len(cursor.documents) {
+		cursor.position = len(cursor.documents)
+		return false
+	}
+	cursor.position++
+	return true
+}
+
+func (cursor *memoryCursor) Decode(result interface{}) error {
+	if cursor.position < 0 || cursor.position >= len(cursor.documents) {
+		return errors.New("the cursor has no current document")
+	}
+	return decodeDocument(cursor.documents[cursor.position], result)
+}
+
+func (cursor *memoryCursor) Err() error {
+	return nil
+}
+
+func (cursor *memoryCursor) Close(ctx context.Context) error {
+	cursor.documents = nil
+	return nil
+}
+
+// Private
+// This method returns the positions of the matching documents (insertion order)
+func (c *memoryCollection) matchingPositions(filter interface{}) ([]int, error) {
+	normalizedFilter, err := normalizeDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// The $text conditions are resolved with the text index
+	if normalizedFilter, err = c.resolveTextSearch(normalizedFilter); err != nil {
+		return nil, err
+	}
+
+	positions := []int{}
+	for position, document := range c.store.collections[c.name] {
+		matched, err := matchDocument(document, normalizedFilter)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			positions = append(positions, position)
+		}
+	}
+	return positions, nil
+}
+
+// Private
+// This method returns copies of the matching documents after sort, skip, limit and projection
+func (c *memoryCollection) find(filter interface{}, opts *FindOptions) ([]bson.M, error) {
+	positions, err := c.matchingPositions(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := []bson.M{}
+	for _, position := range positions {
+		documents = append(documents, copyDocument(c.store.collections[c.name][position]))
+	}
+	if opts == nil {
+		return documents, nil
+	}
+
+	sortDocuments(documents, opts.Sort)
+
+	if opts.Skip > 0 {
+		if opts.Skip >= int64(len(documents)) {
+			return []bson.M{}, nil
+		}
+		documents = documents[opts.Skip:]
+	}
+	if opts.Limit > 0 && opts.Limit < int64(len(documents)) {
+		documents = documents[:opts.Limit]
+	}
+
+	projection, err := normalizeDocument(opts.Projection)
+	if err != nil {
+		return nil, err
+	}
+	for position := range documents {
+		documents[position] = applyProjection(documents[position], projection)
+	}
+	return documents, nil
+}
+
+// Private
+// This method updates the first matching document
+// Output: position of the document (-1 if none) and whether it was modified
+func (c *memoryCollection) updateFirst(filter interface{}, update interface{}) (int, bool, error) {
+	positions, err := c.matchingPositions(filter)
+	if err != nil || len(positions) == 0 {
+		return -1, false, err
+	}
+
+	normalizedUpdate, err := normalizeDocument(update)
+	if err != nil {
+		return -1, false, err
+	}
+
+	modified, err := c.updatePosition(positions[0], normalizedUpdate)
+	if err != nil {
+		return -1, false, err
+	}
+	return positions[0], modified, nil
+}
+
+// Private
+// This method applies the (normalized) update to the document of the position and checks the unique indexes
+// A copy is updated, so a failed update leaves the document untouched
+func (c *memoryCollection) updatePosition(position int, normalizedUpdate bson.M) (bool, error) {
+	updatedDocument := copyDocument(c.store.collections[c.name][position])
+	modified, err := applyUpdate(updatedDocument, normalizedUpdate)
+	if err != nil {
+		return false, err
+	}
+
+	if err = c.checkUniqueIndexes(updatedDocument, position); err != nil {
+		return false, err
+	}
+	c.store.collections[c.name][position] = updatedDocument
+	return modified, nil
+}
+
+// Private
+// This method removes the documents of the given (ascending) positions
+func (c *memoryCollection) deletePositions(positions []int) {
+	documents := c.store.collections[c.name]
+	kept := []bson.M{}
+
+	next := 0
+	for position, document := range documents {
+		if next < len(positions) && positions[next] == position {
+			next++
+			continue
+		}
+		kept = append(kept, document)
+	}
+	c.store.collections[c.name] = kept
+}
+
+// Private
+// This method checks the _id and the unique indexes of the collection for the document
+// The ignored position is the current position of an updated document
+func (c *memoryCollection) checkUniqueIndexes(document bson.M, ignoredPosition int) error {
+	indexes := append([]UniqueIndex{{Fields: []string{"_id"}}}, c.store.indexes[c.name]...)
+
+	for _, index := range indexes {
+		isIndexed, err := matchDocument(document, index.PartialFilter)
+		if err != nil {
+			return err
+		}
+		if !isIndexed {
+			continue
+		}
+
+		for position, other := range c.store.collections[c.name] {
+			if position == ignoredPosition {
+				continue
+			}
+			isOtherIndexed, err := matchDocument(other, index.PartialFilter)
+			if err != nil {
+				return err
+			}
+			if isOtherIndexed && sameIndexKey(document, other, index.Fields) {
+				return fmt.Errorf("%w collection: %s index: %s", ErrDuplicateKey, c.name, strings.Join(index.Fields, "_"))
+			}
+		}
+	}
+	return nil
+}
+
+// Private
+// This method checks if two documents have the same values for the index fields
+func sameIndexKey(document bson.M, other bson.M, fields []string) bool {
+	for _, field := range fields {
+		value, _ := getPath(document, field)
+		otherValue, _ := getPath(other, field)
+		if !valuesEqual(value, otherValue) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,356 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mgoBson "gopkg.in/mgo.v2/bson"
+)
+
+type testLicense struct {
+	ID           string             `bson:"_id,omitempty"`
+	UserHolderID primitive.ObjectID `bson:"userHolderId"`
+	CategoryID   primitive.ObjectID `bson:"categoryId"`
+	LicenseKey   string             `bson:"licenseKey"`
+	IsActive     string             `bson:"isActive"`
+	CreatedDt    string             `bson:"createdDt"`
+	Tags         []string           `bson:"tags,omitempty"`
+}
+
+// This method creates a memory collection with some licenses
+func newTestCollection(t *testing.T) (Collection, []primitive.ObjectID, primitive.ObjectID) {
+	memoryStore := NewMemoryStore()
+	memoryStore.CreateUniqueIndex("licenses", UniqueIndex{Fields: []string{"licenseKey"}})
+	collection := memoryStore.Collection("licenses")
+
+	userID := primitive.NewObjectID()
+	categoryIDs := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+	licenses := []testLicense{
+		{UserHolderID: userID, CategoryID: categoryIDs[0], LicenseKey: "AAA-1", IsActive: "1", CreatedDt: "2024-01-10 10:00:00", Tags: []string{"trial"}},
+		{UserHolderID: userID, CategoryID: categoryIDs[1], LicenseKey: "BBB-2", IsActive: "0", CreatedDt: "2024-02-10 10:00:00"},
+		{UserHolderID: primitive.NewObjectID(), CategoryID: categoryIDs[0], LicenseKey: "CCC-3", IsActive: "1", CreatedDt: "2024-03-10 10:00:00"},
+	}
+
+	for _, license := range licenses {
+		if _, err := collection.InsertOne(context.TODO(), license); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return collection, categoryIDs, userID
+}
+
+func TestMemoryCollectionFind(t *testing.T) {
+	collection, categoryIDs, userID := newTestCollection(t)
+
+	testCases := []struct {
+		name   string
+		filter interface{}
+		keys   []string
+	}{
+		{"all documents", mgoBson.M{}, []string{"AAA-1", "BBB-2", "CCC-3"}},
+		{"equality on object id", mgoBson.M{"userHolderId": userID}, []string{"AAA-1", "BBB-2"}},
+		{"range of string dates", mgoBson.M{"createdDt": mgoBson.M{"$gte": "2024-02-01 00:00:00", "$lte": "2024-03-01 00:00:00"}}, []string{"BBB-2"}},
+		{"in list of ids", bson.M{"categoryId": bson.M{"$in": []primitive.ObjectID{categoryIDs[1]}}}, []string{"BBB-2"}},
+		{"array contains", bson.M{"tags": "trial"}, []string{"AAA-1"}},
+		{"not existent field", bson.M{"tags": bson.M{"$exists": false}}, []string{"BBB-2", "CCC-3"}},
+		{"or", bson.M{"$or": bson.A{bson.M{"isActive": "0"}, bson.M{"licenseKey": "CCC-3"}}}, []string{"BBB-2", "CCC-3"}},
+		{"regex", bson.M{"licenseKey": bson.M{"$regex": "^a", "$options": "i"}}, []string{"AAA-1"}},
+		{"not equal", bson.M{"isActive": bson.M{"$ne": "1"}}, []string{"BBB-2"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			licenses := []testLicense{}
+			if err := collection.Find(context.TODO(), testCase.filter, nil, &licenses); err != nil {
+				t.Fatal(err)
+			}
+
+			keys := []string{}
+			for _, license := range licenses {
+				keys = append(keys, license.LicenseKey)
+			}
+			if len(keys) != len(testCase.keys) {
+				t.Fatalf("expected %v, got %v", testCase.keys, keys)
+			}
+			for position := range keys {
+				if keys[position] != testCase.keys[position] {
+					t.Fatalf("expected %v, got %v", testCase.keys, keys)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryCollectionOptions(t *testing.T) {
+	collection, _, _ := newTestCollection(t)
+
+	// Sort, skip, limit and projection
+	licenses := []bson.M{}
+	opts := &FindOptions{Sort: bson.D{{Key: "createdDt", Value: -1}}, Skip: 1, Limit: 1, Projection: mgoBson.M{"licenseKey": 0}}
+	if err := collection.Find(context.TODO(), bson.M{}, opts, &licenses); err != nil {
+		t.Fatal(err)
+	}
+	if len(licenses) != 1 || licenses[0]["createdDt"] != "2024-02-10 10:00:00" {
+		t.Fatalf("unexpected documents: %v", licenses)
+	}
+	if _, found := licenses[0]["licenseKey"]; found {
+		t.Fatal("expected the excluded field to be removed")
+	}
+
+	// The string ids are decoded from the object ids
+	license := testLicense{}
+	if err := collection.FindOne(context.TODO(), bson.M{"licenseKey": "AAA-1"}, nil, &license); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := primitive.ObjectIDFromHex(license.ID); err != nil {
+		t.Fatalf("expected an object id, got %q", license.ID)
+	}
+
+	// Not existent document
+	err := collection.FindOne(context.TODO(), bson.M{"licenseKey": "ZZZ"}, nil, &license)
+	if err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryCollectionFindCursor(t *testing.T) {
+	collection, _, _ := newTestCollection(t)
+	ctx := context.TODO()
+
+	opts := &FindOptions{Sort: bson.D{{Key: "createdDt", Value: -1}}, Projection: mgoBson.M{"tags": 0}}
+	cursor, err := collection.FindCursor(ctx, bson.M{"isActive": "1"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close(ctx)
+
+	keys := []string{}
+	for cursor.Next(ctx) {
+		license := testLicense{}
+		if err := cursor.Decode(&license); err != nil {
+			t.Fatal(err)
+		}
+		if len(license.Tags) > 0 {
+			t.Fatalf("expected the excluded field to be removed, got %v", license.Tags)
+		}
+		keys = append(keys, license.LicenseKey)
+	}
+	if err := cursor.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "CCC-3" || keys[1] != "AAA-1" {
+		t.Fatalf("expected [CCC-3 AAA-1], got %v", keys)
+	}
+
+	// No current document after the iteration
+	if err := cursor.Decode(&testLicense{}); err == nil {
+		t.Fatal("expected an error after the last document")
+	}
+}
+
+func TestMemoryCollectionWrites(t *testing.T) {
+	collection, categoryIDs, _ := newTestCollection(t)
+	ctx := context.TODO()
+
+	// Update operators
+	result, err := collection.UpdateOne(ctx, bson.M{"licenseKey": "BBB-2"}, bson.M{
+		"$set":  bson.M{"isActive": "1"},
+		"$push": bson.M{"tags": "renewed"},
+	})
+	if err != nil || result.MatchedCount != 1 || result.ModifiedCount != 1 {
+		t.Fatalf("unexpected update result %+v: %v", result, err)
+	}
+
+	updated := testLicense{}
+	if err = collection.FindOneAndUpdate(ctx, bson.M{"licenseKey": "BBB-2"}, bson.M{"$addToSet": bson.M{"tags": "renewed"}}, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.IsActive != "1" || len(updated.Tags) != 1 || updated.Tags[0] != "renewed" {
+		t.Fatalf("unexpected updated document: %+v", updated)
+	}
+
+	// Items inserted at a position (JSON Patch add of a list item)
+	err = collection.FindOneAndUpdate(ctx, bson.M{"licenseKey": "BBB-2"}, bson.M{"$push": bson.M{"tags": bson.M{"$each": bson.A{"first", "second"}, "$position": 0}}}, &updated)
+	if err != nil || len(updated.Tags) != 3 || updated.Tags[0] != "first" || updated.Tags[1] != "second" || updated.Tags[2] != "renewed" {
+		t.Fatalf("unexpected items %v: %v", updated.Tags, err)
+	}
+
+	// All the matching documents
+	result, err = collection.UpdateMany(ctx, bson.M{"categoryId": categoryIDs[0]}, bson.M{"$set": bson.M{"isActive": "0"}})
+	if err != nil || result.MatchedCount != 2 || result.ModifiedCount != 2 {
+		t.Fatalf("unexpected update result %+v: %v", result, err)
+	}
+	result, err = collection.UpdateMany(ctx, bson.M{"isActive": "0"}, bson.M{"$set": bson.M{"isActive": "0"}})
+	if err != nil || result.MatchedCount != 2 || result.ModifiedCount != 0 {
+		t.Fatalf("unexpected update result %+v: %v", result, err)
+	}
+
+	// Unique index
+	_, err = collection.InsertOne(ctx, testLicense{LicenseKey: "AAA-1"})
+	if !IsDuplicateKey(err) {
+		t.Fatalf("expected a duplicate key error, got %v", err)
+	}
+	_, err = collection.UpdateOne(ctx, bson.M{"licenseKey": "CCC-3"}, bson.M{"$set": bson.M{"licenseKey": "AAA-1"}})
+	if !IsDuplicateKey(err) {
+		t.Fatalf("expected a duplicate key error, got %v", err)
+	}
+
+	// Count per field
+	groupCounts, err := collection.CountByField(ctx, bson.M{}, "categoryId")
+	if err != nil || len(groupCounts) != 2 || groupCounts[0].Value != categoryIDs[0] || groupCounts[0].Count != 2 {
+		t.Fatalf("unexpected group counts %+v: %v", groupCounts, err)
+	}
+
+	// Deletes
+	deletedCount, err := collection.DeleteMany(ctx, bson.M{"categoryId": categoryIDs[0]})
+	if err != nil || deletedCount != 2 {
+		t.Fatalf("expected 2 deleted documents, got %d: %v", deletedCount, err)
+	}
+	count, err := collection.CountDocuments(ctx, bson.M{})
+	if err != nil || count != 1 {
+		t.Fatalf("expected 1 document, got %d: %v", count, err)
+	}
+}
+
+func TestMemoryCollectionBulkWrite(t *testing.T) {
+	ctx := context.TODO()
+	operations := []BulkOperation{
+		{Kind: BULK_INSERT, Document: testLicense{LicenseKey: "DDD-4"}},
+		{Kind: BULK_INSERT, Document: testLicense{LicenseKey: "AAA-1"}},
+		{Kind: BULK_UPDATE, Filter: bson.M{"licenseKey": "BBB-2"}, Update: bson.M{"$set": bson.M{"isActive": "1"}}},
+		{Kind: BULK_UPDATE, Filter: bson.M{"licenseKey": "ZZZ-9"}, Update: bson.M{"$set": bson.M{"isActive": "1"}}},
+		{Kind: BULK_DELETE, Filter: bson.M{"licenseKey": "CCC-3"}},
+	}
+
+	// Ordered: the writes stop at the duplicate key
+	collection, _, _ := newTestCollection(t)
+	result, err := collection.BulkWrite(ctx, operations, true)
+	if err != nil || result.InsertedCount != 1 || result.MatchedCount != 0 || len(result.Errors) != 1 || result.Errors[0].Index != 1 || !IsDuplicateKey(result.Errors[0].Err) {
+		t.Fatalf("unexpected ordered result %+v: %v", result, err)
+	}
+	if count, _ := collection.CountDocuments(ctx, bson.M{"licenseKey": "CCC-3"}); count != 1 {
+		t.Fatalf("expected the writes after the failed write not to run")
+	}
+
+	// Unordered: every write runs
+	collection, _, _ = newTestCollection(t)
+	result, err = collection.BulkWrite(ctx, operations, false)
+	if err != nil || result.InsertedCount != 1 || result.MatchedCount != 1 || result.ModifiedCount != 1 || result.DeletedCount != 1 || len(result.Errors) != 1 {
+		t.Fatalf("unexpected unordered result %+v: %v", result, err)
+	}
+
+	// Not supported kinds fail the whole bulk write
+	if _, err = collection.BulkWrite(ctx, []BulkOperation{operations[0], {Kind: "replace"}}, false); err == nil {
+		t.Fatalf("expected an error for the not supported kind")
+	}
+	if count, _ := collection.CountDocuments(ctx, bson.M{"licenseKey": "DDD-4"}); count != 1 {
+		t.Fatalf("expected no write of the not valid bulk write")
+	}
+}
+
+func TestMemoryStoreTransactions(t *testing.T) {
+	memoryStore := NewMemoryStore()
+	memoryStore.CreateUniqueIndex("licenses", UniqueIndex{Fields: []string{"userHolderId"}, PartialFilter: bson.M{"isActive": "1"}})
+	licenses := memoryStore.Collection("licenses")
+	categories := memoryStore.Collection("categories")
+	ctx := context.TODO()
+
+	userID := primitive.NewObjectID()
+	if _, err := licenses.InsertOne(ctx, testLicense{UserHolderID: userID, LicenseKey: "AAA-1", IsActive: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := categories.InsertOne(ctx, bson.M{"title": "Bronze"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed transaction leaves all the collections untouched
+	err := memoryStore.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := categories.DeleteMany(ctx, bson.M{}); err != nil {
+			return err
+		}
+		if _, err := licenses.UpdateOne(ctx, bson.M{"licenseKey": "AAA-1"}, bson.M{"$set": bson.M{"isActive": "0"}}); err != nil {
+			return err
+		}
+
+		// Second active license of the same user (partial unique index)
+		_, err := licenses.InsertOne(ctx, testLicense{UserHolderID: userID, LicenseKey: "BBB-2", IsActive: "1"})
+		if err != nil {
+			return err
+		}
+		_, err = licenses.InsertOne(ctx, testLicense{UserHolderID: userID, LicenseKey: "CCC-3", IsActive: "1"})
+		return err
+	})
+	if !IsDuplicateKey(err) {
+		t.Fatalf("expected a duplicate key error, got %v", err)
+	}
+
+	for name, expected := range map[string]int64{"categories": 1, "licenses": 1} {
+		count, err := memoryStore.Collection(name).CountDocuments(ctx, bson.M{})
+		if err != nil || count != expected {
+			t.Fatalf("%s: expected %d documents after the rollback, got %d: %v", name, expected, count, err)
+		}
+	}
+	active, err := licenses.CountDocuments(ctx, bson.M{"licenseKey": "AAA-1", "isActive": "1"})
+	if err != nil || active != 1 {
+		t.Fatalf("expected the update to be rolled back, got %d: %v", active, err)
+	}
+
+	// A successful transaction keeps its writes
+	err = memoryStore.WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := licenses.UpdateOne(ctx, bson.M{"licenseKey": "AAA-1"}, bson.M{"$set": bson.M{"isActive": "0"}})
+		if err != nil {
+			return err
+		}
+		_, err = licenses.InsertOne(ctx, testLicense{UserHolderID: userID, LicenseKey: "BBB-2", IsActive: "1"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := licenses.CountDocuments(ctx, bson.M{"userHolderId": userID})
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 licenses after the commit, got %d: %v", count, err)
+	}
+}
+
+func TestMemoryCollectionTextSearch(t *testing.T) {
+	memoryStore := NewMemoryStore()
+	collection := memoryStore.Collection("licenses")
+	for _, license := range []testLicense{{LicenseKey: "AAA-1", Tags: []string{"Trial", "mobile"}}, {LicenseKey: "BBB-2", Tags: []string{"desktop"}}} {
+		if _, err := collection.InsertOne(context.TODO(), license); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without a text index the $text queries fail, like MongoDB
+	if _, err := collection.CountDocuments(context.TODO(), bson.M{"$text": bson.M{"$search": "trial"}}); err == nil {
+		t.Fatal("expected an error without a text index")
+	}
+	memoryStore.CreateTextIndex("licenses", TextIndex{Fields: []string{"licenseKey", "tags"}})
+
+	testCases := []struct {
+		name   string
+		filter interface{}
+		count  int64
+	}{
+		{"word of an array", bson.M{"$text": bson.M{"$search": "TRIAL"}}, 1},
+		{"any of the words", bson.M{"$text": bson.M{"$search": "desktop, mobile"}}, 2},
+		{"word of a key", bson.M{"$text": bson.M{"$search": "bbb"}}, 1},
+		{"no word", bson.M{"$text": bson.M{"$search": "tablet"}}, 0},
+		{"with other conditions", bson.M{"$text": bson.M{"$search": "desktop mobile"}, "licenseKey": "AAA-1"}, 1},
+		{"in or", bson.M{"$or": bson.A{bson.M{"$text": bson.M{"$search": "tablet"}}, bson.M{"licenseKey": bson.M{"$regex": "^bb", "$options": "i"}}}}, 1},
+	}
+
+	for _, testCase := range testCases {
+		count, err := collection.CountDocuments(context.TODO(), testCase.filter)
+		if err != nil {
+			t.Errorf("%s: %v", testCase.name, err)
+			continue
+		}
+		if count != testCase.count {
+			t.Errorf("%s: expected %d documents, got %d", testCase.name, testCase.count, count)
+		}
+	}
+}
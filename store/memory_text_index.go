@@ -0,0 +1,138 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Text index of the memory store: the fields of the $text queries of the collection (like the MongoDB text indexes)
+// The searches use an inverted index of the words of the fields, without the stemming and the stop words of MongoDB
+type TextIndex struct {
+	Fields []string
+}
+
+// This method sets the text index of the collection (one per collection, like MongoDB)
+func (memoryStore *MemoryStore) CreateTextIndex(collectionName string, index TextIndex) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	memoryStore.textIndexes[collectionName] = index
+}
+
+// Private
+// This method replaces the $text conditions of the filter (at any depth of $and, $or and $nor)
+// with the ids of the documents that contain any of the words of the search
+func (c *memoryCollection) resolveTextSearch(filter bson.M) (bson.M, error) {
+	resolved := bson.M{}
+	idConditions := bson.A{}
+
+	for key, condition := range filter {
+		switch key {
+		case "$text":
+			ids, err := c.textSearchIDs(condition)
+			if err != nil {
+				return nil, err
+			}
+			idConditions = append(idConditions, bson.M{"_id": bson.M{"$in": ids}})
+
+		case "$and", "$or", "$nor":
+			subFilters, ok := condition.(bson.A)
+			if !ok {
+				resolved[key] = condition
+				continue
+			}
+
+			resolvedSubFilters := bson.A{}
+			for _, subFilter := range subFilters {
+				if subFilterDocument, ok := subFilter.(bson.M); ok {
+					resolvedSubFilter, err := c.resolveTextSearch(subFilterDocument)
+					if err != nil {
+						return nil, err
+					}
+					subFilter = resolvedSubFilter
+				}
+				resolvedSubFilters = append(resolvedSubFilters, subFilter)
+			}
+			resolved[key] = resolvedSubFilters
+
+		default:
+			resolved[key] = condition
+		}
+	}
+
+	// The ids of the search are added to the other conditions of the filter
+	if len(idConditions) > 0 {
+		if existentConditions, ok := resolved["$and"].(bson.A); ok {
+			idConditions = append(existentConditions, idConditions...)
+		}
+		resolved["$and"] = idConditions
+	}
+	return resolved, nil
+}
+
+// Private
+// This method returns the ids of the documents of the $text condition ({"$search": "words"})
+func (c *memoryCollection) textSearchIDs(condition interface{}) (bson.A, error) {
+	index, found := c.store.textIndexes[c.name]
+	if !found {
+		return nil, errors.New("text index required for $text query of " + c.name)
+	}
+
+	conditionDocument, ok := condition.(bson.M)
+	if !ok {
+		return nil, errors.New("$text must be a document")
+	}
+	search, ok := conditionDocument["$search"].(string)
+	if !ok {
+		return nil, errors.New("$text needs a $search string")
+	}
+
+	invertedIndex := c.invertedIndex(index)
+	ids := bson.A{}
+	added := map[string]bool{}
+	for _, word := range textWords(search) {
+		for _, id := range invertedIndex[word] {
+			if key := fmt.Sprint(id); !added[key] {
+				added[key] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// Private
+// This method builds the inverted index of the collection: word -> ids of the documents with the word
+// It is built on every search, since the documents are replaced by every write
+func (c *memoryCollection) invertedIndex(index TextIndex) map[string][]interface{} {
+	invertedIndex := map[string][]interface{}{}
+	for _, document := range c.store.collections[c.name] {
+		documentWords := map[string]bool{}
+		for _, field := range index.Fields {
+			values, _ := lookupPath(document, field)
+			for _, value := range values {
+				if text, isText := value.(string); isText {
+					for _, word := range textWords(text) {
+						documentWords[word] = true
+					}
+				}
+			}
+		}
+		for word := range documentWords {
+			invertedIndex[word] = append(invertedIndex[word], document["_id"])
+		}
+	}
+	return invertedIndex
+}
+
+// Private
+// This method splits the text to its lower case words (letters and digits)
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(character rune) bool {
+		return !unicode.IsLetter(character) && !unicode.IsDigit(character)
+	})
+}
@@ -0,0 +1,241 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Store over a MongoDB database
+type MongoStore struct {
+	Database *mongo.Database
+}
+
+// MongoDB collection of the store
+type mongoCollection struct {
+	collection *mongo.Collection
+}
+
+// This method creates a new store over the given MongoDB database
+func NewMongoStore(database *mongo.Database) *MongoStore {
+	return &MongoStore{Database: database}
+}
+
+// This method returns the collection with the given name
+func (mongoStore *MongoStore) Collection(name string) Collection {
+	return &mongoCollection{collection: mongoStore.Database.Collection(name)}
+}
+
+// This method runs the function in a MongoDB transaction (snapshot reads, majority writes)
+// The driver retries the function on TransientTransactionError and the commit on UnknownTransactionCommitResult
+// NOTE: the transactions need a replica set or a sharded cluster (e.g. MongoDB Atlas)
+func (mongoStore *MongoStore) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := mongoStore.Database.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	opts := options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.Majority())
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	}, opts)
+	return err
+}
+
+func (c *mongoCollection) InsertOne(ctx context.Context, document interface{}) (primitive.ObjectID, error) {
+	result, err := c.collection.InsertOne(ctx, document)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	insertedID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, errors.New("the inserted id is not an object id")
+	}
+	return insertedID, nil
+}
+
+func (c *mongoCollection) FindOne(ctx context.Context, filter interface{}, opts *FindOptions, result interface{}) error {
+	findOneOptions := options.FindOne()
+	if opts != nil {
+		if opts.Projection != nil {
+			findOneOptions.SetProjection(opts.Projection)
+		}
+		if opts.Sort != nil {
+			findOneOptions.SetSort(opts.Sort)
+		}
+		if opts.Skip > 0 {
+			findOneOptions.SetSkip(opts.Skip)
+		}
+	}
+
+	err := c.collection.FindOne(ctx, filter, findOneOptions).Decode(result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (c *mongoCollection) Find(ctx context.Context, filter interface{}, opts *FindOptions, results interface{}) error {
+	rows, err := c.collection.Find(ctx, filter, mongoFindOptions(opts))
+	if err != nil {
+		return err
+	}
+
+	return rows.All(ctx, results)
+}
+
+func (c *mongoCollection) FindCursor(ctx context.Context, filter interface{}, opts *FindOptions) (Cursor, error) {
+	return c.collection.Find(ctx, filter, mongoFindOptions(opts))
+}
+
+func (c *mongoCollection) CountDocuments(ctx context.Context, filter interface{}) (int64, error) {
+	return c.collection.CountDocuments(ctx, filter)
+}
+
+func (c *mongoCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error) {
+	result, err := c.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateResult{MatchedCount: result.MatchedCount, ModifiedCount: result.ModifiedCount}, nil
+}
+
+func (c *mongoCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error) {
+	result, err := c.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateResult{MatchedCount: result.MatchedCount, ModifiedCount: result.ModifiedCount}, nil
+}
+
+func (c *mongoCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, result interface{}) error {
+	err := c.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (c *mongoCollection) DeleteOne(ctx context.Context, filter interface{}) (int64, error) {
+	result, err := c.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (c *mongoCollection) DeleteMany(ctx context.Context, filter interface{}) (int64, error) {
+	result, err := c.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (c *mongoCollection) CountByField(ctx context.Context, filter interface{}, field string) ([]GroupCount, error) {
+
+	// Match and group stages
+	if filter == nil {
+		filter = bson.M{}
+	}
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+	}
+
+	rows, err := c.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []struct {
+		Value interface{} `bson:"_id"`
+		Count int64       `bson:"count"`
+	}{}
+	if err = rows.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	groupCounts := []GroupCount{}
+	for _, group := range groups {
+		groupCounts = append(groupCounts, GroupCount{Value: group.Value, Count: group.Count})
+	}
+	return groupCounts, nil
+}
+
+func (c *mongoCollection) BulkWrite(ctx context.Context, operations []BulkOperation, ordered bool) (*BulkWriteResult, error) {
+	writeModels := []mongo.WriteModel{}
+	for index, operation := range operations {
+		switch operation.Kind {
+		case BULK_INSERT:
+			writeModels = append(writeModels, mongo.NewInsertOneModel().SetDocument(operation.Document))
+		case BULK_UPDATE:
+			writeModels = append(writeModels, mongo.NewUpdateOneModel().SetFilter(operation.Filter).SetUpdate(operation.Update))
+		case BULK_DELETE:
+			writeModels = append(writeModels, mongo.NewDeleteOneModel().SetFilter(operation.Filter))
+		default:
+			return nil, fmt.Errorf("not supported kind %q of the bulk write %d", operation.Kind, index)
+		}
+	}
+
+	bulkResult := &BulkWriteResult{Errors: []BulkWriteError{}}
+	result, err := c.collection.BulkWrite(ctx, writeModels, options.BulkWrite().SetOrdered(ordered))
+
+	// The failed writes are reported with the counts of the successful ones (the write concern errors fail the bulk)
+	var bulkException mongo.BulkWriteException
+	if errors.As(err, &bulkException) && bulkException.WriteConcernError == nil {
+		for _, writeError := range bulkException.WriteErrors {
+			bulkResult.Errors = append(bulkResult.Errors, BulkWriteError{
+				Index: writeError.Index,
+				Err:   mongo.WriteException{WriteErrors: mongo.WriteErrors{writeError.WriteError}},
+			})
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if result != nil {
+		bulkResult.InsertedCount = result.InsertedCount
+		bulkResult.MatchedCount = result.MatchedCount
+		bulkResult.ModifiedCount = result.ModifiedCount
+		bulkResult.DeletedCount = result.DeletedCount
+	}
+	return bulkResult, nil
+}
+
+// Private
+// This method transforms the store find options to MongoDB find options
+func mongoFindOptions(opts *FindOptions) *options.FindOptions {
+	findOptions := options.Find()
+	if opts == nil {
+		return findOptions
+	}
+
+	if opts.Skip > 0 {
+		findOptions.SetSkip(opts.Skip)
+	}
+	if opts.Limit > 0 {
+		findOptions.SetLimit(opts.Limit)
+	}
+	if opts.Sort != nil {
+		findOptions.SetSort(opts.Sort)
+	}
+	if opts.Projection != nil {
+		findOptions.SetProjection(opts.Projection)
+	}
+	return findOptions
+}
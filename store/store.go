@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Error of the not existent documents (FindOne, FindOneAndUpdate)
+var ErrNotFound = errors.New("document not found")
+
+// Error of the duplicate values of unique indexes (MemoryStore)
+var ErrDuplicateKey = errors.New("duplicate key error")
+
+// Options of the find queries
+// The projection is a document like the MongoDB projections ({"password": 0})
+type FindOptions struct {
+	Skip       int64
+	Limit      int64
+	Sort       bson.D
+	Projection interface{}
+}
+
+// Result of an update statement
+type UpdateResult struct {
+	MatchedCount  int64
+	ModifiedCount int64
+}
+
+// Kinds of the writes of a bulk write
+const BULK_INSERT = "insert"
+const BULK_UPDATE = "update"
+const BULK_DELETE = "delete"
+
+// Write of a bulk write
+// The inserts have a document, the updates a filter and an update statement (first matching document)
+// and the deletes a filter (first matching document)
+type BulkOperation struct {
+	Kind     string
+	Document interface{}
+	Filter   interface{}
+	Update   interface{}
+}
+
+// Error of a failed write of a bulk write, with the index of the write in the bulk
+type BulkWriteError struct {
+	Index int
+	Err   error
+}
+
+// Result of a bulk write: the counts of the successful writes and the errors of the failed ones
+// The ordered bulk writes stop at the first failed write, so the writes after it are not executed
+type BulkWriteResult struct {
+	InsertedCount int64
+	MatchedCount  int64
+	ModifiedCount int64
+	DeletedCount  int64
+	Errors        []BulkWriteError
+}
+
+// Cursor over the matching documents of a find query, which decodes one document at a time
+// The cursor must be closed after the iteration
+type Cursor interface {
+	Next(ctx context.Context) bool
+	Decode(result interface{}) error
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// Number of documents with the same value of a field
+type GroupCount struct {
+	Value interface{}
+	Count int64
+}
+
+// Store gives access to the collections of the database
+// The handlers receive the store through dependency injection, so they can run
+// against MongoDB (MongoStore) or against memory in the tests (MemoryStore)
+type Store interface {
+	Collection(name string) Collection
+
+	// This method runs the function in a transaction: all its writes are committed together or none
+	// The operations of the function must use the given context, and the function may run again
+	// when the transaction fails with a transient error (e.g. a write conflict)
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// This method checks if the error is a duplicate key error of any store
+func IsDuplicateKey(err error) bool {
+	return errors.Is(err, ErrDuplicateKey) || mongo.IsDuplicateKeyError(err)
+}
+
+// Collection of documents
+// The filters and the update statements use the MongoDB query language
+type Collection interface {
+
+	// This method inserts a new document and returns its id
+	InsertOne(ctx context.Context, document interface{}) (primitive.ObjectID, error)
+
+	// This method decodes the first matching document into the result (ErrNotFound if none)
+	FindOne(ctx context.Context, filter interface{}, opts *FindOptions, result interface{}) error
+
+	// This method decodes all the matching documents into the results (pointer to slice)
+	Find(ctx context.Context, filter interface{}, opts *FindOptions, results interface{}) error
+
+	// This method returns a cursor over the matching documents, so they are not loaded all together (e.g. the exports)
+	FindCursor(ctx context.Context, filter interface{}, opts *FindOptions) (Cursor, error)
+
+	// This method counts the matching documents
+	CountDocuments(ctx context.Context, filter interface{}) (int64, error)
+
+	// This method updates the first matching document
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error)
+
+	// This method updates all the matching documents
+	// Like MongoDB the updates are not atomic: a failed update keeps the documents updated before it (use a transaction)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error)
+
+	// This method updates the first matching document and decodes it after the update (ErrNotFound if none)
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, result interface{}) error
+
+	// This method deletes the first matching document and returns the deleted count
+	DeleteOne(ctx context.Context, filter interface{}) (int64, error)
+
+	// This method deletes all the matching documents and returns the deleted count
+	DeleteMany(ctx context.Context, filter interface{}) (int64, error)
+
+	// This method counts the matching documents per value of the given field
+	CountByField(ctx context.Context, filter interface{}, field string) ([]GroupCount, error)
+
+	// This method runs the writes in one bulk write, in order (stop at the first failed write) or unordered (run all the writes)
+	// The failed writes are in the errors of the result, the error is returned only if the bulk write could not run
+	BulkWrite(ctx context.Context, operations []BulkOperation, ordered bool) (*BulkWriteResult, error)
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cursor of the keyset pagination: the signature of the sort and the values of the sort keys of a document
+// The token of the cursor is opaque to the clients (base64 of the BSON document)
+type Cursor struct {
+	Sort   string        `bson:"s"`
+	Values []interface{} `bson:"v"`
+}
+
+// This method encodes the cursor to its token
+func EncodeCursor(cursor Cursor) (string, error) {
+	data, err := bson.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// This method decodes the token of a cursor
+// Only scalar values are accepted (no documents or arrays), so a token can not inject operators in the filter
+func DecodeCursor(token string) (Cursor, error) {
+	cursor := Cursor{}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, errors.New("the cursor is not valid")
+	}
+	if err = bson.Unmarshal(data, &cursor); err != nil || len(cursor.Values) == 0 {
+		return cursor, errors.New("the cursor is not valid")
+	}
+
+	for _, value := range cursor.Values {
+		switch value.(type) {
+		case nil, string, bool, int32, int64, float64, primitive.DateTime, primitive.ObjectID:
+		default:
+			return cursor, fmt.Errorf("the cursor has a not valid value of type %T", value)
+		}
+	}
+	return cursor, nil
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEncodeAndDecodeCursor(t *testing.T) {
+	objectID, _ := primitive.ObjectIDFromHex("65a251c101cef849fc779521")
+	createdDt := time.Date(2024, 1, 18, 9, 35, 36, 0, time.UTC)
+
+	token, err := EncodeCursor(Cursor{Sort: "-createdDt,title,_id", Values: []interface{}{createdDt, "Silver", objectID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cursor, err := DecodeCursor(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Cursor{Sort: "-createdDt,title,_id", Values: []interface{}{primitive.NewDateTimeFromTime(createdDt), "Silver", objectID}}
+	if !reflect.DeepEqual(cursor, expected) {
+		t.Errorf("expected %v, got %v", expected, cursor)
+	}
+}
+
+func TestDecodeCursorRejectsNotValidTokens(t *testing.T) {
+	withOperator, _ := bson.Marshal(bson.M{"s": "title,_id", "v": bson.A{bson.M{"$ne": nil}, "x"}})
+	withoutValues, _ := bson.Marshal(bson.M{"s": "title,_id"})
+
+	tokens := map[string]string{
+		"not base64":     "not a cursor!",
+		"not bson":       base64.RawURLEncoding.EncodeToString([]byte("title")),
+		"operator value": base64.RawURLEncoding.EncodeToString(withOperator),
+		"without values": base64.RawURLEncoding.EncodeToString(withoutValues),
+		"empty":          "",
+	}
+
+	for name, token := range tokens {
+		if cursor, err := DecodeCursor(token); err == nil {
+			t.Errorf("%s: expected an error, got %v", name, cursor)
+		}
+	}
+}
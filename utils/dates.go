@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Layout of the dates before the native BSON dates (stored as strings)
+const LEGACY_DATE_LAYOUT = "2006-01-02 15:04:05"
+
+// Layouts accepted in the query parameters and the request bodies, RFC 3339 first
+// The layouts without a time zone are read as UTC
+var ACCEPTED_DATE_LAYOUTS = []string{time.RFC3339Nano, "2006-01-02T15:04:05", LEGACY_DATE_LAYOUT, "2006-01-02"}
+
+// This method returns the current time in UTC, truncated to the milliseconds that a BSON date keeps
+func NowUTC() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// This method parses the given date in one of the accepted layouts and returns it in UTC
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range ACCEPTED_DATE_LAYOUTS {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date '%s', expected RFC 3339 (e.g. 2024-01-18T09:35:36Z)", value)
+}
+
+// This method returns the bson names of the time.Time fields of the model
+func DateFieldsOf[T any]() []string {
+	var model T
+	modelType := reflect.TypeOf(model)
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return []string{}
+	}
+
+	dateFields := []string{}
+	timeType := reflect.TypeOf(time.Time{})
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		if field.Type != timeType {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("bson"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		dateFields = append(dateFields, name)
+	}
+	return dateFields
+}
+
+// This method converts the string values of the given date fields of the document to time.Time (UTC)
+// Missing fields are skipped, any other value type is an error
+func ConvertDateFields(document map[string]interface{}, dateFields []string) error {
+	for _, field := range dateFields {
+		value, found := document[field]
+		if !found {
+			continue
+		}
+
+		switch typedValue := value.(type) {
+		case time.Time:
+			document[field] = typedValue.UTC()
+		case string:
+			parsed, err := ParseDate(typedValue)
+			if err != nil {
+				return fmt.Errorf("field '%s': %w", field, err)
+			}
+			document[field] = parsed
+		default:
+			return fmt.Errorf("field '%s': a date string is expected", field)
+		}
+	}
+	return nil
+}
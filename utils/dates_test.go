@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Model with date fields for the reflection
+type datedModel struct {
+	Title      string    `bson:"title"`
+	CreatedDt  time.Time `bson:"createdDt,omitempty"`
+	Begin_dt   time.Time `bson:"begin_dt"`
+	UntaggedDt time.Time
+}
+
+func TestParseDate(t *testing.T) {
+	expected := time.Date(2024, 1, 18, 9, 35, 36, 0, time.UTC)
+
+	testCases := []struct {
+		value    string
+		expected time.Time
+		isValid  bool
+	}{
+		{"2024-01-18T09:35:36Z", expected, true},
+		{"2024-01-18T11:35:36+02:00", expected, true},
+		{"2024-01-18T09:35:36.250Z", expected.Add(250 * time.Millisecond), true},
+		{"2024-01-18T09:35:36", expected, true},
+		{"2024-01-18 09:35:36", expected, true},
+		{"2024-01-18", time.Date(2024, 1, 18, 0, 0, 0, 0, time.UTC), true},
+		{"18/01/2024", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, testCase := range testCases {
+		parsed, err := ParseDate(testCase.value)
+		if !testCase.isValid {
+			if err == nil {
+				t.Errorf("%q: expected an error", testCase.value)
+			}
+			continue
+		}
+		if err != nil || !parsed.Equal(testCase.expected) || parsed.Location() != time.UTC {
+			t.Errorf("%q: expected %v, got %v: %v", testCase.value, testCase.expected, parsed, err)
+		}
+	}
+}
+
+func TestDateFieldsOf(t *testing.T) {
+	if fields := DateFieldsOf[datedModel](); !reflect.DeepEqual(fields, []string{"createdDt", "begin_dt", "UntaggedDt"}) {
+		t.Fatalf("unexpected date fields %v", fields)
+	}
+	if fields := DateFieldsOf[string](); len(fields) != 0 {
+		t.Fatalf("expected no date fields, got %v", fields)
+	}
+}
+
+func TestConvertDateFields(t *testing.T) {
+	document := map[string]interface{}{"title": "2024-01-18", "createdDt": "2024-01-18T09:35:36Z"}
+	if err := ConvertDateFields(document, []string{"createdDt", "begin_dt"}); err != nil {
+		t.Fatal(err)
+	}
+	if document["title"] != "2024-01-18" {
+		t.Fatalf("a field that is not a date was converted: %v", document["title"])
+	}
+	if _, found := document["begin_dt"]; found {
+		t.Fatal("a missing date field was added")
+	}
+	if !document["createdDt"].(time.Time).Equal(time.Date(2024, 1, 18, 9, 35, 36, 0, time.UTC)) {
+		t.Fatalf("unexpected converted date %v", document["createdDt"])
+	}
+
+	for _, value := range []interface{}{"not a date", 1705570536, nil} {
+		if err := ConvertDateFields(map[string]interface{}{"createdDt": value}, []string{"createdDt"}); err == nil {
+			t.Errorf("%v: expected an error", value)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,3 +21,20 @@ func HandleError(context *gin.Context, statusReturn int, messageReturn string, e
 	})
 
 }
+
+// This method handles the not valid document data on the API requests (422 Unprocessable Entity)
+// Inputs:
+//  1. context of request
+//  2. Any message to add to the error response
+//  3. Error string
+//  4. Errors of the fields (e.g. field, rule and message of every not valid field)
+func HandleValidationError(context *gin.Context, messageReturn string, errorHappened string, fieldErrors interface{}) {
+
+	// Handle error and abort this API request
+	context.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+		"message": messageReturn,
+		"error":   errorHappened,
+		"errors":  fieldErrors,
+	})
+
+}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Query parameter of the format of the exports: format=csv
+const EXPORT_FORMAT_PARAM = "format"
+
+// Formats of the exports
+const EXPORT_FORMAT_CSV = "csv"
+const EXPORT_FORMAT_XLSX = "xlsx"
+const EXPORT_FORMAT_NDJSON = "ndjson"
+
+// Format of the exports without the query parameter
+const EXPORT_DEFAULT_FORMAT = EXPORT_FORMAT_CSV
+
+// First characters of the CSV values that the spreadsheets evaluate as formulas (CSV injection)
+const CSV_FORMULA_CHARACTERS = "=+-@\t\r"
+
+// Format of an export: the content type and the extension of the file
+type ExportFormat struct {
+	Name        string
+	ContentType string
+	Extension   string
+}
+
+// Supported formats of the exports
+var EXPORT_FORMATS = map[string]ExportFormat{
+	EXPORT_FORMAT_CSV:    {Name: EXPORT_FORMAT_CSV, ContentType: "text/csv; charset=utf-8", Extension: "csv"},
+	EXPORT_FORMAT_XLSX:   {Name: EXPORT_FORMAT_XLSX, ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extension: "xlsx"},
+	EXPORT_FORMAT_NDJSON: {Name: EXPORT_FORMAT_NDJSON, ContentType: "application/x-ndjson", Extension: "ndjson"},
+}
+
+// Writer of an export, that writes the rows one by one to the response (no document is kept in memory)
+// The values of the rows are the values of the JSON responses (strings, json.Number, booleans, nil, maps and lists)
+type ExportWriter interface {
+
+	// This method writes the names of the columns, before the rows
+	WriteHeader(columns []string) error
+
+	// This method writes the values of a row, in the order of the columns
+	WriteRow(values []interface{}) error
+
+	// This method sends the buffered rows to the writer
+	Flush() error
+
+	// This method ends the export (e.g. the closing tags of the spreadsheet)
+	Close() error
+}
+
+// This method returns the format of the format query parameter (the default format if it is empty)
+func ParseExportFormat(value string) (ExportFormat, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		value = EXPORT_DEFAULT_FORMAT
+	}
+
+	format, found := EXPORT_FORMATS[value]
+	if !found {
+		names := []string{}
+		for name := range EXPORT_FORMATS {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return ExportFormat{}, fmt.Errorf("format '%s' is not supported, allowed: %s", value, strings.Join(names, ", "))
+	}
+	return format, nil
+}
+
+// This method returns the writer of the format
+func NewExportWriter(format ExportFormat, writer io.Writer) (ExportWriter, error) {
+	switch format.Name {
+	case EXPORT_FORMAT_CSV:
+		return &csvExportWriter{writer: csv.NewWriter(writer)}, nil
+	case EXPORT_FORMAT_NDJSON:
+		return &ndjsonExportWriter{writer: writer}, nil
+	case EXPORT_FORMAT_XLSX:
+		return newXLSXExportWriter(writer)
+	}
+	return nil, fmt.Errorf("format '%s' is not supported", format.Name)
+}
+
+// CSV ---------------------
+
+// Writer of the CSV exports (RFC 4180), with a header row
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvExportWriter) WriteHeader(columns []string) error {
+	return w.writer.Write(columns)
+}
+
+func (w *csvExportWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for position, value := range values {
+		text, err := csvValue(value)
+		if err != nil {
+			return err
+		}
+		record[position] = text
+	}
+	return w.writer.Write(record)
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvExportWriter) Close() error {
+	return w.Flush()
+}
+
+// Private
+// This method returns the text of a CSV value: the JSON of the maps and lists, and the texts that start with the
+// characters of the formulas are escaped with a quote, so the spreadsheets do not evaluate them
+func csvValue(value interface{}) (string, error) {
+	switch typedValue := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if typedValue != "" && strings.ContainsRune(CSV_FORMULA_CHARACTERS, rune(typedValue[0])) {
+			return "'" + typedValue, nil
+		}
+		return typedValue, nil
+	case json.Number:
+		return typedValue.String(), nil
+	case bool:
+		return fmt.Sprint(typedValue), nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// NDJSON ------------------
+
+// Writer of the NDJSON exports: a JSON object per line, with the fields in the order of the columns
+type ndjsonExportWriter struct {
+	writer  io.Writer
+	columns []string
+}
+
+func (w *ndjsonExportWriter) WriteHeader(columns []string) error {
+	w.columns = columns
+	return nil
+}
+
+func (w *ndjsonExportWriter) WriteRow(values []interface{}) error {
+	if len(values) != len(w.columns) {
+		return fmt.Errorf("the row has %d values, expected %d columns", len(values), len(w.columns))
+	}
+
+	var line strings.Builder
+	line.WriteString("{")
+	for position, column := range w.columns {
+		if position > 0 {
+			line.WriteString(",")
+		}
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(values[position])
+		if err != nil {
+			return err
+		}
+		line.Write(key)
+		line.WriteString(":")
+		line.Write(value)
+	}
+	line.WriteString("}\n")
+
+	_, err := io.WriteString(w.writer, line.String())
+	return err
+}
+
+func (w *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+func (w *ndjsonExportWriter) Close() error {
+	return nil
+}
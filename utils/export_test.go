@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Private
+// This method writes an export with a header and the rows
+func writeTestExport(t *testing.T, name string, columns []string, rows ...[]interface{}) []byte {
+	format, err := ParseExportFormat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	writer, err := NewExportWriter(format, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.WriteHeader(columns); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err = writer.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return output.Bytes()
+}
+
+func TestParseExportFormat(t *testing.T) {
+	for value, expected := range map[string]string{"": EXPORT_FORMAT_CSV, "CSV": EXPORT_FORMAT_CSV, " xlsx ": EXPORT_FORMAT_XLSX, "ndjson": EXPORT_FORMAT_NDJSON} {
+		if format, err := ParseExportFormat(value); err != nil || format.Name != expected {
+			t.Errorf("%q: expected %s, got %v (%v)", value, expected, format, err)
+		}
+	}
+	if _, err := ParseExportFormat("pdf"); err == nil || !strings.Contains(err.Error(), "csv, ndjson, xlsx") {
+		t.Errorf("expected the allowed formats, got %v", err)
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	columns := []string{"title", "price", "isActive", "comments", "tags"}
+	output := writeTestExport(t, EXPORT_FORMAT_CSV, columns,
+		[]interface{}{"Gold, \"plus\"", json.Number("20"), true, nil, []interface{}{"a"}},
+		[]interface{}{"=HYPERLINK(\"x\")", json.Number("1.5"), false, "-1", map[string]interface{}{"a": json.Number("1")}},
+	)
+
+	expected := "title,price,isActive,comments,tags\n" +
+		"\"Gold, \"\"plus\"\"\",20,true,,\"[\"\"a\"\"]\"\n" +
+		"\"'=HYPERLINK(\"\"x\"\")\",1.5,false,'-1,\"{\"\"a\"\":1}\"\n"
+	if string(output) != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+}
+
+func TestNDJSONExport(t *testing.T) {
+	output := writeTestExport(t, EXPORT_FORMAT_NDJSON, []string{"title", "price", "comments"},
+		[]interface{}{"Gold", json.Number("9007199254740993"), nil},
+		[]interface{}{"=1", json.Number("5"), "line\nbreak"},
+	)
+
+	expected := "{\"title\":\"Gold\",\"price\":9007199254740993,\"comments\":null}\n" +
+		"{\"title\":\"=1\",\"price\":5,\"comments\":\"line\\nbreak\"}\n"
+	if string(output) != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+}
+
+func TestXLSXExport(t *testing.T) {
+	output := writeTestExport(t, EXPORT_FORMAT_XLSX, []string{"title", "price", "isActive", "comments"},
+		[]interface{}{"Gold & <Silver>", json.Number("20"), true, nil},
+	)
+
+	archive, err := zip.NewReader(bytes.NewReader(output), int64(len(output)))
+	if err != nil {
+		t.Fatalf("expected a zip archive, got %v", err)
+	}
+
+	parts := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[file.Name] = string(content)
+	}
+	for _, part := range XLSX_STATIC_PARTS {
+		if parts[part.name] != part.content {
+			t.Errorf("unexpected part %s: %q", part.name, parts[part.name])
+		}
+	}
+
+	expected := XLSX_SHEET_START +
+		`<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">title</t></is></c>` +
+		`<c r="B1" t="inlineStr"><is><t xml:space="preserve">price</t></is></c>` +
+		`<c r="C1" t="inlineStr"><is><t xml:space="preserve">isActive</t></is></c>` +
+		`<c r="D1" t="inlineStr"><is><t xml:space="preserve">comments</t></is></c></row>` +
+		`<row r="2"><c r="A2" t="inlineStr"><is><t xml:space="preserve">Gold &amp; &lt;Silver&gt;</t></is></c>` +
+		`<c r="B2"><v>20</v></c><c r="C2" t="b"><v>1</v></c></row>` +
+		XLSX_SHEET_END
+	if parts[XLSX_SHEET_PART] != expected {
+		t.Errorf("expected %s, got %s", expected, parts[XLSX_SHEET_PART])
+	}
+}
+
+func TestXLSXColumnName(t *testing.T) {
+	for position, expected := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if name := xlsxColumnName(position); name != expected {
+			t.Errorf("%d: expected %s, got %s", position, expected, name)
+		}
+	}
+}
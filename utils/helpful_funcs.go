@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/json"
 	"strconv"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -12,6 +13,11 @@ func CheckStringNotEmpty(sGiven string) bool {
 	return len(sGiven) > 0
 }
 
+// This method returns the normalized email (trimmed, lower case), so the emails match whatever their case
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // This method checks if the given string is an allowed user role
 func CheckAllowedRole(roleGiven string) bool {
 
@@ -1,73 +1,558 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
-// This method generates a new JWT for the user
-func GenerateToken(email string, userId string) (string, error) {
-
-	// Retrieve the secret key from the ENV file
-	secretKey := os.Getenv("SECRET_JWT_KEY")
-
-	// First parameter: Signing Method
-	// Second parameter: Additional user data for the JWT
-	// Third parameter: Expires at (2 hours from NOW)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"email":  email,
-		"userId": userId,
-		"exp":    time.Now().Add(time.Hour * 2).Unix(),
-	})
+// Signing key of the JWT tokens
+// A key without a private part is a retired key, that is only used for verification
+type SigningKey struct {
+	KeyID      string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+// Ring with all the known JWT keys
+// The active key signs the new tokens, all the keys verify the received tokens
+type KeyRing struct {
+	ActiveKeyID string
+	Keys        map[string]*SigningKey
+}
+
+// JSON Web Key (RFC 7517) of a public signing key
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JSON Web Key Set (RFC 7517)
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Global key ring for all the JWT operations
+var jwtKeyRing *KeyRing
+var jwtKeyRingMutex sync.Mutex
+
+// This method loads the JWT signing keys from the ENV configuration
+// JWT_KEYS_DIR: directory with the PEM keys, the file name (without the .pem extension) is the key id
+// JWT_ACTIVE_KEY_ID: the key id that signs the new tokens (default: the last key id in alphabetical order)
+// JWT_EPHEMERAL_KEYS: "true" generates an ephemeral Ed25519 key when no directory is given (development only),
+// otherwise a missing directory is an error
+func InitJWTKeys() error {
+
+	// Development mode: an ephemeral key only on request, so a missing configuration never signs with it
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	var keyRing *KeyRing
+	var err error
+	if !CheckStringNotEmpty(keysDir) && os.Getenv("JWT_EPHEMERAL_KEYS") == "true" {
+		fmt.Println("WARNING: JWT_KEYS_DIR is not set. Using an ephemeral Ed25519 signing key.")
+		keyRing, err = NewEphemeralKeyRing()
+	} else {
+		keyRing, err = LoadKeyRing(keysDir, os.Getenv("JWT_ACTIVE_KEY_ID"))
+	}
+	if err != nil {
+		return err
+	}
+
+	// Set the global key ring
+	SetKeyRing(keyRing)
+	fmt.Println("JWT active signing key:", keyRing.ActiveKeyID)
+	return nil
+}
+
+// This method sets the global key ring (used also by the tests)
+func SetKeyRing(keyRing *KeyRing) {
+	jwtKeyRingMutex.Lock()
+	defer jwtKeyRingMutex.Unlock()
+	jwtKeyRing = keyRing
+}
+
+// Private
+// This method returns the global key ring and initializes it if needed
+func currentKeyRing() (*KeyRing, error) {
+	jwtKeyRingMutex.Lock()
+	keyRing := jwtKeyRing
+	jwtKeyRingMutex.Unlock()
+
+	if keyRing != nil {
+		return keyRing, nil
+	}
+
+	// Not initialized key ring
+	err := InitJWTKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	jwtKeyRingMutex.Lock()
+	defer jwtKeyRingMutex.Unlock()
+	return jwtKeyRing, nil
+}
+
+// This method loads all the PEM keys of the given directory
+// Private keys (*.pem) can sign and verify, public keys (*.pub.pem) can only verify
+func LoadKeyRing(keysDir string, activeKeyID string) (*KeyRing, error) {
+
+	// Not configured keys directory
+	if !CheckStringNotEmpty(keysDir) {
+		return nil, errors.New("JWT_KEYS_DIR is not set (JWT_EPHEMERAL_KEYS=true uses an ephemeral key for development)")
+	}
+
+	// Read all the PEM files of the directory
+	pemFiles, err := filepath.Glob(filepath.Join(keysDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keyRing := &KeyRing{Keys: map[string]*SigningKey{}}
+	signingKeyIDs := []string{}
+	for _, pemFile := range pemFiles {
+
+		// The key id is the file name without the extensions
+		baseName := filepath.Base(pemFile)
+		isPublicOnly := strings.HasSuffix(baseName, ".pub.pem")
+		keyID := strings.TrimSuffix(strings.TrimSuffix(baseName, ".pem"), ".pub")
+
+		pemBytes, err := os.ReadFile(pemFile)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parse the key
+		var signingKey *SigningKey
+		if isPublicOnly {
+			signingKey, err = ParsePublicKeyPEM(keyID, pemBytes)
+		} else {
+			signingKey, err = ParsePrivateKeyPEM(keyID, pemBytes)
+		}
+		if err != nil {
+			return nil, errors.New("invalid JWT key { " + baseName + " }: " + err.Error())
+		}
+
+		// A private key overrides the public key with the same key id
+		if existentKey, found := keyRing.Keys[keyID]; found && existentKey.PrivateKey != nil {
+			continue
+		}
+		keyRing.Keys[keyID] = signingKey
+
+		if signingKey.PrivateKey != nil {
+			signingKeyIDs = append(signingKeyIDs, keyID)
+		}
+	}
+
+	// At least one signing key is necessary
+	if len(signingKeyIDs) == 0 {
+		return nil, errors.New("no JWT private key found in { " + keysDir + " }")
+	}
+
+	// Set the active signing key
+	if !CheckStringNotEmpty(activeKeyID) {
+		sort.Strings(signingKeyIDs)
+		activeKeyID = signingKeyIDs[len(signingKeyIDs)-1]
+	}
+	activeKey, found := keyRing.Keys[activeKeyID]
+	if !found || activeKey.PrivateKey == nil {
+		return nil, errors.New("the active JWT key { " + activeKeyID + " } has no private key")
+	}
+	keyRing.ActiveKeyID = activeKeyID
+
+	return keyRing, nil
+}
+
+// This method creates a key ring with a new random Ed25519 key
+func NewEphemeralKeyRing() (*KeyRing, error) {
+
+	// Generate the key pair
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Random key id
+	keyID, err := GenerateSecureRandomBytes(8)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyRing{
+		ActiveKeyID: keyID,
+		Keys: map[string]*SigningKey{
+			keyID: {
+				KeyID:      keyID,
+				Method:     jwt.SigningMethodEdDSA,
+				PrivateKey: privateKey,
+				PublicKey:  publicKey,
+			},
+		},
+	}, nil
+}
+
+// This method parses a PEM private key (PKCS#8 RSA/Ed25519 or PKCS#1 RSA)
+func ParsePrivateKeyPEM(keyID string, pemBytes []byte) (*SigningKey, error) {
+
+	// Decode the PEM block
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("not valid PEM data")
+	}
+
+	// Parse the private key
+	var privateKey interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the signing method by the key type
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{KeyID: keyID, Method: jwt.SigningMethodRS256, PrivateKey: key, PublicKey: &key.PublicKey}, nil
+
+	case ed25519.PrivateKey:
+		return &SigningKey{KeyID: keyID, Method: jwt.SigningMethodEdDSA, PrivateKey: key, PublicKey: key.Public()}, nil
+
+	default:
+		return nil, errors.New("not supported private key type (RSA or Ed25519 only)")
+	}
+}
+
+// This method parses a PEM public key (PKIX RSA/Ed25519)
+func ParsePublicKeyPEM(keyID string, pemBytes []byte) (*SigningKey, error) {
+
+	// Decode the PEM block
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("not valid PEM data")
+	}
+
+	// Parse the public key
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the signing method by the key type
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &SigningKey{KeyID: keyID, Method: jwt.SigningMethodRS256, PublicKey: key}, nil
+
+	case ed25519.PublicKey:
+		return &SigningKey{KeyID: keyID, Method: jwt.SigningMethodEdDSA, PublicKey: key}, nil
+
+	default:
+		return nil, errors.New("not supported public key type (RSA or Ed25519 only)")
+	}
+}
+
+// This method returns the public keys of the key ring as a JSON Web Key Set
+func (keyRing *KeyRing) JWKS() JSONWebKeySet {
+
+	// Sorted key ids for a stable output
+	keyIDs := []string{}
+	for keyID := range keyRing.Keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	keySet := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, keyID := range keyIDs {
+		signingKey := keyRing.Keys[keyID]
+
+		switch publicKey := signingKey.PublicKey.(type) {
+		case *rsa.PublicKey:
+			keySet.Keys = append(keySet.Keys, JSONWebKey{
+				KeyType:   "RSA",
+				KeyID:     keyID,
+				Use:       "sig",
+				Algorithm: signingKey.Method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+
+		case ed25519.PublicKey:
+			keySet.Keys = append(keySet.Keys, JSONWebKey{
+				KeyType:   "OKP",
+				KeyID:     keyID,
+				Use:       "sig",
+				Algorithm: signingKey.Method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+
+	return keySet
+}
+
+// This method returns the JSON Web Key Set of the global key ring
+func GetJWKS() (JSONWebKeySet, error) {
+
+	keyRing, err := currentKeyRing()
+	if err != nil {
+		return JSONWebKeySet{}, err
+	}
+
+	return keyRing.JWKS(), nil
+}
+
+// Types of the tokens that we sign (typ header)
+// A token is only accepted as its own type, e.g. the pending OIDC login never passes as an access token
+const JWT_TYPE_ACCESS = "JWT"
+const JWT_TYPE_OIDC_LOGIN = "oidc-login+jwt"
+
+// This method signs the given claims with the active key, as a token of the given type
+func SignClaims(claims jwt.Claims, tokenType string) (string, error) {
+
+	// Retrieve the active signing key
+	keyRing, err := currentKeyRing()
+	if err != nil {
+		return "", err
+	}
+	signingKey := keyRing.Keys[keyRing.ActiveKeyID]
+
+	// Set the key id in the header for the verification
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.KeyID
+	token.Header["typ"] = tokenType
 
 	// Return the produced token as Signed
-	// Sign Key = Secret Key only known to us
-	return token.SignedString([]byte(secretKey))
+	// Sign Key = Private Key only known to us
+	return token.SignedString(signingKey.PrivateKey)
 }
 
-// This method verifies a JWT token
-func VerifyToken(token string) (string, error) {
+// This method verifies the type and the signature of the given token and decodes its claims
+func ParseSignedClaims(token string, claims jwt.Claims, tokenType string) error {
 
-	// Retrieve the secret key from the ENV file
-	secretKey := os.Getenv("SECRET_JWT_KEY")
+	// Retrieve the key ring
+	keyRing, err := currentKeyRing()
+	if err != nil {
+		return err
+	}
 
 	// Parse the received token
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+	parsedToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+
+		// Only the tokens of the expected type
+		if typ, _ := token.Header["typ"].(string); typ != tokenType {
+			return nil, errors.New("not valid token type")
+		}
 
-		// Check the signing method type
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
+		// Find the verification key by the key id
+		keyID, ok := token.Header["kid"].(string)
 		if !ok {
+			return nil, errors.New("missing key id")
+		}
+		signingKey, found := keyRing.Keys[keyID]
+		if !found {
+			return nil, errors.New("unknown key id")
+		}
+
+		// Check the signing method type against the key
+		if token.Method.Alg() != signingKey.Method.Alg() {
 			return nil, errors.New("invalid signing method")
 		}
 
 		// Return success
-		return []byte(secretKey), nil
+		return signingKey.PublicKey, nil
 	})
 
 	// Check if present error
 	if err != nil {
-		return "", errors.New("could not parse token")
+		return errors.New("could not parse token")
 	}
 
 	// Check the token validity
 	if !parsedToken.Valid {
-		return "", errors.New("not valid token")
+		return errors.New("not valid token")
+	}
+
+	return nil
+}
+
+// Authentication methods of the tokens
+const AUTH_METHOD_PASSWORD = "password"
+const AUTH_METHOD_OIDC = "oidc"
+
+// Typed claims of the JWT tokens of our users
+type TokenClaims struct {
+	jwt.StandardClaims
+	Email      string `json:"email"`
+	UserID     string `json:"userId"`
+	AuthMethod string `json:"authMethod,omitempty"`
+}
+
+// Settings of the JWT tokens of our users
+type JWTSettings struct {
+	Issuer    string
+	Audience  string
+	ClockSkew time.Duration
+	TTL       time.Duration
+}
+
+// Global JWT settings (defaults, overridden by InitJWTSettings)
+var JWTConfig = JWTSettings{
+	Issuer:    "go-rest-api",
+	Audience:  "go-rest-api",
+	ClockSkew: time.Minute,
+	TTL:       2 * time.Hour,
+}
+
+// Allowed characters of a bearer token (RFC 6750: b64token)
+var bearerTokenRegex = regexp.MustCompile(`^[A-Za-z0-9\-._~+/]+=*$`)
+
+// This method loads the JWT settings from the ENV configuration
+// JWT_ISSUER, JWT_AUDIENCE, JWT_CLOCK_SKEW_SECONDS
+func InitJWTSettings() error {
+
+	if CheckStringNotEmpty(os.Getenv("JWT_ISSUER")) {
+		JWTConfig.Issuer = os.Getenv("JWT_ISSUER")
+	}
+
+	if CheckStringNotEmpty(os.Getenv("JWT_AUDIENCE")) {
+		JWTConfig.Audience = os.Getenv("JWT_AUDIENCE")
 	}
 
-	// Check the token data and expires at
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", errors.New("not valid token")
+	if CheckStringNotEmpty(os.Getenv("JWT_CLOCK_SKEW_SECONDS")) {
+		skewSeconds, err := TransformStringToInteger64(os.Getenv("JWT_CLOCK_SKEW_SECONDS"))
+		if err != nil || skewSeconds < 0 {
+			return errors.New("not valid JWT_CLOCK_SKEW_SECONDS value")
+		}
+		JWTConfig.ClockSkew = time.Duration(skewSeconds) * time.Second
+	}
+
+	return nil
+}
+
+// This method validates the standard claims (exp, nbf, iat, iss, aud, jti) with the clock skew tolerance
+// It is called by the JWT parser after the signature verification
+func (claims TokenClaims) Valid() error {
+
+	now := time.Now()
+	skew := JWTConfig.ClockSkew
+
+	// Expires at (required)
+	if claims.ExpiresAt == 0 || now.Add(-skew).Unix() > claims.ExpiresAt {
+		return errors.New("token is expired")
 	}
 
-	// Access the token data
-	fmt.Println("USER ID:", claims["userId"])
-	userId := claims["userId"].(string)
+	// Not before
+	if claims.NotBefore != 0 && now.Add(skew).Unix() < claims.NotBefore {
+		return errors.New("token is not valid yet")
+	}
+
+	// Issued at (required)
+	if claims.IssuedAt == 0 || now.Add(skew).Unix() < claims.IssuedAt {
+		return errors.New("token used before issued")
+	}
+
+	// Issuer and audience
+	if claims.Issuer != JWTConfig.Issuer {
+		return errors.New("not valid token issuer")
+	}
+	if claims.Audience != JWTConfig.Audience {
+		return errors.New("not valid token audience")
+	}
+
+	// Token id and user id
+	if !CheckStringNotEmpty(claims.Id) || !CheckStringNotEmpty(claims.UserID) {
+		return errors.New("missing token id or user id")
+	}
+
+	return nil
+}
+
+// This method extracts the token of an "Authorization: Bearer <token>" header (RFC 6750)
+func ExtractBearerToken(authorizationHeader string) (string, error) {
+
+	// Not existent header
+	if !CheckStringNotEmpty(authorizationHeader) {
+		return "", errors.New("missing authorization header")
+	}
+
+	// Scheme (case insensitive) and token
+	scheme, token, found := strings.Cut(authorizationHeader, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", errors.New("not supported authorization scheme")
+	}
+
+	token = strings.TrimLeft(token, " ")
+	if !bearerTokenRegex.MatchString(token) {
+		return "", errors.New("not valid bearer token")
+	}
+
+	return token, nil
+}
+
+// This method generates a new JWT for the user
+// The authentication method (password, oidc:<provider>) is kept in the token
+func GenerateToken(email string, userId string, authMethod string) (string, error) {
+
+	// Unique token id
+	tokenID, err := GenerateSecureRandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+
+	// Additional user data for the JWT
+	// Expires at (2 hours from NOW)
+	now := time.Now()
+	return SignClaims(TokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			Issuer:    JWTConfig.Issuer,
+			Audience:  JWTConfig.Audience,
+			Subject:   userId,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(JWTConfig.TTL).Unix(),
+		},
+		Email:      email,
+		UserID:     userId,
+		AuthMethod: authMethod,
+	}, JWT_TYPE_ACCESS)
+}
+
+// This method verifies a JWT token and returns its claims
+func VerifyToken(token string) (*TokenClaims, error) {
+
+	// Check the signature and the standard claims
+	claims := TokenClaims{}
+	err := ParseSignedClaims(token, &claims, JWT_TYPE_ACCESS)
+	if err != nil {
+		return nil, err
+	}
 
-	// Success and return the userId and nil for error
-	return userId, nil
+	// Success and return the claims and nil for error
+	return &claims, nil
 }
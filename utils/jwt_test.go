@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestExtractBearerToken(t *testing.T) {
+	testCases := []struct {
+		header  string
+		token   string
+		isValid bool
+	}{
+		{"Bearer abc.def-ghi_jkl", "abc.def-ghi_jkl", true},
+		{"bearer abc.def", "abc.def", true},
+		{"BEARER  abc.def==", "abc.def==", true},
+		{"", "", false},
+		{"abc.def.ghi", "", false},
+		{"Basic dXNlcjpwYXNz", "", false},
+		{"Bearer ", "", false},
+		{"Bearer abc def", "", false},
+		{"Bearer abc=def", "", false},
+	}
+
+	for _, testCase := range testCases {
+		token, err := ExtractBearerToken(testCase.header)
+		if testCase.isValid && (err != nil || token != testCase.token) {
+			t.Errorf("header %q: expected token %q, got %q (%v)", testCase.header, testCase.token, token, err)
+		}
+		if !testCase.isValid && err == nil {
+			t.Errorf("header %q: expected an error", testCase.header)
+		}
+	}
+}
+
+func TestVerifyTokenClaims(t *testing.T) {
+	keyRing, err := NewEphemeralKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyRing(keyRing)
+
+	// Valid token
+	token, err := GenerateToken("user@example.com", "65a8d750f00cf0007816e841", AUTH_METHOD_PASSWORD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := VerifyToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != "65a8d750f00cf0007816e841" || claims.Email != "user@example.com" || claims.Id == "" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	// Token signed with our key, but with not valid standard claims
+	now := time.Now()
+	validClaims := func() TokenClaims {
+		return TokenClaims{
+			StandardClaims: jwt.StandardClaims{
+				Id:        "jti-1",
+				Issuer:    JWTConfig.Issuer,
+				Audience:  JWTConfig.Audience,
+				IssuedAt:  now.Unix(),
+				ExpiresAt: now.Add(time.Hour).Unix(),
+			},
+			UserID: "65a8d750f00cf0007816e841",
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		change  func(claims *TokenClaims)
+		isValid bool
+	}{
+		{"valid", func(claims *TokenClaims) {}, true},
+		{"expired within clock skew", func(claims *TokenClaims) { claims.ExpiresAt = now.Add(-30 * time.Second).Unix() }, true},
+		{"expired", func(claims *TokenClaims) { claims.ExpiresAt = now.Add(-5 * time.Minute).Unix() }, false},
+		{"not before in the future", func(claims *TokenClaims) { claims.NotBefore = now.Add(5 * time.Minute).Unix() }, false},
+		{"issued in the future within clock skew", func(claims *TokenClaims) { claims.IssuedAt = now.Add(30 * time.Second).Unix() }, true},
+		{"wrong issuer", func(claims *TokenClaims) { claims.Issuer = "someone-else" }, false},
+		{"wrong audience", func(claims *TokenClaims) { claims.Audience = "other-api" }, false},
+		{"missing token id", func(claims *TokenClaims) { claims.Id = "" }, false},
+		{"missing user id", func(claims *TokenClaims) { claims.UserID = "" }, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			claims := validClaims()
+			testCase.change(&claims)
+
+			token, err := SignClaims(claims, JWT_TYPE_ACCESS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = VerifyToken(token)
+			if testCase.isValid && err != nil {
+				t.Fatalf("expected a valid token: %v", err)
+			}
+			if !testCase.isValid && err == nil {
+				t.Fatal("expected the token to be rejected")
+			}
+		})
+	}
+}
+
+func TestSignedClaimsTokenTypes(t *testing.T) {
+	keyRing, err := NewEphemeralKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyRing(keyRing)
+
+	// Valid access claims, signed as another type of token
+	now := time.Now()
+	claims := TokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        "jti-1",
+			Issuer:    JWTConfig.Issuer,
+			Audience:  JWTConfig.Audience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Hour).Unix(),
+		},
+		UserID: "65a8d750f00cf0007816e841",
+	}
+	loginToken, err := SignClaims(claims, JWT_TYPE_OIDC_LOGIN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = VerifyToken(loginToken); err == nil {
+		t.Fatal("expected the login token to be rejected as an access token")
+	}
+
+	// The access tokens are not accepted as the other types
+	accessToken, err := GenerateToken("user@example.com", "65a8d750f00cf0007816e841", AUTH_METHOD_PASSWORD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ParseSignedClaims(accessToken, &jwt.StandardClaims{}, JWT_TYPE_OIDC_LOGIN); err == nil {
+		t.Fatal("expected the access token to be rejected as a login token")
+	}
+	if err = ParseSignedClaims(accessToken, &TokenClaims{}, JWT_TYPE_ACCESS); err != nil {
+		t.Fatalf("expected a valid access token: %v", err)
+	}
+}
+
+func TestInitJWTKeysNeedsTheKeysDirectory(t *testing.T) {
+	defer SetKeyRing(nil)
+	t.Setenv("JWT_KEYS_DIR", "")
+	t.Setenv("JWT_ACTIVE_KEY_ID", "")
+
+	// No directory and no ephemeral key requested
+	t.Setenv("JWT_EPHEMERAL_KEYS", "")
+	if err := InitJWTKeys(); err == nil {
+		t.Fatal("expected an error without JWT_KEYS_DIR")
+	}
+
+	// Ephemeral key of the development mode
+	t.Setenv("JWT_EPHEMERAL_KEYS", "true")
+	if err := InitJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	keyRing, err := currentKeyRing()
+	if err != nil || keyRing.Keys[keyRing.ActiveKeyID] == nil {
+		t.Fatalf("expected the ephemeral active key, got %+v: %v", keyRing, err)
+	}
+}
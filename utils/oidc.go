@@ -0,0 +1,447 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// OpenID Connect provider (relying party configuration)
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// Discovered configuration
+	discovery *OIDCDiscovery
+	keys      map[string]interface{}
+	mutex     sync.Mutex
+
+	// Time of the last fetch of the keys, and the unknown key ids with the time of the fetch that missed them
+	keysFetchedAt time.Time
+	unknownKeys   map[string]time.Time
+}
+
+// OpenID Connect discovery document (the used fields only)
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Verified identity of the ID token
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+}
+
+// Claims of the ID token
+type oidcIDTokenClaims struct {
+	jwt.StandardClaims
+	Audience      interface{} `json:"aud"`
+	Nonce         string      `json:"nonce"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	GivenName     string      `json:"given_name"`
+	FamilyName    string      `json:"family_name"`
+	Name          string      `json:"name"`
+}
+
+// Min interval between two fetches of the keys of a provider, whatever the key ids of the tokens
+const OIDC_KEYS_REFRESH_INTERVAL = time.Minute
+
+// Time that an unknown key id is rejected without fetching the keys again
+const OIDC_UNKNOWN_KEY_TTL = 10 * time.Minute
+
+// Max number of the remembered unknown key ids of a provider (the oldest are forgotten all together)
+const OIDC_MAX_UNKNOWN_KEYS = 1000
+
+// HTTP client for all the calls to the providers
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Global configured providers
+var OIDCProviders = map[string]*OIDCProvider{}
+
+// This method loads the OIDC providers from the ENV configuration
+// OIDC_PROVIDERS: comma separated provider names (e.g. google,microsoft)
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL
+func InitOIDCProviders() error {
+
+	providers := map[string]*OIDCProvider{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !CheckStringNotEmpty(name) {
+			continue
+		}
+
+		// Read the provider configuration
+		envPrefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := &OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(envPrefix + "ISSUER"),
+			ClientID:     os.Getenv(envPrefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(envPrefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(envPrefix + "REDIRECT_URL"),
+			Scopes:       []string{"openid", "email", "profile"},
+		}
+
+		if !CheckStringNotEmpty(provider.Issuer) || !CheckStringNotEmpty(provider.ClientID) || !CheckStringNotEmpty(provider.RedirectURL) {
+			return errors.New("missing issuer, client id or redirect url for the OIDC provider { " + name + " }")
+		}
+		providers[name] = provider
+	}
+
+	OIDCProviders = providers
+	return nil
+}
+
+// This method fetches (once) the discovery document of the provider
+func (provider *OIDCProvider) Discover(ctx context.Context) (*OIDCDiscovery, error) {
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.discovery != nil {
+		return provider.discovery, nil
+	}
+
+	// Retrieve the configuration
+	discovery := OIDCDiscovery{}
+	wellKnownURL := strings.TrimSuffix(provider.Issuer, "/") + "/.well-known/openid-configuration"
+	err := oidcGetJSON(ctx, wellKnownURL, &discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	// The issuer must match the configured issuer
+	if discovery.Issuer != provider.Issuer {
+		return nil, errors.New("the discovered issuer { " + discovery.Issuer + " } does not match the configured issuer")
+	}
+
+	provider.discovery = &discovery
+	return provider.discovery, nil
+}
+
+// This method creates a new PKCE pair (RFC 7636)
+// Output: code verifier, S256 code challenge
+func NewPKCE() (string, string, error) {
+
+	// 32 random bytes -> 64 HEX characters (allowed length: 43-128)
+	codeVerifier, err := GenerateSecureRandomBytes(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return codeVerifier, PKCEChallenge(codeVerifier), nil
+}
+
+// This method returns the S256 code challenge of the code verifier
+func PKCEChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// This method returns the URL of the authorization endpoint for the authorization code flow
+func (provider *OIDCProvider) AuthCodeURL(ctx context.Context, state string, nonce string, codeChallenge string) (string, error) {
+
+	discovery, err := provider.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Set the query parameters
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientID)
+	query.Set("redirect_uri", provider.RedirectURL)
+	query.Set("scope", strings.Join(provider.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// This method exchanges the authorization code for the tokens and returns the raw ID token
+func (provider *OIDCProvider) Exchange(ctx context.Context, code string, codeVerifier string) (string, error) {
+
+	discovery, err := provider.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Token request body
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.RedirectURL)
+	form.Set("client_id", provider.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if CheckStringNotEmpty(provider.ClientSecret) {
+		form.Set("client_secret", provider.ClientSecret)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := oidcHTTPClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	// Token response
+	tokenResponse := struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	err = json.NewDecoder(response.Body).Decode(&tokenResponse)
+	if err != nil {
+		return "", errors.New("could not decode the token response: " + err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK || CheckStringNotEmpty(tokenResponse.Error) {
+		return "", errors.New("token exchange failed: " + tokenResponse.Error + " " + tokenResponse.ErrorDescription)
+	}
+	if !CheckStringNotEmpty(tokenResponse.IDToken) {
+		return "", errors.New("the token response has no id_token")
+	}
+
+	return tokenResponse.IDToken, nil
+}
+
+// This method verifies the ID token (signature, issuer, audience, expiration, nonce)
+func (provider *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*OIDCIdentity, error) {
+
+	// Parse and verify the signature with the provider keys
+	claims := oidcIDTokenClaims{}
+	parsedToken, err := jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+
+		// Only asymmetric algorithms are accepted
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, errors.New("invalid signing method")
+		}
+
+		keyID, _ := token.Header["kid"].(string)
+		return provider.verificationKey(ctx, keyID)
+	})
+	if err != nil {
+		return nil, errors.New("could not verify the ID token: " + err.Error())
+	}
+	if !parsedToken.Valid {
+		return nil, errors.New("not valid ID token")
+	}
+
+	// Issuer
+	if claims.Issuer != provider.Issuer {
+		return nil, errors.New("not valid ID token issuer")
+	}
+
+	// Audience (string or array of strings)
+	if !oidcAudienceContains(claims.Audience, provider.ClientID) {
+		return nil, errors.New("not valid ID token audience")
+	}
+
+	// Expiration is required
+	if claims.ExpiresAt == 0 {
+		return nil, errors.New("missing ID token expiration")
+	}
+
+	// Nonce
+	if claims.Nonce != nonce {
+		return nil, errors.New("not valid ID token nonce")
+	}
+
+	// Subject
+	if !CheckStringNotEmpty(claims.Subject) {
+		return nil, errors.New("missing ID token subject")
+	}
+
+	// email_verified can be a boolean or a string ("true")
+	emailVerified := false
+	switch value := claims.EmailVerified.(type) {
+	case bool:
+		emailVerified = value
+	case string:
+		emailVerified = value == "true"
+	}
+
+	return &OIDCIdentity{
+		Subject:       claims.Subject,
+		Email:         NormalizeEmail(claims.Email),
+		EmailVerified: emailVerified,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+		Name:          claims.Name,
+	}, nil
+}
+
+// Private
+// This method returns the provider key with the given key id
+// The keys are fetched again if the key id is unknown (provider key rotation), at most once per OIDC_KEYS_REFRESH_INTERVAL,
+// and the key ids that the last fetches did not have are rejected without fetching for OIDC_UNKNOWN_KEY_TTL
+func (provider *OIDCProvider) verificationKey(ctx context.Context, keyID string) (interface{}, error) {
+	unknownKeyErr := errors.New("unknown key id { " + keyID + " }")
+
+	provider.mutex.Lock()
+	key, found := provider.keys[keyID]
+	if found {
+		provider.mutex.Unlock()
+		return key, nil
+	}
+	now := time.Now()
+	missedAt, missed := provider.unknownKeys[keyID]
+	if (missed && now.Sub(missedAt) < OIDC_UNKNOWN_KEY_TTL) || now.Sub(provider.keysFetchedAt) < OIDC_KEYS_REFRESH_INTERVAL {
+		provider.mutex.Unlock()
+		return nil, unknownKeyErr
+	}
+	// The fetch is reserved before the call, so the concurrent requests do not fetch again
+	provider.keysFetchedAt = now
+	provider.mutex.Unlock()
+
+	discovery, err := provider.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieve the provider keys
+	keySet := struct {
+		Keys []JSONWebKey `json:"keys"`
+	}{}
+	err = oidcGetJSON(ctx, discovery.JWKSURI, &keySet)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jsonWebKey := range keySet.Keys {
+		publicKey, err := oidcParseJSONWebKey(jsonWebKey)
+		if err != nil {
+			fmt.Println("Skipping OIDC key", jsonWebKey.KeyID, ":", err)
+			continue
+		}
+		keys[jsonWebKey.KeyID] = publicKey
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.keys = keys
+
+	key, found = keys[keyID]
+	if !found {
+		if provider.unknownKeys == nil || len(provider.unknownKeys) >= OIDC_MAX_UNKNOWN_KEYS {
+			provider.unknownKeys = map[string]time.Time{}
+		}
+		provider.unknownKeys[keyID] = now
+		return nil, unknownKeyErr
+	}
+	return key, nil
+}
+
+// Private
+// This method transforms a JSON Web Key to a public key (RSA or EC P-256)
+func oidcParseJSONWebKey(jsonWebKey JSONWebKey) (interface{}, error) {
+	switch jsonWebKey.KeyType {
+	case "RSA":
+		nBytes, err := base64.RawURLEncoding.DecodeString(jsonWebKey.N)
+		if err != nil {
+			return nil, err
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(jsonWebKey.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: int(new(big.Int).SetBytes(eBytes).Int64())}, nil
+
+	case "EC":
+		if jsonWebKey.Curve != "P-256" {
+			return nil, errors.New("not supported curve " + jsonWebKey.Curve)
+		}
+		xBytes, err := base64.RawURLEncoding.DecodeString(jsonWebKey.X)
+		if err != nil {
+			return nil, err
+		}
+		yBytes, err := base64.RawURLEncoding.DecodeString(jsonWebKey.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(xBytes), Y: new(big.Int).SetBytes(yBytes)}, nil
+
+	default:
+		return nil, errors.New("not supported key type " + jsonWebKey.KeyType)
+	}
+}
+
+// Private
+// This method checks the audience claim of the ID token
+func oidcAudienceContains(audience interface{}, clientID string) bool {
+	switch value := audience.(type) {
+	case string:
+		return value == clientID
+	case []interface{}:
+		for _, item := range value {
+			if item == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Private
+// This method executes a GET request and decodes the JSON response
+func oidcGetJSON(ctx context.Context, requestURL string, target interface{}) error {
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := oidcHTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return errors.New("unexpected status " + response.Status + " from " + requestURL)
+	}
+
+	return json.NewDecoder(response.Body).Decode(target)
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Local mock OpenID Connect provider
+type mockOIDCProvider struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	clientID      string
+	codeChallenge string
+	nonce         string
+	claims        jwt.MapClaims
+	jwksRequests  int
+}
+
+// This method starts a mock provider with discovery, token and JWKS endpoints
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := &mockOIDCProvider{key: key, clientID: "test-client"}
+	mux := http.NewServeMux()
+	mock.server = httptest.NewServer(mux)
+	t.Cleanup(mock.server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OIDCDiscovery{
+			Issuer:                mock.server.URL,
+			AuthorizationEndpoint: mock.server.URL + "/authorize",
+			TokenEndpoint:         mock.server.URL + "/token",
+			JWKSURI:               mock.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		mock.jwksRequests++
+		json.NewEncoder(w).Encode(JSONWebKeySet{Keys: []JSONWebKey{{
+			KeyType:   "RSA",
+			KeyID:     "mock-key",
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}}})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		// PKCE check of the mock provider
+		if r.Form.Get("code") != "good-code" || PKCEChallenge(r.Form.Get("code_verifier")) != mock.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, mock.claims)
+		token.Header["kid"] = "mock-key"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "access_token": "x", "token_type": "Bearer"})
+	})
+
+	return mock
+}
+
+// This method returns the default valid claims of the ID token
+func (mock *mockOIDCProvider) validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            mock.server.URL,
+		"aud":            mock.clientID,
+		"sub":            "external-123",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          mock.nonce,
+		"email":          "Someone@Example.com",
+		"email_verified": true,
+		"given_name":     "Some",
+		"family_name":    "One",
+	}
+}
+
+func TestOIDCAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := &OIDCProvider{
+		Name:        "mock",
+		Issuer:      mock.server.URL,
+		ClientID:    mock.clientID,
+		RedirectURL: "http://localhost:8082/auth/oidc/mock/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	}
+
+	codeVerifier, codeChallenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.codeChallenge = codeChallenge
+	mock.nonce = "nonce-1"
+	mock.claims = mock.validClaims()
+
+	// Authorization URL
+	authorizationURL, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", codeChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedURL, _ := url.Parse(authorizationURL)
+	query := parsedURL.Query()
+	if !strings.HasPrefix(authorizationURL, mock.server.URL+"/authorize?") || query.Get("code_challenge") != codeChallenge || query.Get("code_challenge_method") != "S256" || query.Get("state") != "state-1" || query.Get("nonce") != "nonce-1" {
+		t.Fatalf("unexpected authorization URL: %s", authorizationURL)
+	}
+
+	// Wrong verifier is rejected by the provider
+	_, err = provider.Exchange(context.Background(), "good-code", "wrong-verifier")
+	if err == nil {
+		t.Fatal("expected the exchange to fail with a wrong code verifier")
+	}
+
+	// Code exchange and ID token verification
+	rawIDToken, err := provider.Exchange(context.Background(), "good-code", codeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := provider.VerifyIDToken(context.Background(), rawIDToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "external-123" || identity.Email != "someone@example.com" || !identity.EmailVerified || identity.GivenName != "Some" {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+
+	// Nonce mismatch
+	_, err = provider.VerifyIDToken(context.Background(), rawIDToken, "other-nonce")
+	if err == nil {
+		t.Fatal("expected a nonce mismatch error")
+	}
+}
+
+func TestOIDCVerifyIDTokenRejectsInvalidClaims(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := &OIDCProvider{Name: "mock", Issuer: mock.server.URL, ClientID: mock.clientID, RedirectURL: "http://localhost/cb"}
+
+	codeVerifier, codeChallenge, _ := NewPKCE()
+	mock.codeChallenge = codeChallenge
+	mock.nonce = "n"
+
+	testCases := []struct {
+		name   string
+		change func(claims jwt.MapClaims)
+	}{
+		{"wrong audience", func(claims jwt.MapClaims) { claims["aud"] = "other-client" }},
+		{"wrong issuer", func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"missing subject", func(claims jwt.MapClaims) { delete(claims, "sub") }},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			mock.claims = mock.validClaims()
+			testCase.change(mock.claims)
+
+			rawIDToken, err := provider.Exchange(context.Background(), "good-code", codeVerifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = provider.VerifyIDToken(context.Background(), rawIDToken, "n")
+			if err == nil {
+				t.Fatal("expected the ID token to be rejected")
+			}
+		})
+	}
+
+	// Audience as an array
+	mock.claims = mock.validClaims()
+	mock.claims["aud"] = []string{"another", mock.clientID}
+	rawIDToken, _ := provider.Exchange(context.Background(), "good-code", codeVerifier)
+	_, err := provider.VerifyIDToken(context.Background(), rawIDToken, "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOIDCVerificationKeyRefetchLimits(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := &OIDCProvider{Name: "mock", Issuer: mock.server.URL, ClientID: mock.clientID, RedirectURL: "http://localhost/cb"}
+	ctx := context.Background()
+
+	// The first key fetches the keys, the known keys are not fetched again
+	for i := 0; i < 2; i++ {
+		if _, err := provider.verificationKey(ctx, "mock-key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mock.jwksRequests != 1 {
+		t.Fatalf("expected 1 keys request, got %d", mock.jwksRequests)
+	}
+
+	// Unknown key ids right after a fetch are rejected without fetching
+	for _, keyID := range []string{"unknown-1", "unknown-2", "unknown-3"} {
+		if _, err := provider.verificationKey(ctx, keyID); err == nil {
+			t.Fatalf("expected the key id %s to be unknown", keyID)
+		}
+	}
+	if mock.jwksRequests != 1 {
+		t.Fatalf("expected no keys request in the refresh interval, got %d", mock.jwksRequests)
+	}
+
+	// After the refresh interval an unknown key id fetches the keys once, and the miss is remembered
+	provider.keysFetchedAt = time.Now().Add(-OIDC_KEYS_REFRESH_INTERVAL)
+	if _, err := provider.verificationKey(ctx, "unknown-1"); err == nil {
+		t.Fatal("expected the key id to be unknown")
+	}
+	provider.keysFetchedAt = time.Now().Add(-OIDC_KEYS_REFRESH_INTERVAL)
+	if _, err := provider.verificationKey(ctx, "unknown-1"); err == nil {
+		t.Fatal("expected the key id to be unknown")
+	}
+	if mock.jwksRequests != 2 {
+		t.Fatalf("expected 2 keys requests, got %d", mock.jwksRequests)
+	}
+
+	// The remembered miss expires
+	provider.unknownKeys["unknown-1"] = time.Now().Add(-OIDC_UNKNOWN_KEY_TTL)
+	provider.verificationKey(ctx, "unknown-1")
+	if mock.jwksRequests != 3 {
+		t.Fatalf("expected 3 keys requests, got %d", mock.jwksRequests)
+	}
+}
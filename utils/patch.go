@@ -0,0 +1,299 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Content types of the request bodies of the updates (PATCH)
+// The JSON body is a shallow update of the top level fields
+const CONTENT_TYPE_JSON = "application/json"
+const CONTENT_TYPE_MERGE_PATCH = "application/merge-patch+json"
+const CONTENT_TYPE_JSON_PATCH = "application/json-patch+json"
+
+// Max number of operations of a JSON Patch
+const MAX_JSON_PATCH_OPERATIONS = 100
+
+// Operations of the JSON Patch (RFC 6902)
+// The move and copy operations need the current values of the document, so they are not supported
+const JSON_PATCH_ADD = "add"
+const JSON_PATCH_REMOVE = "remove"
+const JSON_PATCH_REPLACE = "replace"
+const JSON_PATCH_TEST = "test"
+const JSON_PATCH_MOVE = "move"
+const JSON_PATCH_COPY = "copy"
+
+// Last segment of a JSON Patch path that appends to a list (e.g. /items/-)
+const JSON_PATCH_APPEND = "-"
+
+// Error of the valid patches that cannot be translated to a single atomic update
+var ErrUnsupportedPatch = errors.New("not supported patch")
+
+// Patch of a document, translated to the MongoDB update operators
+type DocumentPatch struct {
+
+	// Values of the dotted paths ($set)
+	Set bson.M
+
+	// Removed dotted paths ($unset)
+	Unset []string
+
+	// Items added to the lists ($push with $each and $position)
+	Push bson.M
+
+	// Values of the test operations (preconditions of the update)
+	Tests bson.M
+
+	// Dotted paths that must exist (targets of the remove and replace operations)
+	Existing []string
+}
+
+// This method returns the MongoDB update operators of the patch (without the empty operators)
+func (patch DocumentPatch) Update() bson.M {
+	update := bson.M{}
+	if len(patch.Set) > 0 {
+		update["$set"] = patch.Set
+	}
+	if len(patch.Unset) > 0 {
+		unset := bson.M{}
+		for _, path := range patch.Unset {
+			unset[path] = ""
+		}
+		update["$unset"] = unset
+	}
+	if len(patch.Push) > 0 {
+		update["$push"] = patch.Push
+	}
+	return update
+}
+
+// This method returns the preconditions of the patch: the values of the tests and the existent paths
+// Output: nil if the patch has no preconditions
+func (patch DocumentPatch) Preconditions() []bson.M {
+	var preconditions []bson.M
+	for _, path := range sortedKeys(patch.Tests) {
+		preconditions = append(preconditions, bson.M{path: patch.Tests[path]})
+	}
+	for _, path := range patch.Existing {
+		preconditions = append(preconditions, bson.M{path: bson.M{"$exists": true}})
+	}
+	return preconditions
+}
+
+// This method returns all the changed dotted paths of the patch (set, removed and lists with added items), sorted
+func (patch DocumentPatch) ChangedPaths() []string {
+	paths := append(sortedKeys(patch.Set), patch.Unset...)
+	paths = append(paths, sortedKeys(patch.Push)...)
+	sort.Strings(paths)
+	return paths
+}
+
+// This method translates a JSON Merge Patch (RFC 7396) to a patch of the document
+// The null values remove the fields and the objects are merged field by field, any other value (e.g. a list) replaces the field
+func CompileMergePatch(mergePatch map[string]interface{}) (DocumentPatch, error) {
+	patch := newDocumentPatch()
+	if err := compileMergePatchObject(&patch, "", mergePatch); err != nil {
+		return DocumentPatch{}, err
+	}
+	return patch, checkPatchConflicts(patch)
+}
+
+// This method translates the operations of a JSON Patch (RFC 6902) to a patch of the document
+// The test operations and the targets of the remove and replace operations are the preconditions of the update
+// Output: ErrUnsupportedPatch for the operations that cannot be applied atomically (move, copy, removal of a list item)
+func CompileJSONPatch(operations []interface{}) (DocumentPatch, error) {
+	if len(operations) == 0 {
+		return DocumentPatch{}, errors.New("the JSON Patch has no operations")
+	}
+	if len(operations) > MAX_JSON_PATCH_OPERATIONS {
+		return DocumentPatch{}, fmt.Errorf("the JSON Patch has more than %d operations", MAX_JSON_PATCH_OPERATIONS)
+	}
+
+	patch := newDocumentPatch()
+	for position, rawOperation := range operations {
+		if err := compileJSONPatchOperation(&patch, rawOperation); err != nil {
+			return DocumentPatch{}, fmt.Errorf("operation %d: %w", position, err)
+		}
+	}
+	return patch, checkPatchConflicts(patch)
+}
+
+// Private
+// This method returns an empty patch
+func newDocumentPatch() DocumentPatch {
+	return DocumentPatch{Set: bson.M{}, Unset: []string{}, Push: bson.M{}, Tests: bson.M{}, Existing: []string{}}
+}
+
+// Private
+// This method adds the fields of an object of the merge patch to the patch, under the dotted path prefix
+func compileMergePatchObject(patch *DocumentPatch, prefix string, object map[string]interface{}) error {
+	for _, key := range sortedKeys(object) {
+		if err := checkPatchSegment(key); err != nil {
+			return err
+		}
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		switch value := object[key].(type) {
+		case nil:
+			patch.Unset = append(patch.Unset, path)
+		case map[string]interface{}:
+			if err := compileMergePatchObject(patch, path, value); err != nil {
+				return err
+			}
+		default:
+			patch.Set[path] = value
+		}
+	}
+	return nil
+}
+
+// Private
+// This method adds an operation of the JSON Patch to the patch
+func compileJSONPatchOperation(patch *DocumentPatch, rawOperation interface{}) error {
+	operation, ok := rawOperation.(map[string]interface{})
+	if !ok {
+		return errors.New("the operation is not an object")
+	}
+
+	op, _ := operation["op"].(string)
+	pointer, ok := operation["path"].(string)
+	if !ok {
+		return errors.New("the operation has no 'path'")
+	}
+	value, hasValue := operation["value"]
+
+	switch op {
+	case JSON_PATCH_ADD, JSON_PATCH_REPLACE, JSON_PATCH_TEST:
+		if !hasValue {
+			return fmt.Errorf("the '%s' operation has no 'value'", op)
+		}
+	case JSON_PATCH_REMOVE:
+	case JSON_PATCH_MOVE, JSON_PATCH_COPY:
+		return fmt.Errorf("%w: the '%s' operation, use the remove and add operations", ErrUnsupportedPatch, op)
+	default:
+		return fmt.Errorf("not valid operation '%s', expected add, remove, replace or test", op)
+	}
+
+	segments, err := parseJSONPointer(pointer)
+	if err != nil {
+		return err
+	}
+	path := strings.Join(segments, ".")
+	lastSegment := segments[len(segments)-1]
+	parentPath := strings.Join(segments[:len(segments)-1], ".")
+	_, isIndex := listIndex(lastSegment)
+
+	switch op {
+	case JSON_PATCH_ADD:
+		if lastSegment == JSON_PATCH_APPEND && parentPath == "" {
+			return errors.New("not valid path '" + pointer + "', the document is not a list")
+		}
+
+		// Items of the lists: appended (/-) or inserted at the index
+		if lastSegment == JSON_PATCH_APPEND || (isIndex && parentPath != "") {
+			if _, found := patch.Push[parentPath]; found {
+				return fmt.Errorf("the list '%s' gets items from more than one operation", parentPath)
+			}
+			push := bson.M{"$each": []interface{}{value}}
+			if index, ok := listIndex(lastSegment); ok {
+				push["$position"] = index
+			}
+			patch.Push[parentPath] = push
+			return nil
+		}
+		patch.Set[path] = value
+
+	case JSON_PATCH_REPLACE:
+		patch.Set[path] = value
+		patch.Existing = append(patch.Existing, path)
+
+	case JSON_PATCH_REMOVE:
+		if isIndex && parentPath != "" {
+			return fmt.Errorf("%w: the removal of the item '%s' of a list, replace the whole list instead", ErrUnsupportedPatch, pointer)
+		}
+		patch.Unset = append(patch.Unset, path)
+		patch.Existing = append(patch.Existing, path)
+
+	case JSON_PATCH_TEST:
+		if _, found := patch.Tests[path]; found {
+			return fmt.Errorf("the path '%s' is tested more than once", pointer)
+		}
+		patch.Tests[path] = value
+	}
+	return nil
+}
+
+// Private
+// This method parses a JSON Pointer (RFC 6901) of a field of the document to its segments
+// The segments are field names or list indexes, so the MongoDB operators and the dotted names are rejected
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" || pointer == "/" {
+		return nil, errors.New("the path of the whole document cannot be patched")
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.New("not valid path '" + pointer + "', expected a JSON Pointer (e.g. /title)")
+	}
+
+	segments := strings.Split(pointer[1:], "/")
+	for position, segment := range segments {
+		segment = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		if err := checkPatchSegment(segment); err != nil {
+			return nil, err
+		}
+		segments[position] = segment
+	}
+	return segments, nil
+}
+
+// Private
+// This method checks that a segment of a path is a plain field name (no empty, dotted or $ names)
+func checkPatchSegment(segment string) error {
+	if segment == "" || strings.HasPrefix(segment, "$") || strings.ContainsAny(segment, ".\x00") {
+		return errors.New("not valid field name '" + segment + "' in the patch")
+	}
+	return nil
+}
+
+// Private
+// This method returns the index of a list segment (a non negative integer without leading zeros)
+func listIndex(segment string) (int64, bool) {
+	if segment == "" || (len(segment) > 1 && segment[0] == '0') {
+		return 0, false
+	}
+	index, err := strconv.ParseInt(segment, 10, 64)
+	return index, err == nil && index >= 0
+}
+
+// Private
+// This method checks that no two changes of the patch have the same path, or a path inside the other one
+// (MongoDB rejects the conflicting update operators)
+func checkPatchConflicts(patch DocumentPatch) error {
+	paths := patch.ChangedPaths()
+	for position, path := range paths {
+		for _, other := range paths[position+1:] {
+			if path == other || strings.HasPrefix(path, other+".") || strings.HasPrefix(other, path+".") {
+				return fmt.Errorf("conflicting changes of the path '%s' and '%s'", path, other)
+			}
+		}
+	}
+	return nil
+}
+
+// Private
+// This method returns the sorted keys of a map
+func sortedKeys[V any](values map[string]V) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Private
+// This method decodes the JSON of a test case with the numbers of the requests
+func decodePatchJSON(t *testing.T, body string) interface{} {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("%s: %v", body, err)
+	}
+	return value
+}
+
+func TestCompileMergePatch(t *testing.T) {
+	mergePatch := decodePatchJSON(t, `{"title": "Gold", "comments": null, "settings": {"theme": "dark", "font": null}, "tags": ["a"]}`)
+	patch, err := CompileMergePatch(mergePatch.(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("expected a valid merge patch, got %v", err)
+	}
+
+	expected := bson.M{
+		"$set":   bson.M{"title": "Gold", "settings.theme": "dark", "tags": []interface{}{"a"}},
+		"$unset": bson.M{"comments": "", "settings.font": ""},
+	}
+	if !reflect.DeepEqual(patch.Update(), expected) {
+		t.Errorf("expected %v, got %v", expected, patch.Update())
+	}
+	if preconditions := patch.Preconditions(); preconditions != nil {
+		t.Errorf("expected no preconditions, got %v", preconditions)
+	}
+
+	for _, body := range []string{`{"$where": 1}`, `{"a.b": 1}`, `{"": 1}`, `{"settings": {"$set": 1}}`} {
+		if _, err := CompileMergePatch(decodePatchJSON(t, body).(map[string]interface{})); err == nil {
+			t.Errorf("%s: expected a not valid field name", body)
+		}
+	}
+}
+
+func TestCompileJSONPatch(t *testing.T) {
+	operations := decodePatchJSON(t, `[
+		{"op": "test", "path": "/version", "value": 3},
+		{"op": "replace", "path": "/title", "value": "Gold"},
+		{"op": "add", "path": "/settings/a~1b", "value": "x"},
+		{"op": "remove", "path": "/comments"},
+		{"op": "add", "path": "/tags/-", "value": "new"},
+		{"op": "add", "path": "/items/0", "value": "first"}
+	]`)
+	patch, err := CompileJSONPatch(operations.([]interface{}))
+	if err != nil {
+		t.Fatalf("expected a valid JSON Patch, got %v", err)
+	}
+
+	expected := bson.M{
+		"$set":   bson.M{"title": "Gold", "settings.a/b": "x"},
+		"$unset": bson.M{"comments": ""},
+		"$push": bson.M{
+			"tags":  bson.M{"$each": []interface{}{"new"}},
+			"items": bson.M{"$each": []interface{}{"first"}, "$position": int64(0)},
+		},
+	}
+	if !reflect.DeepEqual(patch.Update(), expected) {
+		t.Errorf("expected %v, got %v", expected, patch.Update())
+	}
+
+	expectedPreconditions := []bson.M{
+		{"version": json.Number("3")},
+		{"title": bson.M{"$exists": true}},
+		{"comments": bson.M{"$exists": true}},
+	}
+	if !reflect.DeepEqual(patch.Preconditions(), expectedPreconditions) {
+		t.Errorf("expected %v, got %v", expectedPreconditions, patch.Preconditions())
+	}
+	if paths := patch.ChangedPaths(); !reflect.DeepEqual(paths, []string{"comments", "items", "settings.a/b", "tags", "title"}) {
+		t.Errorf("unexpected changed paths %v", paths)
+	}
+}
+
+func TestCompileJSONPatchErrors(t *testing.T) {
+	testCases := []struct {
+		body        string
+		unsupported bool
+	}{
+		{`[]`, false},
+		{`[1]`, false},
+		{`[{"op": "add", "path": "/title"}]`, false},
+		{`[{"op": "replace", "value": 1}]`, false},
+		{`[{"op": "increment", "path": "/count", "value": 1}]`, false},
+		{`[{"op": "add", "path": "title", "value": 1}]`, false},
+		{`[{"op": "add", "path": "/", "value": 1}]`, false},
+		{`[{"op": "add", "path": "/-", "value": 1}]`, false},
+		{`[{"op": "add", "path": "/$where", "value": 1}]`, false},
+		{`[{"op": "add", "path": "/a.b", "value": 1}]`, false},
+		{`[{"op": "replace", "path": "/settings", "value": {}}, {"op": "remove", "path": "/settings/theme"}]`, false},
+		{`[{"op": "add", "path": "/tags/-", "value": 1}, {"op": "add", "path": "/tags/-", "value": 2}]`, false},
+		{`[{"op": "test", "path": "/title", "value": 1}, {"op": "test", "path": "/title", "value": 2}]`, false},
+		{`[{"op": "move", "from": "/a", "path": "/b"}]`, true},
+		{`[{"op": "copy", "from": "/a", "path": "/b"}]`, true},
+		{`[{"op": "remove", "path": "/tags/1"}]`, true},
+	}
+
+	for _, testCase := range testCases {
+		_, err := CompileJSONPatch(decodePatchJSON(t, testCase.body).([]interface{}))
+		if err == nil {
+			t.Errorf("%s: expected an error", testCase.body)
+			continue
+		}
+		if errors.Is(err, ErrUnsupportedPatch) != testCase.unsupported {
+			t.Errorf("%s: expected unsupported %v, got %v", testCase.body, testCase.unsupported, err)
+		}
+	}
+
+	tooMany := make([]interface{}, MAX_JSON_PATCH_OPERATIONS+1)
+	for position := range tooMany {
+		tooMany[position] = map[string]interface{}{"op": "test", "path": "/title", "value": position}
+	}
+	if _, err := CompileJSONPatch(tooMany); err == nil || !strings.Contains(err.Error(), "more than") {
+		t.Errorf("expected the max number of operations, got %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package utils
+
+import "gopkg.in/mgo.v2/bson"
+
+// Markers of the soft deleted documents
+// The documents that are not deleted have a null deletedAt, so the unique partial indexes can tell them apart
+const DELETED_AT_FIELD = "deletedAt"
+const DELETED_BY_FIELD = "deletedBy"
+
+// This method adds the condition of the not deleted documents to the filter
+func NotDeleted(filter bson.M) bson.M {
+	filter[DELETED_AT_FIELD] = nil
+	return filter
+}
+
+// This method adds the condition of the soft deleted documents (trash) to the filter
+func OnlyDeleted(filter bson.M) bson.M {
+	filter[DELETED_AT_FIELD] = bson.M{"$ne": nil}
+	return filter
+}
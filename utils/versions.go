@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Version of the documents for the optimistic concurrency control
+// Every write increments it, and the ETag of a document is its version
+const VERSION_FIELD = "version"
+
+// Version of the new documents
+const INITIAL_VERSION int64 = 1
+
+// This method adds the increment of the version to the update statement
+func IncrementVersion(update bson.M) bson.M {
+	update["$inc"] = bson.M{VERSION_FIELD: int64(1)}
+	return update
+}
+
+// This method returns the (strong) ETag of the given version, e.g. "3"
+func FormatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// This method parses the version of an ETag (the weak W/ prefix is accepted)
+func ParseETag(etag string) (int64, error) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	if len(etag) < 2 || !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		return 0, errors.New("not valid ETag '" + etag + "', expected a quoted version (e.g. \"3\")")
+	}
+
+	version, err := strconv.ParseInt(etag[1:len(etag)-1], 10, 64)
+	if err != nil || version < INITIAL_VERSION {
+		return 0, errors.New("not valid ETag '" + etag + "', expected a quoted version (e.g. \"3\")")
+	}
+	return version, nil
+}
+
+// This method checks if the list of ETags of an If-None-Match header matches the version ("*" matches any version)
+func ETagMatches(header string, version int64) bool {
+	for _, etag := range strings.Split(header, ",") {
+		if strings.TrimSpace(etag) == "*" {
+			return true
+		}
+		if parsed, err := ParseETag(etag); err == nil && parsed == version {
+			return true
+		}
+	}
+	return false
+}
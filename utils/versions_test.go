@@ -0,0 +1,50 @@
+package utils
+
+import "testing"
+
+func TestParseETag(t *testing.T) {
+	testCases := []struct {
+		etag     string
+		expected int64
+		isValid  bool
+	}{
+		{`"3"`, 3, true},
+		{`W/"12"`, 12, true},
+		{` "1" `, 1, true},
+		{`3`, 0, false},
+		{`"0"`, 0, false},
+		{`"abc"`, 0, false},
+		{`"`, 0, false},
+		{``, 0, false},
+	}
+
+	for _, testCase := range testCases {
+		version, err := ParseETag(testCase.etag)
+		if testCase.isValid != (err == nil) || version != testCase.expected {
+			t.Errorf("%q: expected %d (valid %v), got %d: %v", testCase.etag, testCase.expected, testCase.isValid, version, err)
+		}
+	}
+
+	if etag := FormatETag(7); etag != `"7"` {
+		t.Errorf("expected the ETag \"7\", got %s", etag)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	testCases := []struct {
+		header   string
+		expected bool
+	}{
+		{`"2"`, true},
+		{`"1", W/"2"`, true},
+		{`*`, true},
+		{`"1"`, false},
+		{`not-an-etag`, false},
+	}
+
+	for _, testCase := range testCases {
+		if matches := ETagMatches(testCase.header, 2); matches != testCase.expected {
+			t.Errorf("%q: expected %v, got %v", testCase.header, testCase.expected, matches)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Max number of rows of a worksheet (header included)
+const XLSX_MAX_ROWS = 1048576
+
+// Name of the worksheet of the exports
+const XLSX_SHEET_NAME = "Export"
+
+// Parts of the workbook that do not depend on the rows (the worksheet is written row by row)
+var XLSX_STATIC_PARTS = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + XLSX_SHEET_NAME + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// Worksheet of the rows, the last part of the workbook
+const XLSX_SHEET_PART = "xl/worksheets/sheet1.xml"
+const XLSX_SHEET_START = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+const XLSX_SHEET_END = `</sheetData></worksheet>`
+
+// Writer of the XLSX exports (Office Open XML workbook with a single worksheet)
+// The texts are inline strings, so the rows are written as they come without a table of shared strings
+type xlsxExportWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	rows    int
+}
+
+// Private
+// This method writes the parts of the workbook and the start of the worksheet
+func newXLSXExportWriter(writer io.Writer) (*xlsxExportWriter, error) {
+	archive := zip.NewWriter(writer)
+	for _, part := range XLSX_STATIC_PARTS {
+		partWriter, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(partWriter, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheetWriter, err := archive.Create(XLSX_SHEET_PART)
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(sheetWriter)
+	if _, err = sheet.WriteString(XLSX_SHEET_START); err != nil {
+		return nil, err
+	}
+	return &xlsxExportWriter{archive: archive, sheet: sheet}, nil
+}
+
+func (w *xlsxExportWriter) WriteHeader(columns []string) error {
+	values := make([]interface{}, len(columns))
+	for position, column := range columns {
+		values[position] = column
+	}
+	return w.WriteRow(values)
+}
+
+func (w *xlsxExportWriter) WriteRow(values []interface{}) error {
+	if w.rows >= XLSX_MAX_ROWS {
+		return fmt.Errorf("the worksheet has more than %d rows", XLSX_MAX_ROWS)
+	}
+	w.rows++
+
+	row := strconv.Itoa(w.rows)
+	w.sheet.WriteString(`<row r="` + row + `">`)
+	for position, value := range values {
+		reference := xlsxColumnName(position) + row
+		switch typedValue := value.(type) {
+		case nil:
+			continue
+		case json.Number:
+			w.sheet.WriteString(`<c r="` + reference + `"><v>` + typedValue.String() + `</v></c>`)
+		case bool:
+			flag := "0"
+			if typedValue {
+				flag = "1"
+			}
+			w.sheet.WriteString(`<c r="` + reference + `" t="b"><v>` + flag + `</v></c>`)
+		case string:
+			// The inline strings are never evaluated as formulas, so they are not escaped like the CSV values
+			w.writeInlineString(reference, typedValue)
+		default:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			w.writeInlineString(reference, string(data))
+		}
+	}
+	_, err := w.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (w *xlsxExportWriter) Flush() error {
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.archive.Flush()
+}
+
+func (w *xlsxExportWriter) Close() error {
+	if _, err := w.sheet.WriteString(XLSX_SHEET_END); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.archive.Close()
+}
+
+// Private
+// This method writes a cell with an inline string, the not valid XML characters are replaced
+func (w *xlsxExportWriter) writeInlineString(reference string, text string) {
+	w.sheet.WriteString(`<c r="` + reference + `" t="inlineStr"><is><t xml:space="preserve">`)
+	xml.EscapeText(w.sheet, []byte(text))
+	w.sheet.WriteString(`</t></is></c>`)
+}
+
+// Private
+// This method returns the name of the column of a position (0: A, 25: Z, 26: AA)
+func xlsxColumnName(position int) string {
+	name := ""
+	for position >= 0 {
+		name = string(rune('A'+position%26)) + name
+		position = position/26 - 1
+	}
+	return name
+}